@@ -2,6 +2,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
@@ -326,7 +327,19 @@ func showPackages(c *gin.Context, reflist *deb.PackageRefList, collectionFactory
 	}
 }
 
+// detailedError is an error which provides additional fields for JSON response
+type detailedError interface {
+	Details() map[string]interface{}
+}
+
+// AbortWithJSONError aborts request, responding with error as JSON object,
+// errors implementing detailedError get additional fields in the response
 func AbortWithJSONError(c *gin.Context, code int, err error) {
 	c.Writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-	_ = c.AbortWithError(code, err)
+	ginErr := c.AbortWithError(code, err)
+
+	var detailed detailedError
+	if errors.As(err, &detailed) {
+		ginErr.SetMeta(detailed.Details())
+	}
 }
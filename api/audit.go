@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/task"
+	"github.com/gin-gonic/gin"
+)
+
+// advisoriesPathFromQuery returns path to advisories uploaded to the upload directory
+func advisoriesPathFromQuery(c *gin.Context) (string, bool) {
+	advisories := c.Query("advisories")
+	if advisories == "" {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("advisories parameter is required"))
+		return "", false
+	}
+
+	if !verifyPath(advisories) {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("wrong advisories path"))
+		return "", false
+	}
+
+	return filepath.Join(context.UploadPath(), advisories), true
+}
+
+// runAudit matches packages from reflist returned by loadRefList against advisories,
+// release of advisories defaults to the one derived from distribution
+func runAudit(c *gin.Context, taskName string, resources []string, skipMirror string, distribution string,
+	loadRefList func(collectionFactory *deb.CollectionFactory) (*deb.PackageRefList, error)) {
+	advisoriesPath, ok := advisoriesPathFromQuery(c)
+	if !ok {
+		return
+	}
+	release := c.Query("release")
+	derived := release == ""
+	if derived {
+		release = deb.AuditRelease(distribution)
+	}
+	if release == "" {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("distribution is unknown, release parameter is required"))
+		return
+	}
+
+	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		advisories, err := deb.LoadAdvisories(advisoriesPath, release)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
+		}
+
+		if derived && advisories.Len() == 0 {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil},
+				fmt.Errorf("no advisories for release %s derived from distribution %s, release parameter is required", release, distribution)
+		}
+
+		collectionFactory := context.NewCollectionFactory()
+
+		refList, err := loadRefList(collectionFactory)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		list, err := deb.NewPackageListFromRefList(refList, collectionFactory.PackageCollection(), out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages: %s", err)
+		}
+
+		report, err := deb.AuditPackageList(list, advisories, collectionFactory, skipMirror)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to audit: %s", err)
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: report}, nil
+	})
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
@@ -24,165 +25,178 @@ import (
 func apiDBCleanup(c *gin.Context) {
 	resources := []string{string(task.AllResourcesKey)}
 	maybeRunTaskInBackground(c, "Clean up db", resources, func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
-		var err error
+		return nil, dbCleanup(out, detail)
+	})
+}
 
-		collectionFactory := context.NewCollectionFactory()
+// dbCleanup removes unreferenced packages from the database and unreferenced files from the package pool
+func dbCleanup(out aptly.Progress, detail *task.Detail) error {
+	var err error
 
-		// collect information about referenced packages...
-		existingPackageRefs := deb.NewPackageRefList()
+	collectionFactory := context.NewCollectionFactory()
 
-		out.Printf("Loading mirrors, local repos, snapshots and published repos...")
-		err = collectionFactory.RemoteRepoCollection().ForEach(func(repo *deb.RemoteRepo) error {
-			e := collectionFactory.RemoteRepoCollection().LoadComplete(repo)
-			if e != nil {
-				return e
-			}
-			if repo.RefList() != nil {
-				existingPackageRefs = existingPackageRefs.Merge(repo.RefList(), false, true)
-			}
+	// collect information about referenced packages...
+	existingPackageRefs := deb.NewPackageRefList()
 
-			return nil
-		})
-		if err != nil {
-			return nil, err
+	out.Printf("Loading mirrors, local repos, snapshots and published repos...")
+	err = collectionFactory.RemoteRepoCollection().ForEach(func(repo *deb.RemoteRepo) error {
+		e := collectionFactory.RemoteRepoCollection().LoadComplete(repo)
+		if e != nil {
+			return e
 		}
-
-		err = collectionFactory.LocalRepoCollection().ForEach(func(repo *deb.LocalRepo) error {
-			e := collectionFactory.LocalRepoCollection().LoadComplete(repo)
-			if e != nil {
-				return e
-			}
-
-			if repo.RefList() != nil {
-				existingPackageRefs = existingPackageRefs.Merge(repo.RefList(), false, true)
-			}
-
-			return nil
-		})
-		if err != nil {
-			return nil, err
+		if repo.RefList() != nil {
+			existingPackageRefs = existingPackageRefs.Merge(repo.RefList(), false, true)
 		}
 
-		err = collectionFactory.SnapshotCollection().ForEach(func(snapshot *deb.Snapshot) error {
-			e := collectionFactory.SnapshotCollection().LoadComplete(snapshot)
-			if e != nil {
-				return e
-			}
-
-			existingPackageRefs = existingPackageRefs.Merge(snapshot.RefList(), false, true)
+		// packages required to reconstruct mirror as of any moment during history retention period
+		retained, e := collectionFactory.MirrorHistoryCollection().RetainedRefList(repo.UUID,
+			context.Config().MirrorHistoryRetention, time.Now())
+		if e != nil {
+			return e
+		}
+		existingPackageRefs = existingPackageRefs.Merge(retained, false, true)
 
-			return nil
-		})
-		if err != nil {
-			return nil, err
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = collectionFactory.LocalRepoCollection().ForEach(func(repo *deb.LocalRepo) error {
+		e := collectionFactory.LocalRepoCollection().LoadComplete(repo)
+		if e != nil {
+			return e
 		}
 
-		err = collectionFactory.PublishedRepoCollection().ForEach(func(published *deb.PublishedRepo) error {
-			if published.SourceKind != deb.SourceLocalRepo {
-				return nil
-			}
-			e := collectionFactory.PublishedRepoCollection().LoadComplete(published, collectionFactory)
-			if e != nil {
-				return e
-			}
+		if repo.RefList() != nil {
+			existingPackageRefs = existingPackageRefs.Merge(repo.RefList(), false, true)
+		}
 
-			for _, component := range published.Components() {
-				existingPackageRefs = existingPackageRefs.Merge(published.RefList(component), false, true)
-			}
-			return nil
-		})
-		if err != nil {
-			return nil, err
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = collectionFactory.SnapshotCollection().ForEach(func(snapshot *deb.Snapshot) error {
+		e := collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+		if e != nil {
+			return e
 		}
 
-		// ... and compare it to the list of all packages
-		out.Printf("Loading list of all packages...")
-		allPackageRefs := collectionFactory.PackageCollection().AllPackageRefs()
+		existingPackageRefs = existingPackageRefs.Merge(snapshot.RefList(), false, true)
 
-		toDelete := allPackageRefs.Subtract(existingPackageRefs)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		// delete packages that are no longer referenced
-		out.Printf("Deleting unreferenced packages (%d)...", toDelete.Len())
+	err = collectionFactory.PublishedRepoCollection().ForEach(func(published *deb.PublishedRepo) error {
+		if published.SourceKind != deb.SourceLocalRepo {
+			return nil
+		}
+		e := collectionFactory.PublishedRepoCollection().LoadComplete(published, collectionFactory)
+		if e != nil {
+			return e
+		}
 
-		// database can't err as collection factory already constructed
-		db, _ := context.Database()
+		for _, component := range published.Components() {
+			existingPackageRefs = existingPackageRefs.Merge(published.RefList(component), false, true)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		if toDelete.Len() > 0 {
-			batch := db.CreateBatch()
-			_ = toDelete.ForEach(func(ref []byte) error {
-				_ = collectionFactory.PackageCollection().DeleteByKey(ref, batch)
-				return nil
-			})
+	// ... and compare it to the list of all packages
+	out.Printf("Loading list of all packages...")
+	allPackageRefs := collectionFactory.PackageCollection().AllPackageRefs()
 
-			err = batch.Write()
-			if err != nil {
-				return nil, fmt.Errorf("unable to write to DB: %s", err)
-			}
-		}
+	toDelete := allPackageRefs.Subtract(existingPackageRefs)
 
-		// now, build a list of files that should be present in Repository (package pool)
-		out.Printf("Building list of files referenced by packages...")
-		referencedFiles := make([]string, 0, existingPackageRefs.Len())
+	// delete packages that are no longer referenced
+	out.Printf("Deleting unreferenced packages (%d)...", toDelete.Len())
 
-		err = existingPackageRefs.ForEach(func(key []byte) error {
-			pkg, err2 := collectionFactory.PackageCollection().ByKey(key)
-			if err2 != nil {
-				tail := ""
-				return fmt.Errorf("unable to load package %s: %s%s", string(key), err2, tail)
-			}
-			paths, err2 := pkg.FilepathList(context.PackagePool())
-			if err2 != nil {
-				return err2
-			}
-			referencedFiles = append(referencedFiles, paths...)
+	// database can't err as collection factory already constructed
+	db, _ := context.Database()
 
+	if toDelete.Len() > 0 {
+		batch := db.CreateBatch()
+		_ = toDelete.ForEach(func(ref []byte) error {
+			_ = collectionFactory.PackageCollection().DeleteByKey(ref, batch)
 			return nil
 		})
-		if err != nil {
-			return nil, err
-		}
-
-		sort.Strings(referencedFiles)
 
-		// build a list of files in the package pool
-		out.Printf("Building list of files in package pool...")
-		existingFiles, err := context.PackagePool().FilepathList(out)
+		err = batch.Write()
 		if err != nil {
-			return nil, fmt.Errorf("unable to collect file paths: %s", err)
+			return fmt.Errorf("unable to write to DB: %s", err)
 		}
+	}
 
-		// find files which are in the pool but not referenced by packages
-		filesToDelete := utils.StrSlicesSubstract(existingFiles, referencedFiles)
-
-		// delete files that are no longer referenced
-		out.Printf("Deleting unreferenced files (%d)...", len(filesToDelete))
+	// now, build a list of files that should be present in Repository (package pool)
+	out.Printf("Building list of files referenced by packages...")
+	referencedFiles := make([]string, 0, existingPackageRefs.Len())
 
-		countFilesToDelete := len(filesToDelete)
-		taskDetail := struct {
-			TotalNumberOfPackagesToDelete     int
-			RemainingNumberOfPackagesToDelete int
-		}{
-			countFilesToDelete, countFilesToDelete,
+	err = existingPackageRefs.ForEach(func(key []byte) error {
+		pkg, err2 := collectionFactory.PackageCollection().ByKey(key)
+		if err2 != nil {
+			tail := ""
+			return fmt.Errorf("unable to load package %s: %s%s", string(key), err2, tail)
 		}
-		detail.Store(taskDetail)
-
-		if countFilesToDelete > 0 {
-			var size, totalSize int64
-			for _, file := range filesToDelete {
-				size, err = context.PackagePool().Remove(file)
-				if err != nil {
-					return nil, err
-				}
-
-				taskDetail.RemainingNumberOfPackagesToDelete--
-				detail.Store(taskDetail)
-				totalSize += size
+		paths, err2 := pkg.FilepathList(context.PackagePool())
+		if err2 != nil {
+			return err2
+		}
+		referencedFiles = append(referencedFiles, paths...)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(referencedFiles)
+
+	// build a list of files in the package pool
+	out.Printf("Building list of files in package pool...")
+	existingFiles, err := context.PackagePool().FilepathList(out)
+	if err != nil {
+		return fmt.Errorf("unable to collect file paths: %s", err)
+	}
+
+	// find files which are in the pool but not referenced by packages
+	filesToDelete := utils.StrSlicesSubstract(existingFiles, referencedFiles)
+
+	// delete files that are no longer referenced
+	out.Printf("Deleting unreferenced files (%d)...", len(filesToDelete))
+
+	countFilesToDelete := len(filesToDelete)
+	taskDetail := struct {
+		TotalNumberOfPackagesToDelete     int
+		RemainingNumberOfPackagesToDelete int
+	}{
+		countFilesToDelete, countFilesToDelete,
+	}
+	detail.Store(taskDetail)
+
+	if countFilesToDelete > 0 {
+		var size, totalSize int64
+		for _, file := range filesToDelete {
+			size, err = context.PackagePool().Remove(file)
+			if err != nil {
+				return err
 			}
 
-			out.Printf("Disk space freed: %s...", utils.HumanBytes(totalSize))
+			taskDetail.RemainingNumberOfPackagesToDelete--
+			detail.Store(taskDetail)
+			totalSize += size
 		}
 
-		out.Printf("Compacting database...")
-		return nil, db.CompactDB()
-	})
+		out.Printf("Disk space freed: %s...", utils.HumanBytes(totalSize))
+	}
+
+	out.Printf("Compacting database...")
+	return db.CompactDB()
 }
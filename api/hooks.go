@@ -0,0 +1,31 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aptly-dev/aptly/hooks"
+	"github.com/aptly-dev/aptly/task"
+)
+
+// runPreHooks runs hooks configured before the operation, failing hook
+// aborts the task with 412 Precondition Failed
+func runPreHooks(operation string, objects hooks.Objects) (*task.ProcessReturnValue, error) {
+	err := context.Hooks().Pre(operation, objects)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusPreconditionFailed, Value: nil}, err
+	}
+
+	return nil, nil
+}
+
+// errorCode returns 412 Precondition Failed if err is caused by failing pre hook,
+// and code otherwise
+func errorCode(err error, code int) int {
+	var hookErr *hooks.Error
+	if errors.As(err, &hookErr) {
+		return http.StatusPreconditionFailed
+	}
+
+	return code
+}
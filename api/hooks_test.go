@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/hooks"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/gin-gonic/gin"
+	. "gopkg.in/check.v1"
+)
+
+type HooksSuite struct {
+	APISuite
+
+	output string
+}
+
+var _ = Suite(&HooksSuite{})
+
+func (s *HooksSuite) SetUpSuite(c *C) {
+	s.APISuite.SetUpSuite(c)
+
+	s.output = filepath.Join(c.MkDir(), "payload")
+
+	s.context.Config().Hooks = []utils.Hook{
+		{
+			Events:  []string{"pre-snapshot-create"},
+			Command: []string{"sh", "-c", `if grep -q '"Snapshot":"hooks-blocked"'; then echo "snapshot is frozen"; exit 1; fi`},
+		},
+		{
+			Events:  []string{"post-snapshot-create"},
+			Command: []string{"sh", "-c", `cat > "$1"`, "hook", s.output},
+		},
+	}
+}
+
+func (s *HooksSuite) TearDownSuite(c *C) {
+	// configuration is global, hooks shouldn't leak into other suites; shutdown
+	// of the context waits for post hooks and drops the hook runner
+	s.context.Config().Hooks = nil
+	s.APISuite.TearDownSuite(c)
+}
+
+func (s *HooksSuite) TestSnapshotCreate(c *C) {
+	body, err := json.Marshal(gin.H{"Name": "hooks-blocked"})
+	c.Assert(err, IsNil)
+
+	response, err := s.HTTPRequest("POST", "/api/snapshots", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 412)
+	c.Check(response.Body.String(), Matches, `.*pre-snapshot-create hook sh failed: snapshot is frozen.*`)
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/hooks-blocked", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+
+	body, err = json.Marshal(gin.H{"Name": "hooks-allowed", "Description": "from hooks test"})
+	c.Assert(err, IsNil)
+
+	response, err = s.HTTPRequest("POST", "/api/snapshots", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+	defer func() {
+		_, _ = s.HTTPRequest("DELETE", "/api/snapshots/hooks-allowed", nil)
+	}()
+
+	s.context.Hooks().Wait()
+
+	contents, err := os.ReadFile(s.output)
+	c.Assert(err, IsNil)
+
+	var payload hooks.Payload
+	c.Assert(json.Unmarshal(contents, &payload), IsNil)
+	c.Check(payload.Event, Equals, "post-snapshot-create")
+	c.Check(payload.Objects["Snapshot"], Equals, "hooks-allowed")
+	c.Check(payload.Objects["Description"], Equals, "from hooks test")
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/task"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// defaultIncomingPollInterval is used for queues without poll interval configured
+const defaultIncomingPollInterval = 60 * time.Second
+
+type incomingQueueResponse struct {
+	// Name of the queue
+	Name string
+	// Directory watched for uploads
+	Directory string
+	// Directory rejected uploads are moved to
+	RejectDirectory string
+	// Template of local repo name
+	Repo string
+	// Number of complete uploads waiting to be processed
+	Pending int
+}
+
+// @Summary List Incoming Queues
+// @Description **Get list of incoming queues configured in `incoming_queues` section of configuration**
+// @Description Each queue reports number of complete uploads waiting to be processed.
+// @Tags Incoming
+// @Produce json
+// @Success 200 {array} incomingQueueResponse "List of incoming queues"
+// @Router /api/incoming [get]
+func apiIncomingList(c *gin.Context) {
+	result := []incomingQueueResponse{}
+
+	for name, queue := range context.Config().IncomingQueues {
+		pending := 0
+		if uploads, err := deb.IncomingUploads(queue.Directory); err == nil {
+			pending = len(uploads)
+		}
+
+		result = append(result, incomingQueueResponse{
+			Name:            name,
+			Directory:       queue.Directory,
+			RejectDirectory: queue.GetRejectDirectory(),
+			Repo:            queue.GetRepo(),
+			Pending:         pending,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	c.JSON(200, result)
+}
+
+// incomingQueueTask returns task which processes complete uploads in incoming queue
+func incomingQueueTask(name string, queue utils.IncomingQueue) (string, []string, task.Process) {
+	taskName := fmt.Sprintf("Process incoming queue %s", name)
+	resources := []string{task.AllLocalReposResourcesKey, queue.Directory}
+
+	return taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		collectionFactory := context.NewCollectionFactory()
+
+		records, err := deb.ProcessIncomingQueue(name, queue, context.GetVerifier(), getVerifier, out, collectionFactory,
+			context.PackagePool(), query.Parse, time.Now())
+		for _, record := range records {
+			out.Printf("%s\n", record)
+		}
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to process incoming queue: %s", err)
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: records}, nil
+	}
+}
+
+// @Summary Process Incoming Queue
+// @Description **Include complete uploads from incoming queue into local repos**
+// @Description Accepted uploads are removed from the queue directory, rejected uploads are moved to reject directory along with `.reason` file.
+// @Description Returns audit records of processed uploads.
+// @Tags Incoming
+// @Param name path string true "incoming queue name"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {array} deb.IncomingAuditRecord "Audit records of processed uploads"
+// @Failure 404 {object} Error "Incoming queue not found"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/incoming/{name}/process [post]
+func apiIncomingProcess(c *gin.Context) {
+	name := c.Params.ByName("name")
+
+	queue, ok := context.Config().IncomingQueues[name]
+	if !ok {
+		AbortWithJSONError(c, 404, fmt.Errorf("incoming queue %s not found", name))
+		return
+	}
+
+	taskName, resources, proc := incomingQueueTask(name, queue)
+	maybeRunTaskInBackground(c, taskName, resources, proc)
+}
+
+// @Summary Incoming Queue Log
+// @Description **Get audit records of uploads processed from incoming queue**
+// @Description Every accepted or rejected upload is recorded along with reason of rejection.
+// @Tags Incoming
+// @Param name path string true "incoming queue name"
+// @Produce json
+// @Success 200 {array} deb.IncomingAuditRecord "Audit records"
+// @Failure 404 {object} Error "Incoming queue not found"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/incoming/{name}/log [get]
+func apiIncomingLog(c *gin.Context) {
+	name := c.Params.ByName("name")
+
+	if _, ok := context.Config().IncomingQueues[name]; !ok {
+		AbortWithJSONError(c, 404, fmt.Errorf("incoming queue %s not found", name))
+		return
+	}
+
+	records, err := context.NewCollectionFactory().IncomingAuditCollection().ForQueue(name)
+	if err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("unable to show log: %s", err))
+		return
+	}
+
+	c.JSON(200, records)
+}
+
+// WatchIncomingQueues polls incoming queues configured, starting background task
+// when complete uploads appear, until ctx is cancelled
+//
+// Should be called after Router.
+func WatchIncomingQueues(ctx gocontext.Context) {
+	for name, queue := range context.Config().IncomingQueues {
+		go watchIncomingQueue(ctx, name, queue)
+	}
+}
+
+func watchIncomingQueue(ctx gocontext.Context, name string, queue utils.IncomingQueue) {
+	interval := defaultIncomingPollInterval
+	if queue.PollInterval > 0 {
+		interval = time.Duration(queue.PollInterval) * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastTaskID := -1
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		uploads, err := deb.IncomingUploads(queue.Directory)
+		if err != nil {
+			log.Warn().Msgf("unable to scan incoming queue %s: %s", name, err)
+			continue
+		}
+		if len(uploads) == 0 {
+			continue
+		}
+
+		// keep only the latest task of the queue, skip if it's still running;
+		// task might have been deleted by user already
+		if lastTaskID != -1 {
+			if _, err = context.TaskList().GetTaskByID(lastTaskID); err == nil {
+				if _, err = context.TaskList().DeleteTaskByID(lastTaskID); err != nil {
+					continue
+				}
+			}
+		}
+
+		taskName, resources, proc := incomingQueueTask(name, queue)
+		t, conflictErr := runTaskInBackground(taskName, resources, proc)
+		if conflictErr != nil {
+			log.Warn().Msgf("unable to process incoming queue %s: %s", name, conflictErr)
+			continue
+		}
+
+		lastTaskID = t.ID
+	}
+}
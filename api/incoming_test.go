@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/gin-gonic/gin"
+	. "gopkg.in/check.v1"
+)
+
+type IncomingSuite struct {
+	APISuite
+}
+
+var _ = Suite(&IncomingSuite{})
+
+func (s *IncomingSuite) TestIncomingProcess(c *C) {
+	dir := c.MkDir()
+	for _, name := range []string{
+		"hardlink_0.2.1_amd64.changes",
+		"hardlink_0.2.1.dsc",
+		"hardlink_0.2.1.tar.gz",
+		"hardlink_0.2.1_amd64.deb",
+		"hardlink_0.2.0_i386.deb",
+		"hardlink_0.2.1_amd64.buildinfo",
+	} {
+		c.Assert(utils.CopyFile(filepath.Join("../deb/testdata/changes", name), filepath.Join(dir, name)), IsNil)
+	}
+
+	s.context.Config().IncomingQueues["api-test"] = utils.IncomingQueue{
+		Directory:        dir,
+		Repo:             "incoming-{{.Distribution}}",
+		AcceptUnsigned:   true,
+		IgnoreSignatures: true,
+	}
+	defer delete(s.context.Config().IncomingQueues, "api-test")
+
+	response, err := s.HTTPRequest("GET", "/api/incoming", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches, `.*"Name":"api-test".*"Repo":"incoming-\{\{.Distribution\}\}","Pending":1.*`)
+
+	body, err := json.Marshal(gin.H{"Name": "incoming-unstable"})
+	c.Assert(err, IsNil)
+	response, err = s.HTTPRequest("POST", "/api/repos", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+	defer func() {
+		_, _ = s.HTTPRequest("DELETE", "/api/repos/incoming-unstable?force=1", nil)
+	}()
+
+	response, err = s.HTTPRequest("POST", "/api/incoming/api-test/process", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	var records []deb.IncomingAuditRecord
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &records), IsNil)
+	c.Assert(records, HasLen, 1)
+	c.Check(records[0].Accepted, Equals, true)
+	c.Check(records[0].Repo, Equals, "incoming-unstable")
+
+	_, err = os.Stat(filepath.Join(dir, "hardlink_0.2.1_amd64.changes"))
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	response, err = s.HTTPRequest("GET", "/api/incoming/api-test/log", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+
+	var log []deb.IncomingAuditRecord
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &log), IsNil)
+	c.Assert(len(log) > 0, Equals, true)
+	c.Check(log[len(log)-1].ChangesFile, Equals, "hardlink_0.2.1_amd64.changes")
+}
+
+func (s *IncomingSuite) TestWatchIncomingQueueTasksCleared(c *C) {
+	dir := c.MkDir()
+	upload := func() {
+		for _, name := range []string{
+			"hardlink_0.2.1_amd64.changes",
+			"hardlink_0.2.1.dsc",
+			"hardlink_0.2.1.tar.gz",
+			"hardlink_0.2.1_amd64.deb",
+			"hardlink_0.2.0_i386.deb",
+			"hardlink_0.2.1_amd64.buildinfo",
+		} {
+			c.Assert(utils.CopyFile(filepath.Join("../deb/testdata/changes", name), filepath.Join(dir, name)), IsNil)
+		}
+	}
+	processed := func() bool {
+		for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(50 * time.Millisecond) {
+			if _, err := os.Stat(filepath.Join(dir, "hardlink_0.2.1_amd64.changes")); os.IsNotExist(err) {
+				s.context.TaskList().Wait()
+				return true
+			}
+		}
+		return false
+	}
+
+	body, err := json.Marshal(gin.H{"Name": "incoming-unstable"})
+	c.Assert(err, IsNil)
+	response, err := s.HTTPRequest("POST", "/api/repos", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+	defer func() {
+		_, _ = s.HTTPRequest("DELETE", "/api/repos/incoming-unstable?force=1", nil)
+	}()
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+
+	go watchIncomingQueue(ctx, "watch-test", utils.IncomingQueue{
+		Directory:        dir,
+		Repo:             "incoming-{{.Distribution}}",
+		AcceptUnsigned:   true,
+		IgnoreSignatures: true,
+		PollInterval:     1,
+	})
+
+	upload()
+	c.Assert(processed(), Equals, true)
+
+	// task of the queue is deleted by user, watcher keeps processing uploads
+	response, err = s.HTTPRequest("POST", "/api/tasks-clear", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	upload()
+	c.Assert(processed(), Equals, true)
+}
+
+func (s *IncomingSuite) TestIncomingNotFound(c *C) {
+	response, err := s.HTTPRequest("POST", "/api/incoming/no-such-queue/process", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+
+	response, err = s.HTTPRequest("GET", "/api/incoming/no-such-queue/log", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+}
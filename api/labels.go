@@ -0,0 +1,24 @@
+package api
+
+import (
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/gin-gonic/gin"
+)
+
+// labelSelectorsFromQuery parses label selectors passed as (repeated) label query parameter
+func labelSelectorsFromQuery(c *gin.Context) (deb.LabelSelectors, error) {
+	return deb.ParseLabelSelectors(c.QueryArray("label"))
+}
+
+// replaceLabels validates labels passed in request, empty set of labels is stored as nil
+func replaceLabels(labels deb.Labels) (deb.Labels, error) {
+	if err := labels.Validate(); err != nil {
+		return nil, err
+	}
+
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	return labels, nil
+}
@@ -2,18 +2,22 @@ package api
 
 import (
 	gocontext "context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/hooks"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/aptly-dev/aptly/task"
@@ -66,16 +70,29 @@ func uniqueStrings(input []string) []string {
 // @Summary List Mirrors
 // @Description **Show list of currently available mirrors**
 // @Description Each mirror is returned as in “show” API.
+// @Description List could be narrowed down with label selectors, see snapshot list API.
 // @Tags Mirrors
 // @Produce json
+// @Param label query []string false "Label selectors"
 // @Success 200 {array} remoteRepoResponse
+// @Failure 400 {object} Error "Bad Request"
 // @Router /api/mirrors [get]
 func apiMirrorsList(c *gin.Context) {
+	selectors, err := labelSelectorsFromQuery(c)
+	if err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.RemoteRepoCollection()
 
 	result := []remoteRepoResponse{}
-	err := collection.ForEach(func(repo *deb.RemoteRepo) error {
+	err = collection.ForEach(func(repo *deb.RemoteRepo) error {
+		if !selectors.Matches(repo.Labels) {
+			return nil
+		}
+
 		err := collection.LoadComplete(repo)
 		if err != nil {
 			return err
@@ -95,7 +112,7 @@ func apiMirrorsList(c *gin.Context) {
 type mirrorCreateParams struct {
 	// Name of mirror to be created
 	Name string `binding:"required"          json:"Name"              example:"mirror2"`
-	// Url of the archive to mirror
+	// Url of the archive to mirror (file://, s3:// URLs and local paths are supported as well)
 	ArchiveURL string `binding:"required"    json:"ArchiveURL"        example:"http://deb.debian.org/debian"`
 	// Distribution name to mirror
 	Distribution string `                    json:"Distribution"      example:"'buster', for flat repositories use './'"`
@@ -123,6 +140,16 @@ type mirrorCreateParams struct {
 	SkipArchitectureCheck bool `             json:"SkipArchitectureCheck"`
 	// Set "true" to skip the verification of Release file signatures
 	IgnoreSignatures bool `                  json:"IgnoreSignatures"`
+	// Name of authentication profile (mirrorAuth section of configuration) to access the archive
+	Auth string `                            json:"Auth"              example:"vendor"`
+	// Set "true" to accept Release files which have expired (Valid-Until is in the past)
+	IgnoreValidUntil bool `                  json:"IgnoreValidUntil"`
+	// Mirrors and snapshots to look up dependencies missing in filtered mirror
+	DependencySources []string `             json:"DependencySources" example:"mirror:bookworm-main"`
+	// Retention policy for package versions
+	Retention *deb.RetentionPolicy `         json:"Retention"`
+	// Arbitrary key/value labels
+	Labels deb.Labels `                      json:"Labels"            example:"team:infra"`
 }
 
 // @Summary Create Mirror
@@ -133,6 +160,7 @@ type mirrorCreateParams struct {
 // @Produce json
 // @Success 200 {object} deb.RemoteRepo
 // @Failure 400 {object} Error "Bad Request"
+// @Failure 409 {object} Error "Release file has expired"
 // @Router /api/mirrors [post]
 func apiMirrorsCreate(c *gin.Context) {
 	var err error
@@ -179,6 +207,28 @@ func apiMirrorsCreate(c *gin.Context) {
 	repo.SkipArchitectureCheck = b.SkipArchitectureCheck
 	repo.DownloadSources = b.DownloadSources
 	repo.DownloadUdebs = b.DownloadUdebs
+	repo.Auth = b.Auth
+	repo.IgnoreValidUntil = b.IgnoreValidUntil
+
+	err = repo.SetDependencySources(b.DependencySources, collectionFactory)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
+
+	if b.Retention != nil {
+		repo.Retention, err = deb.NewRetentionPolicy(b.Retention.KeepLatest, b.Retention.KeepAge)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: %s", err))
+			return
+		}
+	}
+
+	repo.Labels, err = replaceLabels(b.Labels)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
 
 	verifier, err := getVerifier(b.Keyrings)
 	if err != nil {
@@ -186,10 +236,19 @@ func apiMirrorsCreate(c *gin.Context) {
 		return
 	}
 
-	downloader := context.NewDownloader(nil)
+	downloader, err := context.NewMirrorDownloader(nil, repo.Auth)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
+
 	err = repo.Fetch(c.Request.Context(), downloader, verifier, b.IgnoreSignatures)
 	if err != nil {
-		AbortWithJSONError(c, 400, fmt.Errorf("unable to fetch mirror: %s", err))
+		code := 400
+		if errors.As(err, new(*deb.ReleaseFreshnessError)) {
+			code = 409
+		}
+		AbortWithJSONError(c, code, fmt.Errorf("unable to fetch mirror: %w", err))
 		return
 	}
 
@@ -374,6 +433,70 @@ func apiMirrorsPackages(c *gin.Context) {
 	}
 }
 
+// @Summary Mirror History
+// @Description **Get list of updates of a mirror**
+// @Description Each successful update records time of the update, date of Release file and number of changed packages.
+// @Tags Mirrors
+// @Param name path string true "mirror name"
+// @Produce json
+// @Success 200 {array} deb.MirrorHistoryEntry "List of updates"
+// @Failure 404 {object} Error "Mirror not found"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/mirrors/{name}/history [get]
+func apiMirrorsHistory(c *gin.Context) {
+	collectionFactory := context.NewCollectionFactory()
+
+	repo, err := collectionFactory.RemoteRepoCollection().ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, fmt.Errorf("unable to show history: %s", err))
+		return
+	}
+
+	entries, err := collectionFactory.MirrorHistoryCollection().ForMirror(repo.UUID, false)
+	if err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("unable to show history: %s", err))
+		return
+	}
+
+	c.JSON(200, entries)
+}
+
+// @Summary Mirror History Entry
+// @Description **Get changes in package list made by a mirror update**
+// @Description Changes are returned as pairs of package keys, `Left` is the package before the update, `Right` is the package after the update.
+// @Tags Mirrors
+// @Param name path string true "mirror name"
+// @Param id path int true "update number"
+// @Produce json
+// @Success 200 {object} deb.MirrorHistoryEntry "Update with list of changes"
+// @Failure 400 {object} Error "Invalid update number"
+// @Failure 404 {object} Error "Mirror or update not found"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/mirrors/{name}/history/{id} [get]
+func apiMirrorsHistoryShow(c *gin.Context) {
+	collectionFactory := context.NewCollectionFactory()
+
+	repo, err := collectionFactory.RemoteRepoCollection().ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, fmt.Errorf("unable to show history: %s", err))
+		return
+	}
+
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to show history: invalid update number %#v", c.Params.ByName("id")))
+		return
+	}
+
+	entry, err := collectionFactory.MirrorHistoryCollection().ByID(repo.UUID, id)
+	if err != nil {
+		AbortWithJSONError(c, 404, fmt.Errorf("unable to show history: %s", err))
+		return
+	}
+
+	c.JSON(200, entry)
+}
+
 type mirrorEditParams struct {
 	// Package query that is applied to mirror packages
 	Filter *string `         json:"Filter" example:"xserver-xorg"`
@@ -393,6 +516,16 @@ type mirrorEditParams struct {
 	Keyrings []string `      json:"Keyrings"       example:"trustedkeys.gpg"`
 	// Set "true" to skip the verification of Release file signatures
 	IgnoreSignatures *bool ` json:"IgnoreSignatures"`
+	// Name of authentication profile (mirrorAuth section of configuration), empty to disable
+	Auth *string `           json:"Auth"           example:"vendor"`
+	// Set "true" to accept Release files which have expired (Valid-Until is in the past)
+	IgnoreValidUntil *bool ` json:"IgnoreValidUntil"`
+	// Mirrors and snapshots to look up dependencies missing in filtered mirror, empty list to clear
+	DependencySources *[]string `json:"DependencySources" example:"mirror:bookworm-main"`
+	// Retention policy for package versions, empty policy to disable
+	Retention *deb.RetentionPolicy `json:"Retention"`
+	// Replace labels, empty object removes all the labels
+	Labels deb.Labels `json:"Labels" example:"approved:true"`
 }
 
 // @Summary Edit Mirror
@@ -405,7 +538,7 @@ type mirrorEditParams struct {
 // @Success 200 {object} deb.RemoteRepo "Mirror was edited successfully"
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Mirror not found"
-// @Failure 409 {object} Error "Aptly db locked"
+// @Failure 409 {object} Error "Aptly db locked or Release file has expired"
 // @Failure 500 {object} Error "Internal Error"
 // @Router /api/mirrors/{name} [post]
 func apiMirrorsEdit(c *gin.Context) {
@@ -467,6 +600,34 @@ func apiMirrorsEdit(c *gin.Context) {
 	if b.IgnoreSignatures != nil {
 		ignoreSignatures = *b.IgnoreSignatures
 	}
+	if b.IgnoreValidUntil != nil {
+		repo.IgnoreValidUntil = *b.IgnoreValidUntil
+	}
+	if b.DependencySources != nil {
+		err = repo.SetDependencySources(*b.DependencySources, collectionFactory)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to edit: %s", err))
+			return
+		}
+	}
+	if b.Retention != nil {
+		repo.Retention, err = deb.NewRetentionPolicy(b.Retention.KeepLatest, b.Retention.KeepAge)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to edit: %s", err))
+			return
+		}
+	}
+	if b.Labels != nil {
+		repo.Labels, err = replaceLabels(b.Labels)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to edit: %s", err))
+			return
+		}
+	}
+	if b.Auth != nil && *b.Auth != repo.Auth {
+		repo.Auth = *b.Auth
+		fetchMirror = true
+	}
 
 	if repo.IsFlat() && repo.DownloadUdebs {
 		AbortWithJSONError(c, 400, fmt.Errorf("unable to edit: flat mirrors don't support udebs"))
@@ -480,9 +641,19 @@ func apiMirrorsEdit(c *gin.Context) {
 			return
 		}
 
-		err = repo.Fetch(c.Request.Context(), context.Downloader(), verifier, ignoreSignatures)
+		downloader, err := context.NewMirrorDownloader(nil, repo.Auth)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to edit: %s", err))
+			return
+		}
+
+		err = repo.Fetch(c.Request.Context(), downloader, verifier, ignoreSignatures)
 		if err != nil {
-			AbortWithJSONError(c, 500, fmt.Errorf("unable to edit: %s", err))
+			code := 500
+			if errors.As(err, new(*deb.ReleaseFreshnessError)) {
+				code = 409
+			}
+			AbortWithJSONError(c, code, fmt.Errorf("unable to edit: %w", err))
 			return
 		}
 	}
@@ -507,6 +678,8 @@ type mirrorUpdateParams struct {
 	IgnoreSignatures bool `       json:"IgnoreSignatures"`
 	// Set "true" to force a mirror update even if another process is already updating the mirror (use with caution!)
 	ForceUpdate bool `            json:"ForceUpdate"`
+	// Set "true" to accept Release file which is older than the one fetched on previous update
+	ForceReleaseDate bool `       json:"ForceReleaseDate"`
 	// Set "true" to skip downloading already downloaded packages
 	SkipExistingPackages bool `   json:"SkipExistingPackages"`
 	// Set "true" to download only the latest version per package/architecture
@@ -525,7 +698,9 @@ type mirrorUpdateParams struct {
 // @Success 202 {object} task.Task "Mirror is being updated"
 // @Failure 400 {object} Error "Unable to determine list of architectures"
 // @Failure 404 {object} Error "Mirror not found"
+// @Failure 409 {object} Error "Release file has expired or is older than the one fetched previously"
 // @Failure 500 {object} Error "Internal Error"
+// @Failure 412 {object} Error "Rejected by pre hook"
 // @Router /api/mirrors/{name} [put]
 func apiMirrorsUpdate(c *gin.Context) {
 	var (
@@ -566,15 +741,31 @@ func apiMirrorsUpdate(c *gin.Context) {
 		return
 	}
 
+	// package downloads report to the global progress, while index downloads report to the task output
+	packageDownloader, err := context.NewMirrorDownloader(context.Progress(), remote.Auth)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to update: %s", err))
+		return
+	}
+
 	resources := []string{string(remote.Key())}
 	maybeRunTaskInBackground(c, "Update mirror "+b.Name, resources, func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
 		ctx := gocontext.Background()
-		downloader := context.NewDownloader(out)
-		err := remote.Fetch(ctx, downloader, verifier, b.IgnoreSignatures)
+		downloader, err := context.NewMirrorDownloader(out, remote.Auth)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 		}
 
+		remote.ForceReleaseDate = b.ForceReleaseDate
+		err = remote.Fetch(ctx, downloader, verifier, b.IgnoreSignatures)
+		if err != nil {
+			code := http.StatusInternalServerError
+			if errors.As(err, new(*deb.ReleaseFreshnessError)) {
+				code = http.StatusConflict
+			}
+			return &task.ProcessReturnValue{Code: code, Value: nil}, fmt.Errorf("unable to update: %w", err)
+		}
+
 		if !b.ForceUpdate {
 			err = remote.CheckLock()
 			if err != nil {
@@ -582,6 +773,10 @@ func apiMirrorsUpdate(c *gin.Context) {
 			}
 		}
 
+		if ret, err := runPreHooks(hooks.MirrorUpdate, hooks.RemoteRepoObjects(remote)); err != nil {
+			return ret, fmt.Errorf("unable to update: %s", err)
+		}
+
 		err = remote.DownloadPackageIndexes(ctx, out, downloader, verifier, collectionFactory, b.IgnoreSignatures, remote.SkipComponentCheck)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
@@ -607,6 +802,29 @@ func apiMirrorsUpdate(c *gin.Context) {
 			if err != nil {
 				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 			}
+
+			if remote.FilterWithDeps && len(remote.DependencySources) > 0 {
+				external, unresolved, err := remote.ResolveExternalDependencies(context.DependencyOptions(), collectionFactory, out)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+				}
+				for _, dep := range external {
+					out.Printf("Dependency satisfied by dependency source: %s\n", dep)
+				}
+				for i := range unresolved {
+					out.Printf("Unresolved dependency: %s\n", unresolved[i].String())
+				}
+			}
+		}
+
+		if remote.Retention != nil {
+			pruned, err := remote.ApplyRetention(collectionFactory, time.Now())
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+			}
+			if len(pruned) > 0 {
+				out.Printf("Pruned by retention policy: %s\n", strings.Join(pruned, ", "))
+			}
 		}
 
 		queue, downloadSize, err := remote.BuildDownloadQueue(context.PackagePool(), collectionFactory.PackageCollection(),
@@ -717,7 +935,7 @@ func apiMirrorsUpdate(c *gin.Context) {
 						}
 
 						// download file...
-						e = context.Downloader().DownloadWithChecksum(
+						e = packageDownloader.DownloadWithChecksum(
 							context,
 							remote.PackageURL(task.File.DownloadURL()).String(),
 							task.TempDownPath,
@@ -782,13 +1000,65 @@ func apiMirrorsUpdate(c *gin.Context) {
 		}
 
 		log.Info().Msgf("%s: Finalizing download...", b.Name)
-		_ = remote.FinalizeDownload(collectionFactory, out)
+		err = remote.FinalizeDownload(collectionFactory, out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+		}
+		if remote.RefList() != nil {
+			log.Info().Msgf("%s: Extracting licenses...", b.Name)
+			_, err = deb.ExtractLicenses(remote.RefList(), collectionFactory.PackageCollection(), context.PackagePool(), out)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+			}
+		}
 		err = collectionFactory.RemoteRepoCollection().Update(remote)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 		}
 
+		context.Hooks().Post(hooks.MirrorUpdate, hooks.RemoteRepoObjects(remote))
+
 		log.Info().Msgf("%s: Mirror updated successfully", b.Name)
 		return &task.ProcessReturnValue{Code: http.StatusNoContent, Value: nil}, nil
 	})
 }
+
+// @Summary Mirror Audit
+// @Description **Report packages in mirror affected by known vulnerabilities**
+// @Description
+// @Description Packages are matched against locally stored advisories uploaded to the upload directory beforehand,
+// @Description see snapshot audit for details. The audited mirror itself is not used when looking up fixed versions.
+// @Description
+// @Description See also: `aptly mirror audit`
+// @Tags Mirrors
+// @Param name path string true "mirror name"
+// @Param advisories query string true "advisories in the upload directory: `<dir>` or `<dir>/<file>`"
+// @Param release query string false "match only advisories for the release (codename or OSV ecosystem), defaults to codename of the mirror distribution"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} deb.AuditReport "Audit report"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Mirror not found"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/mirrors/{name}/audit [get]
+func apiMirrorsAudit(c *gin.Context) {
+	repo, err := context.NewCollectionFactory().RemoteRepoCollection().ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, fmt.Errorf("unable to audit: %s", err))
+		return
+	}
+
+	resources := []string{string(repo.Key())}
+	runAudit(c, "Audit mirror "+repo.Name, resources, repo.Name, repo.Codename(), func(collectionFactory *deb.CollectionFactory) (*deb.PackageRefList, error) {
+		err := collectionFactory.RemoteRepoCollection().LoadComplete(repo)
+		if err != nil {
+			return nil, err
+		}
+
+		if repo.RefList() == nil {
+			return nil, fmt.Errorf("unable to audit: mirror %s not updated", repo.Name)
+		}
+
+		return repo.RefList(), nil
+	})
+}
@@ -3,6 +3,10 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/gin-gonic/gin"
@@ -103,3 +107,124 @@ func (s *MirrorSuite) TestGetMirrorsReturns500OnCorruptRefList(c *C) {
 	c.Assert(response.Code, Equals, 500)
 	c.Assert(response.Body.String(), Matches, ".*unable to show:.*")
 }
+
+func (s *MirrorSuite) TestMirrorHistory(c *C) {
+	collectionFactory := s.context.NewCollectionFactory()
+
+	repo, err := deb.NewRemoteRepo("history-mirror", "http://example.com/debian", "stable", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(err, IsNil)
+	c.Assert(collectionFactory.RemoteRepoCollection().Add(repo), IsNil)
+	defer func() { _ = collectionFactory.RemoteRepoCollection().Drop(repo) }()
+
+	key := "Pamd64 hello 2.10-3 1234abcd"
+	c.Assert(collectionFactory.MirrorHistoryCollection().Add(&deb.MirrorHistoryEntry{
+		MirrorUUID:  repo.UUID,
+		ReleaseDate: "Sat, 17 Oct 2026 08:15:00 UTC",
+		NumPackages: 1,
+		Added:       1,
+		Changes:     []deb.MirrorHistoryChange{{Right: &key}},
+	}), IsNil)
+
+	response, err := s.HTTPRequest("GET", "/api/mirrors/history-mirror/history", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	var entries []map[string]interface{}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &entries), IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0]["ID"], Equals, float64(1))
+	c.Check(entries[0]["ReleaseDate"], Equals, "Sat, 17 Oct 2026 08:15:00 UTC")
+	c.Check(entries[0]["Changes"], IsNil)
+
+	response, err = s.HTTPRequest("GET", "/api/mirrors/history-mirror/history/1", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	var entry map[string]interface{}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &entry), IsNil)
+	c.Check(entry["Changes"], DeepEquals, []interface{}{map[string]interface{}{"Left": nil, "Right": key}})
+
+	response, _ = s.HTTPRequest("GET", "/api/mirrors/history-mirror/history/2", nil)
+	c.Check(response.Code, Equals, 404)
+	c.Check(response.Body.String(), Equals, "{\"error\":\"unable to show history: history entry #2 not found\"}")
+
+	response, _ = s.HTTPRequest("GET", "/api/mirrors/history-mirror/history/last", nil)
+	c.Check(response.Code, Equals, 400)
+
+	response, _ = s.HTTPRequest("GET", "/api/mirrors/does-not-exist/history", nil)
+	c.Check(response.Code, Equals, 404)
+}
+
+func (s *MirrorSuite) TestSnapshotMirrorAsOf(c *C) {
+	collectionFactory := s.context.NewCollectionFactory()
+
+	repo, err := deb.NewRemoteRepo("as-of-mirror", "http://example.com/debian", "stable", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(err, IsNil)
+	c.Assert(collectionFactory.RemoteRepoCollection().Add(repo), IsNil)
+	defer func() { _ = collectionFactory.RemoteRepoCollection().Drop(repo) }()
+	putRawDBValue(c, &s.APISuite, repo.RefKey(), deb.NewPackageRefList().Encode())
+
+	c.Assert(collectionFactory.MirrorHistoryCollection().Add(&deb.MirrorHistoryEntry{
+		MirrorUUID: repo.UUID,
+		Time:       time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+	}), IsNil)
+
+	response, _ := s.HTTPRequest("POST", "/api/mirrors/as-of-mirror/snapshots", strings.NewReader(`{"Name":"as-of-snap","AsOf":"yesterday"}`))
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*wrong time.*")
+
+	response, _ = s.HTTPRequest("POST", "/api/mirrors/as-of-mirror/snapshots", strings.NewReader(`{"Name":"as-of-snap","AsOf":"2026-08-01"}`))
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*history of mirror as-of-mirror starts at 2026-09-01T00:00:00Z.*")
+
+	response, _ = s.HTTPRequest("POST", "/api/mirrors/as-of-mirror/snapshots", strings.NewReader(`{"Name":"as-of-snap","AsOf":"2026-09-15T00:00Z"}`))
+	c.Assert(response.Code, Equals, 201)
+
+	var snapshot map[string]interface{}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &snapshot), IsNil)
+	c.Check(snapshot["Description"], Matches, "Snapshot from mirror .* as of 2026-09-15T00:00:00Z")
+
+	response, _ = s.HTTPRequest("DELETE", "/api/snapshots/as-of-snap", nil)
+	c.Check(response.Code, Equals, 200)
+}
+
+func (s *MirrorSuite) TestCreateMirrorExpiredRelease(c *C) {
+	root := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(root, "dists", "stable"), 0755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(root, "dists", "stable", "Release"), []byte(`Date: Sat, 10 Feb 2024 09:30:00 UTC
+Valid-Until: Sat, 17 Feb 2024 09:30:00 UTC
+Architectures: amd64
+Components: main
+`), 0644), IsNil)
+
+	params := gin.H{
+		"Name":             "expired-mirror",
+		"ArchiveURL":       root,
+		"Distribution":     "stable",
+		"IgnoreSignatures": true,
+	}
+	body, err := json.Marshal(params)
+	c.Assert(err, IsNil)
+
+	response, err := s.HTTPRequest("POST", "/api/mirrors", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 409)
+
+	var result map[string]interface{}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &result), IsNil)
+	c.Check(result["error"], Matches, "unable to fetch mirror: Release file of .* has expired on .*")
+	c.Check(result["reason"], Equals, "expired")
+	c.Check(result["validUntil"], Equals, "2024-02-17T09:30:00Z")
+
+	params["IgnoreValidUntil"] = true
+	body, err = json.Marshal(params)
+	c.Assert(err, IsNil)
+
+	response, err = s.HTTPRequest("POST", "/api/mirrors", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 201)
+	c.Check(response.Body.String(), Matches, ".*\"IgnoreValidUntil\":true.*")
+
+	response, _ = s.HTTPRequest("DELETE", "/api/mirrors/expired-mirror", nil)
+	c.Check(response.Code, Equals, 204)
+}
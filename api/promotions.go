@@ -0,0 +1,317 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/task"
+)
+
+// promotionsResourcesKey is used as task resource key to serialize changes to promotion requests
+const promotionsResourcesKey = "__promotions__"
+
+type promotionPathResponse struct {
+	// Name of the promotion path
+	Name string
+	// Source local repo
+	From string
+	// Destination local repo
+	To string
+	// Number of approvals required
+	Approvals int
+	// Whether packages are moved instead of being copied
+	Move bool
+}
+
+// @Summary List Promotion Paths
+// @Description **Get list of promotion paths configured in `promotion_paths` section of configuration**
+// @Tags Promotions
+// @Produce json
+// @Success 200 {array} promotionPathResponse "List of promotion paths"
+// @Router /api/promotions/paths [get]
+func apiPromotionsPaths(c *gin.Context) {
+	result := []promotionPathResponse{}
+
+	for name, path := range context.Config().PromotionPaths {
+		result = append(result, promotionPathResponse{
+			Name:      name,
+			From:      path.From,
+			To:        path.To,
+			Approvals: path.Approvals,
+			Move:      path.Move,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	c.JSON(200, result)
+}
+
+// @Summary List Promotion Requests
+// @Description **Get list of promotion requests with their audit trail**
+// @Tags Promotions
+// @Param state query string false "return only requests in this state: pending, approved, rejected, promoted or failed"
+// @Produce json
+// @Success 200 {array} deb.PromotionRequest "List of promotion requests"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/promotions [get]
+func apiPromotionsList(c *gin.Context) {
+	requests, err := context.NewCollectionFactory().PromotionCollection().List(c.Query("state"))
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, requests)
+}
+
+// promotionRequestByID looks up promotion request by ID from URL
+func promotionRequestByID(c *gin.Context, collectionFactory *deb.CollectionFactory) (*deb.PromotionRequest, bool) {
+	id, err := strconv.Atoi(c.Params.ByName("id"))
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("invalid promotion request number %#v", c.Params.ByName("id")))
+		return nil, false
+	}
+
+	request, err := collectionFactory.PromotionCollection().ByID(id)
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return nil, false
+	}
+
+	return request, true
+}
+
+// @Summary Show Promotion Request
+// @Description **Get promotion request with its approvals and audit trail**
+// @Tags Promotions
+// @Param id path int true "promotion request number"
+// @Produce json
+// @Success 200 {object} deb.PromotionRequest "Promotion request"
+// @Failure 400 {object} Error "Invalid request number"
+// @Failure 404 {object} Error "Promotion request not found"
+// @Router /api/promotions/{id} [get]
+func apiPromotionsShow(c *gin.Context) {
+	request, ok := promotionRequestByID(c, context.NewCollectionFactory())
+	if !ok {
+		return
+	}
+
+	c.JSON(200, request)
+}
+
+// promotionResources returns task resources for promotion between local repos
+func promotionResources(collectionFactory *deb.CollectionFactory, from, to string) []string {
+	resources := []string{promotionsResourcesKey}
+
+	for _, name := range []string{from, to} {
+		if repo, err := collectionFactory.LocalRepoCollection().ByName(name); err == nil {
+			resources = append(resources, string(repo.Key()))
+		}
+	}
+
+	return resources
+}
+
+// executePromotion executes promotion request if it collected required approvals, saving the outcome
+func executePromotion(collectionFactory *deb.CollectionFactory, request *deb.PromotionRequest, out aptly.Progress) (*task.ProcessReturnValue, error) {
+	if !request.Ready() {
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: request}, nil
+	}
+
+	promoteErr := request.Execute(collectionFactory, query.Parse, context.DependencyOptions(), context.ArchitecturesList(),
+		context.PackagePool(), getVerifier, out, time.Now())
+
+	err := collectionFactory.PromotionCollection().Update(request)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save promotion request: %s", err)
+	}
+
+	if promoteErr != nil {
+		return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, fmt.Errorf("unable to promote: %s", promoteErr)
+	}
+
+	out.Printf("Promoted %d packages from %s to %s\n", len(request.Packages), request.From, request.To)
+
+	return &task.ProcessReturnValue{Code: http.StatusOK, Value: request}, nil
+}
+
+type promotionCreateParams struct {
+	// Name of promotion path
+	Path string `binding:"required" json:"Path"     example:"staging"`
+	// Package queries selecting packages to promote
+	Queries []string `binding:"required" json:"Queries"  example:"myapp (=0.1.12)"`
+	// Promote dependencies of selected packages as well
+	WithDeps bool `                   json:"WithDeps"`
+	// User requesting promotion (not authenticated by aptly)
+	User string `binding:"required" json:"User"     example:"alice"`
+	// Reason of promotion
+	Comment string `                   json:"Comment"`
+}
+
+// @Summary Create Promotion Request
+// @Description **Request promotion of packages along promotion path**
+// @Description Promotion is executed (packages are copied or moved from source to destination repo) once required number of approvals is collected, paths not requiring approvals are promoted immediately.
+// @Description Packages should pass validation configured for destination repo, retention policy of destination repo is applied after promotion.
+// @Tags Promotions
+// @Consume json
+// @Param request body promotionCreateParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 201 {object} deb.PromotionRequest "Promotion request created"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Promotion path or local repo not found"
+// @Failure 422 {object} Error "Promotion failed"
+// @Router /api/promotions [post]
+func apiPromotionsCreate(c *gin.Context) {
+	var b promotionCreateParams
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	path, ok := context.Config().PromotionPaths[b.Path]
+	if !ok {
+		AbortWithJSONError(c, 404, fmt.Errorf("promotion path %s not found", b.Path))
+		return
+	}
+
+	for _, value := range b.Queries {
+		if _, err := query.Parse(value); err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to parse query %s: %s", value, err))
+			return
+		}
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	for _, name := range []string{path.From, path.To} {
+		if _, err := collectionFactory.LocalRepoCollection().ByName(name); err != nil {
+			AbortWithJSONError(c, 404, err)
+			return
+		}
+	}
+
+	request, err := deb.NewPromotionRequest(b.Path, path, b.Queries, b.WithDeps, b.User, b.Comment, time.Now())
+	if err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
+	taskName := fmt.Sprintf("Request promotion along path %s", b.Path)
+	resources := promotionResources(collectionFactory, path.From, path.To)
+
+	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		err := collectionFactory.PromotionCollection().Add(request)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save promotion request: %s", err)
+		}
+
+		ret, err := executePromotion(collectionFactory, request, out)
+		if err == nil {
+			ret.Code = http.StatusCreated
+		}
+		return ret, err
+	})
+}
+
+type promotionReviewParams struct {
+	// User approving or rejecting promotion (not authenticated by aptly)
+	User string `binding:"required" json:"User"     example:"bob"`
+	// Comment on the decision
+	Comment string `                   json:"Comment"`
+}
+
+// apiPromotionsReview approves or rejects promotion request
+func apiPromotionsReview(c *gin.Context, approve bool) {
+	var b promotionReviewParams
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	request, ok := promotionRequestByID(c, collectionFactory)
+	if !ok {
+		return
+	}
+
+	action := "Reject"
+	if approve {
+		action = "Approve"
+	}
+
+	taskName := fmt.Sprintf("%s promotion request #%d", action, request.ID)
+	resources := promotionResources(collectionFactory, request.From, request.To)
+
+	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		// reload request, as it might have been changed before task started
+		request, err := collectionFactory.PromotionCollection().ByID(request.ID)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusNotFound, Value: nil}, err
+		}
+
+		if approve {
+			err = request.Approve(b.User, b.Comment, time.Now())
+		} else {
+			err = request.Reject(b.User, b.Comment, time.Now())
+		}
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, err
+		}
+
+		err = collectionFactory.PromotionCollection().Update(request)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save promotion request: %s", err)
+		}
+
+		return executePromotion(collectionFactory, request, out)
+	})
+}
+
+// @Summary Approve Promotion Request
+// @Description **Approve pending promotion request**
+// @Description Requester can't approve own request. Promotion is executed once required number of approvals is collected.
+// @Description
+// @Description User names are taken from request as is, aptly doesn't authenticate them: access to the API should be restricted (e.g. by authenticating reverse proxy) to enforce approvals.
+// @Tags Promotions
+// @Consume json
+// @Param id path int true "promotion request number"
+// @Param request body promotionReviewParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} deb.PromotionRequest "Promotion request"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Promotion request not found"
+// @Failure 409 {object} Error "Promotion request can't be approved"
+// @Failure 422 {object} Error "Promotion failed"
+// @Router /api/promotions/{id}/approve [post]
+func apiPromotionsApprove(c *gin.Context) {
+	apiPromotionsReview(c, true)
+}
+
+// @Summary Reject Promotion Request
+// @Description **Reject pending promotion request**
+// @Tags Promotions
+// @Consume json
+// @Param id path int true "promotion request number"
+// @Param request body promotionReviewParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} deb.PromotionRequest "Promotion request"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Promotion request not found"
+// @Failure 409 {object} Error "Promotion request can't be rejected"
+// @Router /api/promotions/{id}/reject [post]
+func apiPromotionsReject(c *gin.Context) {
+	apiPromotionsReview(c, false)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/gin-gonic/gin"
+	. "gopkg.in/check.v1"
+)
+
+type PromotionsSuite struct {
+	APISuite
+}
+
+var _ = Suite(&PromotionsSuite{})
+
+func (s *PromotionsSuite) postJSON(c *C, url string, params gin.H) (int, []byte) {
+	body, err := json.Marshal(params)
+	c.Assert(err, IsNil)
+
+	response, err := s.HTTPRequest("POST", url, bytes.NewReader(body))
+	c.Assert(err, IsNil)
+
+	return response.Code, response.Body.Bytes()
+}
+
+func (s *PromotionsSuite) TestPromotion(c *C) {
+	for _, name := range []string{"promotion-dev", "promotion-staging"} {
+		code, _ := s.postJSON(c, "/api/repos", gin.H{"Name": name})
+		c.Assert(code, Equals, 201)
+		defer func(name string) {
+			_, _ = s.HTTPRequest("DELETE", "/api/repos/"+name+"?force=1", nil)
+		}(name)
+	}
+
+	uploadDir := filepath.Join(s.context.UploadPath(), "promotion")
+	c.Assert(os.MkdirAll(uploadDir, 0755), IsNil)
+	c.Assert(utils.CopyFile("../system/files/libboost-program-options-dev_1.49.0.1_i386.deb",
+		filepath.Join(uploadDir, "libboost-program-options-dev_1.49.0.1_i386.deb")), IsNil)
+
+	response, err := s.HTTPRequest("POST", "/api/repos/promotion-dev/file/promotion", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	s.context.Config().PromotionPaths["api-test"] = utils.PromotionPath{From: "promotion-dev", To: "promotion-staging", Approvals: 1}
+	defer delete(s.context.Config().PromotionPaths, "api-test")
+
+	response, err = s.HTTPRequest("GET", "/api/promotions/paths", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches, `.*"Name":"api-test","From":"promotion-dev","To":"promotion-staging","Approvals":1,"Move":false.*`)
+
+	code, _ := s.postJSON(c, "/api/promotions", gin.H{"Path": "no-such-path", "Queries": []string{"a"}, "User": "alice"})
+	c.Check(code, Equals, 404)
+
+	code, _ = s.postJSON(c, "/api/promotions", gin.H{"Path": "api-test", "Queries": []string{"Name ("}, "User": "alice"})
+	c.Check(code, Equals, 400)
+
+	code, body := s.postJSON(c, "/api/promotions", gin.H{
+		"Path":    "api-test",
+		"Queries": []string{"libboost-program-options-dev"},
+		"User":    "alice",
+		"Comment": "release 1.49",
+	})
+	c.Assert(code, Equals, 201)
+
+	var request deb.PromotionRequest
+	c.Assert(json.Unmarshal(body, &request), IsNil)
+	c.Check(request.State, Equals, deb.PromotionPending)
+	c.Check(request.RequestedBy, Equals, "alice")
+
+	url := fmt.Sprintf("/api/promotions/%d", request.ID)
+
+	code, _ = s.postJSON(c, url+"/approve", gin.H{"User": "alice"})
+	c.Check(code, Equals, 409)
+
+	code, body = s.postJSON(c, url+"/approve", gin.H{"User": "bob", "Comment": "lgtm"})
+	c.Assert(code, Equals, 200, Commentf("%s", body))
+	c.Assert(json.Unmarshal(body, &request), IsNil)
+	c.Check(request.State, Equals, deb.PromotionPromoted)
+	c.Check(request.Packages, DeepEquals, []string{"libboost-program-options-dev_1.49.0.1_i386"})
+
+	response, err = s.HTTPRequest("GET", "/api/repos/promotion-staging/packages", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Body.String(), Equals, `["Pi386 libboost-program-options-dev 1.49.0.1 918d2f433384e378"]`)
+
+	response, err = s.HTTPRequest("GET", url, nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &request), IsNil)
+	c.Assert(request.Events, HasLen, 3)
+	c.Check(request.Events[0].Action, Equals, "created")
+	c.Check(request.Events[1].User, Equals, "bob")
+	c.Check(request.Events[2].Action, Equals, deb.PromotionPromoted)
+
+	code, _ = s.postJSON(c, url+"/reject", gin.H{"User": "carol"})
+	c.Check(code, Equals, 409)
+
+	response, err = s.HTTPRequest("GET", "/api/promotions?state=promoted", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+
+	var requests []deb.PromotionRequest
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &requests), IsNil)
+	c.Check(requests[len(requests)-1].ID, Equals, request.ID)
+}
+
+func (s *PromotionsSuite) TestPromotionNotFound(c *C) {
+	response, err := s.HTTPRequest("GET", "/api/promotions/999999", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+
+	response, err = s.HTTPRequest("GET", "/api/promotions/abc", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+
+	code, _ := s.postJSON(c, "/api/promotions/999999/approve", gin.H{"User": "bob"})
+	c.Check(code, Equals, 404)
+}
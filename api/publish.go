@@ -7,6 +7,7 @@ import (
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/hooks"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/task"
 	"github.com/aptly-dev/aptly/utils"
@@ -437,6 +438,7 @@ type publishedRepoUpdateSwitchParams struct {
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Published repository or source not found"
 // @Failure 500 {object} Error "Internal Error"
+// @Failure 412 {object} Error "Rejected by pre hook"
 // @Router /api/publish/{prefix}/{distribution} [put]
 func apiPublishUpdateSwitch(c *gin.Context) {
 	var b publishedRepoUpdateSwitchParams
@@ -526,12 +528,24 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 		revision := published.ObtainRevision()
 		sources := revision.Sources
 
-		if published.SourceKind == deb.SourceSnapshot {
+		operation := hooks.PublishUpdate
+		objects := hooks.PublishedRepoObjects(published)
+
+		if published.SourceKind == deb.SourceSnapshot && len(b.Snapshots) > 0 {
+			snapshots := map[string]string{}
 			for _, snapshotInfo := range b.Snapshots {
 				component := snapshotInfo.Component
 				name := snapshotInfo.Name
 				sources[component] = name
+				snapshots[component] = name
 			}
+
+			operation = hooks.PublishSwitch
+			objects["Snapshots"] = snapshots
+		}
+
+		if ret, err := runPreHooks(operation, objects); err != nil {
+			return ret, fmt.Errorf("unable to update: %s", err)
 		}
 
 		result, err := published.Update(collectionFactory, out)
@@ -558,6 +572,8 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 			}
 		}
 
+		context.Hooks().Post(operation, objects)
+
 		return &task.ProcessReturnValue{Code: http.StatusOK, Value: published}, nil
 	})
 }
@@ -581,6 +597,7 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Published repository not found"
 // @Failure 500 {object} Error "Internal Error"
+// @Failure 412 {object} Error "Rejected by pre hook"
 // @Router /api/publish/{prefix}/{distribution} [delete]
 func apiPublishDrop(c *gin.Context) {
 	param := slashEscape(c.Params.ByName("prefix"))
@@ -602,12 +619,20 @@ func apiPublishDrop(c *gin.Context) {
 	resources := []string{string(published.Key())}
 	taskName := fmt.Sprintf("Delete published %s repository %s/%s", published.SourceKind, published.StoragePrefix(), published.Distribution)
 	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		objects := hooks.Objects{"Storage": storage, "Prefix": prefix, "Distribution": distribution}
+
+		if ret, err := runPreHooks(hooks.PublishDrop, objects); err != nil {
+			return ret, fmt.Errorf("unable to drop: %s", err)
+		}
+
 		err := collection.Remove(context, storage, prefix, distribution,
 			collectionFactory, out, force, skipCleanup)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to drop: %s", err)
 		}
 
+		context.Hooks().Post(hooks.PublishDrop, objects)
+
 		return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{}}, nil
 	})
 }
@@ -1033,6 +1058,7 @@ type publishedRepoUpdateParams struct {
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Published repository/component not found"
 // @Failure 500 {object} Error "Internal Error"
+// @Failure 412 {object} Error "Rejected by pre hook"
 // @Router /api/publish/{prefix}/{distribution}/update [post]
 func apiPublishUpdate(c *gin.Context) {
 	var b publishedRepoUpdateParams
@@ -1101,6 +1127,12 @@ func apiPublishUpdate(c *gin.Context) {
 	resources := []string{string(published.Key())}
 	taskName := fmt.Sprintf("Update published %s repository %s/%s", published.SourceKind, published.StoragePrefix(), published.Distribution)
 	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		objects := hooks.PublishedRepoObjects(published)
+
+		if ret, err := runPreHooks(hooks.PublishUpdate, objects); err != nil {
+			return ret, fmt.Errorf("unable to update: %s", err)
+		}
+
 		result, err := published.Update(collectionFactory, out)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
@@ -1125,6 +1157,105 @@ func apiPublishUpdate(c *gin.Context) {
 			}
 		}
 
+		context.Hooks().Post(hooks.PublishUpdate, objects)
+
 		return &task.ProcessReturnValue{Code: http.StatusOK, Value: published}, nil
 	})
 }
+
+// @Summary Published Repository Audit
+// @Description **Report packages in published repository affected by known vulnerabilities**
+// @Description
+// @Description Packages of all the components are matched against locally stored advisories uploaded to the upload directory beforehand,
+// @Description see snapshot audit for details.
+// @Description
+// @Description See also: `aptly publish audit`
+// @Tags Publish
+// @Param prefix path string true "publishing prefix, use `:.` instead of `.` because it is ambigious in URLs"
+// @Param distribution path string true "distribution name"
+// @Param advisories query string true "advisories in the upload directory: `<dir>` or `<dir>/<file>`"
+// @Param release query string false "match only advisories for the release (codename or OSV ecosystem), defaults to distribution"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} deb.AuditReport "Audit report"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Published repository not found"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/publish/{prefix}/{distribution}/audit [get]
+func apiPublishAudit(c *gin.Context) {
+	param := slashEscape(c.Params.ByName("prefix"))
+	storage, prefix := deb.ParsePrefix(param)
+	distribution := slashEscape(c.Params.ByName("distribution"))
+
+	published, err := context.NewCollectionFactory().PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, fmt.Errorf("unable to audit: %s", err))
+		return
+	}
+
+	taskName := fmt.Sprintf("Audit published %s repository %s/%s", published.SourceKind, published.StoragePrefix(), published.Distribution)
+	resources := []string{string(published.Key())}
+	runAudit(c, taskName, resources, "", published.Distribution, func(collectionFactory *deb.CollectionFactory) (*deb.PackageRefList, error) {
+		err := collectionFactory.PublishedRepoCollection().LoadComplete(published, collectionFactory)
+		if err != nil {
+			return nil, err
+		}
+
+		refList := deb.NewPackageRefList()
+		for _, component := range published.Components() {
+			refList = refList.Merge(published.RefList(component), false, true)
+		}
+
+		return refList, nil
+	})
+}
+
+// @Summary Published Repository SBOM
+// @Description **Get software bill of materials of published repository**
+// @Description
+// @Description Packages of all the components are listed, see snapshot SBOM for details. Package URLs include published distribution.
+// @Description
+// @Description See also: `aptly publish sbom`
+// @Tags Publish
+// @Param prefix path string true "publishing prefix, use `:.` instead of `.` because it is ambigious in URLs"
+// @Param distribution path string true "distribution name"
+// @Param format query string false "SBOM format: `spdx-json` (default) or `cyclonedx-json`"
+// @Param purlNamespace query string false "vendor in package URLs, `debian` by default"
+// @Produce json
+// @Success 200 {object} object "SBOM document"
+// @Failure 400 {object} Error "Unknown SBOM format"
+// @Failure 404 {object} Error "Published repository not found"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/publish/{prefix}/{distribution}/sbom [get]
+func apiPublishSBOM(c *gin.Context) {
+	format, ok := sbomFormatFromQuery(c)
+	if !ok {
+		return
+	}
+
+	param := slashEscape(c.Params.ByName("prefix"))
+	storage, prefix := deb.ParsePrefix(param)
+	distribution := slashEscape(c.Params.ByName("distribution"))
+
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.PublishedRepoCollection()
+
+	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, fmt.Errorf("unable to generate SBOM: %s", err))
+		return
+	}
+
+	err = collection.LoadComplete(published, collectionFactory)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusInternalServerError, fmt.Errorf("unable to generate SBOM: %s", err))
+		return
+	}
+
+	refLists := map[string]*deb.PackageRefList{}
+	for _, component := range published.Components() {
+		refLists[component] = published.RefList(component)
+	}
+
+	writeSBOM(c, format, published.GetPath(), published.Distribution, refLists, collectionFactory)
+}
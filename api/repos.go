@@ -1,17 +1,20 @@
 package api
 
 import (
+	gocontext "context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/database"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/hooks"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/aptly-dev/aptly/task"
 	"github.com/aptly-dev/aptly/utils"
@@ -67,16 +70,29 @@ func reposServeInAPIMode(c *gin.Context) {
 // @Summary List Repositories
 // @Description **Get list of available repos**
 // @Description Each repo is returned as in “show” API.
+// @Description List could be narrowed down with label selectors, see snapshot list API.
 // @Tags Repos
 // @Produce  json
+// @Param label query []string false "Label selectors"
 // @Success 200 {array} localRepoResponse
+// @Failure 400 {object} Error "Bad Request"
 // @Router /api/repos [get]
 func apiReposList(c *gin.Context) {
 	result := []localRepoResponse{}
 
+	selectors, err := labelSelectorsFromQuery(c)
+	if err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.LocalRepoCollection()
-	err := collection.ForEach(func(r *deb.LocalRepo) error {
+	err = collection.ForEach(func(r *deb.LocalRepo) error {
+		if !selectors.Matches(r.Labels) {
+			return nil
+		}
+
 		err := collection.LoadComplete(r)
 		if err != nil {
 			return err
@@ -104,6 +120,14 @@ type repoCreateParams struct {
 	DefaultComponent string `        json:"DefaultComponent"     example:"main"`
 	// Snapshot name to create repoitory from (optional)
 	FromSnapshot string `            json:"FromSnapshot"         example:""`
+	// Retention policy for package versions (optional)
+	Retention *deb.RetentionPolicy `json:"Retention"`
+	// Uploaders rules for including .changes files (optional)
+	Uploaders *deb.Uploaders `json:"Uploaders"`
+	// Checks packages should pass to be added (optional)
+	Validation *deb.Validation `json:"Validation"`
+	// Arbitrary key/value labels (optional)
+	Labels deb.Labels `json:"Labels" example:"team:infra"`
 }
 
 // @Summary Create Repository
@@ -120,6 +144,7 @@ type repoCreateParams struct {
 // @Param request body repoCreateParams true "Parameters"
 // @Produce  json
 // @Success 201 {object} deb.LocalRepo
+// @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Source snapshot not found"
 // @Failure 409 {object} Error "Local repo already exists"
 // @Failure 500 {object} Error "Internal error"
@@ -135,6 +160,42 @@ func apiReposCreate(c *gin.Context) {
 	repo.DefaultComponent = b.DefaultComponent
 	repo.DefaultDistribution = b.DefaultDistribution
 
+	if b.Retention != nil {
+		var err error
+		repo.Retention, err = deb.NewRetentionPolicy(b.Retention.KeepLatest, b.Retention.KeepAge)
+		if err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if b.Validation != nil {
+		var err error
+		repo.Validation, err = deb.NewValidation(b.Validation.Validators, b.Validation.Architectures, b.Validation.MaxSize,
+			b.Validation.Keyrings, b.Validation.RequireSignature)
+		if err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if b.Uploaders != nil && !b.Uploaders.IsEmpty() {
+		if err := b.Uploaders.Compile(query.Parse); err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, err)
+			return
+		}
+		repo.Uploaders = b.Uploaders
+	}
+
+	if b.Labels != nil {
+		var err error
+		repo.Labels, err = replaceLabels(b.Labels)
+		if err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 
 	if b.FromSnapshot != "" {
@@ -182,6 +243,14 @@ type reposEditParams struct {
 	DefaultDistribution *string `        json:"DefaultDistribution"  example:""`
 	// Change Devault Component for publishing
 	DefaultComponent *string `        json:"DefaultComponent"     example:""`
+	// Change retention policy for package versions, empty policy to disable
+	Retention *deb.RetentionPolicy `json:"Retention"`
+	// Change uploaders rules for including .changes files, empty rules to disable
+	Uploaders *deb.Uploaders `json:"Uploaders"`
+	// Change checks packages should pass to be added, empty list of validators to disable
+	Validation *deb.Validation `json:"Validation"`
+	// Replace labels, empty object removes all the labels
+	Labels deb.Labels `json:"Labels" example:"approved:true"`
 }
 
 // @Summary Update Repository
@@ -192,6 +261,7 @@ type reposEditParams struct {
 // @Param request body reposEditParams true "Parameters"
 // @Produce json
 // @Success 200 {object} deb.LocalRepo "msg"
+// @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Not Found"
 // @Failure 500 {object} Error "Internal Server Error"
 // @Router /api/repos/{name} [put]
@@ -229,6 +299,39 @@ func apiReposEdit(c *gin.Context) {
 	if b.DefaultComponent != nil {
 		repo.DefaultComponent = *b.DefaultComponent
 	}
+	if b.Retention != nil {
+		repo.Retention, err = deb.NewRetentionPolicy(b.Retention.KeepLatest, b.Retention.KeepAge)
+		if err != nil {
+			AbortWithJSONError(c, 400, err)
+			return
+		}
+	}
+	if b.Validation != nil {
+		repo.Validation, err = deb.NewValidation(b.Validation.Validators, b.Validation.Architectures, b.Validation.MaxSize,
+			b.Validation.Keyrings, b.Validation.RequireSignature)
+		if err != nil {
+			AbortWithJSONError(c, 400, err)
+			return
+		}
+	}
+	if b.Uploaders != nil {
+		if b.Uploaders.IsEmpty() {
+			repo.Uploaders = nil
+		} else {
+			if err = b.Uploaders.Compile(query.Parse); err != nil {
+				AbortWithJSONError(c, 400, err)
+				return
+			}
+			repo.Uploaders = b.Uploaders
+		}
+	}
+	if b.Labels != nil {
+		repo.Labels, err = replaceLabels(b.Labels)
+		if err != nil {
+			AbortWithJSONError(c, 400, err)
+			return
+		}
+	}
 
 	err = collection.Update(repo)
 	if err != nil {
@@ -239,6 +342,33 @@ func apiReposEdit(c *gin.Context) {
 	c.JSON(200, repo)
 }
 
+// GET /api/repos/:name/uploaders
+// @Summary Get Repository Uploaders
+// @Description Returns uploaders rules for including .changes files into local repository.
+// @Tags Repos
+// @Param name path string true "Repository name"
+// @Produce  json
+// @Success 200 {object} deb.Uploaders
+// @Failure 404 {object} Error "Repository not found"
+// @Router /api/repos/{name}/uploaders [get]
+func apiReposUploadersShow(c *gin.Context) {
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.LocalRepoCollection()
+
+	repo, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	if repo.Uploaders == nil {
+		c.JSON(200, &deb.Uploaders{})
+		return
+	}
+
+	c.JSON(200, repo.Uploaders)
+}
+
 // GET /api/repos/:name
 // @Summary Get Repository Info
 // @Description Returns basic information about local repository.
@@ -354,7 +484,7 @@ type reposPackagesAddDeleteParams struct {
 }
 
 // Handler for both add and delete
-func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(list *deb.PackageList, p *deb.Package, out aptly.Progress) error) {
+func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, operation string, cb func(repo *deb.LocalRepo, list *deb.PackageList, p *deb.Package, out aptly.Progress) error) {
 	var b reposPackagesAddDeleteParams
 
 	if c.Bind(&b) != nil {
@@ -378,6 +508,12 @@ func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(li
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
 		}
 
+		if ret, err := runPreHooks(operation, hooks.Objects{"Repo": repo.Name, "Packages": b.PackageRefs}); err != nil {
+			return ret, err
+		}
+
+		oldRefList := repo.RefList()
+
 		out.Printf("Loading packages...\n")
 		list, err := deb.NewPackageListFromRefList(repo.RefList(), collectionFactory.PackageCollection(), nil)
 		if err != nil {
@@ -396,7 +532,7 @@ func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(li
 
 				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
 			}
-			err = cb(list, p, out)
+			err = cb(repo, list, p, out)
 			if err != nil {
 				return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
 			}
@@ -408,6 +544,9 @@ func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(li
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
 		}
+
+		context.Hooks().Post(operation, hooks.LocalRepoChanges(repo, oldRefList))
+
 		return &task.ProcessReturnValue{Code: http.StatusOK, Value: repo}, nil
 	})
 }
@@ -418,6 +557,7 @@ func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(li
 // @Description Any package can be added that is present in the aptly database (from any mirror, snapshot, local repository). This API combined with package list (search) APIs allows one to implement importing, copying, moving packages around.
 // @Description
 // @Description API verifies that packages actually exist in aptly database and checks constraint that conflicting packages can’t be part of the same local repository.
+// @Description Packages should also pass validation configured for the local repository.
 // @Tags Repos
 // @Param name path string true "Repository name"
 // @Consume  json
@@ -428,9 +568,24 @@ func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(li
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Not Found"
 // @Failure 400 {object} Error "Internal Server Error"
+// @Failure 412 {object} Error "Rejected by pre hook"
 // @Router /api/repos/{name}/packages [post]
 func apiReposPackagesAdd(c *gin.Context) {
-	apiReposPackagesAddDelete(c, "Add packages to repo ", func(list *deb.PackageList, p *deb.Package, out aptly.Progress) error {
+	var validation *deb.Validation
+
+	apiReposPackagesAddDelete(c, "Add packages to repo ", hooks.RepoAdd, func(repo *deb.LocalRepo, list *deb.PackageList, p *deb.Package, out aptly.Progress) error {
+		if validation == nil && repo.Validation != nil {
+			var err error
+			validation, err = repo.Validation.Prepare(getVerifier)
+			if err != nil {
+				return err
+			}
+		}
+
+		if errs := validation.Validate(p, deb.NewValidatedPoolFile(p, context.PackagePool())); len(errs) > 0 {
+			return fmt.Errorf("package %s failed validation: %s", p, errors.Join(errs...))
+		}
+
 		out.Printf("Adding package %s\n", p.Name)
 		return list.Add(p)
 	})
@@ -450,9 +605,10 @@ func apiReposPackagesAdd(c *gin.Context) {
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Not Found"
 // @Failure 400 {object} Error "Internal Server Error"
+// @Failure 412 {object} Error "Rejected by pre hook"
 // @Router /api/repos/{name}/packages [delete]
 func apiReposPackagesDelete(c *gin.Context) {
-	apiReposPackagesAddDelete(c, "Delete packages from repo ", func(list *deb.PackageList, p *deb.Package, out aptly.Progress) error {
+	apiReposPackagesAddDelete(c, "Delete packages from repo ", hooks.RepoRemove, func(_ *deb.LocalRepo, list *deb.PackageList, p *deb.Package, out aptly.Progress) error {
 		out.Printf("Removing package %s\n", p.Name)
 		list.Remove(p)
 		return nil
@@ -495,6 +651,7 @@ func apiReposPackageFromFile(c *gin.Context) {
 // @Failure 400 {object} Error "wrong file"
 // @Failure 404 {object} Error "Repository not found"
 // @Failure 500 {object} Error "Error adding files"
+// @Failure 412 {object} Error "Rejected by pre hook"
 // @Router /api/repos/{name}/file/{dir} [post]
 func apiReposPackageFromDir(c *gin.Context) {
 	forceReplace := c.Request.URL.Query().Get("forceReplace") == "1"
@@ -534,46 +691,15 @@ func apiReposPackageFromDir(c *gin.Context) {
 	resources := []string{string(repo.Key())}
 	resources = append(resources, sources...)
 	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
-		err = collection.LoadComplete(repo)
-		if err != nil {
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
-		}
-
-		verifier := context.GetVerifier()
-
-		var (
-			packageFiles, failedFiles    []string
-			otherFiles                   []string
-			processedFiles, failedFiles2 []string
-			reporter                     = &aptly.RecordingResultReporter{
-				Warnings:     []string{},
-				AddedLines:   []string{},
-				RemovedLines: []string{},
-			}
-			list *deb.PackageList
-		)
-
-		packageFiles, otherFiles, failedFiles = deb.CollectPackageFiles(sources, reporter)
-
-		list, err := deb.NewPackageListFromRefList(repo.RefList(), collectionFactory.PackageCollection(), nil)
-		if err != nil {
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages: %s", err)
-		}
-
-		processedFiles, failedFiles2, err = deb.ImportPackageFiles(list, packageFiles, forceReplace, verifier, context.PackagePool(),
-			collectionFactory.PackageCollection(), reporter, nil, collectionFactory.ChecksumCollection)
-		failedFiles = append(failedFiles, failedFiles2...)
-		processedFiles = append(processedFiles, otherFiles...)
-
-		if err != nil {
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to import package files: %s", err)
+		reporter := &aptly.RecordingResultReporter{
+			Warnings:     []string{},
+			AddedLines:   []string{},
+			RemovedLines: []string{},
 		}
 
-		repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
-
-		err = collectionFactory.LocalRepoCollection().Update(repo)
+		processedFiles, failedFiles, pruned, err := addPackageFilesToRepo(repo, collectionFactory, sources, forceReplace, reporter)
 		if err != nil {
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
+			return &task.ProcessReturnValue{Code: errorCode(err, http.StatusInternalServerError), Value: nil}, err
 		}
 
 		if !noRemove {
@@ -590,26 +716,230 @@ func apiReposPackageFromDir(c *gin.Context) {
 			_ = os.Remove(filepath.Join(context.UploadPath(), dirParam))
 		}
 
-		if failedFiles == nil {
-			failedFiles = []string{}
+		return addPackageFilesResult(out, reporter, failedFiles, pruned), nil
+	})
+}
+
+// addPackageFilesToRepo imports package files from sources into local repo, applying retention
+// policy of the repo, and saves the repo
+func addPackageFilesToRepo(repo *deb.LocalRepo, collectionFactory *deb.CollectionFactory, sources []string, forceReplace bool,
+	reporter aptly.ResultReporter) (processedFiles, failedFiles, pruned []string, err error) {
+	err = collectionFactory.LocalRepoCollection().LoadComplete(repo)
+	if err != nil {
+		return
+	}
+
+	var otherFiles, packageFiles, failedFiles2 []string
+
+	packageFiles, otherFiles, failedFiles = deb.CollectPackageFiles(sources, reporter)
+
+	err = context.Hooks().Pre(hooks.RepoAdd, hooks.Objects{"Repo": repo.Name, "Files": packageFiles})
+	if err != nil {
+		return
+	}
+
+	oldRefList := repo.RefList()
+
+	list, err := deb.NewPackageListFromRefList(repo.RefList(), collectionFactory.PackageCollection(), nil)
+	if err != nil {
+		err = fmt.Errorf("unable to load packages: %s", err)
+		return
+	}
+
+	validation, err := repo.Validation.Prepare(getVerifier)
+	if err != nil {
+		return
+	}
+
+	processedFiles, failedFiles2, err = deb.ImportPackageFiles(list, packageFiles, forceReplace, context.GetVerifier(), context.PackagePool(),
+		collectionFactory.PackageCollection(), reporter, nil, validation, collectionFactory.ChecksumCollection)
+	failedFiles = append(failedFiles, failedFiles2...)
+	processedFiles = append(processedFiles, otherFiles...)
+
+	if err != nil {
+		err = fmt.Errorf("unable to import package files: %s", err)
+		return
+	}
+
+	pruned, err = deb.ApplyRetentionPolicy(repo.Retention, repo.UUID, list, collectionFactory, time.Now())
+	if err != nil {
+		err = fmt.Errorf("unable to apply retention policy: %s", err)
+		return
+	}
+
+	repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
+
+	err = collectionFactory.LocalRepoCollection().Update(repo)
+	if err != nil {
+		err = fmt.Errorf("unable to save: %s", err)
+		return
+	}
+
+	context.Hooks().Post(hooks.RepoAdd, hooks.LocalRepoChanges(repo, oldRefList))
+
+	return
+}
+
+// addPackageFilesResult reports results of adding package files to the local repo
+func addPackageFilesResult(out aptly.Progress, reporter *aptly.RecordingResultReporter, failedFiles, pruned []string) *task.ProcessReturnValue {
+	if failedFiles == nil {
+		failedFiles = []string{}
+	}
+
+	if len(reporter.AddedLines) > 0 {
+		out.Printf("Added: %s\n", strings.Join(reporter.AddedLines, ", "))
+	}
+	if len(reporter.RemovedLines) > 0 {
+		out.Printf("Removed: %s\n", strings.Join(reporter.RemovedLines, ", "))
+	}
+	if len(reporter.Warnings) > 0 {
+		out.Printf("Warnings: %s\n", strings.Join(reporter.Warnings, ", "))
+	}
+	if len(failedFiles) > 0 {
+		out.Printf("Failed files: %s\n", strings.Join(failedFiles, ", "))
+	}
+	if len(pruned) > 0 {
+		out.Printf("Pruned by retention policy: %s\n", strings.Join(pruned, ", "))
+	}
+
+	return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{
+		"Report":      reporter,
+		"FailedFiles": failedFiles,
+		"Pruned":      pruned,
+	}}
+}
+
+type reposAddURLsParams struct {
+	// Package files to download, optionally with expected SHA256 checksum
+	URLs []deb.PackageURL `binding:"required" json:"URLs"`
+	// When adding package that conflicts with existing package, remove existing package
+	ForceReplace bool `json:"ForceReplace"`
+}
+
+// @Summary Add Packages from URLs
+// @Description **Download package files and import them to the local repository**
+// @Description
+// @Description Files are downloaded from HTTP(S) URLs, and verified against expected SHA256 checksum when specified.
+// @Description For source packages, URLs of .dsc file and all the files it references should be specified.
+// @Description
+// @Description ```
+// @Description $ curl -X POST -H 'Content-Type: application/json' --data '{"URLs": [{"URL": "https://ci.example.com/myapp_0.1.3_amd64.deb"}]}' http://localhost:8080/api/repos/testing/urls
+// @Description ```
+// @Tags Repos
+// @Param name path string true "Repository name"
+// @Consume json
+// @Param request body reposAddURLsParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} task.ProcessReturnValue
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Repository not found"
+// @Failure 500 {object} Error "Error adding files"
+// @Failure 412 {object} Error "Rejected by pre hook"
+// @Router /api/repos/{name}/urls [post]
+func apiReposPackageFromURLs(c *gin.Context) {
+	var b reposAddURLsParams
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	if len(b.URLs) == 0 {
+		AbortWithJSONError(c, 400, fmt.Errorf("no URLs specified"))
+		return
+	}
+	for _, pkgURL := range b.URLs {
+		if err := pkgURL.Validate(); err != nil {
+			AbortWithJSONError(c, 400, err)
+			return
 		}
+	}
 
-		if len(reporter.AddedLines) > 0 {
-			out.Printf("Added: %s\n", strings.Join(reporter.AddedLines, ", "))
+	collectionFactory := context.NewCollectionFactory()
+
+	name := c.Params.ByName("name")
+	repo, err := collectionFactory.LocalRepoCollection().ByName(name)
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	taskName := fmt.Sprintf("Add packages from URLs to repo %s", name)
+	maybeRunTaskInBackground(c, taskName, []string{string(repo.Key())}, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		downloadDir, err := os.MkdirTemp("", "aptly-repo-add")
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to create temporary directory: %s", err)
 		}
-		if len(reporter.RemovedLines) > 0 {
-			out.Printf("Removed: %s\n", strings.Join(reporter.RemovedLines, ", "))
+		defer func() { _ = os.RemoveAll(downloadDir) }()
+
+		reporter := &aptly.RecordingResultReporter{
+			Warnings:     []string{},
+			AddedLines:   []string{},
+			RemovedLines: []string{},
 		}
-		if len(reporter.Warnings) > 0 {
-			out.Printf("Warnings: %s\n", strings.Join(reporter.Warnings, ", "))
+
+		failedURLs := deb.DownloadPackageURLs(gocontext.Background(), context.NewDownloader(nil), b.URLs, downloadDir, reporter)
+
+		_, failedFiles, pruned, err := addPackageFilesToRepo(repo, collectionFactory, []string{downloadDir}, b.ForceReplace, reporter)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: errorCode(err, http.StatusInternalServerError), Value: nil}, err
 		}
-		if len(failedFiles) > 0 {
-			out.Printf("Failed files: %s\n", strings.Join(failedFiles, ", "))
+
+		return addPackageFilesResult(out, reporter, append(failedURLs, failedFiles...), pruned), nil
+	})
+}
+
+// @Summary Prune Repository
+// @Description **Remove package versions according to retention policy of the local repository**
+// @Description
+// @Description Package versions which are not among latest versions (`KeepLatest`) and were first seen
+// @Description earlier than `KeepAge` ago are removed, versions referenced by published repositories are always kept.
+// @Description Keys of removed packages are returned.
+// @Tags Repos
+// @Param name path string true "Repository name"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} object "Removed package keys"
+// @Failure 400 {object} Error "Repository has no retention policy"
+// @Failure 404 {object} Error "Repository not found"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/repos/{name}/prune [post]
+func apiReposPrune(c *gin.Context) {
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.LocalRepoCollection()
+
+	name := c.Params.ByName("name")
+	repo, err := collection.ByName(name)
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	if repo.Retention == nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to prune: local repo %s has no retention policy", repo.Name))
+		return
+	}
+
+	resources := []string{string(repo.Key())}
+	maybeRunTaskInBackground(c, "Prune repo "+name, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		err := collection.LoadComplete(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to prune: %s", err)
+		}
+
+		removed, err := repo.ApplyRetention(collectionFactory, time.Now(), out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to prune: %s", err)
+		}
+
+		err = collection.Update(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
 		}
 
+		out.Printf("Pruned %d packages\n", len(removed))
+
 		return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{
-			"Report":      reporter,
-			"FailedFiles": failedFiles,
+			"Removed": removed,
 		}}, nil
 	})
 }
@@ -701,42 +1031,18 @@ func apiReposCopyPackage(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages in src: %s", err)
 		}
 
-		srcList.PrepareIndex()
-
-		var architecturesList []string
-
-		if jsonBody.WithDeps {
-			dstList.PrepareIndex()
-
-			// Calculate architectures
-			if len(context.ArchitecturesList()) > 0 {
-				architecturesList = context.ArchitecturesList()
-			} else {
-				architecturesList = dstList.Architectures(false)
-			}
-
-			sort.Strings(architecturesList)
-
-			if len(architecturesList) == 0 {
-				return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, fmt.Errorf("unable to determine list of architectures, please specify explicitly")
-			}
-		}
-
-		// srcList.Filter|FilterWithProgress only accept query list
+		// srcList.FilterForCopy only accepts query list
 		queries := make([]deb.PackageQuery, 1)
 		queries[0], err = query.Parse(fileName)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, fmt.Errorf("unable to parse query '%s': %s", fileName, err)
 		}
 
-		toProcess, err := srcList.Filter(deb.FilterOptions{
-			Queries:           queries,
-			WithDependencies:  jsonBody.WithDeps,
-			Source:            dstList,
-			DependencyOptions: context.DependencyOptions(),
-			Architectures:     architecturesList,
-			Progress:          context.Progress(),
-		})
+		toProcess, err := srcList.FilterForCopy(dstList, queries, jsonBody.WithDeps, context.ArchitecturesList(),
+			context.DependencyOptions(), context.Progress())
+		if err == deb.ErrNoArchitectures {
+			return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, err
+		}
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("filter error: %s", err)
 		}
@@ -882,7 +1188,7 @@ func apiReposIncludePackageFromDir(c *gin.Context) {
 		changesFiles, failedFiles = deb.CollectChangesFiles(sources, reporter)
 		_, failedFiles2, err = deb.ImportChangesFiles(
 			changesFiles, reporter, acceptUnsigned, ignoreSignature, forceReplace, noRemoveFiles, verifier,
-			repoTemplate, context.Progress(), collectionFactory.LocalRepoCollection(), collectionFactory.PackageCollection(),
+			getVerifier, repoTemplate, context.Progress(), collectionFactory.LocalRepoCollection(), collectionFactory.PackageCollection(),
 			context.PackagePool(), collectionFactory.ChecksumCollection, nil, query.Parse)
 		failedFiles = append(failedFiles, failedFiles2...)
 
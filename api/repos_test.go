@@ -3,8 +3,11 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"os"
+	"path/filepath"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
 	"github.com/gin-gonic/gin"
 	. "gopkg.in/check.v1"
 )
@@ -61,3 +64,213 @@ func (s *ReposSuite) TestGetReposReturns500OnCorruptRefList(c *C) {
 	c.Assert(response.Code, Equals, 500)
 	c.Assert(response.Body.String(), Matches, ".*msgpack.*|.*decode.*")
 }
+
+func (s *ReposSuite) TestReposPrune(c *C) {
+	body, err := json.Marshal(gin.H{"Name": "prune-repo", "Retention": gin.H{"KeepAge": "month"}})
+	c.Assert(err, IsNil)
+
+	response, err := s.HTTPRequest("POST", "/api/repos", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*wrong age.*")
+
+	body, err = json.Marshal(gin.H{"Name": "prune-repo", "Retention": gin.H{"KeepLatest": 2, "KeepAge": "30d"}})
+	c.Assert(err, IsNil)
+
+	response, err = s.HTTPRequest("POST", "/api/repos", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+	defer func() {
+		_, _ = s.HTTPRequest("DELETE", "/api/repos/prune-repo", nil)
+	}()
+
+	var repo map[string]interface{}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &repo), IsNil)
+	c.Check(repo["Retention"], DeepEquals, map[string]interface{}{"KeepLatest": float64(2), "KeepAge": "30d"})
+
+	response, err = s.HTTPRequest("POST", "/api/repos/prune-repo/prune", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Equals, `{"Removed":[]}`)
+
+	// empty policy disables retention
+	body, err = json.Marshal(gin.H{"Name": "prune-repo", "Retention": gin.H{}})
+	c.Assert(err, IsNil)
+
+	response, err = s.HTTPRequest("PUT", "/api/repos/prune-repo", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+
+	response, err = s.HTTPRequest("POST", "/api/repos/prune-repo/prune", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*has no retention policy.*")
+
+	response, err = s.HTTPRequest("POST", "/api/repos/no-such-repo/prune", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+}
+
+func (s *ReposSuite) TestReposPackageFromURLs(c *C) {
+	body, err := json.Marshal(gin.H{"URLs": []gin.H{{"URL": "ftp://example.com/app_1.0_amd64.deb"}}})
+	c.Assert(err, IsNil)
+
+	response, err := s.HTTPRequest("POST", "/api/repos/no-such-repo/urls", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*unsupported URL.*")
+
+	body, err = json.Marshal(gin.H{"URLs": []gin.H{{"URL": "https://example.com/app_1.0_amd64.deb"}}})
+	c.Assert(err, IsNil)
+
+	response, err = s.HTTPRequest("POST", "/api/repos/no-such-repo/urls", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+}
+
+func (s *ReposSuite) TestReposUploaders(c *C) {
+	body, err := json.Marshal(gin.H{"Name": "uploaders-repo", "Uploaders": gin.H{
+		"rules": []gin.H{{"condition": "Source (", "allow": []string{"*"}}},
+	}})
+	c.Assert(err, IsNil)
+
+	response, err := s.HTTPRequest("POST", "/api/repos", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*error parsing query.*")
+
+	body, err = json.Marshal(gin.H{"Name": "uploaders-repo", "Uploaders": gin.H{
+		"groups": gin.H{"team": []string{"37E1C17570096AD1"}},
+		"rules":  []gin.H{{"sources": []string{"lib*"}, "allow": []string{"team"}, "denyDowngrade": true}},
+	}})
+	c.Assert(err, IsNil)
+
+	response, err = s.HTTPRequest("POST", "/api/repos", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+	defer func() {
+		_, _ = s.HTTPRequest("DELETE", "/api/repos/uploaders-repo", nil)
+	}()
+
+	response, err = s.HTTPRequest("GET", "/api/repos/uploaders-repo", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Not(Matches), ".*Uploaders.*")
+
+	response, err = s.HTTPRequest("GET", "/api/repos/uploaders-repo/uploaders", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+
+	uploaders := &deb.Uploaders{}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), uploaders), IsNil)
+	c.Check(uploaders.Rules, HasLen, 1)
+	c.Check(uploaders.Rules[0].Sources, DeepEquals, []string{"lib*"})
+	c.Check(uploaders.Rules[0].DenyDowngrade, Equals, true)
+
+	// empty rules disable uploaders restrictions
+	body, err = json.Marshal(gin.H{"Name": "uploaders-repo", "Uploaders": gin.H{}})
+	c.Assert(err, IsNil)
+
+	response, err = s.HTTPRequest("PUT", "/api/repos/uploaders-repo", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+
+	response, err = s.HTTPRequest("GET", "/api/repos/uploaders-repo/uploaders", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Equals, `{"groups":null,"rules":null}`)
+
+	response, err = s.HTTPRequest("GET", "/api/repos/none/uploaders", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+}
+
+func (s *ReposSuite) TestReposValidation(c *C) {
+	body, err := json.Marshal(gin.H{"Name": "validation-repo", "Validation": gin.H{"Validators": []string{"no-such"}}})
+	c.Assert(err, IsNil)
+
+	response, err := s.HTTPRequest("POST", "/api/repos", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*unknown validator.*")
+
+	body, err = json.Marshal(gin.H{"Name": "validation-repo", "Validation": gin.H{
+		"Validators":    []string{"maintainer", "architecture"},
+		"Architectures": []string{"i386"},
+	}})
+	c.Assert(err, IsNil)
+
+	response, err = s.HTTPRequest("POST", "/api/repos", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+	defer func() {
+		_, _ = s.HTTPRequest("DELETE", "/api/repos/validation-repo", nil)
+	}()
+
+	uploadDir := filepath.Join(s.context.UploadPath(), "validation")
+	c.Assert(os.MkdirAll(uploadDir, 0755), IsNil)
+	defer func() { _ = os.RemoveAll(uploadDir) }()
+	c.Assert(utils.CopyFile("../deb/testdata/changes/hardlink_0.2.1_amd64.deb", filepath.Join(uploadDir, "hardlink_0.2.1_amd64.deb")), IsNil)
+
+	response, err = s.HTTPRequest("POST", "/api/repos/validation-repo/file/validation?noRemove=1", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches, `.*"FailedFiles":\[".*hardlink_0.2.1_amd64.deb"\].*`)
+	c.Check(response.Body.String(), Matches, ".*failed validation: architecture: architecture amd64 is not allowed.*")
+
+	// allowing architecture lets package in
+	body, err = json.Marshal(gin.H{"Name": "validation-repo", "Validation": gin.H{
+		"Validators":    []string{"maintainer", "architecture"},
+		"Architectures": []string{"i386", "amd64"},
+	}})
+	c.Assert(err, IsNil)
+
+	response, err = s.HTTPRequest("PUT", "/api/repos/validation-repo", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	response, err = s.HTTPRequest("POST", "/api/repos/validation-repo/file/validation", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches, `.*"FailedFiles":\[\].*`)
+}
+
+func (s *ReposSuite) TestReposLabels(c *C) {
+	request := func(method, url string, params gin.H) (int, string) {
+		body, err := json.Marshal(params)
+		c.Assert(err, IsNil)
+
+		response, err := s.HTTPRequest(method, url, bytes.NewReader(body))
+		c.Assert(err, IsNil)
+		return response.Code, response.Body.String()
+	}
+
+	code, _ := request("POST", "/api/repos", gin.H{"Name": "labels-repo", "Labels": gin.H{"bad key": "1"}})
+	c.Check(code, Equals, 400)
+
+	code, body := request("POST", "/api/repos", gin.H{"Name": "labels-repo", "Labels": gin.H{"team": "infra"}})
+	c.Assert(code, Equals, 201, Commentf("%s", body))
+	defer func() {
+		_, _ = s.HTTPRequest("DELETE", "/api/repos/labels-repo", nil)
+	}()
+	c.Check(body, Matches, `.*"Labels":\{"team":"infra"\}.*`)
+
+	code, body = request("GET", "/api/repos?label=team%3Dinfra", nil)
+	c.Assert(code, Equals, 200)
+	c.Check(body, Matches, `\[\{"Name":"labels-repo".*\]`)
+
+	code, body = request("GET", "/api/repos?label=team%3Dinfra&label=approved", nil)
+	c.Assert(code, Equals, 200)
+	c.Check(body, Equals, "[]")
+
+	code, _ = request("GET", "/api/repos?label=%3Dinfra", nil)
+	c.Check(code, Equals, 400)
+
+	code, body = request("PUT", "/api/repos/labels-repo", gin.H{"Name": "labels-repo", "Comment": "labelled"})
+	c.Assert(code, Equals, 200)
+	c.Check(body, Matches, `.*"Labels":\{"team":"infra"\}.*`)
+
+	code, body = request("PUT", "/api/repos/labels-repo", gin.H{"Name": "labels-repo", "Labels": gin.H{}})
+	c.Assert(code, Equals, 200)
+	c.Check(body, Not(Matches), `.*"Labels".*`)
+}
@@ -140,6 +140,7 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.GET("/repos/:name", apiReposShow)
 		api.PUT("/repos/:name", apiReposEdit)
 		api.DELETE("/repos/:name", apiReposDrop)
+		api.GET("/repos/:name/uploaders", apiReposUploadersShow)
 
 		api.GET("/repos/:name/packages", apiReposPackagesShow)
 		api.POST("/repos/:name/packages", apiReposPackagesAdd)
@@ -147,6 +148,8 @@ func Router(c *ctx.AptlyContext) http.Handler {
 
 		api.POST("/repos/:name/file/:dir/:file", apiReposPackageFromFile)
 		api.POST("/repos/:name/file/:dir", apiReposPackageFromDir)
+		api.POST("/repos/:name/urls", apiReposPackageFromURLs)
+		api.POST("/repos/:name/prune", apiReposPrune)
 		api.POST("/repos/:name/copy/:src/:file", apiReposCopyPackage)
 
 		api.POST("/repos/:name/include/:dir/:file", apiReposIncludePackageFromFile)
@@ -163,12 +166,30 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.GET("/mirrors", apiMirrorsList)
 		api.GET("/mirrors/:name", apiMirrorsShow)
 		api.GET("/mirrors/:name/packages", apiMirrorsPackages)
+		api.GET("/mirrors/:name/history", apiMirrorsHistory)
+		api.GET("/mirrors/:name/history/:id", apiMirrorsHistoryShow)
+		api.GET("/mirrors/:name/audit", apiMirrorsAudit)
 		api.POST("/mirrors", apiMirrorsCreate)
 		api.POST("/mirrors/:name", apiMirrorsEdit)
 		api.PUT("/mirrors/:name", apiMirrorsUpdate)
 		api.DELETE("/mirrors/:name", apiMirrorsDrop)
 	}
 
+	{
+		api.GET("/incoming", apiIncomingList)
+		api.GET("/incoming/:name/log", apiIncomingLog)
+		api.POST("/incoming/:name/process", apiIncomingProcess)
+	}
+
+	{
+		api.GET("/promotions", apiPromotionsList)
+		api.GET("/promotions/paths", apiPromotionsPaths)
+		api.GET("/promotions/:id", apiPromotionsShow)
+		api.POST("/promotions", apiPromotionsCreate)
+		api.POST("/promotions/:id/approve", apiPromotionsApprove)
+		api.POST("/promotions/:id/reject", apiPromotionsReject)
+	}
+
 	{
 		api.GET("/gpg/keys", apiGPGListKeys)
 		api.POST("/gpg/key", apiGPGAddKey)
@@ -201,18 +222,29 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.PUT("/publish/:prefix/:distribution/sources/:component", apiPublishUpdateSource)
 		api.DELETE("/publish/:prefix/:distribution/sources/:component", apiPublishRemoveSource)
 		api.POST("/publish/:prefix/:distribution/update", apiPublishUpdate)
+		api.GET("/publish/:prefix/:distribution/audit", apiPublishAudit)
+		api.GET("/publish/:prefix/:distribution/sbom", apiPublishSBOM)
 	}
 
 	{
 		api.GET("/snapshots", apiSnapshotsList)
 		api.POST("/snapshots", apiSnapshotsCreate)
+		api.POST("/snapshots/gc", apiSnapshotsGC)
+		api.POST("/snapshots/import", apiSnapshotsImport)
 		api.PUT("/snapshots/:name", apiSnapshotsUpdate)
 		api.GET("/snapshots/:name", apiSnapshotsShow)
 		api.GET("/snapshots/:name/packages", apiSnapshotsSearchPackages)
 		api.DELETE("/snapshots/:name", apiSnapshotsDrop)
 		api.GET("/snapshots/:name/diff/:withSnapshot", apiSnapshotsDiff)
+		api.GET("/snapshots/:name/changes", apiSnapshotsChanges)
+		api.POST("/snapshots/:name/export", apiSnapshotsExport)
 		api.POST("/snapshots/:name/merge", apiSnapshotsMerge)
 		api.POST("/snapshots/:name/pull", apiSnapshotsPull)
+		api.POST("/snapshots/:name/filter", apiSnapshotsFilter)
+		api.GET("/snapshots/:name/verify", apiSnapshotsVerify)
+		api.GET("/snapshots/:name/audit", apiSnapshotsAudit)
+		api.GET("/snapshots/:name/sbom", apiSnapshotsSBOM)
+		api.GET("/snapshots/:name/licenses", apiSnapshotsLicenses)
 	}
 
 	{
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// sbomFormatFromQuery returns SBOM format requested, SPDX by default
+func sbomFormatFromQuery(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", deb.SBOMFormatSPDX)
+	if !utils.StrSliceHasItem(deb.SBOMFormats, format) {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unknown SBOM format %#v, expected one of: %s",
+			format, strings.Join(deb.SBOMFormats, ", ")))
+		return "", false
+	}
+
+	return format, true
+}
+
+// writeSBOM responds with SBOM listing packages of the components
+func writeSBOM(c *gin.Context, format string, name, distribution string, refLists map[string]*deb.PackageRefList,
+	collectionFactory *deb.CollectionFactory) {
+	var buf bytes.Buffer
+	err := deb.WriteSBOM(&buf, format, refLists, collectionFactory.PackageCollection(), deb.SBOMOptions{
+		Name:          name,
+		Distribution:  distribution,
+		PurlNamespace: c.Query("purlNamespace"),
+	})
+	if err != nil {
+		AbortWithJSONError(c, http.StatusInternalServerError, fmt.Errorf("unable to generate SBOM: %s", err))
+		return
+	}
+
+	c.Data(http.StatusOK, deb.SBOMContentType(format), buf.Bytes())
+}
@@ -1,14 +1,21 @@
 package api
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/database"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/hooks"
+	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/aptly-dev/aptly/task"
 	"github.com/gin-gonic/gin"
@@ -18,13 +25,25 @@ import (
 // @Description **Get list of snapshots**
 // @Description
 // @Description Each snapshot is returned as in “show” API.
+// @Description
+// @Description List could be narrowed down with label selectors: `label=key=value`, `label=key!=value` or `label=key` (label is set),
+// @Description all the selectors should match.
 // @Tags Snapshots
 // @Produce  json
+// @Param sort query string false "Sort order: name (default), time or label:<key>"
+// @Param label query []string false "Label selectors"
 // @Success 200 {array} snapshotResponse
+// @Failure 400 {object} Error "Bad Request"
 // @Router /api/snapshots [get]
 func apiSnapshotsList(c *gin.Context) {
 	SortMethodString := c.Request.URL.Query().Get("sort")
 
+	selectors, err := labelSelectorsFromQuery(c)
+	if err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.SnapshotCollection()
 
@@ -33,7 +52,11 @@ func apiSnapshotsList(c *gin.Context) {
 	}
 
 	result := []snapshotResponse{}
-	err := collection.ForEachSorted(SortMethodString, func(snapshot *deb.Snapshot) error {
+	err = collection.ForEachSorted(SortMethodString, func(snapshot *deb.Snapshot) error {
+		if !selectors.Matches(snapshot.Labels) {
+			return nil
+		}
+
 		err := collection.LoadComplete(snapshot)
 		if err != nil {
 			return err
@@ -55,10 +78,16 @@ type snapshotsCreateFromMirrorParams struct {
 	Name string `binding:"required"     json:"Name"                 example:"snap1"`
 	// Description of snapshot
 	Description string `                json:"Description"`
+	// Labels of snapshot
+	Labels deb.Labels `                 json:"Labels"               example:"team:infra"`
+	// Create snapshot of mirror as it was at the specified time (RFC3339 time or date), reconstructed from mirror history
+	AsOf string `                       json:"AsOf"                 example:"2026-09-01T00:00:00Z"`
 }
 
 // @Summary Snapshot Mirror
 // @Description **Create a snapshot of a mirror**
+// @Description
+// @Description With `AsOf` set, snapshot captures mirror contents as they were at the specified time, reconstructed from mirror update history.
 // @Tags Snapshots
 // @Produce json
 // @Param request body snapshotsCreateFromMirrorParams true "Parameters"
@@ -69,6 +98,7 @@ type snapshotsCreateFromMirrorParams struct {
 // @Failure 404 {object} Error "Mirror Not Found"
 // @Failure 409 {object} Error "Conflicting snapshot"
 // @Failure 500 {object} Error "Internal Server Error"
+// @Failure 412 {object} Error "Rejected by pre hook"
 // @Router /api/mirrors/{name}/snapshots [post]
 func apiSnapshotsCreateFromMirror(c *gin.Context) {
 	var (
@@ -82,6 +112,21 @@ func apiSnapshotsCreateFromMirror(c *gin.Context) {
 		return
 	}
 
+	labels, err := replaceLabels(b.Labels)
+	if err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
+	var asOf time.Time
+	if b.AsOf != "" {
+		asOf, err = deb.ParseAsOf(b.AsOf)
+		if err != nil {
+			AbortWithJSONError(c, 400, err)
+			return
+		}
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.RemoteRepoCollection()
 	snapshotCollection := collectionFactory.SnapshotCollection()
@@ -107,7 +152,11 @@ func apiSnapshotsCreateFromMirror(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
 		}
 
-		snapshot, err = deb.NewSnapshotFromRepository(b.Name, repo)
+		if b.AsOf != "" {
+			snapshot, err = deb.NewSnapshotFromRepositoryAsOf(b.Name, repo, asOf, collectionFactory, context.PackagePool())
+		} else {
+			snapshot, err = deb.NewSnapshotFromRepository(b.Name, repo)
+		}
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
 		}
@@ -115,11 +164,19 @@ func apiSnapshotsCreateFromMirror(c *gin.Context) {
 		if b.Description != "" {
 			snapshot.Description = b.Description
 		}
+		snapshot.Labels = labels
+
+		if ret, err := runPreHooks(hooks.SnapshotCreate, hooks.SnapshotObjects(snapshot)); err != nil {
+			return ret, err
+		}
 
 		err = snapshotCollection.Add(snapshot)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
 		}
+
+		context.Hooks().Post(hooks.SnapshotCreate, hooks.SnapshotObjects(snapshot))
+
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
 	})
 }
@@ -129,6 +186,8 @@ type snapshotsCreateParams struct {
 	Name string `binding:"required"  json:"Name"                 example:"snap2"`
 	// Description of snapshot
 	Description string `             json:"Description"`
+	// Labels of snapshot
+	Labels deb.Labels `              json:"Labels"               example:"team:infra"`
 	// List of source snapshots
 	SourceSnapshots []string `       json:"SourceSnapshots"      example:"snap1"`
 	// List of package refs
@@ -147,6 +206,7 @@ type snapshotsCreateParams struct {
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Source snapshot or package refs not found"
 // @Failure 500 {object} Error "Internal Server Error"
+// @Failure 412 {object} Error "Rejected by pre hook"
 // @Router /api/snapshots [post]
 func apiSnapshotsCreate(c *gin.Context) {
 	var (
@@ -159,6 +219,12 @@ func apiSnapshotsCreate(c *gin.Context) {
 		return
 	}
 
+	labels, err := replaceLabels(b.Labels)
+	if err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
 	if b.Description == "" {
 		if len(b.SourceSnapshots)+len(b.PackageRefs) == 0 {
 			b.Description = "Created as empty"
@@ -207,11 +273,19 @@ func apiSnapshotsCreate(c *gin.Context) {
 		}
 
 		snapshot = deb.NewSnapshotFromRefList(b.Name, sources, deb.NewPackageRefListFromPackageList(list), b.Description)
+		snapshot.Labels = labels
+
+		if ret, err := runPreHooks(hooks.SnapshotCreate, hooks.SnapshotObjects(snapshot)); err != nil {
+			return ret, err
+		}
 
 		err = snapshotCollection.Add(snapshot)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
 		}
+
+		context.Hooks().Post(hooks.SnapshotCreate, hooks.SnapshotObjects(snapshot))
+
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
 	})
 }
@@ -221,6 +295,8 @@ type snapshotsCreateFromRepositoryParams struct {
 	Name string `binding:"required"               json:"Name"                 example:"snap1"`
 	// Description of snapshot
 	Description string `                          json:"Description"`
+	// Labels of snapshot
+	Labels deb.Labels `                           json:"Labels"               example:"team:infra"`
 }
 
 // @Summary Snapshot Repository
@@ -235,6 +311,7 @@ type snapshotsCreateFromRepositoryParams struct {
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 500 {object} Error "Internal Server Error"
 // @Failure 404 {object} Error "Repo Not Found"
+// @Failure 412 {object} Error "Rejected by pre hook"
 // @Router /api/repos/{name}/snapshots [post]
 func apiSnapshotsCreateFromRepository(c *gin.Context) {
 	var (
@@ -248,6 +325,12 @@ func apiSnapshotsCreateFromRepository(c *gin.Context) {
 		return
 	}
 
+	labels, err := replaceLabels(b.Labels)
+	if err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.LocalRepoCollection()
 	snapshotCollection := collectionFactory.SnapshotCollection()
@@ -276,11 +359,19 @@ func apiSnapshotsCreateFromRepository(c *gin.Context) {
 		if b.Description != "" {
 			snapshot.Description = b.Description
 		}
+		snapshot.Labels = labels
+
+		if ret, err := runPreHooks(hooks.SnapshotCreate, hooks.SnapshotObjects(snapshot)); err != nil {
+			return ret, err
+		}
 
 		err = snapshotCollection.Add(snapshot)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
 		}
+
+		context.Hooks().Post(hooks.SnapshotCreate, hooks.SnapshotObjects(snapshot))
+
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
 	})
 }
@@ -290,10 +381,12 @@ type snapshotsUpdateParams struct {
 	Name string `       json:"Name"  example:"snap2"`
 	// Change Description of snapshot
 	Description string `json:"Description"`
+	// Replace Labels of snapshot, empty object removes all the labels
+	Labels deb.Labels `json:"Labels"      example:"approved:true"`
 }
 
 // @Summary Update Snapshot
-// @Description **Update snapshot metadata (Name, Description)**
+// @Description **Update snapshot metadata (Name, Description, Labels)**
 // @Tags Snapshots
 // @Param request body snapshotsUpdateParams true "Parameters"
 // @Param name path string true "Snapshot name"
@@ -315,6 +408,12 @@ func apiSnapshotsUpdate(c *gin.Context) {
 		return
 	}
 
+	labels, err := replaceLabels(b.Labels)
+	if err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.SnapshotCollection()
 	name := c.Params.ByName("name")
@@ -341,6 +440,10 @@ func apiSnapshotsUpdate(c *gin.Context) {
 			snapshot.Description = b.Description
 		}
 
+		if b.Labels != nil {
+			snapshot.Labels = labels
+		}
+
 		err = collectionFactory.SnapshotCollection().Update(snapshot)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
@@ -429,6 +532,184 @@ func apiSnapshotsDrop(c *gin.Context) {
 	})
 }
 
+// @Summary Snapshot garbage collection
+// @Description **Drop snapshots according to retention rules**
+// @Description Evaluates `snapshotRetention` rules from the configuration and drops snapshots none of the rules keeps.
+// @Description Published snapshots and snapshots used as source of other snapshots are never dropped.
+// @Description Provide `dryRun=1` to only report decisions, `cleanup=1` to run db cleanup afterwards.
+// @Tags Snapshots
+// @Param dryRun query string false "Don't drop anything, only report"
+// @Param cleanup query string false "Run db cleanup after dropping snapshots"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {array} deb.SnapshotGCDecision
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/snapshots/gc [post]
+func apiSnapshotsGC(c *gin.Context) {
+	dryRun := c.Request.URL.Query().Get("dryRun") == "1"
+	cleanup := c.Request.URL.Query().Get("cleanup") == "1"
+
+	resources := []string{string(task.AllResourcesKey)}
+	maybeRunTaskInBackground(c, "Garbage collect snapshots", resources, func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
+		collectionFactory := context.NewCollectionFactory()
+
+		decisions, err := deb.PlanSnapshotGC(context.Config().SnapshotRetention, collectionFactory, time.Now())
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		if !dryRun {
+			err = deb.ApplySnapshotGC(decisions, collectionFactory.SnapshotCollection())
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+			}
+
+			if cleanup {
+				err = dbCleanup(out, detail)
+				if err != nil {
+					return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+				}
+			}
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: decisions}, nil
+	})
+}
+
+type snapshotsExportParams struct {
+	// GPG options
+	Signing signingParams ` json:"Signing"`
+}
+
+// @Summary Export Snapshot
+// @Description **Export snapshot with packages as a bundle**
+// @Description Bundle is a tar archive holding snapshot, package stanzas and package files with a signed manifest.
+// @Description It can be imported into another aptly instance via `POST /api/snapshots/import`.
+// @Tags Snapshots
+// @Consume json
+// @Param name path string true "Snapshot name"
+// @Param request body snapshotsExportParams true "Parameters"
+// @Produce application/x-tar
+// @Success 200 {file} file "Bundle"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Snapshot Not Found"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/snapshots/{name}/export [post]
+func apiSnapshotsExport(c *gin.Context) {
+	var b snapshotsExportParams
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	name := c.Params.ByName("name")
+	collectionFactory := context.NewCollectionFactory()
+
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(name)
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	signer, err := getSigner(&b.Signing)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to initialize GPG signer: %s", err))
+		return
+	}
+
+	c.Header("Content-Type", "application/x-tar")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", snapshot.Name+".bundle"))
+
+	err = deb.ExportSnapshotBundle(c.Writer, snapshot, collectionFactory, context.PackagePool(), signer, nil)
+	if err != nil {
+		if !c.Writer.Written() {
+			c.Header("Content-Disposition", "")
+			AbortWithJSONError(c, 500, err)
+			return
+		}
+
+		// bundle is partially streamed already, the only option is to abort
+		_ = c.Error(err)
+		c.Abort()
+	}
+}
+
+// @Summary Import Snapshot
+// @Description **Create snapshot from the bundle**
+// @Description Request body is a bundle produced by snapshot export. Checksums of all the bundle members and signature
+// @Description of the bundle manifest are verified, package files already present in the package pool are not imported again.
+// @Tags Snapshots
+// @Accept application/x-tar
+// @Param name query string false "Snapshot name, defaults to the name stored in the bundle"
+// @Param keyring query []string false "GPG keyring to use when verifying bundle signature"
+// @Param ignoreSignatures query string false "Disable verification of bundle signature"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 201 {object} deb.Snapshot "Created Snapshot"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/snapshots/import [post]
+func apiSnapshotsImport(c *gin.Context) {
+	name := c.Request.URL.Query().Get("name")
+
+	ignoreSignatures := context.Config().GpgDisableVerify
+	if value := c.Request.URL.Query().Get("ignoreSignatures"); value != "" {
+		ignoreSignatures = value == "1"
+	}
+
+	var (
+		verifier pgp.Verifier
+		err      error
+	)
+	if !ignoreSignatures {
+		verifier, err = getVerifier(c.Request.URL.Query()["keyring"])
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to initialize GPG verifier: %s", err))
+			return
+		}
+	}
+
+	// bundle is saved first, so that import could run in background
+	bundle, err := os.CreateTemp("", "aptly-bundle")
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	_, err = io.Copy(bundle, c.Request.Body)
+	if err1 := bundle.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		_ = os.Remove(bundle.Name())
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to read bundle: %s", err))
+		return
+	}
+
+	resources := []string{}
+	if name != "" {
+		resources = append(resources, "S"+name)
+	}
+
+	maybeRunTaskInBackground(c, "Import snapshot", resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		defer func() { _ = os.Remove(bundle.Name()) }()
+
+		reader, err := os.Open(bundle.Name())
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+		defer func() { _ = reader.Close() }()
+
+		collectionFactory := context.NewCollectionFactory()
+		snapshot, err := deb.ImportSnapshotBundle(reader, name, collectionFactory, context.PackagePool(), verifier, out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
+	})
+}
+
 // @Summary Snapshot diff
 // @Description **Return the diff between two snapshots (name & withSnapshot)**
 // @Description Provide `onlyMatching=1` to return only packages present in both snapshots.
@@ -525,9 +806,165 @@ func apiSnapshotsSearchPackages(c *gin.Context) {
 	showPackages(c, snapshot.RefList(), collectionFactory)
 }
 
+// @Summary Snapshot Changes
+// @Description **Return changes between snapshot and another snapshot, mirror, local repo or published repository**
+// @Description
+// @Description Changes are classified as added, removed, upgraded, downgraded or rebuilt (same version, different contents) and grouped by source package.
+// @Description Parameter `with` specifies the other side: `snapshot:<name>` (or plain `<name>`), `mirror:<name>`, `repo:<name>` or `publish:[<storage>:]<prefix>/<distribution>`.
+// @Description
+// @Description Diff could be returned as JSON, CSV or human-readable changelog summary, changelog entries are read from `changelog.Debian.gz` of packages in the pool.
+// @Tags Snapshots
+// @Produce json
+// @Produce text/csv
+// @Produce plain
+// @Param name path string true "Snapshot name"
+// @Param with query string true "Package source to diff against"
+// @Param format query string false "Output format: json (default), csv or changelog"
+// @Param changelogs query int false "include changelog entries in JSON output: 1 to enable"
+// @Param onlyMatching query int false "only return packages present on both sides: 1 to enable"
+// @Success 200 {object} deb.SemanticDiff "Changes"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Snapshot or package source not found"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/snapshots/{name}/changes [get]
+func apiSnapshotsChanges(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" && format != "changelog" {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unknown diff format %#v, expected json, csv or changelog", format))
+		return
+	}
+
+	with := c.Query("with")
+	if with == "" {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("package source to diff against is required"))
+		return
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, err)
+		return
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	description, refList, err := deb.ResolvePackageRefs(with, collectionFactory)
+	if err != nil {
+		code := http.StatusNotFound
+		if errors.Is(err, deb.ErrWrongPackageSource) {
+			code = http.StatusBadRequest
+		}
+		AbortWithJSONError(c, code, err)
+		return
+	}
+
+	diffs, err := snapshot.RefList().Diff(refList, collectionFactory.PackageCollection())
+	if err != nil {
+		AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if c.Query("onlyMatching") == "1" {
+		matching := deb.PackageDiffs{}
+		for _, pdiff := range diffs {
+			if pdiff.Left != nil && pdiff.Right != nil {
+				matching = append(matching, pdiff)
+			}
+		}
+		diffs = matching
+	}
+
+	diff := deb.NewSemanticDiff("snapshot:"+snapshot.Name, description, diffs)
+
+	if format == "changelog" || c.Query("changelogs") == "1" {
+		diff.LoadChangelogs(context.PackagePool(), nil)
+	}
+
+	var buf bytes.Buffer
+
+	switch format {
+	case "csv":
+		err = diff.WriteCSV(&buf)
+		if err == nil {
+			c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+		}
+	case "changelog":
+		err = diff.WriteChangelog(&buf)
+		if err == nil {
+			c.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+		}
+	default:
+		c.JSON(http.StatusOK, diff)
+	}
+
+	if err != nil {
+		AbortWithJSONError(c, http.StatusInternalServerError, err)
+	}
+}
+
+type snapshotsMergeRuleParams struct {
+	// Name of source snapshot, empty or * for all sources
+	Source string `                   json:"Source"      example:"snapshot2"`
+	// Package query
+	Query string `binding:"required" json:"Query"        example:"nginx (>= 1.24)"`
+}
+
 type snapshotsMergeParams struct {
 	// List of snapshot names to be merged
-	Sources []string `binding:"required" json:"Sources"     example:"snapshot1"`
+	Sources []string `binding:"required"      json:"Sources"     example:"snapshot1"`
+	// Priorities of source snapshots by name (500 by default), packages from sources with higher priority win conflicts
+	Priorities map[string]int `              json:"Priorities"`
+	// Prefer packages matching queries over priorities
+	Pins []snapshotsMergeRuleParams `        json:"Pins"`
+	// Exclude packages matching queries from source snapshots
+	Excludes []snapshotsMergeRuleParams `    json:"Excludes"`
+}
+
+type snapshotsMergeReportResponse struct {
+	// Resulting snapshot
+	Snapshot *deb.Snapshot `json:"Snapshot"`
+	// Excluded packages and resolved conflicts
+	Report *deb.MergeReport `json:"Report"`
+}
+
+// mergeRules parses merge rules from request parameters
+func (body *snapshotsMergeParams) mergeRules() (*deb.MergeRules, error) {
+	rules := &deb.MergeRules{Priorities: body.Priorities}
+
+	parse := func(params []snapshotsMergeRuleParams) ([]*deb.MergeRule, error) {
+		result := make([]*deb.MergeRule, len(params))
+		for i, param := range params {
+			q, err := query.Parse(param.Query)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse query %#v: %s", param.Query, err)
+			}
+
+			source := param.Source
+			if source == "*" {
+				source = ""
+			}
+
+			result[i] = &deb.MergeRule{Source: source, QueryString: param.Query, Query: q}
+		}
+
+		return result, nil
+	}
+
+	var err error
+	if rules.Pins, err = parse(body.Pins); err != nil {
+		return nil, err
+	}
+	if rules.Excludes, err = parse(body.Excludes); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
 }
 
 // @Summary Snapshot Merge
@@ -536,15 +973,19 @@ type snapshotsMergeParams struct {
 // @Description Merge happens from left to right. By default, packages with the same name-architecture pair are replaced during merge (package from latest snapshot on the list wins).
 // @Description
 // @Description If only one snapshot is specified, merge copies source into destination.
+// @Description
+// @Description Conflicts could be resolved with rules similar to apt pinning: packages matching `Pins` win first, then packages from sources with the highest `Priorities`. Packages matching `Excludes` are removed from the sources before merge. With `report=1`, response contains the snapshot and the merge report explaining which source won each conflict.
 // @Tags Snapshots
 // @Consume json
 // @Produce json
 // @Param name path string true "Name of the snapshot to be created"
 // @Param latest query int false "merge only the latest version of each package"
 // @Param no-remove query int false "all versions of packages are preserved during merge"
+// @Param report query int false "return merge report along with the snapshot"
 // @Param request body snapshotsMergeParams true "Parameters"
 // @Param _async query bool false "Run in background and return task object"
 // @Success 201 {object} deb.Snapshot "Resulting snapshot object"
+// @Success 201 {object} snapshotsMergeReportResponse "Resulting snapshot object with merge report (report=1)"
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Not Found"
 // @Failure 500 {object} Error "Internal Error"
@@ -576,6 +1017,13 @@ func apiSnapshotsMerge(c *gin.Context) {
 		return
 	}
 
+	withReport := c.Request.URL.Query().Get("report") == "1"
+	rules, err := body.mergeRules()
+	if err != nil {
+		AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	snapshotCollection := collectionFactory.SnapshotCollection()
 
@@ -592,21 +1040,32 @@ func apiSnapshotsMerge(c *gin.Context) {
 	}
 
 	maybeRunTaskInBackground(c, "Merge snapshot "+name, resources, func(_ aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
-		err = snapshotCollection.LoadComplete(sources[0])
-		if err != nil {
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
-		}
-		result := sources[0].RefList()
-		for i := 1; i < len(sources); i++ {
+		for i := range sources {
 			err = snapshotCollection.LoadComplete(sources[i])
 			if err != nil {
 				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
 			}
-			result = result.Merge(sources[i].RefList(), overrideMatching, false)
 		}
 
-		if latest {
-			result.FilterLatestRefs()
+		var (
+			result *deb.PackageRefList
+			report *deb.MergeReport
+		)
+
+		if rules.Empty() && !withReport {
+			result = sources[0].RefList()
+			for i := 1; i < len(sources); i++ {
+				result = result.Merge(sources[i].RefList(), overrideMatching, false)
+			}
+
+			if latest {
+				result.FilterLatestRefs()
+			}
+		} else {
+			result, report, err = deb.MergeSnapshotsWithRules(sources, rules, latest, noRemove, collectionFactory.PackageCollection())
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
+			}
 		}
 
 		sourceDescription := make([]string, len(sources))
@@ -622,6 +1081,10 @@ func apiSnapshotsMerge(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to create snapshot: %s", err)
 		}
 
+		if withReport {
+			return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshotsMergeReportResponse{Snapshot: snapshot, Report: report}}, nil
+		}
+
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
 	})
 }
@@ -823,3 +1286,427 @@ func apiSnapshotsPull(c *gin.Context) {
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: destinationSnapshot}, nil
 	})
 }
+
+type dependencyOptionsParams struct {
+	// Follow Suggests, overrides dependencyFollowSuggests from configuration
+	FollowSuggests *bool `json:"DependencyFollowSuggests"`
+	// Follow Recommends, overrides dependencyFollowRecommends from configuration
+	FollowRecommends *bool `json:"DependencyFollowRecommends"`
+	// Follow all variants of dependencies, overrides dependencyFollowAllVariants from configuration
+	FollowAllVariants *bool `json:"DependencyFollowAllVariants"`
+	// Follow dependency from binary package to source package, overrides dependencyFollowSource from configuration
+	FollowSource *bool `json:"DependencyFollowSource"`
+}
+
+// dependencyOptionsFromQuery parses dependency options from query parameters (dep-follow-suggests=1, etc.)
+func dependencyOptionsFromQuery(c *gin.Context) dependencyOptionsParams {
+	var params dependencyOptionsParams
+
+	for name, option := range map[string]**bool{
+		"dep-follow-suggests":     &params.FollowSuggests,
+		"dep-follow-recommends":   &params.FollowRecommends,
+		"dep-follow-all-variants": &params.FollowAllVariants,
+		"dep-follow-source":       &params.FollowSource,
+	} {
+		if value, ok := c.GetQuery(name); ok {
+			enabled := value == "1"
+			*option = &enabled
+		}
+	}
+
+	return params
+}
+
+// options returns dependency options configured, with overrides from params applied
+func (params dependencyOptionsParams) options() int {
+	options := context.DependencyOptions()
+
+	for _, override := range []struct {
+		value *bool
+		flag  int
+	}{
+		{params.FollowSuggests, deb.DepFollowSuggests},
+		{params.FollowRecommends, deb.DepFollowRecommends},
+		{params.FollowAllVariants, deb.DepFollowAllVariants},
+		{params.FollowSource, deb.DepFollowSource},
+	} {
+		if override.value == nil {
+			continue
+		}
+
+		if *override.value {
+			options |= override.flag
+		} else {
+			options &^= override.flag
+		}
+	}
+
+	return options
+}
+
+type snapshotsFilterParams struct {
+	dependencyOptionsParams
+
+	// Name of the snapshot to be created
+	Destination string `binding:"required" json:"Destination"       example:"wheezy-required"`
+	// List of package queries, packages matching any of the queries are included
+	Queries []string `binding:"required"   json:"Queries"           example:"Priority (required)"`
+	// Include dependencies of matching packages
+	WithDeps bool `                        json:"WithDeps"`
+	// List of architectures (optional)
+	Architectures []string `               json:"Architectures"     example:"amd64, armhf"`
+}
+
+// @Summary Snapshot Filter
+// @Description **Filter packages in snapshot producing another snapshot**
+// @Description
+// @Description New snapshot `Destination` is created with packages of snapshot `name` matching any of `Queries`, with dependencies if `WithDeps` is set.
+// @Description If architectures are limited (with config architectures or parameter `Architectures`), dependencies are resolved only for mentioned architectures, otherwise aptly will process all architectures in the snapshot.
+// @Description Dependency handling options default to configuration values.
+// @Description
+// @Description See also: `aptly snapshot filter`
+// @Tags Snapshots
+// @Param name path string true "Name of the snapshot to be filtered"
+// @Param request body snapshotsFilterParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Consume json
+// @Produce json
+// @Success 201 {object} deb.Snapshot "Resulting snapshot object"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Not Found"
+// @Failure 409 {object} Error "Destination snapshot already exists"
+// @Failure 422 {object} Error "Unable to determine list of architectures"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/snapshots/{name}/filter [post]
+func apiSnapshotsFilter(c *gin.Context) {
+	var body snapshotsFilterParams
+
+	if c.Bind(&body) != nil {
+		return
+	}
+
+	queries := make([]deb.PackageQuery, len(body.Queries))
+	for i, q := range body.Queries {
+		var err error
+
+		queries[i], err = query.Parse(q)
+		if err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unable to parse query '%s': %s", q, err))
+			return
+		}
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	snapshotCollection := collectionFactory.SnapshotCollection()
+
+	source, err := snapshotCollection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, err)
+		return
+	}
+
+	if _, err = snapshotCollection.ByName(body.Destination); err == nil {
+		AbortWithJSONError(c, http.StatusConflict, fmt.Errorf("snapshot with name %s already exists", body.Destination))
+		return
+	}
+
+	resources := []string{string(source.ResourceKey())}
+	taskName := fmt.Sprintf("Filter snapshot %s into %s", source.Name, body.Destination)
+	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		err := snapshotCollection.LoadComplete(source)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		out.Printf("Loading packages (%d)...\n", source.RefList().Len())
+		packageList, err := deb.NewPackageListFromRefList(source.RefList(), collectionFactory.PackageCollection(), out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages: %s", err)
+		}
+
+		packageList.PrepareIndex()
+
+		var architecturesList []string
+
+		if len(body.Architectures) > 0 {
+			architecturesList = body.Architectures
+		} else if len(context.ArchitecturesList()) > 0 {
+			architecturesList = context.ArchitecturesList()
+		} else {
+			architecturesList = packageList.Architectures(false)
+		}
+
+		sort.Strings(architecturesList)
+
+		if len(architecturesList) == 0 && body.WithDeps {
+			return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, deb.ErrNoArchitectures
+		}
+
+		result, err := packageList.Filter(deb.FilterOptions{
+			Queries:           queries,
+			WithDependencies:  body.WithDeps,
+			DependencyOptions: body.options(),
+			Architectures:     architecturesList,
+			Progress:          out,
+		})
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to filter: %s", err)
+		}
+
+		destination := deb.NewSnapshotFromPackageList(body.Destination, []*deb.Snapshot{source}, result,
+			fmt.Sprintf("Filtered '%s', query was: '%s'", source.Name, strings.Join(body.Queries, ", ")))
+
+		err = snapshotCollection.Add(destination)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to create snapshot: %s", err)
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: destination}, nil
+	})
+}
+
+// @Summary Snapshot Verify
+// @Description **Verify dependencies in snapshot**
+// @Description
+// @Description Dependencies of packages in snapshot `name` are resolved in the snapshot itself and additional snapshots `source`.
+// @Description Every package with unsatisfied dependency is returned, once for each architecture dependency can't be satisfied for.
+// @Description Dependency handling options default to configuration values.
+// @Description
+// @Description See also: `aptly snapshot verify`
+// @Tags Snapshots
+// @Param name path string true "Name of the snapshot to be verified"
+// @Param source query []string false "additional snapshots used as dependency sources"
+// @Param architectures query string false "comma-separated list of architectures to verify"
+// @Param dep-follow-suggests query int false "follow Suggests: 1 to enable, 0 to disable"
+// @Param dep-follow-recommends query int false "follow Recommends: 1 to enable, 0 to disable"
+// @Param dep-follow-all-variants query int false "follow all variants of dependencies: 1 to enable, 0 to disable"
+// @Param dep-follow-source query int false "follow dependency from binary package to source package: 1 to enable, 0 to disable"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {array} deb.UnresolvedDependency "Unresolved dependencies"
+// @Failure 404 {object} Error "Not Found"
+// @Failure 422 {object} Error "Unable to determine list of architectures"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/snapshots/{name}/verify [get]
+func apiSnapshotsVerify(c *gin.Context) {
+	collectionFactory := context.NewCollectionFactory()
+	snapshotCollection := collectionFactory.SnapshotCollection()
+
+	names := append([]string{c.Params.ByName("name")}, c.QueryArray("source")...)
+
+	snapshots := make([]*deb.Snapshot, len(names))
+	resources := make([]string, len(names))
+	for i, name := range names {
+		var err error
+
+		snapshots[i], err = snapshotCollection.ByName(name)
+		if err != nil {
+			AbortWithJSONError(c, http.StatusNotFound, err)
+			return
+		}
+
+		resources[i] = string(snapshots[i].ResourceKey())
+	}
+
+	var architecturesList []string
+	if value := c.Query("architectures"); value != "" {
+		architecturesList = strings.Split(value, ",")
+	}
+
+	dependencyOptions := dependencyOptionsFromQuery(c).options()
+
+	maybeRunTaskInBackground(c, "Verify snapshot "+snapshots[0].Name, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		sourcePackageList := deb.NewPackageList()
+
+		var packageList *deb.PackageList
+
+		for i, snapshot := range snapshots {
+			err := snapshotCollection.LoadComplete(snapshot)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+			}
+
+			list, err := deb.NewPackageListFromRefList(snapshot.RefList(), collectionFactory.PackageCollection(), out)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages: %s", err)
+			}
+
+			if i == 0 {
+				packageList = list
+			}
+
+			err = sourcePackageList.Append(list)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to merge sources: %s", err)
+			}
+		}
+
+		sourcePackageList.PrepareIndex()
+
+		if len(architecturesList) == 0 {
+			if len(context.ArchitecturesList()) > 0 {
+				architecturesList = context.ArchitecturesList()
+			} else {
+				architecturesList = packageList.Architectures(true)
+			}
+		}
+
+		if len(architecturesList) == 0 {
+			return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, deb.ErrNoArchitectures
+		}
+
+		unresolved, err := packageList.UnresolvedDependencies(dependencyOptions, architecturesList, sourcePackageList, out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to verify dependencies: %s", err)
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: unresolved}, nil
+	})
+}
+
+// @Summary Snapshot Audit
+// @Description **Report packages in snapshot affected by known vulnerabilities**
+// @Description
+// @Description Packages are matched by source package name and version against locally stored advisories: Debian Security Tracker JSON
+// @Description or OSV records (single file, directory or .zip archive) uploaded to the upload directory beforehand.
+// @Description For each vulnerability, versions of the package fixing it available in mirrors are listed.
+// @Description
+// @Description See also: `aptly snapshot audit`
+// @Tags Snapshots
+// @Param name path string true "Name of the snapshot to be audited"
+// @Param advisories query string true "advisories in the upload directory: `<dir>` or `<dir>/<file>`"
+// @Param release query string false "match only advisories for the release (codename or OSV ecosystem), defaults to distribution of snapshot sources"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} deb.AuditReport "Audit report"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Not Found"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/snapshots/{name}/audit [get]
+func apiSnapshotsAudit(c *gin.Context) {
+	collectionFactory := context.NewCollectionFactory()
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, err)
+		return
+	}
+
+	resources := []string{string(snapshot.ResourceKey())}
+	distribution := deb.SnapshotDistribution(snapshot, collectionFactory)
+	runAudit(c, "Audit snapshot "+snapshot.Name, resources, "", distribution, func(collectionFactory *deb.CollectionFactory) (*deb.PackageRefList, error) {
+		err := collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+		if err != nil {
+			return nil, err
+		}
+
+		return snapshot.RefList(), nil
+	})
+}
+
+// @Summary Snapshot SBOM
+// @Description **Get software bill of materials of snapshot**
+// @Description
+// @Description Every package of the snapshot is listed with name, version, architecture, source package, maintainer,
+// @Description checksums of package file and package URL (purl).
+// @Description
+// @Description See also: `aptly snapshot sbom`
+// @Tags Snapshots
+// @Param name path string true "Snapshot name"
+// @Param format query string false "SBOM format: `spdx-json` (default) or `cyclonedx-json`"
+// @Param purlNamespace query string false "vendor in package URLs, `debian` by default"
+// @Produce json
+// @Success 200 {object} object "SBOM document"
+// @Failure 400 {object} Error "Unknown SBOM format"
+// @Failure 404 {object} Error "Not Found"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/snapshots/{name}/sbom [get]
+func apiSnapshotsSBOM(c *gin.Context) {
+	format, ok := sbomFormatFromQuery(c)
+	if !ok {
+		return
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, err)
+		return
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeSBOM(c, format, snapshot.Name, "", map[string]*deb.PackageRefList{"": snapshot.RefList()}, collectionFactory)
+}
+
+// @Summary Snapshot Licenses
+// @Description **Get licenses of packages in snapshot**
+// @Description
+// @Description Licenses are extracted from copyright files of binary packages, only machine-readable (DEP-5)
+// @Description copyright files declare licenses. Licenses of packages which haven't been extracted yet are
+// @Description extracted from the package pool, packages which can't be read are listed with the error.
+// @Description
+// @Description Provide `q` to list only packages matching the query, e.g. `$License (% GPL-3*)`.
+// @Description
+// @Description See also: `aptly snapshot licenses`
+// @Tags Snapshots
+// @Param name path string true "Snapshot name"
+// @Param q query string false "Package query"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {array} deb.PackageLicensesEntry
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Not Found"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/snapshots/{name}/licenses [get]
+func apiSnapshotsLicenses(c *gin.Context) {
+	var q deb.PackageQuery
+
+	if queryS := c.Request.URL.Query().Get("q"); queryS != "" {
+		var err error
+		q, err = query.Parse(queryS)
+		if err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, err)
+		return
+	}
+
+	resources := []string{string(snapshot.ResourceKey())}
+	maybeRunTaskInBackground(c, "List licenses of snapshot "+snapshot.Name, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		err := collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		// packages imported by older versions of aptly don't have licenses extracted yet
+		_, err = deb.ExtractLicenses(snapshot.RefList(), collectionFactory.PackageCollection(), context.PackagePool(), out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		list, err := deb.NewPackageListFromRefList(snapshot.RefList(), collectionFactory.PackageCollection(), out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		if q != nil {
+			list.PrepareIndex()
+			list, err = list.Filter(deb.FilterOptions{Queries: []deb.PackageQuery{q}})
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+			}
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: deb.LicenseInventory(list)}, nil
+	})
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/gin-gonic/gin"
+	. "gopkg.in/check.v1"
+)
+
+type SnapshotGCSuite struct {
+	APISuite
+}
+
+var _ = Suite(&SnapshotGCSuite{})
+
+func (s *SnapshotGCSuite) SetUpSuite(c *C) {
+	s.APISuite.SetUpSuite(c)
+
+	s.context.Config().SnapshotRetention = []utils.SnapshotRetentionRule{
+		{Pattern: "gc-*", KeepLast: 1},
+	}
+}
+
+func (s *SnapshotGCSuite) gc(c *C, query string) map[string]string {
+	response, err := s.HTTPRequest("POST", "/api/snapshots/gc"+query, nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	var decisions []struct {
+		Name   string
+		Drop   bool
+		Reason string
+	}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &decisions), IsNil)
+
+	result := map[string]string{}
+	for _, decision := range decisions {
+		if decision.Drop {
+			result[decision.Name] = "drop"
+		} else {
+			result[decision.Name] = "keep"
+		}
+	}
+
+	return result
+}
+
+func (s *SnapshotGCSuite) TestGC(c *C) {
+	for _, name := range []string{"gc-1", "gc-2", "gc-3"} {
+		body, err := json.Marshal(gin.H{"Name": name})
+		c.Assert(err, IsNil)
+
+		response, err := s.HTTPRequest("POST", "/api/snapshots", bytes.NewReader(body))
+		c.Assert(err, IsNil)
+		c.Assert(response.Code, Equals, 201)
+	}
+
+	planned := s.gc(c, "?dryRun=1")
+	c.Assert(planned, HasLen, 3)
+
+	kept := ""
+	for name, action := range planned {
+		if action == "keep" {
+			c.Check(kept, Equals, "")
+			kept = name
+		}
+	}
+	c.Assert(kept, Not(Equals), "")
+
+	for name := range planned {
+		response, err := s.HTTPRequest("GET", "/api/snapshots/"+name, nil)
+		c.Assert(err, IsNil)
+		c.Check(response.Code, Equals, 200)
+	}
+
+	c.Check(s.gc(c, ""), DeepEquals, planned)
+
+	for name, action := range planned {
+		response, err := s.HTTPRequest("GET", "/api/snapshots/"+name, nil)
+		c.Assert(err, IsNil)
+		if action == "keep" {
+			c.Check(response.Code, Equals, 200)
+		} else {
+			c.Check(response.Code, Equals, 404)
+		}
+	}
+
+	c.Check(s.gc(c, ""), DeepEquals, map[string]string{kept: "keep"})
+
+	response, err := s.HTTPRequest("DELETE", "/api/snapshots/"+kept, nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+}
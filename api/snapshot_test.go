@@ -1,9 +1,16 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/gin-gonic/gin"
 	. "gopkg.in/check.v1"
 )
 
@@ -51,3 +58,499 @@ func (s *SnapshotsSuite) TestGetSnapshotsReturns500OnCorruptRefList(c *C) {
 	c.Assert(response.Code, Equals, 500)
 	c.Assert(response.Body.String(), Matches, ".*msgpack.*|.*decode.*")
 }
+
+func (s *SnapshotsSuite) addDependencySnapshot(c *C, name string, packages ...deb.Stanza) *deb.Snapshot {
+	collectionFactory := s.context.NewCollectionFactory()
+
+	list := deb.NewPackageList()
+	for _, stanza := range packages {
+		p := deb.NewPackageFromControlFile(stanza)
+		c.Assert(collectionFactory.PackageCollection().Update(p), IsNil)
+		c.Assert(list.Add(p), IsNil)
+	}
+
+	snapshot := deb.NewSnapshotFromPackageList(name, nil, list, "")
+	c.Assert(collectionFactory.SnapshotCollection().Add(snapshot), IsNil)
+
+	return snapshot
+}
+
+func (s *SnapshotsSuite) TestFilterVerify(c *C) {
+	s.addDependencySnapshot(c, "filter-source",
+		deb.Stanza{"Package": "filter-app", "Version": "1.0", "Architecture": "amd64", "Depends": "filter-lib (>= 1.0), filter-missing",
+			"Filename": "filter-app_1.0_amd64.deb", "Size": "1", "MD5sum": "00"},
+		deb.Stanza{"Package": "filter-lib", "Version": "1.1", "Architecture": "amd64",
+			"Filename": "filter-lib_1.1_amd64.deb", "Size": "1", "MD5sum": "00"},
+		deb.Stanza{"Package": "filter-other", "Version": "2.0", "Architecture": "amd64",
+			"Filename": "filter-other_2.0_amd64.deb", "Size": "1", "MD5sum": "00"})
+	s.addDependencySnapshot(c, "filter-extra",
+		deb.Stanza{"Package": "filter-missing", "Version": "0.1", "Architecture": "all",
+			"Filename": "filter-missing_0.1_all.deb", "Size": "1", "MD5sum": "00"})
+
+	post := func(url string, params gin.H) (int, string) {
+		body, err := json.Marshal(params)
+		c.Assert(err, IsNil)
+
+		response, err := s.HTTPRequest("POST", url, bytes.NewReader(body))
+		c.Assert(err, IsNil)
+		return response.Code, response.Body.String()
+	}
+
+	code, _ := post("/api/snapshots/no-such-snapshot/filter", gin.H{"Destination": "filtered", "Queries": []string{"filter-app"}})
+	c.Check(code, Equals, 404)
+
+	code, _ = post("/api/snapshots/filter-source/filter", gin.H{"Destination": "filter-extra", "Queries": []string{"filter-app"}})
+	c.Check(code, Equals, 409)
+
+	code, _ = post("/api/snapshots/filter-source/filter", gin.H{"Destination": "filtered", "Queries": []string{"Name ("}})
+	c.Check(code, Equals, 400)
+
+	code, body := post("/api/snapshots/filter-source/filter", gin.H{"Destination": "filtered", "Queries": []string{"filter-app"}, "WithDeps": true})
+	c.Assert(code, Equals, 201, Commentf("%s", body))
+
+	var snapshot deb.Snapshot
+	c.Assert(json.Unmarshal([]byte(body), &snapshot), IsNil)
+	c.Check(snapshot.Description, Equals, "Filtered 'filter-source', query was: 'filter-app'")
+
+	response, err := s.HTTPRequest("GET", "/api/snapshots/filtered/packages", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	var refs []string
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &refs), IsNil)
+	sort.Strings(refs)
+	c.Assert(refs, HasLen, 2)
+	c.Check(refs[0], Matches, "Pamd64 filter-app 1.0 [0-9a-f]+")
+	c.Check(refs[1], Matches, "Pamd64 filter-lib 1.1 [0-9a-f]+")
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/filtered/verify", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	var unresolved []deb.UnresolvedDependency
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &unresolved), IsNil)
+	c.Check(unresolved, DeepEquals, []deb.UnresolvedDependency{
+		{Package: "filter-app_1.0_amd64", Dependency: "filter-missing [amd64]", Architecture: "amd64"},
+	})
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/filtered/verify?source=filter-extra", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Equals, "[]")
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/filtered/verify?source=no-such-snapshot", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+}
+
+func (s *SnapshotsSuite) TestChanges(c *C) {
+	s.addDependencySnapshot(c, "changes-old",
+		deb.Stanza{"Package": "changes-app", "Version": "1.0", "Architecture": "amd64",
+			"Filename": "changes-app_1.0_amd64.deb", "Size": "1", "MD5sum": "00"},
+		deb.Stanza{"Package": "changes-gone", "Version": "0.1", "Architecture": "all",
+			"Filename": "changes-gone_0.1_all.deb", "Size": "1", "MD5sum": "00"})
+	s.addDependencySnapshot(c, "changes-new",
+		deb.Stanza{"Package": "changes-app", "Version": "1.1", "Architecture": "amd64",
+			"Filename": "changes-app_1.1_amd64.deb", "Size": "1", "MD5sum": "00"})
+
+	response, err := s.HTTPRequest("GET", "/api/snapshots/changes-old/changes?with=changes-new", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200, Commentf("%s", response.Body.String()))
+
+	var diff deb.SemanticDiff
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &diff), IsNil)
+	c.Check(diff.From, Equals, "snapshot:changes-old")
+	c.Check(diff.To, Equals, "snapshot:changes-new")
+	c.Check(diff.Summary[deb.ChangeUpgraded], Equals, 1)
+	c.Check(diff.Summary[deb.ChangeRemoved], Equals, 1)
+	c.Check(diff.Summary[deb.ChangeAdded], Equals, 0)
+	c.Assert(diff.Sources, HasLen, 2)
+	c.Check(diff.Sources[0].Source, Equals, "changes-app")
+	c.Check(diff.Sources[0].Changes[0].Kind, Equals, deb.ChangeUpgraded)
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/changes-old/changes?with=snapshot:changes-new&format=csv&onlyMatching=1", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+	c.Check(response.Header().Get("Content-Type"), Equals, "text/csv; charset=utf-8")
+	c.Check(response.Body.String(), Equals, "Source,Package,Architecture,Change,OldVersion,NewVersion\n"+
+		"changes-app,changes-app,amd64,upgraded,1.0,1.1\n")
+
+	for url, code := range map[string]int{
+		"/api/snapshots/changes-old/changes":                             400,
+		"/api/snapshots/changes-old/changes?with=changes-new&format=xml": 400,
+		"/api/snapshots/changes-old/changes?with=package:changes-app":    400,
+		"/api/snapshots/changes-old/changes?with=mirror:no-such-mirror":  404,
+		"/api/snapshots/no-such-snapshot/changes?with=changes-new":       404,
+	} {
+		response, err = s.HTTPRequest("GET", url, nil)
+		c.Assert(err, IsNil)
+		c.Check(response.Code, Equals, code, Commentf("%s", url))
+	}
+}
+
+func (s *SnapshotsSuite) TestLabels(c *C) {
+	request := func(method, url string, params gin.H) (int, string) {
+		body, err := json.Marshal(params)
+		c.Assert(err, IsNil)
+
+		response, err := s.HTTPRequest(method, url, bytes.NewReader(body))
+		c.Assert(err, IsNil)
+		return response.Code, response.Body.String()
+	}
+
+	code, _ := request("POST", "/api/snapshots", gin.H{"Name": "labels-bad", "Labels": gin.H{"": "x"}})
+	c.Check(code, Equals, 400)
+
+	for name, release := range map[string]string{"labels-b": "2026.04", "labels-a": "2026.10", "labels-c": "2025.10"} {
+		code, body := request("POST", "/api/snapshots", gin.H{"Name": name, "Labels": gin.H{"group": "labels", "release": release}})
+		c.Assert(code, Equals, 201, Commentf("%s", body))
+	}
+
+	names := func(url string) []string {
+		code, body := request("GET", url, nil)
+		c.Assert(code, Equals, 200, Commentf("%s", body))
+
+		var snapshots []deb.Snapshot
+		c.Assert(json.Unmarshal([]byte(body), &snapshots), IsNil)
+
+		result := []string{}
+		for _, snapshot := range snapshots {
+			result = append(result, snapshot.Name)
+		}
+		return result
+	}
+
+	c.Check(names("/api/snapshots?label=group%3Dlabels&sort=label:release"), DeepEquals, []string{"labels-c", "labels-b", "labels-a"})
+	c.Check(names("/api/snapshots?label=group%3Dlabels&label=release!%3D2026.04"), DeepEquals, []string{"labels-a", "labels-c"})
+	c.Check(names("/api/snapshots?label=approved"), DeepEquals, []string{})
+
+	code, body := request("PUT", "/api/snapshots/labels-a", gin.H{"Description": "approved release"})
+	c.Assert(code, Equals, 200, Commentf("%s", body))
+	c.Check(body, Matches, `.*"Labels":\{"group":"labels","release":"2026.10"\}.*`)
+
+	code, body = request("PUT", "/api/snapshots/labels-a", gin.H{"Labels": gin.H{"approved": "true"}})
+	c.Assert(code, Equals, 200, Commentf("%s", body))
+	c.Check(names("/api/snapshots?label=approved%3Dtrue"), DeepEquals, []string{"labels-a"})
+
+	code, _ = request("GET", "/api/snapshots?label=%21%3D", nil)
+	c.Check(code, Equals, 400)
+}
+
+func (s *SnapshotsSuite) TestExportImport(c *C) {
+	body, err := json.Marshal(gin.H{"Name": "bundle-source"})
+	c.Assert(err, IsNil)
+	response, err := s.HTTPRequest("POST", "/api/repos", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+	defer func() {
+		_, _ = s.HTTPRequest("DELETE", "/api/repos/bundle-source?force=1", nil)
+	}()
+
+	uploadDir := filepath.Join(s.context.UploadPath(), "bundle")
+	c.Assert(os.MkdirAll(uploadDir, 0755), IsNil)
+	c.Assert(utils.CopyFile("../system/files/libboost-program-options-dev_1.49.0.1_i386.deb",
+		filepath.Join(uploadDir, "libboost-program-options-dev_1.49.0.1_i386.deb")), IsNil)
+
+	response, err = s.HTTPRequest("POST", "/api/repos/bundle-source/file/bundle", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	body, err = json.Marshal(gin.H{"Name": "bundle-exported", "Labels": gin.H{"team": "infra"}})
+	c.Assert(err, IsNil)
+	response, err = s.HTTPRequest("POST", "/api/repos/bundle-source/snapshots", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+	defer func() {
+		_, _ = s.HTTPRequest("DELETE", "/api/snapshots/bundle-exported", nil)
+	}()
+
+	signing, err := json.Marshal(gin.H{"Signing": gin.H{"Skip": true}})
+	c.Assert(err, IsNil)
+
+	response, err = s.HTTPRequest("POST", "/api/snapshots/no-such-snapshot/export", bytes.NewReader(signing))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+
+	response, err = s.HTTPRequest("POST", "/api/snapshots/bundle-exported/export", bytes.NewReader(signing))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+	c.Check(response.Header().Get("Content-Type"), Equals, "application/x-tar")
+	bundle := response.Body.Bytes()
+
+	response, err = s.HTTPRequest("POST", "/api/snapshots/import?name=bundle-imported", bytes.NewReader(bundle))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*bundle is not signed.*")
+
+	response, err = s.HTTPRequest("POST", "/api/snapshots/import?name=bundle-imported&ignoreSignatures=1", bytes.NewReader(bundle))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+	defer func() {
+		_, _ = s.HTTPRequest("DELETE", "/api/snapshots/bundle-imported", nil)
+	}()
+
+	var snapshot deb.Snapshot
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &snapshot), IsNil)
+	c.Check(snapshot.Name, Equals, "bundle-imported")
+	c.Check(snapshot.Labels, DeepEquals, deb.Labels{"team": "infra"})
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/bundle-imported/packages", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Body.String(), Equals, `["Pi386 libboost-program-options-dev 1.49.0.1 918d2f433384e378"]`)
+
+	response, err = s.HTTPRequest("POST", "/api/snapshots/import?name=bundle-imported&ignoreSignatures=1", bytes.NewReader(bundle))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*snapshot with name bundle-imported already exists.*")
+
+	response, err = s.HTTPRequest("POST", "/api/snapshots/import?ignoreSignatures=1", bytes.NewReader([]byte("garbage")))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+}
+
+func (s *SnapshotsSuite) TestMergeRules(c *C) {
+	stanza := func(name, version string) deb.Stanza {
+		return deb.Stanza{"Package": name, "Version": version, "Architecture": "amd64",
+			"Filename": name + "_" + version + "_amd64.deb", "Size": "1", "MD5sum": "00"}
+	}
+	s.addDependencySnapshot(c, "merge-main", stanza("merge-web", "1.22"), stanza("merge-tool", "1.0"))
+	s.addDependencySnapshot(c, "merge-backports", stanza("merge-web", "1.24"), stanza("merge-tool", "2.0"))
+
+	request := func(url string, params gin.H) (int, string) {
+		body, err := json.Marshal(params)
+		c.Assert(err, IsNil)
+
+		response, err := s.HTTPRequest("POST", url, bytes.NewReader(body))
+		c.Assert(err, IsNil)
+		return response.Code, response.Body.String()
+	}
+
+	code, body := request("/api/snapshots/merge-bad/merge", gin.H{"Sources": []string{"merge-main", "merge-backports"},
+		"Pins": []gin.H{{"Source": "merge-backports", "Query": "merge-web ("}}})
+	c.Check(code, Equals, 400)
+	c.Check(body, Matches, ".*unable to parse query.*")
+
+	code, body = request("/api/snapshots/merge-bad/merge", gin.H{"Sources": []string{"merge-main", "merge-backports"},
+		"Priorities": gin.H{"merge-testing": 900}})
+	c.Check(code, Equals, 400)
+	c.Check(body, Matches, ".*priority set for snapshot merge-testing which is not a merge source.*")
+
+	code, body = request("/api/snapshots/merge-result/merge?report=1", gin.H{"Sources": []string{"merge-main", "merge-backports"},
+		"Priorities": gin.H{"merge-main": 900},
+		"Pins":       []gin.H{{"Source": "merge-backports", "Query": "merge-web"}},
+		"Excludes":   []gin.H{{"Source": "*", "Query": "merge-tool (>= 2.0)"}}})
+	c.Assert(code, Equals, 201, Commentf("%s", body))
+
+	var result struct {
+		Snapshot deb.Snapshot
+		Report   deb.MergeReport
+	}
+	c.Assert(json.Unmarshal([]byte(body), &result), IsNil)
+	c.Check(result.Snapshot.Name, Equals, "merge-result")
+	c.Assert(result.Report.Excluded, HasLen, 1)
+	c.Check(result.Report.Excluded[0].Rule, Equals, "*:merge-tool (>= 2.0)")
+	c.Assert(result.Report.Conflicts, HasLen, 1)
+	c.Check(result.Report.Conflicts[0].Kept, DeepEquals, []deb.MergeCandidate{{Source: "merge-backports", Version: "1.24"}})
+	c.Check(result.Report.Conflicts[0].Reason, Equals, "pinned by merge-backports:merge-web")
+
+	response, err := s.HTTPRequest("GET", "/api/snapshots/merge-result/packages", nil)
+	c.Assert(err, IsNil)
+
+	// refs are ordered by package hash, which differs between runs
+	var refs []string
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &refs), IsNil)
+	sort.Strings(refs)
+	c.Assert(refs, HasLen, 2)
+	c.Check(refs[0], Matches, "Pamd64 merge-tool 1.0 [0-9a-f]+")
+	c.Check(refs[1], Matches, "Pamd64 merge-web 1.24 [0-9a-f]+")
+}
+
+func (s *SnapshotsSuite) TestAudit(c *C) {
+	snapshot := s.addDependencySnapshot(c, "audit-snapshot",
+		deb.Stanza{"Package": "audit-lib", "Version": "1.0-1", "Architecture": "amd64", "Source": "audit-src",
+			"Filename": "audit-lib_1.0-1_amd64.deb", "Size": "1", "MD5sum": "00"},
+		deb.Stanza{"Package": "audit-other", "Version": "2.0", "Architecture": "amd64",
+			"Filename": "audit-other_2.0_amd64.deb", "Size": "1", "MD5sum": "00"})
+
+	dir := filepath.Join(s.context.UploadPath(), "audit")
+	c.Assert(os.MkdirAll(dir, 0755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "tracker.json"), []byte(`{"audit-src": {"CVE-2026-9999": {"releases": {
+		"bookworm": {"status": "resolved", "fixed_version": "1.0-2", "urgency": "high"}}}}}`), 0644), IsNil)
+
+	response, err := s.HTTPRequest("GET", "/api/snapshots/audit-snapshot/audit", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/audit-snapshot/audit?advisories=../tracker.json", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/no-such-snapshot/audit?advisories=audit/tracker.json", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+
+	// distribution of the snapshot is unknown
+	response, err = s.HTTPRequest("GET", "/api/snapshots/audit-snapshot/audit?advisories=audit/tracker.json", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*release parameter is required.*")
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/audit-snapshot/audit?advisories=audit/tracker.json&release=bookworm", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200, Commentf("%s", response.Body.String()))
+
+	var report deb.AuditReport
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &report), IsNil)
+	c.Check(report.Packages, Equals, 2)
+	c.Check(report.AffectedPackages, Equals, 1)
+	c.Assert(report.Findings, HasLen, 1)
+	c.Check(report.Findings[0].Package, Equals, "audit-lib")
+	c.Check(report.Findings[0].Advisory, Equals, "CVE-2026-9999")
+	c.Check(report.Findings[0].FixedVersion, Equals, "1.0-2")
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/audit-snapshot/audit?advisories=audit&release=bullseye", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &report), IsNil)
+	c.Check(report.Findings, HasLen, 0)
+
+	// release derived from suite name matches no advisories
+	collectionFactory := s.context.NewCollectionFactory()
+	mirror, err := deb.NewRemoteRepo("audit-mirror", "http://deb.debian.org/debian", "stable", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(err, IsNil)
+	c.Assert(collectionFactory.RemoteRepoCollection().Add(mirror), IsNil)
+	snapshot.SourceKind, snapshot.SourceIDs = deb.SourceRemoteRepo, []string{mirror.UUID}
+	c.Assert(collectionFactory.SnapshotCollection().Update(snapshot), IsNil)
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/audit-snapshot/audit?advisories=audit/tracker.json", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*no advisories for release stable.*release parameter is required.*")
+
+	// codename from the Release file of the mirror is used
+	mirror.Meta = deb.Stanza{"Codename": "bookworm"}
+	c.Assert(collectionFactory.RemoteRepoCollection().Update(mirror), IsNil)
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/audit-snapshot/audit?advisories=audit/tracker.json", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200, Commentf("%s", response.Body.String()))
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &report), IsNil)
+	c.Check(report.Findings, HasLen, 1)
+}
+
+func (s *SnapshotsSuite) TestSBOM(c *C) {
+	s.addDependencySnapshot(c, "sbom-snapshot",
+		deb.Stanza{"Package": "sbom-lib", "Version": "1:1.0-1+b1", "Architecture": "amd64", "Source": "sbom-src (1:1.0-1)",
+			"Maintainer": "Jane Doe <jane@example.com>", "Filename": "sbom-lib_1.0-1+b1_amd64.deb", "Size": "1", "MD5sum": "00",
+			"SHA256": "ff"})
+
+	response, err := s.HTTPRequest("GET", "/api/snapshots/sbom-snapshot/sbom?format=xml", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/no-such-snapshot/sbom", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/sbom-snapshot/sbom", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+	c.Check(response.Header().Get("Content-Type"), Equals, "application/spdx+json")
+
+	var spdx struct {
+		SPDXVersion string `json:"spdxVersion"`
+		Packages    []struct {
+			Name         string `json:"name"`
+			Supplier     string `json:"supplier"`
+			SourceInfo   string `json:"sourceInfo"`
+			ExternalRefs []struct {
+				ReferenceLocator string `json:"referenceLocator"`
+			} `json:"externalRefs"`
+		} `json:"packages"`
+	}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &spdx), IsNil)
+	c.Check(spdx.SPDXVersion, Equals, "SPDX-2.3")
+	c.Assert(spdx.Packages, HasLen, 1)
+	c.Check(spdx.Packages[0].Name, Equals, "sbom-lib")
+	c.Check(spdx.Packages[0].Supplier, Equals, "Person: Jane Doe (jane@example.com)")
+	c.Check(spdx.Packages[0].SourceInfo, Equals, "built package from: sbom-src 1:1.0-1")
+	c.Check(spdx.Packages[0].ExternalRefs[0].ReferenceLocator, Equals, "pkg:deb/debian/sbom-lib@1%3A1.0-1%2Bb1?arch=amd64")
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/sbom-snapshot/sbom?format=cyclonedx-json&purlNamespace=ubuntu", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+	c.Check(response.Header().Get("Content-Type"), Equals, "application/vnd.cyclonedx+json")
+
+	var cyclonedx struct {
+		BOMFormat  string `json:"bomFormat"`
+		Components []struct {
+			Purl   string `json:"purl"`
+			Hashes []struct {
+				Alg     string `json:"alg"`
+				Content string `json:"content"`
+			} `json:"hashes"`
+		} `json:"components"`
+	}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &cyclonedx), IsNil)
+	c.Check(cyclonedx.BOMFormat, Equals, "CycloneDX")
+	c.Assert(cyclonedx.Components, HasLen, 1)
+	c.Check(cyclonedx.Components[0].Purl, Equals, "pkg:deb/ubuntu/sbom-lib@1%3A1.0-1%2Bb1?arch=amd64")
+	c.Check(cyclonedx.Components[0].Hashes, HasLen, 2)
+}
+
+func (s *SnapshotsSuite) TestLicenses(c *C) {
+	snapshot := s.addDependencySnapshot(c, "licenses-snapshot",
+		deb.Stanza{"Package": "licenses-broken", "Version": "0.1", "Architecture": "amd64",
+			"Filename": "licenses-broken_0.1_amd64.deb", "Size": "1", "MD5sum": "00"},
+		deb.Stanza{"Package": "licenses-gpl", "Version": "1.0", "Architecture": "amd64",
+			"Filename": "licenses-gpl_1.0_amd64.deb", "Size": "1", "MD5sum": "00"},
+		deb.Stanza{"Package": "licenses-mit", "Version": "2.0", "Architecture": "amd64",
+			"Filename": "licenses-mit_2.0_amd64.deb", "Size": "1", "MD5sum": "00"})
+
+	collectionFactory := s.context.NewCollectionFactory()
+	c.Assert(collectionFactory.SnapshotCollection().LoadComplete(snapshot), IsNil)
+	_ = snapshot.RefList().ForEach(func(key []byte) error {
+		p, err := collectionFactory.PackageCollection().ByKey(key)
+		c.Assert(err, IsNil)
+		if p.Name == "licenses-broken" {
+			return nil
+		}
+
+		licenses := &deb.PackageLicenses{HasCopyright: true, MachineReadable: true,
+			Expressions: []string{"GPL-3+"}, Licenses: []string{"GPL-3+"}}
+		if p.Name == "licenses-mit" {
+			licenses.Expressions, licenses.Licenses = []string{"Expat"}, []string{"Expat"}
+		}
+		c.Assert(collectionFactory.PackageCollection().UpdateLicenses(p, licenses), IsNil)
+		return nil
+	})
+
+	response, err := s.HTTPRequest("GET", "/api/snapshots/no-such-snapshot/licenses", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/licenses-snapshot/licenses?q="+url.QueryEscape("$License (("), nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+
+	var inventory []deb.PackageLicensesEntry
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/licenses-snapshot/licenses", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &inventory), IsNil)
+	c.Assert(inventory, HasLen, 3)
+	// package file is missing in the pool, extraction failure is reported
+	c.Check(inventory[0].Package, Equals, "licenses-broken_0.1_amd64")
+	c.Check(inventory[0].Licenses.String(), Matches, "extraction failed: .+")
+	c.Check(inventory[1].Package, Equals, "licenses-gpl_1.0_amd64")
+	c.Check(inventory[1].Licenses.Licenses, DeepEquals, []string{"GPL-3+"})
+	c.Check(inventory[2].Package, Equals, "licenses-mit_2.0_amd64")
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/licenses-snapshot/licenses?q="+url.QueryEscape("$License (% GPL-3*)"), nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &inventory), IsNil)
+	c.Assert(inventory, HasLen, 1)
+	c.Check(inventory[0].Name, Equals, "licenses-gpl")
+}
@@ -39,6 +39,9 @@ func aptlyAPIServe(cmd *commander.Command, args []string) error {
 		return err
 	}
 
+	watchCtx, stopWatching := stdcontext.WithCancel(stdcontext.Background())
+	defer stopWatching()
+
 	// Try to recycle systemd fds for listening
 	listeners, err := activation.Listeners(true)
 	if len(listeners) > 1 {
@@ -48,7 +51,9 @@ func aptlyAPIServe(cmd *commander.Command, args []string) error {
 		listener := listeners[0]
 		defer func() { _ = listener.Close() }()
 		fmt.Printf("\nTaking over web server at: %s (press Ctrl+C to quit)...\n", listener.Addr().String())
-		err = http.Serve(listener, api.Router(context))
+		handler := api.Router(context)
+		api.WatchIncomingQueues(watchCtx)
+		err = http.Serve(listener, handler)
 		if err != nil {
 			return fmt.Errorf("unable to serve: %s", err)
 		}
@@ -60,12 +65,14 @@ func aptlyAPIServe(cmd *commander.Command, args []string) error {
 	fmt.Printf("\nStarting web server at: %s (press Ctrl+C to quit)...\n", listen)
 
 	server := http.Server{Handler: api.Router(context)}
+	api.WatchIncomingQueues(watchCtx)
 
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
 	go (func() {
 		if _, ok := <-sigchan; ok {
 			fmt.Printf("\nShutdown signal received, waiting for background tasks...\n")
+			stopWatching()
 			context.TaskList().Wait()
 			_ = server.Shutdown(stdcontext.Background())
 		}
@@ -108,6 +115,10 @@ or Unix domain socket. When using a socket, Aptly will fully manage the socket
 file. This command also supports taking over from a systemd file descriptors to
 enable systemd socket activation.
 
+Incoming queues configured in incoming_queues section of configuration are
+polled by the server, complete .changes uploads are included into local
+repositories as background tasks.
+
 Example:
 
   $ aptly api serve -listen=:8080
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+)
+
+// addAuditFlags adds flags shared by snapshot, mirror and publish audit commands
+func addAuditFlags(cmd *commander.Command) {
+	cmd.Flag.String("advisories", "", "path to Debian Security Tracker JSON or OSV advisories (file, directory or .zip archive)")
+	cmd.Flag.String("release", "", "match only advisories for this release (codename like bookworm or OSV ecosystem like Debian:12), defaults to codename of audited distribution")
+	cmd.Flag.Bool("json", false, "display report in JSON format")
+}
+
+// aptlyAudit matches packages from refList against advisories and prints the report
+//
+// Release of advisories defaults to the one derived from distribution of audited packages.
+func aptlyAudit(refList *deb.PackageRefList, collectionFactory *deb.CollectionFactory, skipMirror string, distribution string) error {
+	advisoriesPath := context.Flags().Lookup("advisories").Value.String()
+	if advisoriesPath == "" {
+		return fmt.Errorf("unable to audit: -advisories flag is required")
+	}
+
+	release := context.Flags().Lookup("release").Value.String()
+	derived := release == ""
+	if derived {
+		release = deb.AuditRelease(distribution)
+	}
+	if release == "" {
+		return fmt.Errorf("unable to audit: distribution is unknown, -release flag is required")
+	}
+
+	advisories, err := deb.LoadAdvisories(advisoriesPath, release)
+	if err != nil {
+		return fmt.Errorf("unable to audit: %s", err)
+	}
+
+	if derived && advisories.Len() == 0 {
+		return fmt.Errorf("unable to audit: no advisories for release %s derived from distribution %s, -release flag is required", release, distribution)
+	}
+
+	jsonFlag := context.Flags().Lookup("json").Value.Get().(bool)
+	if !jsonFlag {
+		context.Progress().Printf("Loading packages...\n")
+	}
+
+	list, err := deb.NewPackageListFromRefList(refList, collectionFactory.PackageCollection(), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to load packages: %s", err)
+	}
+
+	report, err := deb.AuditPackageList(list, advisories, collectionFactory, skipMirror)
+	if err != nil {
+		return fmt.Errorf("unable to audit: %s", err)
+	}
+
+	if jsonFlag {
+		return printJSON(report)
+	}
+
+	printAuditReport(context.Progress(), report)
+	return nil
+}
+
+func printAuditReport(progress aptly.Progress, report *deb.AuditReport) {
+	var last *deb.AuditFinding
+
+	for _, finding := range report.Findings {
+		if last == nil || last.Package != finding.Package || last.Version != finding.Version || last.Architecture != finding.Architecture {
+			if last != nil {
+				progress.Printf("\n")
+			}
+			progress.Printf("%s_%s_%s (source %s %s):\n", finding.Package, finding.Version, finding.Architecture,
+				finding.Source, finding.SourceVersion)
+		}
+		last = finding
+
+		advisory := finding.Advisory
+		if len(finding.Aliases) > 0 {
+			advisory += " (" + strings.Join(finding.Aliases, ", ") + ")"
+		}
+		if finding.Severity != "" {
+			advisory += ", " + finding.Severity
+		}
+		if description := strings.SplitN(strings.TrimSpace(finding.Description), "\n", 2)[0]; description != "" {
+			advisory += ": " + description
+		}
+		progress.Printf("  * %s\n", advisory)
+
+		if finding.FixedVersion == "" {
+			progress.Printf("    not fixed yet\n")
+		} else {
+			progress.Printf("    fixed in %s\n", finding.FixedVersion)
+		}
+
+		for _, fix := range finding.AvailableFixes {
+			progress.Printf("    available in mirror %s: %s\n", fix.Mirror, fix.Version)
+		}
+	}
+
+	if len(report.Findings) > 0 {
+		progress.Printf("\n")
+	}
+
+	progress.Printf("Found %d vulnerabilities affecting %d of %d packages.\n", len(report.Findings), report.AffectedPackages, report.Packages)
+}
@@ -99,6 +99,7 @@ package environment to new version.`,
 			makeCmdConfig(),
 			makeCmdDB(),
 			makeCmdGraph(),
+			makeCmdIncoming(),
 			makeCmdMirror(),
 			makeCmdRepo(),
 			makeCmdServe(),
@@ -107,6 +108,7 @@ package environment to new version.`,
 			makeCmdPublish(),
 			makeCmdVersion(),
 			makeCmdPackage(),
+			makeCmdPromotion(),
 			makeCmdAPI(),
 		},
 	}
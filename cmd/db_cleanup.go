@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
@@ -56,6 +57,22 @@ func aptlyDBCleanup(cmd *commander.Command, args []string) error {
 			}
 		}
 
+		// packages required to reconstruct mirror as of any moment during history retention period
+		retained, e := collectionFactory.MirrorHistoryCollection().RetainedRefList(repo.UUID,
+			context.Config().MirrorHistoryRetention, time.Now())
+		if e != nil {
+			return e
+		}
+		existingPackageRefs = existingPackageRefs.Merge(retained, false, true)
+
+		if verbose {
+			description := fmt.Sprintf("history of mirror %s", repo.Name)
+			_ = retained.ForEach(func(key []byte) error {
+				packageRefSources[string(key)] = append(packageRefSources[string(key)], description)
+				return nil
+			})
+		}
+
 		for _, poolPath := range repo.AppStreamFiles {
 			referencedAppStreamFiles = append(referencedAppStreamFiles, poolPath)
 		}
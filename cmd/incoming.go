@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/smira/commander"
+)
+
+// lookupIncomingQueue finds incoming queue in configuration by name
+func lookupIncomingQueue(name string) (utils.IncomingQueue, error) {
+	queue, ok := context.Config().IncomingQueues[name]
+	if !ok {
+		return queue, fmt.Errorf("incoming queue %s not found, queues are configured in incoming_queues section of configuration", name)
+	}
+
+	return queue, nil
+}
+
+func makeCmdIncoming() *commander.Command {
+	return &commander.Command{
+		UsageLine: "incoming",
+		Short:     "manage incoming queues of .changes uploads",
+		Subcommands: []*commander.Command{
+			makeCmdIncomingList(),
+			makeCmdIncomingLog(),
+			makeCmdIncomingProcess(),
+		},
+	}
+}
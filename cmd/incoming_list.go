@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyIncomingList(cmd *commander.Command, args []string) error {
+	if len(args) != 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	queues := context.Config().IncomingQueues
+	if len(queues) == 0 {
+		fmt.Printf("No incoming queues configured, add them to incoming_queues section of configuration.\n")
+		return nil
+	}
+
+	names := make([]string, 0, len(queues))
+	for name := range queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("List of incoming queues:\n")
+	for _, name := range names {
+		queue := queues[name]
+
+		pending := "unable to scan directory"
+		if uploads, err := deb.IncomingUploads(queue.Directory); err == nil {
+			pending = fmt.Sprintf("%d uploads pending", len(uploads))
+		}
+
+		fmt.Printf(" * %s: %s -> %s (%s)\n", name, queue.Directory, queue.GetRepo(), pending)
+	}
+
+	return nil
+}
+
+func makeCmdIncomingList() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyIncomingList,
+		UsageLine: "list",
+		Short:     "list incoming queues",
+		Long: `
+List incoming queues configured in incoming_queues section of configuration
+along with number of complete uploads waiting to be processed.
+
+Example:
+
+  $ aptly incoming list
+`,
+		Flag: *flag.NewFlagSet("aptly-incoming-list", flag.ExitOnError),
+	}
+
+	return cmd
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyIncomingLog(cmd *commander.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	if _, err := lookupIncomingQueue(args[0]); err != nil {
+		return fmt.Errorf("unable to show log: %s", err)
+	}
+
+	records, err := context.NewCollectionFactory().IncomingAuditCollection().ForQueue(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to show log: %s", err)
+	}
+
+	if cmd.Flag.Lookup("json").Value.Get().(bool) {
+		return printJSON(records)
+	}
+
+	if len(records) == 0 {
+		fmt.Printf("No uploads processed from incoming queue %s.\n", args[0])
+		return nil
+	}
+
+	fmt.Printf("Uploads processed from incoming queue %s:\n", args[0])
+	for _, record := range records {
+		fmt.Printf(" * %s\n", record)
+	}
+
+	return nil
+}
+
+func makeCmdIncomingLog() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyIncomingLog,
+		UsageLine: "log <name>",
+		Short:     "show uploads processed from incoming queue",
+		Long: `
+Command log displays audit records of uploads processed from incoming queue:
+accepted uploads and rejected uploads along with reason of rejection.
+
+Example:
+
+  $ aptly incoming log default
+`,
+		Flag: *flag.NewFlagSet("aptly-incoming-log", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("json", false, "display log in JSON format")
+
+	return cmd
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyIncomingProcess(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	verifier, err := getVerifier(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	rejected := 0
+
+	for _, name := range args {
+		queue, err := lookupIncomingQueue(name)
+		if err != nil {
+			return fmt.Errorf("unable to process: %s", err)
+		}
+
+		records, err := deb.ProcessIncomingQueue(name, queue, verifier, newKeyringVerifier, context.Progress(), collectionFactory,
+			context.PackagePool(), query.Parse, time.Now())
+		for _, record := range records {
+			if record.Accepted {
+				context.Progress().ColoredPrintf("@g[+]@| %s", record)
+			} else {
+				context.Progress().ColoredPrintf("@r[!]@| %s", record)
+				rejected++
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("unable to process incoming queue %s: %s", name, err)
+		}
+
+		context.Progress().Printf("\nIncoming queue %s processed, %d uploads.\n", name, len(records))
+	}
+
+	if rejected > 0 {
+		return fmt.Errorf("some uploads were rejected")
+	}
+
+	return err
+}
+
+func makeCmdIncomingProcess() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyIncomingProcess,
+		UsageLine: "process <name>...",
+		Short:     "include complete uploads from incoming queues",
+		Long: `
+Command process looks for complete .changes uploads (all referenced files
+present) in directories of incoming queues <name>, and includes them into
+local repositories as 'aptly repo include' does, using settings of the queue
+from incoming_queues section of configuration.
+
+Accepted files are removed from queue directory, rejected uploads are moved
+to reject directory along with .reason file. Every upload gets an audit record,
+see 'aptly incoming log'.
+
+API server ('aptly api serve') polls incoming queues and processes them
+automatically.
+
+Example:
+
+  $ aptly incoming process default
+`,
+		Flag: *flag.NewFlagSet("aptly-incoming-process", flag.ExitOnError),
+	}
+
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying .changes file (could be specified multiple times)")
+
+	return cmd
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/flag"
+)
+
+// labelsFlag collects values of repeatable label flags, it can't be named just -label,
+// as it would clash with Release file label flag of publish commands
+type labelsFlag struct {
+	labels []string
+}
+
+func (l *labelsFlag) Set(value string) error {
+	l.labels = append(l.labels, value)
+	return nil
+}
+
+func (l *labelsFlag) Get() interface{} {
+	return l.labels
+}
+
+func (l *labelsFlag) String() string {
+	return strings.Join(l.labels, ",")
+}
+
+// addLabelFlags adds flag to set labels when creating or editing object
+func addLabelFlags(flags *flag.FlagSet) {
+	flags.Var(&labelsFlag{}, "set-label", "set label in key=value form, empty value removes the label (could be specified multiple times)")
+}
+
+// updateLabels applies labels set on command line to the current labels
+func updateLabels(current deb.Labels, flags *flag.FlagSet) (deb.Labels, error) {
+	return current.Apply(flags.Lookup("set-label").Value.Get().([]string))
+}
+
+// addLabelSelectorFlags adds flag to filter listed objects by labels
+func addLabelSelectorFlags(flags *flag.FlagSet) {
+	flags.Var(&labelsFlag{}, "with-label", "display only objects with matching labels: key=value, key!=value or key (could be specified multiple times)")
+}
+
+// labelSelectors parses label selectors set on command line
+func labelSelectors(flags *flag.FlagSet) (deb.LabelSelectors, error) {
+	return deb.ParseLabelSelectors(flags.Lookup("with-label").Value.Get().([]string))
+}
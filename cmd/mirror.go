@@ -3,6 +3,7 @@ package cmd
 import (
 	"strings"
 
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
@@ -45,6 +46,47 @@ func (k *keyRingsFlag) String() string {
 	return strings.Join(k.keyRings, ",")
 }
 
+type dependencySourcesFlag struct {
+	sources []string
+}
+
+func (d *dependencySourcesFlag) Set(value string) error {
+	d.sources = append(d.sources, value)
+	return nil
+}
+
+func (d *dependencySourcesFlag) Get() interface{} {
+	return d.sources
+}
+
+func (d *dependencySourcesFlag) String() string {
+	return strings.Join(d.sources, ",")
+}
+
+// printExternalDependencies reports dependencies of the filtered mirror resolved via dependency sources
+func printExternalDependencies(repo *deb.RemoteRepo, collectionFactory *deb.CollectionFactory) error {
+	external, unresolved, err := repo.ResolveExternalDependencies(context.DependencyOptions(), collectionFactory, context.Progress())
+	if err != nil {
+		return err
+	}
+
+	if len(external) > 0 {
+		context.Progress().Printf("Dependencies satisfied by dependency sources:\n")
+		for _, dep := range external {
+			context.Progress().Printf("  %s\n", dep)
+		}
+	}
+
+	if len(unresolved) > 0 {
+		context.Progress().Printf("Unresolved dependencies:\n")
+		for i := range unresolved {
+			context.Progress().Printf("  %s\n", unresolved[i].String())
+		}
+	}
+
+	return nil
+}
+
 func makeCmdMirror() *commander.Command {
 	return &commander.Command{
 		UsageLine: "mirror",
@@ -58,6 +100,8 @@ func makeCmdMirror() *commander.Command {
 			makeCmdMirrorRename(),
 			makeCmdMirrorEdit(),
 			makeCmdMirrorSearch(),
+			makeCmdMirrorHistory(),
+			makeCmdMirrorAudit(),
 		},
 	}
 }
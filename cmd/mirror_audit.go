@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyMirrorAudit(cmd *commander.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	repo, err := collectionFactory.RemoteRepoCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to audit: %s", err)
+	}
+
+	err = collectionFactory.RemoteRepoCollection().LoadComplete(repo)
+	if err != nil {
+		return fmt.Errorf("unable to audit: %s", err)
+	}
+
+	if repo.RefList() == nil {
+		return fmt.Errorf("unable to audit: mirror %s not updated", repo.Name)
+	}
+
+	return aptlyAudit(repo.RefList(), collectionFactory, repo.Name, repo.Codename())
+}
+
+func makeCmdMirrorAudit() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyMirrorAudit,
+		UsageLine: "audit <name>",
+		Short:     "report packages in mirror affected by known vulnerabilities",
+		Long: `
+Command audit matches packages of the mirror against locally stored security advisories
+(Debian Security Tracker JSON or OSV records) and reports vulnerable packages together
+with versions fixing the vulnerability available in other mirrors. See
+'aptly snapshot audit' for details.
+
+Example:
+
+  $ aptly mirror audit -advisories=debian-security.json -release=bookworm bookworm-main
+`,
+		Flag: *flag.NewFlagSet("aptly-mirror-audit", flag.ExitOnError),
+	}
+
+	addAuditFlags(cmd)
+
+	return cmd
+}
@@ -53,6 +53,8 @@ func aptlyMirrorCreate(cmd *commander.Command, args []string) error {
 	repo.FilterWithDeps = context.Flags().Lookup("filter-with-deps").Value.Get().(bool)
 	repo.SkipComponentCheck = context.Flags().Lookup("force-components").Value.Get().(bool)
 	repo.SkipArchitectureCheck = context.Flags().Lookup("force-architectures").Value.Get().(bool)
+	repo.Auth = context.Flags().Lookup("auth").Value.String()
+	repo.IgnoreValidUntil = context.Flags().Lookup("ignore-valid-until").Value.Get().(bool)
 
 	if repo.Filter != "" {
 		_, err = query.Parse(repo.Filter)
@@ -61,17 +63,37 @@ func aptlyMirrorCreate(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	repo.Retention, err = updateRetentionPolicy(nil, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	repo.Labels, err = updateLabels(nil, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	err = repo.SetDependencySources(context.Flags().Lookup("dependency-source").Value.Get().([]string), collectionFactory)
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
 	verifier, err := getVerifier(context.Flags())
 	if err != nil {
 		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 	}
 
-	err = repo.Fetch(gocontext.Background(), context.Downloader(), verifier, ignoreSignatures)
+	downloader, err := context.NewMirrorDownloader(context.Progress(), repo.Auth)
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	err = repo.Fetch(gocontext.Background(), downloader, verifier, ignoreSignatures)
 	if err != nil {
 		return fmt.Errorf("unable to fetch mirror: %s", err)
 	}
 
-	collectionFactory := context.NewCollectionFactory()
 	err = collectionFactory.RemoteRepoCollection().Add(repo)
 	if err != nil {
 		return fmt.Errorf("unable to add mirror: %s", err)
@@ -95,6 +117,30 @@ PPA urls could specified in short format:
 
   $ aptly mirror create <name> ppa:<user>/<project>
 
+Repositories available on local filesystem (e.g. mounted DVD or NFS share) could be
+mirrored by specifying file:// URL or plain path as archive url, package files would be
+hardlinked (or copied) into the package pool:
+
+  $ aptly mirror create <name> /media/cdrom bookworm main
+
+Repositories stored in S3 buckets could be mirrored by specifying s3://<endpoint>/<path> as
+archive url, endpoint (bucket, prefix and credentials) is configured in s3_mirror_endpoints
+section of configuration, names not configured there are used as bucket names.
+
+Repositories requiring authentication (HTTP basic auth, bearer token, private CA or client
+certificate) could be mirrored by referencing authentication profile from mirror_auth section
+of configuration with -auth flag.
+
+Filtered mirrors (with -filter-with-deps) might depend on packages from other mirrors (e.g. backports
+depending on main distribution), such mirrors or snapshots could be specified with -dependency-source flag:
+dependencies are pulled from the mirror itself when available, and dependencies satisfied by dependency
+sources are listed on mirror update. Dependencies of packages found in dependency sources are not
+followed further.
+
+Retention policy (-keep-latest, -keep-age) limits package versions kept in the mirror, it's applied
+on each mirror update before packages are downloaded. Versions referenced by published repositories
+are always kept.
+
 Example:
 
   $ aptly mirror create wheezy-main http://mirror.yandex.ru/debian/ wheezy main
@@ -102,13 +148,18 @@ Example:
 		Flag: *flag.NewFlagSet("aptly-mirror-create", flag.ExitOnError),
 	}
 
+	cmd.Flag.String("auth", "", "name of authentication profile (mirror_auth section of configuration) to access repository")
 	cmd.Flag.Bool("ignore-signatures", false, "disable verification of Release file signatures")
+	cmd.Flag.Bool("ignore-valid-until", false, "accept Release files which have expired (Valid-Until is in the past)")
 	cmd.Flag.Bool("with-appstream", false, "download AppStream (DEP-11) metadata")
 	cmd.Flag.Bool("with-installer", false, "download additional not packaged installer files")
 	cmd.Flag.Bool("with-sources", false, "download source packages in addition to binary packages")
 	cmd.Flag.Bool("with-udebs", false, "download .udeb packages (Debian installer support)")
 	AddStringOrFileFlag(&cmd.Flag, "filter", "", "filter packages in mirror, use '@file' to read filter from file or '@-' for stdin")
 	cmd.Flag.Bool("filter-with-deps", false, "when filtering, include dependencies of matching packages as well")
+	cmd.Flag.Var(&dependencySourcesFlag{}, "dependency-source", "mirror:<name> or snapshot:<name> to look up dependencies missing in filtered mirror (could be specified multiple times)")
+	addRetentionFlags(&cmd.Flag)
+	addLabelFlags(&cmd.Flag)
 	cmd.Flag.Bool("force-components", false, "(only with component list) skip check that requested components are listed in Release file")
 	cmd.Flag.Bool("force-architectures", false, "(only with architecture list) skip check that requested architectures are listed in Release file")
 	cmd.Flag.Int("max-tries", 1, "max download tries till process fails with download error")
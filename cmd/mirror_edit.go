@@ -7,6 +7,7 @@ import (
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 
+	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/query"
 )
@@ -48,11 +49,32 @@ func aptlyMirrorEdit(cmd *commander.Command, args []string) error {
 		case "archive-url":
 			repo.SetArchiveRoot(flag.Value.String())
 			fetchMirror = true
+		case "auth":
+			repo.Auth = flag.Value.String()
+			fetchMirror = true
 		case "ignore-signatures":
 			ignoreSignatures = true
+		case "ignore-valid-until":
+			repo.IgnoreValidUntil = flag.Value.Get().(bool)
+		case "dependency-source":
+			err = repo.SetDependencySources(flag.Value.Get().([]string), collectionFactory)
 		}
 	})
 
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	repo.Retention, err = updateRetentionPolicy(repo.Retention, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	repo.Labels, err = updateLabels(repo.Labels, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
 	if repo.IsFlat() && repo.DownloadUdebs {
 		return fmt.Errorf("unable to edit: flat mirrors don't support udebs")
 	}
@@ -80,7 +102,13 @@ func aptlyMirrorEdit(cmd *commander.Command, args []string) error {
 			return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 		}
 
-		err = repo.Fetch(gocontext.Background(), context.Downloader(), verifier, ignoreSignatures)
+		var downloader aptly.Downloader
+		downloader, err = context.NewMirrorDownloader(context.Progress(), repo.Auth)
+		if err != nil {
+			return fmt.Errorf("unable to edit: %s", err)
+		}
+
+		err = repo.Fetch(gocontext.Background(), downloader, verifier, ignoreSignatures)
 		if err != nil {
 			return fmt.Errorf("unable to edit: %s", err)
 		}
@@ -102,7 +130,7 @@ func makeCmdMirrorEdit() *commander.Command {
 		Short:     "edit mirror settings",
 		Long: `
 Command edit allows one to change settings of mirror:
-filters, list of architectures.
+filters, list of architectures, dependency sources, retention policy, labels.
 
 Example:
 
@@ -112,9 +140,14 @@ Example:
 	}
 
 	cmd.Flag.String("archive-url", "", "archive url is the root of archive")
+	cmd.Flag.String("auth", "", "name of authentication profile (mirror_auth section of configuration), empty to disable")
 	AddStringOrFileFlag(&cmd.Flag, "filter", "", "filter packages in mirror, use '@file' to read filter from file or '@-' for stdin")
 	cmd.Flag.Bool("filter-with-deps", false, "when filtering, include dependencies of matching packages as well")
+	cmd.Flag.Var(&dependencySourcesFlag{}, "dependency-source", "mirror:<name> or snapshot:<name> to look up dependencies missing in filtered mirror (could be specified multiple times, empty value to clear)")
+	addRetentionFlags(&cmd.Flag)
+	addLabelFlags(&cmd.Flag)
 	cmd.Flag.Bool("ignore-signatures", false, "disable verification of Release file signatures")
+	cmd.Flag.Bool("ignore-valid-until", false, "accept Release files which have expired (Valid-Until is in the past)")
 	cmd.Flag.Bool("with-appstream", false, "download AppStream (DEP-11) metadata")
 	cmd.Flag.Bool("with-installer", false, "download additional not packaged installer files")
 	cmd.Flag.Bool("with-sources", false, "download source packages in addition to binary packages")
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+
+	"github.com/aptly-dev/aptly/deb"
+)
+
+func aptlyMirrorHistory(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	jsonFlag := cmd.Flag.Lookup("json").Value.Get().(bool)
+	collectionFactory := context.NewCollectionFactory()
+
+	repo, err := collectionFactory.RemoteRepoCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to show history: %s", err)
+	}
+
+	if len(args) == 2 {
+		var id int
+		id, err = strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("unable to show history: invalid entry ID %#v", args[1])
+		}
+
+		var entry *deb.MirrorHistoryEntry
+		entry, err = collectionFactory.MirrorHistoryCollection().ByID(repo.UUID, id)
+		if err != nil {
+			return fmt.Errorf("unable to show history: %s", err)
+		}
+
+		if jsonFlag {
+			return printJSON(entry)
+		}
+
+		return printMirrorHistoryEntry(entry)
+	}
+
+	entries, err := collectionFactory.MirrorHistoryCollection().ForMirror(repo.UUID, false)
+	if err != nil {
+		return fmt.Errorf("unable to show history: %s", err)
+	}
+
+	if jsonFlag {
+		return printJSON(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("No history recorded for mirror %s, run `aptly mirror update %s` first.\n", repo.Name, repo.Name)
+		return err
+	}
+
+	fmt.Printf("History of mirror %s:\n", repo.Name)
+	for _, entry := range entries {
+		fmt.Printf(" * %s\n", entry)
+	}
+
+	fmt.Printf("\nTo see changes made by the update, run `aptly mirror history %s <id>`.\n", repo.Name)
+	return err
+}
+
+func printJSON(v interface{}) error {
+	output, err := json.MarshalIndent(v, "", "  ")
+	if err == nil {
+		fmt.Println(string(output))
+	}
+	return err
+}
+
+func printMirrorHistoryEntry(entry *deb.MirrorHistoryEntry) error {
+	fmt.Printf("Update: #%d\n", entry.ID)
+	fmt.Printf("Date: %s\n", entry.Time.Format("2006-01-02 15:04:05 MST"))
+	if entry.ReleaseDate != "" {
+		fmt.Printf("Release Date: %s\n", entry.ReleaseDate)
+	}
+	fmt.Printf("Number of packages: %d\n", entry.NumPackages)
+	fmt.Printf("Added: %d, Removed: %d, Updated: %d\n", entry.Added, entry.Removed, entry.Updated)
+
+	if len(entry.Changes) == 0 {
+		fmt.Printf("\nNo changes in package list.\n")
+		return nil
+	}
+
+	fmt.Printf("\n  Arch   | Package                                  | Version before                           | Version after\n")
+	for _, change := range entry.Changes {
+		arch, name, before, after := change.Describe()
+
+		code := "!"
+		if change.Left == nil {
+			code = "+"
+		} else if change.Right == nil {
+			code = "-"
+		}
+
+		fmt.Printf("%s %-6s | %-40s | %-40s | %-40s\n", code, arch, name, before, after)
+	}
+
+	return nil
+}
+
+func makeCmdMirrorHistory() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyMirrorHistory,
+		UsageLine: "history <name> [<id>]",
+		Short:     "show history of mirror updates",
+		Long: `
+Shows list of updates of the mirror: each successful update records date of the
+update, date of Release file and number of added, removed and updated packages.
+When <id> of the update is given, shows changes in package list made by the update.
+
+Example:
+
+  $ aptly mirror history wheezy-main
+  $ aptly mirror history wheezy-main 3
+`,
+		Flag: *flag.NewFlagSet("aptly-mirror-history", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("json", false, "display history in JSON format")
+
+	return cmd
+}
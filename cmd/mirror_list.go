@@ -25,20 +25,26 @@ func aptlyMirrorList(cmd *commander.Command, args []string) error {
 }
 
 func aptlyMirrorListTxt(cmd *commander.Command, _ []string) error {
-	var err error
-
 	raw := cmd.Flag.Lookup("raw").Value.Get().(bool)
+
+	selectors, err := labelSelectors(&cmd.Flag)
+	if err != nil {
+		return err
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 
-	repos := make([]string, collectionFactory.RemoteRepoCollection().Len())
-	i := 0
+	repos := make([]string, 0, collectionFactory.RemoteRepoCollection().Len())
 	_ = collectionFactory.RemoteRepoCollection().ForEach(func(repo *deb.RemoteRepo) error {
+		if !selectors.Matches(repo.Labels) {
+			return nil
+		}
+
 		if raw {
-			repos[i] = repo.Name
+			repos = append(repos, repo.Name)
 		} else {
-			repos[i] = repo.String()
+			repos = append(repos, repo.String())
 		}
-		i++
 		return nil
 	})
 
@@ -65,14 +71,17 @@ func aptlyMirrorListTxt(cmd *commander.Command, _ []string) error {
 	return err
 }
 
-func aptlyMirrorListJSON(_ *commander.Command, _ []string) error {
-	var err error
+func aptlyMirrorListJSON(cmd *commander.Command, _ []string) error {
+	selectors, err := labelSelectors(&cmd.Flag)
+	if err != nil {
+		return err
+	}
 
-	repos := make([]*deb.RemoteRepo, context.NewCollectionFactory().RemoteRepoCollection().Len())
-	i := 0
+	repos := make([]*deb.RemoteRepo, 0, context.NewCollectionFactory().RemoteRepoCollection().Len())
 	_ = context.NewCollectionFactory().RemoteRepoCollection().ForEach(func(repo *deb.RemoteRepo) error {
-		repos[i] = repo
-		i++
+		if selectors.Matches(repo.Labels) {
+			repos = append(repos, repo)
+		}
 		return nil
 	})
 
@@ -97,16 +106,20 @@ func makeCmdMirrorList() *commander.Command {
 		UsageLine: "list",
 		Short:     "list mirrors",
 		Long: `
-List shows full list of remote repository mirrors.
+List shows full list of remote repository mirrors, optionally narrowed
+down to mirrors with matching labels.
 
 Example:
 
   $ aptly mirror list
+
+  $ aptly mirror list -with-label team=infra
 `,
 	}
 
 	cmd.Flag.Bool("json", false, "display list in JSON format")
 	cmd.Flag.Bool("raw", false, "display list in machine-readable format")
+	addLabelSelectorFlags(&cmd.Flag)
 
 	return cmd
 }
@@ -48,6 +48,9 @@ func aptlyMirrorShowTxt(_ *commander.Command, args []string) error {
 		fmt.Printf("Status: In Update (PID %d)\n", repo.WorkerPID)
 	}
 	fmt.Printf("Archive Root URL: %s\n", repo.ArchiveRoot)
+	if repo.Auth != "" {
+		fmt.Printf("Authentication: %s\n", repo.Auth)
+	}
 	fmt.Printf("Distribution: %s\n", repo.Distribution)
 	fmt.Printf("Components: %s\n", strings.Join(repo.Components, ", "))
 	fmt.Printf("Architectures: %s\n", strings.Join(repo.Architectures, ", "))
@@ -66,6 +69,9 @@ func aptlyMirrorShowTxt(_ *commander.Command, args []string) error {
 		downloadAppStream = Yes
 	}
 	fmt.Printf("Download AppStream: %s\n", downloadAppStream)
+	if repo.IgnoreValidUntil {
+		fmt.Printf("Ignore Valid-Until: %s\n", Yes)
+	}
 	if repo.Filter != "" {
 		fmt.Printf("Filter: %s\n", repo.Filter)
 		filterWithDeps := No
@@ -74,6 +80,19 @@ func aptlyMirrorShowTxt(_ *commander.Command, args []string) error {
 		}
 		fmt.Printf("Filter With Deps: %s\n", filterWithDeps)
 	}
+	if repo.Retention != nil {
+		fmt.Printf("Retention: %s\n", repo.Retention)
+	}
+	if len(repo.Labels) > 0 {
+		fmt.Printf("Labels: %s\n", repo.Labels)
+	}
+	if len(repo.DependencySources) > 0 {
+		sources := make([]string, len(repo.DependencySources))
+		for i, source := range repo.DependencySources {
+			sources[i] = source.Describe(collectionFactory)
+		}
+		fmt.Printf("Dependency Sources: %s\n", strings.Join(sources, ", "))
+	}
 	if repo.LastDownloadDate.IsZero() {
 		fmt.Printf("Last update: never\n")
 	} else {
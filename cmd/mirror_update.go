@@ -6,12 +6,14 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/hooks"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/aptly-dev/aptly/utils"
 )
@@ -45,31 +47,42 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	err = context.Hooks().Pre(hooks.MirrorUpdate, hooks.RemoteRepoObjects(repo))
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
 	ignoreSignatures := context.Config().GpgDisableVerify
 	if context.Flags().IsSet("ignore-signatures") {
 		ignoreSignatures = context.Flags().Lookup("ignore-signatures").Value.Get().(bool)
 	}
 	ignoreChecksums := context.Flags().Lookup("ignore-checksums").Value.Get().(bool)
+	repo.ForceReleaseDate = context.Flags().Lookup("force-release-date").Value.Get().(bool)
 
 	verifier, err := getVerifier(context.Flags())
 	if err != nil {
 		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 	}
 
-	err = repo.Fetch(ctx, context.Downloader(), verifier, ignoreSignatures)
+	downloader, err := context.NewMirrorDownloader(context.Progress(), repo.Auth)
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	err = repo.Fetch(ctx, downloader, verifier, ignoreSignatures)
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
 	context.Progress().Printf("Downloading & parsing package files...\n")
-	err = repo.DownloadPackageIndexes(ctx, context.Progress(), context.Downloader(), verifier, collectionFactory, ignoreSignatures, ignoreChecksums)
+	err = repo.DownloadPackageIndexes(ctx, context.Progress(), downloader, verifier, collectionFactory, ignoreSignatures, ignoreChecksums)
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
 	if repo.DownloadAppStream && !repo.IsFlat() {
 		context.Progress().Printf("Downloading AppStream metadata...\n")
-		err = repo.DownloadAppStreamFiles(ctx, context.Progress(), context.Downloader(),
+		err = repo.DownloadAppStreamFiles(ctx, context.Progress(), downloader,
 			context.PackagePool(), collectionFactory.ChecksumCollection(nil), ignoreChecksums)
 		if err != nil {
 			return fmt.Errorf("unable to update: %s", err)
@@ -91,6 +104,24 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 			return fmt.Errorf("unable to update: %s", err)
 		}
 		context.Progress().Printf("Packages filtered: %d -> %d.\n", oldLen, newLen)
+
+		if repo.FilterWithDeps && len(repo.DependencySources) > 0 {
+			err = printExternalDependencies(repo, collectionFactory)
+			if err != nil {
+				return fmt.Errorf("unable to update: %s", err)
+			}
+		}
+	}
+
+	if repo.Retention != nil {
+		context.Progress().Printf("Applying retention policy...\n")
+
+		var removed []string
+		removed, err = repo.ApplyRetention(collectionFactory, time.Now())
+		if err != nil {
+			return fmt.Errorf("unable to update: %s", err)
+		}
+		printRetentionReport(removed)
 	}
 
 	var (
@@ -195,7 +226,7 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 					}
 
 					// download file...
-					e = context.Downloader().DownloadWithChecksum(
+					e = downloader.DownloadWithChecksum(
 						context,
 						repo.PackageURL(task.File.DownloadURL()).String(),
 						task.TempDownPath,
@@ -274,12 +305,23 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to update: download errors:\n  %s", strings.Join(errors, "\n  "))
 	}
 
-	_ = repo.FinalizeDownload(collectionFactory, context.Progress())
+	err = repo.FinalizeDownload(collectionFactory, context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+	if repo.RefList() != nil {
+		_, err = deb.ExtractLicenses(repo.RefList(), collectionFactory.PackageCollection(), context.PackagePool(), context.Progress())
+		if err != nil {
+			return fmt.Errorf("unable to update: %s", err)
+		}
+	}
 	err = collectionFactory.RemoteRepoCollection().Update(repo)
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
+	context.Hooks().Post(hooks.MirrorUpdate, hooks.RemoteRepoObjects(repo))
+
 	context.Progress().Printf("\nMirror `%s` has been updated successfully.\n", repo.Name)
 	return err
 }
@@ -294,6 +336,10 @@ Updates remote mirror (downloads package files and meta information). When mirro
 this command should be run for the first time to fetch mirror contents. This command can be
 run multiple times to get updated repository contents. If interrupted, command can be safely restarted.
 
+Release file is rejected if it has expired (see -ignore-valid-until flag of mirror create/edit) or
+if it is older than Release file fetched on previous update, which might indicate replay of stale
+metadata by the remote side.
+
 Example:
 
   $ aptly mirror update wheezy-main
@@ -302,6 +348,7 @@ Example:
 	}
 
 	cmd.Flag.Bool("force", false, "force update mirror even if it is locked by another process")
+	cmd.Flag.Bool("force-release-date", false, "accept Release file which is older than the one fetched on previous update")
 	cmd.Flag.Bool("ignore-checksums", false, "ignore checksum mismatches while downloading package files and metadata")
 	cmd.Flag.Bool("ignore-signatures", false, "disable verification of Release file signatures")
 	cmd.Flag.Bool("skip-existing-packages", false, "do not check file existence for packages listed in the internal database of the mirror")
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/smira/commander"
+)
+
+// lookupPromotionPath finds promotion path in configuration by name
+func lookupPromotionPath(name string) (utils.PromotionPath, error) {
+	path, ok := context.Config().PromotionPaths[name]
+	if !ok {
+		return path, fmt.Errorf("promotion path %s not found, paths are configured in promotion_paths section of configuration", name)
+	}
+
+	return path, nil
+}
+
+// lookupPromotionRequest finds promotion request by ID passed as argument
+func lookupPromotionRequest(collectionFactory *deb.CollectionFactory, arg string) (*deb.PromotionRequest, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid promotion request number %#v", arg)
+	}
+
+	return collectionFactory.PromotionCollection().ByID(id)
+}
+
+// promotionUser returns user name from -user flag, defaulting to current user
+func promotionUser(cmd *commander.Command) string {
+	name := cmd.Flag.Lookup("user").Value.Get().(string)
+	if name != "" {
+		return name
+	}
+
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+
+	return os.Getenv("USER")
+}
+
+// executePromotion executes promotion request if it collected required approvals, saving the outcome
+func executePromotion(collectionFactory *deb.CollectionFactory, request *deb.PromotionRequest) error {
+	if !request.Ready() {
+		fmt.Printf("Promotion request #%d is waiting for approvals (%d/%d).\n", request.ID, len(request.Approvers), request.RequiredApprovals)
+		return nil
+	}
+
+	promoteErr := request.Execute(collectionFactory, query.Parse, context.DependencyOptions(), context.ArchitecturesList(),
+		context.PackagePool(), newKeyringVerifier, context.Progress(), time.Now())
+
+	err := collectionFactory.PromotionCollection().Update(request)
+	if err != nil {
+		return fmt.Errorf("unable to save promotion request: %s", err)
+	}
+
+	if promoteErr != nil {
+		return fmt.Errorf("unable to promote: %s", promoteErr)
+	}
+
+	for _, name := range request.Packages {
+		context.Progress().ColoredPrintf("@g[o]@| %s promoted", name)
+	}
+
+	context.Progress().Printf("\nPromotion request #%d executed, %d packages promoted from %s to %s.\n", request.ID, len(request.Packages),
+		request.From, request.To)
+
+	return nil
+}
+
+func makeCmdPromotion() *commander.Command {
+	return &commander.Command{
+		UsageLine: "promotion",
+		Short:     "manage promotion of packages between local repositories",
+		Subcommands: []*commander.Command{
+			makeCmdPromotionPaths(),
+			makeCmdPromotionList(),
+			makeCmdPromotionCreate(),
+			makeCmdPromotionShow(),
+			makeCmdPromotionApprove(),
+			makeCmdPromotionReject(),
+		},
+	}
+}
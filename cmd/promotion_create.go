@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPromotionCreate(cmd *commander.Command, args []string) error {
+	if len(args) < 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	path, err := lookupPromotionPath(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to create: %s", err)
+	}
+
+	queries := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		queries[i], err = GetStringOrFileContent(arg)
+		if err != nil {
+			return fmt.Errorf("unable to read package query from file %s: %w", arg, err)
+		}
+		if _, err = query.Parse(queries[i]); err != nil {
+			return fmt.Errorf("unable to create: %s", err)
+		}
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	for _, name := range []string{path.From, path.To} {
+		if _, err = collectionFactory.LocalRepoCollection().ByName(name); err != nil {
+			return fmt.Errorf("unable to create: %s", err)
+		}
+	}
+
+	request, err := deb.NewPromotionRequest(args[0], path, queries, cmd.Flag.Lookup("with-deps").Value.Get().(bool),
+		promotionUser(cmd), cmd.Flag.Lookup("comment").Value.Get().(string), time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to create: %s", err)
+	}
+
+	err = collectionFactory.PromotionCollection().Add(request)
+	if err != nil {
+		return fmt.Errorf("unable to create: %s", err)
+	}
+
+	fmt.Printf("Promotion request #%d from %s to %s created.\n", request.ID, request.From, request.To)
+
+	return executePromotion(collectionFactory, request)
+}
+
+func makeCmdPromotionCreate() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPromotionCreate,
+		UsageLine: "create <path> <package-query> ...",
+		Short:     "request promotion of packages",
+		Long: `
+Command create requests promotion of packages matching <package-query> along
+promotion path <path>, copying (or moving) them from source local repo to
+destination local repo. Promotion is executed once the request collects
+number of approvals required by the path, paths which don't require approvals
+are promoted immediately.
+
+Use '@file' to read package queries from file or '@-' for stdin.
+
+Example:
+
+  $ aptly promotion create -comment='release 0.1.12' staging 'myapp (=0.1.12)'
+`,
+		Flag: *flag.NewFlagSet("aptly-promotion-create", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("with-deps", false, "follow dependencies when selecting packages")
+	cmd.Flag.String("user", "", "name of user requesting promotion (defaults to current user)")
+	cmd.Flag.String("comment", "", "reason of promotion")
+
+	return cmd
+}
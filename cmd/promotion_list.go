@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPromotionList(cmd *commander.Command, args []string) error {
+	if len(args) != 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	requests, err := context.NewCollectionFactory().PromotionCollection().List(cmd.Flag.Lookup("state").Value.Get().(string))
+	if err != nil {
+		return fmt.Errorf("unable to list: %s", err)
+	}
+
+	if cmd.Flag.Lookup("json").Value.Get().(bool) {
+		return printJSON(requests)
+	}
+
+	if len(requests) == 0 {
+		fmt.Printf("No promotion requests found, create one with `aptly promotion create`.\n")
+		return nil
+	}
+
+	fmt.Printf("List of promotion requests:\n")
+	for _, request := range requests {
+		fmt.Printf(" * %s\n", request)
+	}
+
+	fmt.Printf("\nTo get more information about promotion request, run `aptly promotion show <id>`.\n")
+	return nil
+}
+
+func makeCmdPromotionList() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPromotionList,
+		UsageLine: "list",
+		Short:     "list promotion requests",
+		Long: `
+List promotion requests along with their state: pending, approved, rejected,
+promoted or failed.
+
+Example:
+
+  $ aptly promotion list -state=pending
+`,
+		Flag: *flag.NewFlagSet("aptly-promotion-list", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("state", "", "list only requests in this state")
+	cmd.Flag.Bool("json", false, "display list in JSON format")
+
+	return cmd
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPromotionPaths(cmd *commander.Command, args []string) error {
+	if len(args) != 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	paths := context.Config().PromotionPaths
+	if len(paths) == 0 {
+		fmt.Printf("No promotion paths configured, add them to promotion_paths section of configuration.\n")
+		return nil
+	}
+
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("List of promotion paths:\n")
+	for _, name := range names {
+		path := paths[name]
+
+		operation := "copy"
+		if path.Move {
+			operation = "move"
+		}
+
+		fmt.Printf(" * %s: %s -> %s (%s, %d approvals required)\n", name, path.From, path.To, operation, path.Approvals)
+	}
+
+	return nil
+}
+
+func makeCmdPromotionPaths() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPromotionPaths,
+		UsageLine: "paths",
+		Short:     "list promotion paths",
+		Long: `
+List promotion paths configured in promotion_paths section of configuration.
+
+Example:
+
+  $ aptly promotion paths
+`,
+		Flag: *flag.NewFlagSet("aptly-promotion-paths", flag.ExitOnError),
+	}
+
+	return cmd
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPromotionApproveReject(cmd *commander.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	command := cmd.Name()
+	collectionFactory := context.NewCollectionFactory()
+
+	request, err := lookupPromotionRequest(collectionFactory, args[0])
+	if err != nil {
+		return fmt.Errorf("unable to %s: %s", command, err)
+	}
+
+	userName := promotionUser(cmd)
+	comment := cmd.Flag.Lookup("comment").Value.Get().(string)
+
+	var verb string
+
+	if command == "approve" { // nolint: goconst
+		verb = "approved"
+		err = request.Approve(userName, comment, time.Now())
+	} else {
+		verb = "rejected"
+		err = request.Reject(userName, comment, time.Now())
+	}
+	if err != nil {
+		return fmt.Errorf("unable to %s: %s", command, err)
+	}
+
+	err = collectionFactory.PromotionCollection().Update(request)
+	if err != nil {
+		return fmt.Errorf("unable to %s: %s", command, err)
+	}
+
+	fmt.Printf("Promotion request #%d %s by %s.\n", request.ID, verb, userName)
+
+	if command == "reject" { // nolint: goconst
+		return nil
+	}
+
+	return executePromotion(collectionFactory, request)
+}
+
+func makeCmdPromotionApprove() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPromotionApproveReject,
+		UsageLine: "approve <id>",
+		Short:     "approve promotion request",
+		Long: `
+Command approve records approval of pending promotion request <id>. Requester
+can't approve own request. Once the request collects number of approvals
+required by promotion path, packages are promoted.
+
+User name is not authenticated, approval is recorded with the name given
+by -user flag or name of current user.
+
+Example:
+
+  $ aptly promotion approve -comment=lgtm 12
+`,
+		Flag: *flag.NewFlagSet("aptly-promotion-approve", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("user", "", "name of approving user (defaults to current user)")
+	cmd.Flag.String("comment", "", "comment on approval")
+
+	return cmd
+}
+
+func makeCmdPromotionReject() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPromotionApproveReject,
+		UsageLine: "reject <id>",
+		Short:     "reject promotion request",
+		Long: `
+Command reject rejects pending promotion request <id>, so that it is never
+executed.
+
+Example:
+
+  $ aptly promotion reject -comment='breaks upgrades' 12
+`,
+		Flag: *flag.NewFlagSet("aptly-promotion-reject", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("user", "", "name of rejecting user (defaults to current user)")
+	cmd.Flag.String("comment", "", "reason of rejection")
+
+	return cmd
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPromotionShow(cmd *commander.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	request, err := lookupPromotionRequest(context.NewCollectionFactory(), args[0])
+	if err != nil {
+		return fmt.Errorf("unable to show: %s", err)
+	}
+
+	if cmd.Flag.Lookup("json").Value.Get().(bool) {
+		return printJSON(request)
+	}
+
+	return printPromotionRequest(request)
+}
+
+func printPromotionRequest(request *deb.PromotionRequest) error {
+	operation := "copy"
+	if request.Move {
+		operation = "move"
+	}
+
+	fmt.Printf("ID: %d\n", request.ID)
+	fmt.Printf("Path: %s (%s %s -> %s)\n", request.Path, operation, request.From, request.To)
+	fmt.Printf("State: %s\n", request.State)
+	fmt.Printf("Requested by: %s\n", request.RequestedBy)
+	fmt.Printf("Queries: %s\n", strings.Join(request.Queries, ", "))
+	withDeps := No
+	if request.WithDeps {
+		withDeps = Yes
+	}
+	fmt.Printf("With dependencies: %s\n", withDeps)
+	fmt.Printf("Approvals: %d/%d", len(request.Approvers), request.RequiredApprovals)
+	if len(request.Approvers) > 0 {
+		fmt.Printf(" (%s)", strings.Join(request.Approvers, ", "))
+	}
+	fmt.Printf("\n")
+
+	if len(request.Packages) > 0 {
+		fmt.Printf("Packages:\n")
+		for _, name := range request.Packages {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+
+	fmt.Printf("Audit trail:\n")
+	for _, event := range request.Events {
+		fmt.Printf("  %s: %s", event.Time.Format("2006-01-02 15:04:05 MST"), event.Action)
+		if event.User != "" {
+			fmt.Printf(" by %s", event.User)
+		}
+		if event.Comment != "" {
+			fmt.Printf(": %s", event.Comment)
+		}
+		fmt.Printf("\n")
+	}
+
+	return nil
+}
+
+func makeCmdPromotionShow() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPromotionShow,
+		UsageLine: "show <id>",
+		Short:     "show details about promotion request",
+		Long: `
+Command show displays full information about promotion request, including
+approvals, promoted packages and audit trail.
+
+Example:
+
+  $ aptly promotion show 12
+`,
+		Flag: *flag.NewFlagSet("aptly-promotion-show", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("json", false, "display record in JSON format")
+
+	return cmd
+}
@@ -71,6 +71,8 @@ func makeCmdPublish() *commander.Command {
 			makeCmdPublishSource(),
 			makeCmdPublishSwitch(),
 			makeCmdPublishUpdate(),
+			makeCmdPublishAudit(),
+			makeCmdPublishSBOM(),
 		},
 	}
 }
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPublishAudit(cmd *commander.Command, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	distribution := args[0]
+	param := "."
+
+	if len(args) == 2 {
+		param = args[1]
+	}
+
+	storage, prefix := deb.ParsePrefix(param)
+
+	collectionFactory := context.NewCollectionFactory()
+	published, err := collectionFactory.PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		return fmt.Errorf("unable to audit: %s", err)
+	}
+
+	err = collectionFactory.PublishedRepoCollection().LoadComplete(published, collectionFactory)
+	if err != nil {
+		return fmt.Errorf("unable to audit: %s", err)
+	}
+
+	refList := deb.NewPackageRefList()
+	for _, component := range published.Components() {
+		refList = refList.Merge(published.RefList(component), false, true)
+	}
+
+	return aptlyAudit(refList, collectionFactory, "", published.Distribution)
+}
+
+func makeCmdPublishAudit() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishAudit,
+		UsageLine: "audit <distribution> [[<endpoint>:]<prefix>]",
+		Short:     "report packages in published repository affected by known vulnerabilities",
+		Long: `
+Command audit matches packages of all the components of published repository against
+locally stored security advisories (Debian Security Tracker JSON or OSV records) and
+reports vulnerable packages together with versions fixing the vulnerability available
+in mirrors. See 'aptly snapshot audit' for details.
+
+Example:
+
+  $ aptly publish audit -advisories=debian-security.json -release=bookworm bookworm ppa
+`,
+		Flag: *flag.NewFlagSet("aptly-publish-audit", flag.ExitOnError),
+	}
+
+	addAuditFlags(cmd)
+
+	return cmd
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/hooks"
 	"github.com/smira/commander"
 )
 
@@ -23,6 +24,13 @@ func aptlyPublishDrop(cmd *commander.Command, args []string) error {
 
 	storage, prefix := deb.ParsePrefix(param)
 
+	objects := hooks.Objects{"Storage": storage, "Prefix": prefix, "Distribution": distribution}
+
+	err = context.Hooks().Pre(hooks.PublishDrop, objects)
+	if err != nil {
+		return fmt.Errorf("unable to remove: %s", err)
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	err = collectionFactory.PublishedRepoCollection().Remove(context, storage, prefix, distribution,
 		collectionFactory, context.Progress(),
@@ -32,6 +40,8 @@ func aptlyPublishDrop(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to remove: %s", err)
 	}
 
+	context.Hooks().Post(hooks.PublishDrop, objects)
+
 	context.Progress().Printf("\nPublished repository has been removed successfully.\n")
 
 	return err
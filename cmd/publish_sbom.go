@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyPublishSBOM(cmd *commander.Command, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	distribution := args[0]
+	param := "."
+
+	if len(args) == 2 {
+		param = args[1]
+	}
+
+	storage, prefix := deb.ParsePrefix(param)
+
+	collectionFactory := context.NewCollectionFactory()
+	published, err := collectionFactory.PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		return fmt.Errorf("unable to generate SBOM: %s", err)
+	}
+
+	err = collectionFactory.PublishedRepoCollection().LoadComplete(published, collectionFactory)
+	if err != nil {
+		return fmt.Errorf("unable to generate SBOM: %s", err)
+	}
+
+	refLists := map[string]*deb.PackageRefList{}
+	for _, component := range published.Components() {
+		refLists[component] = published.RefList(component)
+	}
+
+	return aptlySBOM(published.GetPath(), published.Distribution, refLists, collectionFactory)
+}
+
+func makeCmdPublishSBOM() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishSBOM,
+		UsageLine: "sbom <distribution> [[<endpoint>:]<prefix>]",
+		Short:     "print software bill of materials of published repository",
+		Long: `
+Command sbom prints software bill of materials (SBOM) listing packages of all the components
+of published repository. Package URLs include published distribution, component of each
+package is recorded as well. See 'aptly snapshot sbom' for details.
+
+Example:
+
+  $ aptly publish sbom -format=spdx-json wheezy ppa > wheezy.spdx.json
+`,
+		Flag: *flag.NewFlagSet("aptly-publish-sbom", flag.ExitOnError),
+	}
+
+	addSBOMFlags(cmd)
+
+	return cmd
+}
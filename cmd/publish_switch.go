@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/hooks"
 	"github.com/aptly-dev/aptly/utils"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
@@ -61,6 +62,8 @@ func aptlyPublishSwitch(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("mismatch in number of components (%d) and snapshots (%d)", len(components), len(names))
 	}
 
+	snapshots := map[string]string{}
+
 	snapshotCollection := collectionFactory.SnapshotCollection()
 	for i, component := range components {
 		if !utils.StrSliceHasItem(publishedComponents, component) {
@@ -78,6 +81,15 @@ func aptlyPublishSwitch(cmd *commander.Command, args []string) error {
 		}
 
 		published.UpdateSnapshot(component, snapshot)
+		snapshots[component] = snapshot.Name
+	}
+
+	objects := hooks.PublishedRepoObjects(published)
+	objects["Snapshots"] = snapshots
+
+	err = context.Hooks().Pre(hooks.PublishSwitch, objects)
+	if err != nil {
+		return fmt.Errorf("unable to switch: %s", err)
 	}
 
 	signer, err := getSigner(context.Flags())
@@ -129,6 +141,8 @@ func aptlyPublishSwitch(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	context.Hooks().Post(hooks.PublishSwitch, objects)
+
 	context.Progress().Printf("\nPublished %s repository %s has been successfully switched to new source.\n", published.SourceKind, published.String())
 
 	return err
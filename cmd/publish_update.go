@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/hooks"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -36,6 +37,11 @@ func aptlyPublishUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
+	err = context.Hooks().Pre(hooks.PublishUpdate, hooks.PublishedRepoObjects(published))
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
 	result, err := published.Update(collectionFactory, context.Progress())
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
@@ -100,6 +106,8 @@ func aptlyPublishUpdate(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	context.Hooks().Post(hooks.PublishUpdate, hooks.PublishedRepoObjects(published))
+
 	context.Progress().Printf("\nPublished %s repository %s has been updated successfully.\n", published.SourceKind, published.String())
 
 	return err
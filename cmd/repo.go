@@ -17,8 +17,10 @@ func makeCmdRepo() *commander.Command {
 			makeCmdRepoImport(),
 			makeCmdRepoList(),
 			makeCmdRepoMove(),
+			makeCmdRepoPrune(),
 			makeCmdRepoRemove(),
 			makeCmdRepoShow(),
+			makeCmdRepoSignPackages(),
 			makeCmdRepoRename(),
 			makeCmdRepoSearch(),
 			makeCmdRepoInclude(),
@@ -1,19 +1,52 @@
 package cmd
 
 import (
+	gocontext "context"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/hooks"
+	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/utils"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
 
+type packageURLsFlag struct {
+	urls []deb.PackageURL
+}
+
+func (u *packageURLsFlag) Set(value string) error {
+	pkgURL, err := deb.ParsePackageURL(value)
+	if err != nil {
+		return err
+	}
+
+	u.urls = append(u.urls, pkgURL)
+	return nil
+}
+
+func (u *packageURLsFlag) Get() interface{} {
+	return u.urls
+}
+
+func (u *packageURLsFlag) String() string {
+	urls := make([]string, len(u.urls))
+	for i := range u.urls {
+		urls[i] = u.urls[i].URL
+	}
+	return strings.Join(urls, ",")
+}
+
 func aptlyRepoAdd(cmd *commander.Command, args []string) error {
 	var err error
-	if len(args) < 2 {
+
+	urls := context.Flags().Lookup("url").Value.Get().([]deb.PackageURL)
+	if len(args) < 1 || (len(args) < 2 && len(urls) == 0) {
 		cmd.Usage()
 		return commander.ErrCommandError
 	}
@@ -42,22 +75,92 @@ func aptlyRepoAdd(cmd *commander.Command, args []string) error {
 
 	forceReplace := context.Flags().Lookup("force-replace").Value.Get().(bool)
 
+	validation, err := repo.Validation.Prepare(newKeyringVerifier)
+	if err != nil {
+		return fmt.Errorf("unable to add: %s", err)
+	}
+
 	var packageFiles, otherFiles, failedFiles []string
 
-	packageFiles, otherFiles, failedFiles = deb.CollectPackageFiles(args[1:], &aptly.ConsoleResultReporter{Progress: context.Progress()})
+	locations := args[1:]
+
+	if len(urls) > 0 {
+		var downloadDir string
+
+		downloadDir, err = os.MkdirTemp("", "aptly-repo-add")
+		if err != nil {
+			return fmt.Errorf("unable to create temporary directory: %s", err)
+		}
+		defer func() { _ = os.RemoveAll(downloadDir) }()
+
+		context.Progress().Printf("Downloading packages...\n")
+
+		failedFiles = deb.DownloadPackageURLs(gocontext.Background(), context.NewDownloader(context.Progress()), urls, downloadDir,
+			&aptly.ConsoleResultReporter{Progress: context.Progress()})
+		locations = append(locations, downloadDir)
+	}
+
+	var failedFiles2 []string
 
-	var processedFiles, failedFiles2 []string
+	packageFiles, otherFiles, failedFiles2 = deb.CollectPackageFiles(locations, &aptly.ConsoleResultReporter{Progress: context.Progress()})
+	failedFiles = append(failedFiles, failedFiles2...)
+
+	var signedFiles map[string]string
+
+	if cmd.Name() == "sign-packages" {
+		var (
+			signer  pgp.Signer
+			signDir string
+		)
+
+		signer, err = getSigner(context.Flags())
+		if err != nil {
+			return fmt.Errorf("unable to initialize GPG signer: %s", err)
+		}
+		if signer == nil {
+			return fmt.Errorf("unable to sign packages: signing is disabled in configuration")
+		}
+
+		signDir, err = os.MkdirTemp("", "aptly-repo-sign")
+		if err != nil {
+			return fmt.Errorf("unable to create temporary directory: %s", err)
+		}
+		defer func() { _ = os.RemoveAll(signDir) }()
+
+		packageFiles, signedFiles, err = signPackageFiles(packageFiles, signDir, signer,
+			context.Flags().Lookup("role").Value.String())
+		if err != nil {
+			return fmt.Errorf("unable to sign packages: %s", err)
+		}
+	}
+
+	err = context.Hooks().Pre(hooks.RepoAdd, hooks.Objects{"Repo": repo.Name, "Files": originalFiles(packageFiles, signedFiles)})
+	if err != nil {
+		return fmt.Errorf("unable to add: %s", err)
+	}
+
+	var processedFiles []string
+
+	oldRefList := repo.RefList()
 
 	processedFiles, failedFiles2, err = deb.ImportPackageFiles(list, packageFiles, forceReplace, verifier, context.PackagePool(),
 		collectionFactory.PackageCollection(), &aptly.ConsoleResultReporter{Progress: context.Progress()}, nil,
-		collectionFactory.ChecksumCollection)
-	failedFiles = append(failedFiles, failedFiles2...)
+		validation, collectionFactory.ChecksumCollection)
+	failedFiles = append(failedFiles, originalFiles(failedFiles2, signedFiles)...)
 	if err != nil {
 		return fmt.Errorf("unable to import package files: %s", err)
 	}
 
+	processedFiles = originalFiles(processedFiles, signedFiles)
+
 	processedFiles = append(processedFiles, otherFiles...)
 
+	removed, err := deb.ApplyRetentionPolicy(repo.Retention, repo.UUID, list, collectionFactory, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to apply retention policy: %s", err)
+	}
+	printRetentionReport(removed)
+
 	repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
 
 	err = collectionFactory.LocalRepoCollection().Update(repo)
@@ -65,6 +168,8 @@ func aptlyRepoAdd(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to save: %s", err)
 	}
 
+	context.Hooks().Post(hooks.RepoAdd, hooks.LocalRepoChanges(repo, oldRefList))
+
 	if context.Flags().Lookup("remove-files").Value.Get().(bool) {
 		processedFiles = utils.StrSliceDeduplicate(processedFiles)
 
@@ -91,7 +196,7 @@ func aptlyRepoAdd(cmd *commander.Command, args []string) error {
 func makeCmdRepoAdd() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlyRepoAdd,
-		UsageLine: "add <name> (<package file.deb>|<directory>)...",
+		UsageLine: "add <name> [(<package file.deb>|<directory>)...]",
 		Short:     "add packages to local repository",
 		Long: `
 Command adds packages to local repository from .deb, .udeb (binary packages) and .dsc (source packages) files.
@@ -100,15 +205,22 @@ patterns. Every file discovered would be analyzed to extract metadata, package w
 to the database. Files would be imported to internal package pool. For source packages, all required files are
 added automatically as well. Extra files for source package should be in the same directory as *.dsc file.
 
+Package files could be downloaded from HTTP(S) URLs with -url flag (could be specified multiple times), expected
+SHA256 checksum of the file could be specified in URL fragment: -url https://host/pkg.deb#sha256=<checksum>.
+To import source packages, specify URLs for .dsc file and all the files it references.
+
 Example:
 
   $ aptly repo add testing myapp-0.1.2.deb incoming/
+
+  $ aptly repo add -url https://ci.example.com/artifacts/myapp_0.1.3_amd64.deb testing
 `,
 		Flag: *flag.NewFlagSet("aptly-repo-add", flag.ExitOnError),
 	}
 
 	cmd.Flag.Bool("remove-files", false, "remove files that have been imported successfully into repository")
 	cmd.Flag.Bool("force-replace", false, "when adding package that conflicts with existing package, remove existing package")
+	cmd.Flag.Var(&packageURLsFlag{}, "url", "URL of package file to download and add, with optional #sha256=<checksum> (could be specified multiple times)")
 
 	return cmd
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -25,6 +26,24 @@ func aptlyRepoCreate(cmd *commander.Command, args []string) error {
 		if err != nil {
 			return err
 		}
+		if err = repo.Uploaders.Compile(query.Parse); err != nil {
+			return err
+		}
+	}
+
+	repo.Retention, err = updateRetentionPolicy(nil, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to add local repo: %s", err)
+	}
+
+	repo.Validation, err = updateValidation(nil, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to add local repo: %s", err)
+	}
+
+	repo.Labels, err = updateLabels(nil, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to add local repo: %s", err)
 	}
 
 	collectionFactory := context.NewCollectionFactory()
@@ -66,6 +85,16 @@ another local repository or imported from the mirror.
 If local package repository is created from snapshot, repo initial
 contents are copied from snapsot contents.
 
+Retention policy (-keep-latest, -keep-age) limits package versions kept in the
+repository, it's applied after packages are added and by 'aptly repo prune'.
+Versions referenced by published repositories are always kept.
+
+Validators (-validators) check packages when they're added, included or
+imported into the repository, packages failing validation are skipped.
+Validator 'signature' verifies dpkg-sig signatures embedded into binary packages
+against -validate-keyring keyrings, with -require-signature unsigned packages are
+rejected as well (see 'aptly repo sign-packages').
+
 Example:
 
   $ aptly repo create testing
@@ -79,6 +108,9 @@ Example:
 	cmd.Flag.String("distribution", "", "default distribution when publishing")
 	cmd.Flag.String("component", "main", "default component when publishing")
 	cmd.Flag.String("uploaders-file", "", "uploaders.json to be used when including .changes into this repository")
+	addRetentionFlags(&cmd.Flag)
+	addValidationFlags(&cmd.Flag)
+	addLabelFlags(&cmd.Flag)
 
 	return cmd
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/AlekSi/pointer"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -48,11 +49,29 @@ func aptlyRepoEdit(cmd *commander.Command, args []string) error {
 			if err != nil {
 				return err
 			}
+			if err = repo.Uploaders.Compile(query.Parse); err != nil {
+				return err
+			}
 		} else {
 			repo.Uploaders = nil
 		}
 	}
 
+	repo.Retention, err = updateRetentionPolicy(repo.Retention, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	repo.Validation, err = updateValidation(repo.Validation, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	repo.Labels, err = updateLabels(repo.Labels, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
 	err = collectionFactory.LocalRepoCollection().Update(repo)
 	if err != nil {
 		return fmt.Errorf("unable to edit: %s", err)
@@ -69,7 +88,7 @@ func makeCmdRepoEdit() *commander.Command {
 		Short:     "edit properties of local repository",
 		Long: `
 Command edit allows one to change metadata of local repository:
-comment, default distribution and component.
+comment, default distribution and component, retention policy, validators, labels.
 
 Example:
 
@@ -82,6 +101,9 @@ Example:
 	cmd.Flag.String("distribution", "", "default distribution when publishing")
 	cmd.Flag.String("component", "", "default component when publishing")
 	cmd.Flag.String("uploaders-file", "", "uploaders.json to be used when including .changes into this repository")
+	addRetentionFlags(&cmd.Flag)
+	addValidationFlags(&cmd.Flag)
+	addLabelFlags(&cmd.Flag)
 
 	return cmd
 }
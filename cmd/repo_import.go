@@ -14,6 +14,8 @@ func makeCmdRepoImport() *commander.Command {
 Command import looks up packages matching <package-query> in mirror <src-mirror>
 and copies them to local repo <dst-repo>.
 
+Packages failing validation configured for <dst-repo> are skipped.
+
 Example:
 
   $ aptly repo import wheezy-main testing nginx
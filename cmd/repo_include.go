@@ -51,11 +51,8 @@ func aptlyRepoInclude(cmd *commander.Command, args []string) error {
 			return err
 		}
 
-		for i := range uploaders.Rules {
-			uploaders.Rules[i].CompiledCondition, err = query.Parse(uploaders.Rules[i].Condition)
-			if err != nil {
-				return fmt.Errorf("error parsing query %s: %s", uploaders.Rules[i].Condition, err)
-			}
+		if err = uploaders.Compile(query.Parse); err != nil {
+			return err
 		}
 	}
 
@@ -64,9 +61,21 @@ func aptlyRepoInclude(cmd *commander.Command, args []string) error {
 	var changesFiles, failedFiles, failedFiles2 []string
 
 	changesFiles, failedFiles = deb.CollectChangesFiles(args, reporter)
+
+	if context.Flags().Lookup("dry-run").Value.Get().(bool) {
+		var decisions []*deb.ChangesDecision
+		decisions, err = deb.ExplainChangesFiles(changesFiles, acceptUnsigned, ignoreSignatures, verifier, repoTemplate,
+			collectionFactory.LocalRepoCollection(), collectionFactory.PackageCollection(), uploaders, query.Parse)
+		if err != nil {
+			return err
+		}
+
+		printChangesDecisions(decisions)
+		return nil
+	}
 	_, failedFiles2, err = deb.ImportChangesFiles(
-		changesFiles, reporter, acceptUnsigned, ignoreSignatures, forceReplace, noRemoveFiles, verifier, repoTemplate,
-		context.Progress(), collectionFactory.LocalRepoCollection(), collectionFactory.PackageCollection(),
+		changesFiles, reporter, acceptUnsigned, ignoreSignatures, forceReplace, noRemoveFiles, verifier, newKeyringVerifier,
+		repoTemplate, context.Progress(), collectionFactory.LocalRepoCollection(), collectionFactory.PackageCollection(),
 		context.PackagePool(), collectionFactory.ChecksumCollection,
 		uploaders, query.Parse)
 	failedFiles = append(failedFiles, failedFiles2...)
@@ -83,6 +92,27 @@ func aptlyRepoInclude(cmd *commander.Command, args []string) error {
 	return err
 }
 
+// printChangesDecisions explains uploaders decisions for .changes files
+func printChangesDecisions(decisions []*deb.ChangesDecision) {
+	for _, decision := range decisions {
+		if decision.Allowed {
+			context.Progress().ColoredPrintf("@g[+]@| %s -> %s: %s", decision.ChangesFile, decision.Repo, decision.Reason)
+		} else {
+			context.Progress().ColoredPrintf("@r[-]@| %s -> %s: %s", decision.ChangesFile, decision.Repo, decision.Reason)
+		}
+
+		if len(decision.SignatureKeys) > 0 {
+			context.Progress().Printf("    signed by: %v\n", decision.SignatureKeys)
+		}
+		if decision.CurrentVersion != "" {
+			context.Progress().Printf("    current version in repo: %s\n", decision.CurrentVersion)
+		}
+		for _, line := range decision.Trace {
+			context.Progress().Printf("    %s\n", line)
+		}
+	}
+}
+
 func makeCmdRepoInclude() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlyRepoInclude,
@@ -94,7 +124,9 @@ Command include looks for .changes files in list of arguments or specified direc
 and added into local repository. Successfully imported files are removed by default.
 
 Additionally uploads could be restricted with 'uploaders.json' file. Rules in this file control
-uploads based on GPG key ID of .changes file signature and queries on .changes file fields.
+uploads based on GPG key ID of .changes file signature and queries on .changes file fields,
+source package names, target distributions and components, and may deny downgrades. With
+-dry-run nothing is imported, instead the decision for each .changes file is explained.
 
 Example:
 
@@ -110,6 +142,7 @@ Example:
 	cmd.Flag.Bool("ignore-signatures", false, "disable verification of .changes file signature")
 	cmd.Flag.Bool("accept-unsigned", false, "accept unsigned .changes files")
 	cmd.Flag.String("uploaders-file", "", "path to uploaders.json file")
+	cmd.Flag.Bool("dry-run", false, "don't import anything, just explain whether uploads are allowed by uploaders rules")
 
 	return cmd
 }
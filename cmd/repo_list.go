@@ -25,25 +25,30 @@ func aptlyRepoList(cmd *commander.Command, args []string) error {
 }
 
 func aptlyRepoListTxt(cmd *commander.Command, _ []string) error {
-	var err error
-
 	raw := cmd.Flag.Lookup("raw").Value.Get().(bool)
 
+	selectors, err := labelSelectors(&cmd.Flag)
+	if err != nil {
+		return err
+	}
+
 	collectionFactory := context.NewCollectionFactory()
-	repos := make([]string, collectionFactory.LocalRepoCollection().Len())
-	i := 0
+	repos := make([]string, 0, collectionFactory.LocalRepoCollection().Len())
 	_ = collectionFactory.LocalRepoCollection().ForEach(func(repo *deb.LocalRepo) error {
+		if !selectors.Matches(repo.Labels) {
+			return nil
+		}
+
 		if raw {
-			repos[i] = repo.Name
+			repos = append(repos, repo.Name)
 		} else {
 			e := collectionFactory.LocalRepoCollection().LoadComplete(repo)
 			if e != nil {
 				return e
 			}
 
-			repos[i] = fmt.Sprintf(" * %s (packages: %d)", repo.String(), repo.NumPackages())
+			repos = append(repos, fmt.Sprintf(" * %s (packages: %d)", repo.String(), repo.NumPackages()))
 		}
-		i++
 		return nil
 	})
 
@@ -71,19 +76,24 @@ func aptlyRepoListTxt(cmd *commander.Command, _ []string) error {
 	return err
 }
 
-func aptlyRepoListJSON(_ *commander.Command, _ []string) error {
-	var err error
+func aptlyRepoListJSON(cmd *commander.Command, _ []string) error {
+	selectors, err := labelSelectors(&cmd.Flag)
+	if err != nil {
+		return err
+	}
 
-	repos := make([]*deb.LocalRepo, context.NewCollectionFactory().LocalRepoCollection().Len())
-	i := 0
+	repos := make([]*deb.LocalRepo, 0, context.NewCollectionFactory().LocalRepoCollection().Len())
 	_ = context.NewCollectionFactory().LocalRepoCollection().ForEach(func(repo *deb.LocalRepo) error {
+		if !selectors.Matches(repo.Labels) {
+			return nil
+		}
+
 		e := context.NewCollectionFactory().LocalRepoCollection().LoadComplete(repo)
 		if e != nil {
 			return e
 		}
 
-		repos[i] = repo
-		i++
+		repos = append(repos, repo)
 		return nil
 	})
 
@@ -107,16 +117,20 @@ func makeCmdRepoList() *commander.Command {
 		UsageLine: "list",
 		Short:     "list local repositories",
 		Long: `
-List command shows full list of local package repositories.
+List command shows full list of local package repositories, optionally
+narrowed down to repositories with matching labels.
 
 Example:
 
   $ aptly repo list
+
+  $ aptly repo list -with-label team=infra
 `,
 	}
 
 	cmd.Flag.Bool("json", false, "display list in JSON format")
 	cmd.Flag.Bool("raw", false, "display list in machine-readable format")
+	addLabelSelectorFlags(&cmd.Flag)
 
 	return cmd
 }
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"sort"
 
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/query"
@@ -85,29 +84,6 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to load packages: %s", err)
 	}
 
-	srcList.PrepareIndex()
-
-	var architecturesList []string
-
-	withDeps := context.Flags().Lookup("with-deps").Value.Get().(bool)
-
-	if withDeps {
-		dstList.PrepareIndex()
-
-		// Calculate architectures
-		if len(context.ArchitecturesList()) > 0 {
-			architecturesList = context.ArchitecturesList()
-		} else {
-			architecturesList = dstList.Architectures(false)
-		}
-
-		sort.Strings(architecturesList)
-
-		if len(architecturesList) == 0 {
-			return fmt.Errorf("unable to determine list of architectures, please specify explicitly")
-		}
-	}
-
 	queries := make([]deb.PackageQuery, len(args)-2)
 	for i := 0; i < len(args)-2; i++ {
 		value, err := GetStringOrFileContent(args[i+2])
@@ -120,14 +96,18 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 		}
 	}
 
-	toProcess, err := srcList.Filter(deb.FilterOptions{
-		Queries:           queries,
-		WithDependencies:  withDeps,
-		Source:            dstList,
-		DependencyOptions: context.DependencyOptions(),
-		Architectures:     architecturesList,
-		Progress:          context.Progress(),
-	})
+	withDeps := context.Flags().Lookup("with-deps").Value.Get().(bool)
+
+	toProcess, err := srcList.FilterForCopy(dstList, queries, withDeps, context.ArchitecturesList(),
+		context.DependencyOptions(), context.Progress())
+	if err == deb.ErrNoArchitectures {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("unable to %s: %s", command, err)
+	}
+
+	validation, err := dstRepo.Validation.Prepare(newKeyringVerifier)
 	if err != nil {
 		return fmt.Errorf("unable to %s: %s", command, err)
 	}
@@ -143,6 +123,16 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 	}
 
 	err = toProcess.ForEach(func(p *deb.Package) error {
+		if command == "import" && validation != nil { // nolint: goconst
+			errs := validation.Validate(p, deb.NewValidatedPoolFile(p, context.PackagePool()))
+			if len(errs) > 0 {
+				for _, validationErr := range errs {
+					context.Progress().ColoredPrintf("@y[!]@| @!%s failed validation: %s@|", p, validationErr)
+				}
+				return nil
+			}
+		}
+
 		err = dstList.Add(p)
 		if err != nil {
 			return err
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyRepoPrune(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	name := args[0]
+
+	collectionFactory := context.NewCollectionFactory()
+	repo, err := collectionFactory.LocalRepoCollection().ByName(name)
+	if err != nil {
+		return fmt.Errorf("unable to prune: %s", err)
+	}
+
+	if repo.Retention == nil {
+		return fmt.Errorf("unable to prune: local repo %s has no retention policy, configure it with 'aptly repo edit'", repo.Name)
+	}
+
+	err = collectionFactory.LocalRepoCollection().LoadComplete(repo)
+	if err != nil {
+		return fmt.Errorf("unable to prune: %s", err)
+	}
+
+	context.Progress().Printf("Applying retention policy: %s...\n", repo.Retention)
+
+	removed, err := repo.ApplyRetention(collectionFactory, time.Now(), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to prune: %s", err)
+	}
+
+	printRetentionReport(removed)
+
+	err = collectionFactory.LocalRepoCollection().Update(repo)
+	if err != nil {
+		return fmt.Errorf("unable to save: %s", err)
+	}
+
+	context.Progress().Printf("\nLocal repo %s pruned, %d packages removed.\n", repo, len(removed))
+	return err
+}
+
+func makeCmdRepoPrune() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyRepoPrune,
+		UsageLine: "prune <name>",
+		Short:     "remove package versions according to retention policy",
+		Long: `
+Command prune removes package versions which are not retained by retention
+policy of local repository <name>: versions which are not among latest
+versions (-keep-latest) and are older than specified age (-keep-age).
+Versions referenced by published repositories are always kept. Retention
+policy is configured with 'aptly repo create' or 'aptly repo edit'.
+
+Removed packages can be removed completely (including files) by running
+'aptly db cleanup', if not referenced by other repos or snapshots.
+
+Example:
+
+  $ aptly repo edit -keep-latest=3 ci-builds
+  $ aptly repo prune ci-builds
+`,
+		Flag: *flag.NewFlagSet("aptly-repo-prune", flag.ExitOnError),
+	}
+
+	return cmd
+}
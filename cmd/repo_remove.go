@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/hooks"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
@@ -54,21 +55,33 @@ func aptlyRepoRemove(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to remove: %s", err)
 	}
 
+	dryRun := context.Flags().Lookup("dry-run").Value.Get().(bool)
+
+	if !dryRun {
+		err = context.Hooks().Pre(hooks.RepoRemove, hooks.Objects{"Repo": repo.Name, "Packages": toRemove.Strings()})
+		if err != nil {
+			return fmt.Errorf("unable to remove: %s", err)
+		}
+	}
+
 	_ = toRemove.ForEach(func(p *deb.Package) error {
 		list.Remove(p)
 		context.Progress().ColoredPrintf("@r[-]@| %s removed", p)
 		return nil
 	})
 
-	if context.Flags().Lookup("dry-run").Value.Get().(bool) {
+	if dryRun {
 		context.Progress().Printf("\nChanges not saved, as dry run has been requested.\n")
 	} else {
+		oldRefList := repo.RefList()
 		repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
 
 		err = collectionFactory.LocalRepoCollection().Update(repo)
 		if err != nil {
 			return fmt.Errorf("unable to save: %s", err)
 		}
+
+		context.Hooks().Post(hooks.RepoRemove, hooks.LocalRepoChanges(repo, oldRefList))
 	}
 
 	return err
@@ -45,9 +45,18 @@ func aptlyRepoShowTxt(_ *commander.Command, args []string) error {
 	fmt.Printf("Comment: %s\n", repo.Comment)
 	fmt.Printf("Default Distribution: %s\n", repo.DefaultDistribution)
 	fmt.Printf("Default Component: %s\n", repo.DefaultComponent)
+	if len(repo.Labels) > 0 {
+		fmt.Printf("Labels: %s\n", repo.Labels)
+	}
 	if repo.Uploaders != nil {
 		fmt.Printf("Uploaders: %s\n", repo.Uploaders)
 	}
+	if repo.Retention != nil {
+		fmt.Printf("Retention: %s\n", repo.Retention)
+	}
+	if repo.Validation != nil {
+		fmt.Printf("Validation: %s\n", repo.Validation)
+	}
 	fmt.Printf("Number of packages: %d\n", repo.NumPackages())
 
 	withPackages := context.Flags().Lookup("with-packages").Value.Get().(bool)
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+// signPackageFiles signs copies of binary package files placed into tempDir, returning list
+// of files to import and mapping of signed copies to original files
+func signPackageFiles(packageFiles []string, tempDir string, signer pgp.Signer, role string) ([]string, map[string]string, error) {
+	result := make([]string, 0, len(packageFiles))
+	originals := map[string]string{}
+
+	for i, file := range packageFiles {
+		if !strings.HasSuffix(file, ".deb") && !strings.HasSuffix(file, ".udeb") {
+			result = append(result, file)
+			continue
+		}
+
+		dir := filepath.Join(tempDir, strconv.Itoa(i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, nil, err
+		}
+
+		signed := filepath.Join(dir, filepath.Base(file))
+		if err := utils.CopyFile(file, signed); err != nil {
+			return nil, nil, err
+		}
+
+		if err := deb.SignDeb(signed, role, signer, time.Now()); err != nil {
+			return nil, nil, err
+		}
+
+		context.Progress().Printf("Signed %s (role %s)\n", file, role)
+
+		result = append(result, signed)
+		originals[signed] = file
+	}
+
+	return result, originals, nil
+}
+
+// originalFiles returns copy of the list with signed copies replaced by original files
+func originalFiles(files []string, originals map[string]string) []string {
+	result := make([]string, len(files))
+	for i, file := range files {
+		if original, ok := originals[file]; ok {
+			file = original
+		}
+		result[i] = file
+	}
+
+	return result
+}
+
+func makeCmdRepoSignPackages() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyRepoAdd,
+		UsageLine: "sign-packages <name> [(<package file.deb>|<directory>)...]",
+		Short:     "sign binary packages and add them to local repository",
+		Long: `
+Command sign-packages works like 'aptly repo add', but binary packages (.deb, .udeb)
+are signed before being added: signature in dpkg-sig format with role specified by
+-role flag is embedded into the package. Original files are not modified, signed
+copies are imported into the package pool.
+
+Signatures could be verified when packages are added to local repository with
+'signature' validator enabled (see 'aptly repo create').
+
+Example:
+
+  $ aptly repo sign-packages -gpg-key=CI-KEY-ID testing build/
+`,
+		Flag: *flag.NewFlagSet("aptly-repo-sign-packages", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("remove-files", false, "remove files that have been imported successfully into repository")
+	cmd.Flag.Bool("force-replace", false, "when adding package that conflicts with existing package, remove existing package")
+	cmd.Flag.Var(&packageURLsFlag{}, "url", "URL of package file to download and add, with optional #sha256=<checksum> (could be specified multiple times)")
+	cmd.Flag.String("role", "builder", "role of the signature")
+	cmd.Flag.Var(&gpgKeyFlag{}, "gpg-key", "GPG key ID to use when signing packages (flag is repeatable, can be specified multiple times)")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
+	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
+	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
+	cmd.Flag.String("passphrase-file", "", "GPG passphrase-file for the key (warning: could be insecure)")
+	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
+
+	return cmd
+}
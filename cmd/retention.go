@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/flag"
+)
+
+// addRetentionFlags adds flags configuring retention policy of mirror or local repo
+func addRetentionFlags(flags *flag.FlagSet) {
+	flags.Int("keep-latest", 0, "retention policy: number of latest versions to keep per package name and architecture (0 to disable)")
+	flags.String("keep-age", "", "retention policy: keep versions first seen less than specified time ago, e.g. 12h or 30d (empty to disable)")
+}
+
+// updateRetentionPolicy applies retention policy flags set on command line to the current policy
+func updateRetentionPolicy(current *deb.RetentionPolicy, flags *flag.FlagSet) (*deb.RetentionPolicy, error) {
+	var (
+		keepLatest int
+		keepAge    string
+		changed    bool
+	)
+
+	if current != nil {
+		keepLatest, keepAge = current.KeepLatest, current.KeepAge
+	}
+
+	flags.Visit(func(flag *flag.Flag) {
+		switch flag.Name {
+		case "keep-latest":
+			keepLatest = flag.Value.Get().(int)
+			changed = true
+		case "keep-age":
+			keepAge = flag.Value.String()
+			changed = true
+		}
+	})
+
+	if !changed {
+		return current, nil
+	}
+
+	return deb.NewRetentionPolicy(keepLatest, keepAge)
+}
+
+// printRetentionReport lists packages removed by retention policy
+func printRetentionReport(removed []string) {
+	if len(removed) == 0 {
+		return
+	}
+
+	context.Progress().Printf("Retention policy removed %d packages:\n", len(removed))
+	for _, key := range removed {
+		context.Progress().Printf("  %s\n", key)
+	}
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+)
+
+// addSBOMFlags adds flags shared by snapshot and publish sbom commands
+func addSBOMFlags(cmd *commander.Command) {
+	cmd.Flag.String("format", deb.SBOMFormatSPDX, "SBOM format: "+strings.Join(deb.SBOMFormats, ", "))
+	cmd.Flag.String("purl-namespace", "debian", "vendor in package URLs (purl) of packages, e.g. debian or ubuntu")
+}
+
+// aptlySBOM prints SBOM listing packages of the components
+func aptlySBOM(name, distribution string, refLists map[string]*deb.PackageRefList, collectionFactory *deb.CollectionFactory) error {
+	format := context.Flags().Lookup("format").Value.String()
+
+	err := deb.WriteSBOM(os.Stdout, format, refLists, collectionFactory.PackageCollection(), deb.SBOMOptions{
+		Name:          name,
+		Distribution:  distribution,
+		PurlNamespace: context.Flags().Lookup("purl-namespace").Value.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to generate SBOM: %s", err)
+	}
+
+	return nil
+}
@@ -1,12 +1,14 @@
 package cmd
 
 import (
+	gocontext "context"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
@@ -34,6 +36,10 @@ func aptlyServe(cmd *commander.Command, args []string) error {
 		return err
 	}
 
+	if proxyMirror := context.Flags().Lookup("proxy").Value.String(); proxyMirror != "" {
+		return aptlyServeProxy(proxyMirror)
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	if collectionFactory.PublishedRepoCollection().Len() == 0 {
 		fmt.Printf("No published repositories, unable to serve.\n")
@@ -42,17 +48,9 @@ func aptlyServe(cmd *commander.Command, args []string) error {
 
 	listen := context.Flags().Lookup("listen").Value.String()
 
-	listenHost, listenPort, err := net.SplitHostPort(listen)
-
+	listenHost, listenPort, err := splitListen(listen)
 	if err != nil {
-		return fmt.Errorf("wrong -listen specification: %s", err)
-	}
-
-	if listenHost == "" {
-		listenHost, err = os.Hostname()
-		if err != nil {
-			listenHost = "localhost"
-		}
+		return err
 	}
 
 	fmt.Printf("Serving published repositories, recommended apt sources list:\n\n")
@@ -109,6 +107,92 @@ func aptlyServe(cmd *commander.Command, args []string) error {
 	return nil
 }
 
+// splitListen splits -listen flag value into host & port, defaulting host to hostname
+func splitListen(listen string) (listenHost, listenPort string, err error) {
+	listenHost, listenPort, err = net.SplitHostPort(listen)
+	if err != nil {
+		return "", "", fmt.Errorf("wrong -listen specification: %s", err)
+	}
+
+	if listenHost == "" {
+		listenHost, err = os.Hostname()
+		if err != nil {
+			listenHost = "localhost"
+		}
+	}
+
+	return listenHost, listenPort, nil
+}
+
+// aptlyServeProxy serves mirror in pull-through caching proxy mode
+func aptlyServeProxy(name string) error {
+	listen := context.Flags().Lookup("listen").Value.String()
+
+	listenHost, listenPort, err := splitListen(listen)
+	if err != nil {
+		return err
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	repo, err := collectionFactory.RemoteRepoCollection().ByName(name)
+	if err != nil {
+		return fmt.Errorf("unable to serve: %s", err)
+	}
+
+	err = collectionFactory.RemoteRepoCollection().LoadComplete(repo)
+	if err != nil {
+		return fmt.Errorf("unable to serve: %s", err)
+	}
+
+	err = repo.CheckLock()
+	if err != nil {
+		return fmt.Errorf("unable to serve: %s", err)
+	}
+
+	ignoreSignatures := context.Config().GpgDisableVerify
+	if context.Flags().IsSet("ignore-signatures") {
+		ignoreSignatures = context.Flags().Lookup("ignore-signatures").Value.Get().(bool)
+	}
+
+	verifier, err := getVerifier(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
+	}
+
+	downloader, err := context.NewMirrorDownloader(context.Progress(), repo.Auth)
+	if err != nil {
+		return fmt.Errorf("unable to serve: %s", err)
+	}
+
+	proxy := deb.NewMirrorProxy(repo, collectionFactory, context.PackagePool(), downloader, verifier, ignoreSignatures,
+		context.Flags().Lookup("proxy-refresh").Value.Get().(time.Duration))
+
+	context.Progress().Printf("Downloading & parsing package files...\n")
+	err = proxy.Refresh(gocontext.Background(), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to serve: %s", err)
+	}
+
+	fmt.Printf("\nServing mirror %s as caching proxy, recommended apt sources list:\n\n", repo)
+
+	source := fmt.Sprintf("http://%s:%s/ %s", listenHost, listenPort, repo.Distribution)
+	if !repo.IsFlat() {
+		source += " " + strings.Join(repo.Components, " ")
+	}
+	fmt.Printf("deb %s\n", source)
+	if repo.DownloadSources {
+		fmt.Printf("deb-src %s\n", source)
+	}
+
+	fmt.Printf("\nStarting web server at: %s (press Ctrl+C to quit)...\n", listen)
+
+	err = http.ListenAndServe(listen, proxy)
+	if err != nil {
+		return fmt.Errorf("unable to serve: %s", err)
+	}
+	return nil
+}
+
 func makeCmdServe() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlyServe,
@@ -118,14 +202,25 @@ func makeCmdServe() *commander.Command {
 Command serve starts embedded HTTP server (not suitable for real production usage) to serve
 contents of public/ subdirectory of aptly's root that contains published repositories.
 
+With -proxy=<mirror> flag, command serves mirror as pull-through caching proxy for apt clients:
+index files are passed from upstream after verification against signed Release file, package
+files are downloaded on first request, verified and imported into the package pool. Packages
+requested by clients are added to the mirror, so lazily populated mirror could be snapshotted
+and published as usual.
+
 Example:
 
   $ aptly serve -listen=:8080
+  $ aptly serve -proxy=wheezy-main
 `,
 		Flag: *flag.NewFlagSet("aptly-serve", flag.ExitOnError),
 	}
 
 	cmd.Flag.String("listen", ":8080", "host:port for HTTP listening")
+	cmd.Flag.String("proxy", "", "serve mirror with this name as pull-through caching proxy")
+	cmd.Flag.Duration("proxy-refresh", 5*time.Minute, "(only with -proxy) minimal interval between refreshes of package indexes from upstream")
+	cmd.Flag.Bool("ignore-signatures", false, "(only with -proxy) disable verification of Release file signatures")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "(only with -proxy) gpg keyring to use when verifying Release file (could be specified multiple times)")
 
 	return cmd
 }
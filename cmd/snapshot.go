@@ -17,9 +17,16 @@ func makeCmdSnapshot() *commander.Command {
 			makeCmdSnapshotDiff(),
 			makeCmdSnapshotMerge(),
 			makeCmdSnapshotDrop(),
+			makeCmdSnapshotGC(),
 			makeCmdSnapshotRename(),
+			makeCmdSnapshotEdit(),
+			makeCmdSnapshotExport(),
+			makeCmdSnapshotImport(),
 			makeCmdSnapshotSearch(),
 			makeCmdSnapshotFilter(),
+			makeCmdSnapshotAudit(),
+			makeCmdSnapshotSBOM(),
+			makeCmdSnapshotLicenses(),
 		},
 	}
 }
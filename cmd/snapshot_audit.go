@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotAudit(cmd *commander.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to audit: %s", err)
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to audit: %s", err)
+	}
+
+	return aptlyAudit(snapshot.RefList(), collectionFactory, "", deb.SnapshotDistribution(snapshot, collectionFactory))
+}
+
+func makeCmdSnapshotAudit() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotAudit,
+		UsageLine: "audit <name>",
+		Short:     "report packages in snapshot affected by known vulnerabilities",
+		Long: `
+Command audit matches packages of the snapshot against locally stored security advisories
+and reports vulnerable packages. Advisories are loaded from Debian Security Tracker JSON
+(https://security-tracker.debian.org/tracker/data/json) or from OSV records (single file,
+directory of files or .zip archive). Advisories are matched by source package name and
+version. For each vulnerability, versions of the package with the fix available in other
+mirrors are listed. No network access is required.
+
+Only advisories for the release given with flag -release (codename, like bookworm, or OSV
+ecosystem, like Debian:12) are used. By default release is derived from distribution of
+the mirrors and local repos snapshot has been created from (suffixes like -security are
+stripped), if distribution is not known, flag -release is required.
+
+Example:
+
+  $ aptly snapshot audit -advisories=debian-security.json -release=bookworm bookworm-2026-10-01
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-audit", flag.ExitOnError),
+	}
+
+	addAuditFlags(cmd)
+
+	return cmd
+}
@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/hooks"
 	"github.com/smira/commander"
+	"github.com/smira/flag"
 )
 
 func aptlySnapshotCreate(cmd *commander.Command, args []string) error {
@@ -13,6 +16,11 @@ func aptlySnapshotCreate(cmd *commander.Command, args []string) error {
 		snapshot *deb.Snapshot
 	)
 
+	asOfFlag := context.Flags().Lookup("as-of").Value.String()
+	if asOfFlag != "" && !(len(args) == 4 && args[1] == "from" && args[2] == "mirror") { // nolint: goconst
+		return fmt.Errorf("unable to create snapshot: -as-of is supported only for snapshots of mirrors")
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	if len(args) == 4 && args[1] == "from" && args[2] == "mirror" { // nolint: goconst
 		// aptly snapshot create snap from mirror mirror
@@ -35,7 +43,18 @@ func aptlySnapshotCreate(cmd *commander.Command, args []string) error {
 			return fmt.Errorf("unable to create snapshot: %s", err)
 		}
 
-		snapshot, err = deb.NewSnapshotFromRepository(snapshotName, repo)
+		if asOfFlag != "" {
+			var asOf time.Time
+
+			asOf, err = deb.ParseAsOf(asOfFlag)
+			if err != nil {
+				return fmt.Errorf("unable to create snapshot: %s", err)
+			}
+
+			snapshot, err = deb.NewSnapshotFromRepositoryAsOf(snapshotName, repo, asOf, collectionFactory, context.PackagePool())
+		} else {
+			snapshot, err = deb.NewSnapshotFromRepository(snapshotName, repo)
+		}
 		if err != nil {
 			return fmt.Errorf("unable to create snapshot: %s", err)
 		}
@@ -71,11 +90,23 @@ func aptlySnapshotCreate(cmd *commander.Command, args []string) error {
 		return commander.ErrCommandError
 	}
 
+	snapshot.Labels, err = updateLabels(nil, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to create snapshot: %s", err)
+	}
+
+	err = context.Hooks().Pre(hooks.SnapshotCreate, hooks.SnapshotObjects(snapshot))
+	if err != nil {
+		return fmt.Errorf("unable to create snapshot: %s", err)
+	}
+
 	err = collectionFactory.SnapshotCollection().Add(snapshot)
 	if err != nil {
 		return fmt.Errorf("unable to add snapshot: %s", err)
 	}
 
+	context.Hooks().Post(hooks.SnapshotCreate, hooks.SnapshotObjects(snapshot))
+
 	fmt.Printf("\nSnapshot %s successfully created.\nYou can run 'aptly publish snapshot %s' to publish snapshot as Debian repository.\n", snapshot.Name, snapshot.Name)
 
 	return err
@@ -99,12 +130,25 @@ Command create <name> empty creates empty snapshot that could be used as a
 basis for snapshot pull operations, for example. As snapshots are immutable,
 creating one empty snapshot should be enough.
 
+Flag -as-of creates snapshot of mirror contents as they were at the specified
+moment, reconstructed from mirror update history. Packages removed from the
+mirror since then should be still present in the package pool, see
+mirror_history_retention configuration option.
+
 Example:
 
   $ aptly snapshot create wheezy-main-today from mirror wheezy-main
+
+  $ aptly snapshot create -as-of 2026-09-01T00:00Z wheezy-main-september from mirror wheezy-main
+
+  $ aptly snapshot create -set-label team=infra -set-label release=2026.10 infra-2026.10 from repo infra
 `,
+		Flag: *flag.NewFlagSet("aptly-snapshot-create", flag.ExitOnError),
 	}
 
+	cmd.Flag.String("as-of", "", "create snapshot of mirror as it was at the specified time (RFC3339 time or date)")
+	addLabelFlags(&cmd.Flag)
+
 	return cmd
 
 }
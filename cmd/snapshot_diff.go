@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -15,36 +18,41 @@ func aptlySnapshotDiff(cmd *commander.Command, args []string) error {
 	}
 
 	onlyMatching := context.Flags().Lookup("only-matching").Value.Get().(bool)
+	format := context.Flags().Lookup("format").Value.String()
 	collectionFactory := context.NewCollectionFactory()
 
-	// Load <name-a> snapshot
-	snapshotA, err := collectionFactory.SnapshotCollection().ByName(args[0])
+	// Load <name-a> package list
+	descriptionA, refListA, err := deb.ResolvePackageRefs(args[0], collectionFactory)
 	if err != nil {
 		return fmt.Errorf("unable to load snapshot A: %s", err)
 	}
 
-	err = collectionFactory.SnapshotCollection().LoadComplete(snapshotA)
-	if err != nil {
-		return fmt.Errorf("unable to load snapshot A: %s", err)
-	}
-
-	// Load <name-b> snapshot
-	snapshotB, err := collectionFactory.SnapshotCollection().ByName(args[1])
-	if err != nil {
-		return fmt.Errorf("unable to load snapshot B: %s", err)
-	}
-
-	err = collectionFactory.SnapshotCollection().LoadComplete(snapshotB)
+	// Load <name-b> package list
+	descriptionB, refListB, err := deb.ResolvePackageRefs(args[1], collectionFactory)
 	if err != nil {
 		return fmt.Errorf("unable to load snapshot B: %s", err)
 	}
 
 	// Calculate diff
-	diff, err := snapshotA.RefList().Diff(snapshotB.RefList(), collectionFactory.PackageCollection())
+	diff, err := refListA.Diff(refListB, collectionFactory.PackageCollection())
 	if err != nil {
 		return fmt.Errorf("unable to calculate diff: %s", err)
 	}
 
+	if format != "table" { // nolint: goconst
+		if onlyMatching {
+			matching := deb.PackageDiffs{}
+			for _, pdiff := range diff {
+				if pdiff.Left != nil && pdiff.Right != nil {
+					matching = append(matching, pdiff)
+				}
+			}
+			diff = matching
+		}
+
+		return aptlySnapshotDiffSemantic(deb.NewSemanticDiff(descriptionA, descriptionB, diff), format)
+	}
+
 	if len(diff) == 0 {
 		context.Progress().Printf("Snapshots are identical.\n")
 	} else {
@@ -89,6 +97,34 @@ func aptlySnapshotDiff(cmd *commander.Command, args []string) error {
 	return err
 }
 
+// aptlySnapshotDiffSemantic displays diff with changes classified in one of the export formats
+func aptlySnapshotDiffSemantic(diff *deb.SemanticDiff, format string) error {
+	withChangelogs := context.Flags().Lookup("changelogs").Value.Get().(bool)
+
+	switch format {
+	case "json":
+		if withChangelogs {
+			diff.LoadChangelogs(context.PackagePool(), context.Progress())
+		}
+
+		output, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to marshal diff: %s", err)
+		}
+		fmt.Println(string(output))
+
+		return nil
+	case "csv":
+		return diff.WriteCSV(os.Stdout)
+	case "changelog":
+		diff.LoadChangelogs(context.PackagePool(), context.Progress())
+
+		return diff.WriteChangelog(os.Stdout)
+	}
+
+	return fmt.Errorf("unknown diff format %#v, expected table, json, csv or changelog", format)
+}
+
 func makeCmdSnapshotDiff() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlySnapshotDiff,
@@ -100,14 +136,27 @@ of packages, so difference between snapshots is a difference between package
 lists. Package could be either completely missing in one snapshot, or package
 is present in both snapshots with different versions.
 
+Either side could also be a mirror, local repo or published repository,
+specified as mirror:<name>, repo:<name> or publish:[<endpoint>:]<prefix>/<distribution>
+(snapshot:<name> or plain <name> refers to snapshot).
+
+With -format=json, csv or changelog each change is classified as added, removed,
+upgraded, downgraded or rebuilt (same version, different contents), and changes
+are grouped by source package. Changelog format includes changelog entries
+covered by the change, read from changelog.Debian.gz of packages in the pool.
+
 Example:
 
     $ aptly snapshot diff -only-matching wheezy-main wheezy-backports
+
+    $ aptly snapshot diff -format=changelog fleet-2024-05 publish:s3:fleet:./stable
 `,
 		Flag: *flag.NewFlagSet("aptly-snapshot-diff", flag.ExitOnError),
 	}
 
 	cmd.Flag.Bool("only-matching", false, "display diff only for matching packages (don't display missing packages)")
+	cmd.Flag.String("format", "table", "output format: table, json, csv or changelog")
+	cmd.Flag.Bool("changelogs", false, "include changelog entries in JSON output")
 
 	return cmd
 }
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotEdit(cmd *commander.Command, args []string) error {
+	var (
+		err      error
+		snapshot *deb.Snapshot
+	)
+
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	snapshot, err = collectionFactory.SnapshotCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	snapshot.Labels, err = updateLabels(snapshot.Labels, context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	context.Flags().Visit(func(flag *flag.Flag) {
+		if flag.Name == "description" {
+			snapshot.Description = flag.Value.String()
+		}
+	})
+
+	err = collectionFactory.SnapshotCollection().Update(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	fmt.Printf("Snapshot %s successfully updated.\n", snapshot.Name)
+	return err
+}
+
+func makeCmdSnapshotEdit() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotEdit,
+		UsageLine: "edit <name>",
+		Short:     "edit snapshot metadata",
+		Long: `
+Command edit allows one to change metadata of the snapshot: description and
+labels. Snapshot contents can't be changed, as snapshots are immutable.
+
+Example:
+
+  $ aptly snapshot edit -set-label approved=true -set-label candidate= infra-2026.10
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-edit", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("description", "", "description of the snapshot")
+	addLabelFlags(&cmd.Flag)
+
+	return cmd
+}
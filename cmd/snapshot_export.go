@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotExport(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	name, bundlePath := args[0], args[1]
+
+	collectionFactory := context.NewCollectionFactory()
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(name)
+	if err != nil {
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	signer, err := getSigner(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG signer: %s", err)
+	}
+
+	bundle, err := os.Create(bundlePath)
+	if err != nil {
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	err = deb.ExportSnapshotBundle(bundle, snapshot, collectionFactory, context.PackagePool(), signer, context.Progress())
+	if err1 := bundle.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		_ = os.Remove(bundlePath)
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	context.Progress().Printf("\nSnapshot %s has been exported to %s.\n", snapshot.Name, bundlePath)
+
+	return err
+}
+
+func makeCmdSnapshotExport() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotExport,
+		UsageLine: "export <name> <bundle>",
+		Short:     "export snapshot with packages to a bundle",
+		Long: `
+Command export writes snapshot together with package metadata and
+package files to a bundle file, which could be imported into another aptly
+instance with 'aptly snapshot import'. Bundle manifest is signed with GPG
+unless -skip-signing is specified.
+
+Example:
+
+    $ aptly snapshot export wheezy-main wheezy-main.bundle
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-export", flag.ExitOnError),
+	}
+
+	cmd.Flag.Var(&gpgKeyFlag{}, "gpg-key", "GPG key ID to use when signing the bundle (flag is repeatable, can be specified multiple times)")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "GPG keyring to use (instead of default)")
+	cmd.Flag.String("secret-keyring", "", "GPG secret keyring to use (instead of default)")
+	cmd.Flag.String("passphrase", "", "GPG passphrase for the key (warning: could be insecure)")
+	cmd.Flag.String("passphrase-file", "", "GPG passphrase-file for the key (warning: could be insecure)")
+	cmd.Flag.Bool("batch", false, "run GPG with detached tty")
+	cmd.Flag.Bool("skip-signing", false, "don't sign bundle manifest with GPG")
+
+	return cmd
+}
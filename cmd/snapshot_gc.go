@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotGC(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	dryRun := context.Flags().Lookup("dry-run").Value.Get().(bool)
+	cleanup := context.Flags().Lookup("cleanup").Value.Get().(bool)
+
+	rules := context.Config().SnapshotRetention
+	if len(rules) == 0 {
+		return fmt.Errorf("unable to collect garbage: no snapshot retention rules configured")
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	decisions, err := deb.PlanSnapshotGC(rules, collectionFactory, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to collect garbage: %s", err)
+	}
+
+	dropped := 0
+	for _, decision := range decisions {
+		if decision.Drop {
+			dropped++
+		}
+	}
+
+	if len(decisions) > dropped {
+		context.Progress().Printf("Snapshots to keep:\n")
+		for _, decision := range decisions {
+			if !decision.Drop {
+				context.Progress().Printf(" * %s: %s\n", decision.Name, decision.Reason)
+			}
+		}
+	}
+
+	if dropped > 0 {
+		context.Progress().Printf("Snapshots to drop:\n")
+		for _, decision := range decisions {
+			if decision.Drop {
+				context.Progress().Printf(" * %s: %s\n", decision.Name, decision.Reason)
+			}
+		}
+	}
+
+	if dryRun {
+		context.Progress().Printf("\nNot dropping any snapshots as requested.\n")
+		return nil
+	}
+
+	err = deb.ApplySnapshotGC(decisions, collectionFactory.SnapshotCollection())
+	if err != nil {
+		return fmt.Errorf("unable to collect garbage: %s", err)
+	}
+
+	context.Progress().Printf("\nSnapshots dropped: %d.\n", dropped)
+
+	if dropped == 0 {
+		return nil
+	}
+
+	if cleanup {
+		return aptlyDBCleanup(cmd, nil)
+	}
+
+	context.Progress().Printf("You can run 'aptly db cleanup' to remove packages and files not referenced anymore.\n")
+
+	return nil
+}
+
+func makeCmdSnapshotGC() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotGC,
+		UsageLine: "gc",
+		Short:     "drop snapshots according to retention rules",
+		Long: `
+Command gc evaluates snapshot retention rules from the configuration file
+and drops snapshots which are not kept by any of the rules. Snapshots
+not matching any of the rules are never dropped. Snapshots which are published
+or used as source of other snapshots are kept as well.
+
+Example:
+
+  $ aptly snapshot gc -dry-run
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-gc", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("dry-run", false, "don't drop anything, just show what would be dropped")
+	cmd.Flag.Bool("cleanup", false, "run db cleanup after dropping snapshots")
+	cmd.Flag.Bool("verbose", false, "be verbose when running db cleanup")
+
+	return cmd
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotImport(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	bundlePath, name := args[0], ""
+	if len(args) == 2 {
+		name = args[1]
+	}
+
+	ignoreSignatures := context.Config().GpgDisableVerify
+	if context.Flags().IsSet("ignore-signatures") {
+		ignoreSignatures = context.Flags().Lookup("ignore-signatures").Value.Get().(bool)
+	}
+
+	var verifier pgp.Verifier
+	if !ignoreSignatures {
+		verifier, err = getVerifier(context.Flags())
+		if err != nil {
+			return fmt.Errorf("unable to initialize GPG verifier: %s", err)
+		}
+	}
+
+	bundle, err := os.Open(bundlePath)
+	if err != nil {
+		return fmt.Errorf("unable to import: %s", err)
+	}
+	defer func() { _ = bundle.Close() }()
+
+	collectionFactory := context.NewCollectionFactory()
+	snapshot, err := deb.ImportSnapshotBundle(bundle, name, collectionFactory, context.PackagePool(), verifier, context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to import: %s", err)
+	}
+
+	context.Progress().Printf("\nSnapshot %s successfully imported.\nYou can run 'aptly publish snapshot %s' to publish snapshot as Debian repository.\n", snapshot.Name, snapshot.Name)
+
+	return err
+}
+
+func makeCmdSnapshotImport() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotImport,
+		UsageLine: "import <bundle> [<name>]",
+		Short:     "import snapshot from a bundle",
+		Long: `
+Command import creates snapshot from the bundle produced by 'aptly snapshot export'.
+Checksums of all the bundle members and signature of the bundle manifest are verified,
+package files already present in the package pool are not imported again. Snapshot
+name is taken from the bundle unless <name> is specified.
+
+Example:
+
+    $ aptly snapshot import wheezy-main.bundle
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-import", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("ignore-signatures", false, "disable verification of bundle signature")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying bundle signature (could be specified multiple times)")
+
+	return cmd
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotLicenses(cmd *commander.Command, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	jsonFormat := context.Flags().Lookup("json").Value.Get().(bool)
+
+	var q deb.PackageQuery
+	if len(args) == 2 {
+		value, err := GetStringOrFileContent(args[1])
+		if err != nil {
+			return fmt.Errorf("unable to read package query from file %s: %w", args[1], err)
+		}
+
+		q, err = query.Parse(value)
+		if err != nil {
+			return fmt.Errorf("unable to list licenses: %s", err)
+		}
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to list licenses: %s", err)
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to list licenses: %s", err)
+	}
+
+	var progress aptly.Progress
+	if !jsonFormat {
+		progress = context.Progress()
+	}
+
+	// packages imported by older versions of aptly don't have licenses extracted yet
+	_, err = deb.ExtractLicenses(snapshot.RefList(), collectionFactory.PackageCollection(), context.PackagePool(), progress)
+	if err != nil {
+		return fmt.Errorf("unable to list licenses: %s", err)
+	}
+
+	list, err := deb.NewPackageListFromRefList(snapshot.RefList(), collectionFactory.PackageCollection(), progress)
+	if err != nil {
+		return fmt.Errorf("unable to list licenses: %s", err)
+	}
+
+	if q != nil {
+		list.PrepareIndex()
+		list, err = list.Filter(deb.FilterOptions{Queries: []deb.PackageQuery{q}})
+		if err != nil {
+			return fmt.Errorf("unable to list licenses: %s", err)
+		}
+	}
+
+	inventory := deb.LicenseInventory(list)
+
+	if jsonFormat {
+		return printJSON(inventory)
+	}
+
+	for _, entry := range inventory {
+		fmt.Printf("%s: %s\n", entry.Package, entry.Licenses)
+	}
+
+	return nil
+}
+
+func makeCmdSnapshotLicenses() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotLicenses,
+		UsageLine: "licenses <name> [<package-query>]",
+		Short:     "list licenses of packages in snapshot",
+		Long: `
+Command licenses lists licenses of binary packages in the snapshot. Licenses are
+extracted from copyright files (/usr/share/doc/<package>/copyright) when packages are
+imported, only copyright files in machine-readable format (DEP-5) declare licenses.
+
+Licenses could be used in package queries with field $License, so the list could be
+limited to packages matching the query.
+
+Example:
+
+  $ aptly snapshot licenses wheezy-main '$License (% GPL-3*)'
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-licenses", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("json", false, "display licenses in JSON format")
+
+	return cmd
+}
@@ -24,30 +24,43 @@ func aptlySnapshotList(cmd *commander.Command, args []string) error {
 }
 
 func aptlySnapshotListTxt(cmd *commander.Command, _ []string) error {
-	var err error
-
 	raw := cmd.Flag.Lookup("raw").Value.Get().(bool)
 	sortMethodString := cmd.Flag.Lookup("sort").Value.Get().(string)
 
+	selectors, err := labelSelectors(&cmd.Flag)
+	if err != nil {
+		return err
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.SnapshotCollection()
 
 	if raw {
 		_ = collection.ForEachSorted(sortMethodString, func(snapshot *deb.Snapshot) error {
-			fmt.Printf("%s\n", snapshot.Name)
+			if selectors.Matches(snapshot.Labels) {
+				fmt.Printf("%s\n", snapshot.Name)
+			}
 			return nil
 		})
 	} else {
-		if collection.Len() > 0 {
+		snapshots := []*deb.Snapshot{}
+
+		err = collection.ForEachSorted(sortMethodString, func(snapshot *deb.Snapshot) error {
+			if selectors.Matches(snapshot.Labels) {
+				snapshots = append(snapshots, snapshot)
+			}
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if len(snapshots) > 0 {
 			fmt.Printf("List of snapshots:\n")
 
-			err = collection.ForEachSorted(sortMethodString, func(snapshot *deb.Snapshot) error {
+			for _, snapshot := range snapshots {
 				fmt.Printf(" * %s\n", snapshot.String())
-				return nil
-			})
-
-			if err != nil {
-				return err
 			}
 
 			fmt.Printf("\nTo get more information about snapshot, run `aptly snapshot show <name>`.\n")
@@ -60,17 +73,20 @@ func aptlySnapshotListTxt(cmd *commander.Command, _ []string) error {
 }
 
 func aptlySnapshotListJSON(cmd *commander.Command, _ []string) error {
-	var err error
-
 	sortMethodString := cmd.Flag.Lookup("sort").Value.Get().(string)
 
+	selectors, err := labelSelectors(&cmd.Flag)
+	if err != nil {
+		return err
+	}
+
 	collection := context.NewCollectionFactory().SnapshotCollection()
 
-	jsonSnapshots := make([]*deb.Snapshot, collection.Len())
-	i := 0
+	jsonSnapshots := make([]*deb.Snapshot, 0, collection.Len())
 	_ = collection.ForEachSorted(sortMethodString, func(snapshot *deb.Snapshot) error {
-		jsonSnapshots[i] = snapshot
-		i++
+		if selectors.Matches(snapshot.Labels) {
+			jsonSnapshots = append(jsonSnapshots, snapshot)
+		}
 		return nil
 	})
 	if output, e := json.MarshalIndent(jsonSnapshots, "", "  "); e == nil {
@@ -88,17 +104,22 @@ func makeCmdSnapshotList() *commander.Command {
 		UsageLine: "list",
 		Short:     "list snapshots",
 		Long: `
-Command list shows full list of snapshots created.
+Command list shows full list of snapshots created. List could be narrowed
+down to snapshots with matching labels, and sorted by value of the label
+with -sort=label:<key>.
 
 Example:
 
   $ aptly snapshot list
+
+  $ aptly snapshot list -with-label team=infra -sort=label:release
 `,
 	}
 
 	cmd.Flag.Bool("json", false, "display list in JSON format")
 	cmd.Flag.Bool("raw", false, "display list in machine-readable format")
-	cmd.Flag.String("sort", "name", "display list in 'name' or creation 'time' order")
+	cmd.Flag.String("sort", "name", "display list in 'name', creation 'time' or 'label:<key>' value order")
+	addLabelSelectorFlags(&cmd.Flag)
 
 	return cmd
 }
@@ -2,12 +2,114 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
 	"github.com/smira/commander"
 )
 
+// mergeRulesFlag collects values of repeatable merge rule flags
+type mergeRulesFlag struct {
+	rules []string
+}
+
+func (m *mergeRulesFlag) Set(value string) error {
+	m.rules = append(m.rules, value)
+	return nil
+}
+
+func (m *mergeRulesFlag) Get() interface{} {
+	return m.rules
+}
+
+func (m *mergeRulesFlag) String() string {
+	return strings.Join(m.rules, ",")
+}
+
+// parseMergeRule parses rule in <snapshot>:<query> form, * matches all snapshots
+func parseMergeRule(spec string) (*deb.MergeRule, error) {
+	source, queryString, found := strings.Cut(spec, ":")
+	if !found || source == "" {
+		return nil, fmt.Errorf("wrong rule %#v, expected <snapshot>:<query>", spec)
+	}
+
+	if source == "*" {
+		source = ""
+	}
+
+	q, err := query.Parse(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse query %#v: %s", queryString, err)
+	}
+
+	return &deb.MergeRule{Source: source, QueryString: queryString, Query: q}, nil
+}
+
+// parseMergeRules builds merge rules from command line flags
+func parseMergeRules() (*deb.MergeRules, error) {
+	rules := &deb.MergeRules{Priorities: map[string]int{}}
+
+	for _, spec := range context.Flags().Lookup("priority").Value.Get().([]string) {
+		source, value, found := strings.Cut(spec, ":")
+		priority, err := strconv.Atoi(value)
+		if !found || err != nil {
+			return nil, fmt.Errorf("wrong priority %#v, expected <snapshot>:<priority>", spec)
+		}
+
+		rules.Priorities[source] = priority
+	}
+
+	for _, spec := range context.Flags().Lookup("pin").Value.Get().([]string) {
+		rule, err := parseMergeRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules.Pins = append(rules.Pins, rule)
+	}
+
+	for _, spec := range context.Flags().Lookup("exclude").Value.Get().([]string) {
+		rule, err := parseMergeRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules.Excludes = append(rules.Excludes, rule)
+	}
+
+	return rules, nil
+}
+
+// printMergeReport displays excluded packages and explains resolution of conflicts
+func printMergeReport(report *deb.MergeReport) {
+	fmt.Printf("\nExcluded packages:\n")
+	if len(report.Excluded) == 0 {
+		fmt.Printf("  none\n")
+	}
+	for _, excluded := range report.Excluded {
+		fmt.Printf("  %s %s [%s] from %s: excluded by %s\n", excluded.Name, excluded.Version, excluded.Architecture,
+			excluded.Source, excluded.Rule)
+	}
+
+	fmt.Printf("\nResolved conflicts:\n")
+	if len(report.Conflicts) == 0 {
+		fmt.Printf("  none\n")
+	}
+	for _, conflict := range report.Conflicts {
+		kept := make([]string, len(conflict.Kept))
+		for i, candidate := range conflict.Kept {
+			kept[i] = candidate.String()
+		}
+		dropped := make([]string, len(conflict.Dropped))
+		for i, candidate := range conflict.Dropped {
+			dropped[i] = candidate.String()
+		}
+
+		fmt.Printf("  %s [%s]: %s wins over %s (%s)\n", conflict.Name, conflict.Architecture,
+			strings.Join(kept, ", "), strings.Join(dropped, ", "), conflict.Reason)
+	}
+}
+
 func aptlySnapshotMerge(cmd *commander.Command, args []string) error {
 	var err error
 	if len(args) < 2 {
@@ -39,13 +141,31 @@ func aptlySnapshotMerge(cmd *commander.Command, args []string) error {
 
 	overrideMatching := !latest && !noRemove
 
-	result := sources[0].RefList()
-	for i := 1; i < len(sources); i++ {
-		result = result.Merge(sources[i].RefList(), overrideMatching, false)
+	rules, err := parseMergeRules()
+	if err != nil {
+		return fmt.Errorf("unable to merge snapshots: %s", err)
 	}
+	withReport := context.Flags().Lookup("report").Value.Get().(bool)
+
+	var (
+		result *deb.PackageRefList
+		report *deb.MergeReport
+	)
 
-	if latest {
-		result.FilterLatestRefs()
+	if rules.Empty() && !withReport {
+		result = sources[0].RefList()
+		for i := 1; i < len(sources); i++ {
+			result = result.Merge(sources[i].RefList(), overrideMatching, false)
+		}
+
+		if latest {
+			result.FilterLatestRefs()
+		}
+	} else {
+		result, report, err = deb.MergeSnapshotsWithRules(sources, rules, latest, noRemove, collectionFactory.PackageCollection())
+		if err != nil {
+			return fmt.Errorf("unable to merge snapshots: %s", err)
+		}
 	}
 
 	sourceDescription := make([]string, len(sources))
@@ -62,6 +182,10 @@ func aptlySnapshotMerge(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to create snapshot: %s", err)
 	}
 
+	if withReport {
+		printMergeReport(report)
+	}
+
 	fmt.Printf("\nSnapshot %s successfully created.\nYou can run 'aptly publish snapshot %s' to publish snapshot as Debian repository.\n", destination.Name, destination.Name)
 
 	return err
@@ -79,14 +203,30 @@ name-architecture pair are replaced during merge (package from latest snapshot
 on the list wins).  If run with only one source snapshot, merge copies <source> into
 <destination>.
 
+Conflicts between sources could be resolved with rules similar to apt pinning.
+Each source has a priority (500 by default) set with -priority=<snapshot>:<priority>,
+when packages with the same name-architecture pair come from several sources,
+packages from sources with the highest priority win. Flag -pin=<snapshot>:<query>
+prefers packages matching query in the source snapshot over priorities, while
+-exclude=<snapshot>:<query> removes packages matching query from the source
+snapshot before merge. Use * instead of snapshot name to match all the sources.
+Flag -report explains which source won each conflict.
+
 Example:
 
     $ aptly snapshot merge wheezy-w-backports wheezy-main wheezy-backports
+
+    $ aptly snapshot merge -priority=wheezy-main:900 -pin='wheezy-backports:nginx'
+      -exclude='*:Priority (extra)' -report wheezy-w-nginx wheezy-main wheezy-backports
 `,
 	}
 
 	cmd.Flag.Bool("latest", false, "use only the latest version of each package")
 	cmd.Flag.Bool("no-remove", false, "don't remove duplicate arch/name packages")
+	cmd.Flag.Var(&mergeRulesFlag{}, "priority", "priority of the source snapshot: <snapshot>:<priority> (could be specified multiple times)")
+	cmd.Flag.Var(&mergeRulesFlag{}, "pin", "prefer packages matching query in the source snapshot: <snapshot>:<query> (could be specified multiple times)")
+	cmd.Flag.Var(&mergeRulesFlag{}, "exclude", "exclude packages matching query from the source snapshot: <snapshot>:<query> (could be specified multiple times)")
+	cmd.Flag.Bool("report", false, "explain which source won each conflict")
 
 	return cmd
 }
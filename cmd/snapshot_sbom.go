@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotSBOM(cmd *commander.Command, args []string) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to generate SBOM: %s", err)
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to generate SBOM: %s", err)
+	}
+
+	return aptlySBOM(snapshot.Name, "", map[string]*deb.PackageRefList{"": snapshot.RefList()}, collectionFactory)
+}
+
+func makeCmdSnapshotSBOM() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotSBOM,
+		UsageLine: "sbom <name>",
+		Short:     "print software bill of materials of snapshot",
+		Long: `
+Command sbom prints software bill of materials (SBOM) listing every package of the snapshot
+with name, version, architecture, source package, maintainer, checksums of package file
+and package URL (purl). Supported formats are SPDX 2.3 (spdx-json) and CycloneDX 1.5
+(cyclonedx-json).
+
+Example:
+
+  $ aptly snapshot sbom -format=cyclonedx-json wheezy-main > wheezy-main.cdx.json
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-sbom", flag.ExitOnError),
+	}
+
+	addSBOMFlags(cmd)
+
+	return cmd
+}
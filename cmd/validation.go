@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/smira/flag"
+)
+
+// addValidationFlags adds flags configuring package validation of local repo
+func addValidationFlags(flags *flag.FlagSet) {
+	flags.String("validators", "", "comma-separated list of validators packages should pass to be added: "+
+		strings.Join(deb.PackageValidatorNames(), ", ")+" (empty to disable)")
+	flags.String("validate-architectures", "", "comma-separated list of architectures allowed by architecture validator")
+	flags.Int64("validate-max-size", 0, "maximum package file size in bytes allowed by max-size validator")
+	flags.Var(&keyRingsFlag{}, "validate-keyring", "keyring with keys trusted by signature validator, default keyring if not set (could be specified multiple times, empty to reset)")
+	flags.Bool("require-signature", false, "reject packages without signature in signature validator")
+}
+
+// splitList splits comma-separated list, ignoring empty items
+func splitList(value string) []string {
+	result := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// updateValidation applies validation flags set on command line to the current validation
+func updateValidation(current *deb.Validation, flags *flag.FlagSet) (*deb.Validation, error) {
+	var (
+		validators, architectures, keyrings []string
+		maxSize                             int64
+		requireSignature, changed           bool
+	)
+
+	if current != nil {
+		validators, architectures, maxSize = current.Validators, current.Architectures, current.MaxSize
+		keyrings, requireSignature = current.Keyrings, current.RequireSignature
+	}
+
+	flags.Visit(func(flag *flag.Flag) {
+		switch flag.Name {
+		case "validators":
+			validators = splitList(flag.Value.String())
+			changed = true
+		case "validate-architectures":
+			architectures = splitList(flag.Value.String())
+			changed = true
+		case "validate-max-size":
+			maxSize = flag.Value.Get().(int64)
+			changed = true
+		case "validate-keyring":
+			keyrings = splitList(flag.Value.String())
+			changed = true
+		case "require-signature":
+			requireSignature = flag.Value.Get().(bool)
+			changed = true
+		}
+	})
+
+	if !changed {
+		return current, nil
+	}
+
+	return deb.NewValidation(validators, architectures, maxSize, keyrings, requireSignature)
+}
+
+// newKeyringVerifier creates verifier trusting keys from keyrings, it's used to verify
+// signatures of packages
+func newKeyringVerifier(keyrings []string) (pgp.Verifier, error) {
+	verifier := context.GetVerifier()
+	for _, keyring := range keyrings {
+		verifier.AddKeyring(keyring)
+	}
+
+	err := verifier.InitKeyring(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return verifier, nil
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/aptly-dev/aptly/database/goleveldb"
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/hooks"
 	"github.com/aptly-dev/aptly/http"
 	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/s3"
@@ -45,6 +46,7 @@ type AptlyContext struct {
 	progress          aptly.Progress
 	downloader        aptly.Downloader
 	taskList          *task.List
+	hooks             *hooks.Runner
 	database          database.Storage
 	packagePool       aptly.PackagePool
 	publishedStorages map[string]aptly.PublishedStorage
@@ -60,6 +62,9 @@ type AptlyContext struct {
 // Check interface
 var _ aptly.PublishedStorageProvider = &AptlyContext{}
 
+// hooksShutdownTimeout bounds time Shutdown waits for post hooks to finish
+const hooksShutdownTimeout = 30 * time.Second
+
 // FatalError is type for panicking to abort execution with non-zero
 // exit code and print meaningful explanation
 type FatalError struct {
@@ -221,12 +226,35 @@ func (context *AptlyContext) NewDownloader(progress aptly.Progress) aptly.Downlo
 	context.Lock()
 	defer context.Unlock()
 
-	return context.newDownloader(progress)
+	return context.newDownloader(progress, nil)
+}
+
+// NewMirrorDownloader returns instance of new downloader with given progress which
+// applies authentication profile (from mirrorAuth section of configuration) to requests
+func (context *AptlyContext) NewMirrorDownloader(progress aptly.Progress, authName string) (aptly.Downloader, error) {
+	context.Lock()
+	defer context.Unlock()
+
+	if authName == "" {
+		return context.newDownloader(progress, nil), nil
+	}
+
+	authConfig, ok := context.config().MirrorAuth[authName]
+	if !ok {
+		return nil, fmt.Errorf("mirror authentication %q is not configured", authName)
+	}
+
+	auth, err := http.LoadAuth(authConfig)
+	if err != nil {
+		return nil, fmt.Errorf("mirror authentication %q: %s", authName, err)
+	}
+
+	return context.newDownloader(progress, auth), nil
 }
 
 // NewDownloader returns instance of new downloader with given progress without locking
 // so it can be used for internal usage.
-func (context *AptlyContext) newDownloader(progress aptly.Progress) aptly.Downloader {
+func (context *AptlyContext) newDownloader(progress aptly.Progress, auth *http.Auth) aptly.Downloader {
 	var downloadLimit int64
 	limitFlag := context.flags.Lookup("download-limit")
 	if limitFlag != nil {
@@ -247,10 +275,20 @@ func (context *AptlyContext) newDownloader(progress aptly.Progress) aptly.Downlo
 		downloader = downloaderFlag.Value.String()
 	}
 
+	var fallback aptly.Downloader
 	if downloader == "grab" {
-		return http.NewGrabDownloader(downloadLimit*1024, maxTries, progress)
+		fallback = http.NewGrabDownloaderWithAuth(downloadLimit*1024, maxTries, progress, auth)
+	} else {
+		fallback = http.NewDownloaderWithAuth(downloadLimit*1024, maxTries, progress, auth)
 	}
-	return http.NewDownloader(downloadLimit*1024, maxTries, progress)
+
+	fileDownloader := http.NewFileDownloader(progress)
+
+	return http.NewSchemeDownloader(fallback, map[string]aptly.Downloader{
+		"file": fileDownloader,
+		"":     fileDownloader,
+		"s3":   s3.NewDownloader(context.config().S3MirrorRoots, progress),
+	})
 }
 
 // Downloader returns instance of current downloader
@@ -259,7 +297,7 @@ func (context *AptlyContext) Downloader() aptly.Downloader {
 	defer context.Unlock()
 
 	if context.downloader == nil {
-		context.downloader = context.newDownloader(context._progress())
+		context.downloader = context.newDownloader(context._progress(), nil)
 	}
 
 	return context.downloader
@@ -276,6 +314,17 @@ func (context *AptlyContext) TaskList() *task.List {
 	return context.taskList
 }
 
+// Hooks returns runner of hooks configured
+func (context *AptlyContext) Hooks() *hooks.Runner {
+	context.Lock()
+	defer context.Unlock()
+
+	if context.hooks == nil {
+		context.hooks = hooks.NewRunner(context.config().Hooks)
+	}
+	return context.hooks
+}
+
 // DBPath builds path to database
 func (context *AptlyContext) DBPath() string {
 	context.Lock()
@@ -614,6 +663,12 @@ func (context *AptlyContext) Shutdown() {
 	if context.taskList != nil {
 		context.taskList.Stop()
 	}
+	if context.hooks != nil {
+		if !context.hooks.Shutdown(hooksShutdownTimeout) {
+			context._progress().PrintfStdErr("Post hooks are still running after %s, not waiting for them\n", hooksShutdownTimeout)
+		}
+		context.hooks = nil
+	}
 	if context.database != nil {
 		_ = context.database.Close()
 		context.database = nil
@@ -0,0 +1,610 @@
+package deb
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// AdvisoryRange is a range of affected versions, empty bounds are open
+type AdvisoryRange struct {
+	// First affected version, empty or "0" if all the previous versions are affected
+	Introduced string `json:",omitempty"`
+	// Version which fixes the issue, empty if not fixed yet
+	Fixed string `json:",omitempty"`
+	// Last affected version, if the fix is not known
+	LastAffected string `json:",omitempty"`
+}
+
+// Contains checks whether version belongs to the range
+func (r AdvisoryRange) Contains(version string) bool {
+	if r.Introduced != "" && r.Introduced != "0" && CompareVersions(version, r.Introduced) < 0 {
+		return false
+	}
+	if r.Fixed != "" && CompareVersions(version, r.Fixed) >= 0 {
+		return false
+	}
+	if r.LastAffected != "" && CompareVersions(version, r.LastAffected) > 0 {
+		return false
+	}
+
+	return true
+}
+
+// Advisory is a known vulnerability of the source package in one of the releases
+type Advisory struct {
+	// CVE, DSA or other ID
+	ID string
+	// Other IDs of the same issue
+	Aliases []string `json:",omitempty"`
+	// Source package name
+	Source string
+	// Release (distribution codename or OSV ecosystem) advisory applies to
+	Release string `json:",omitempty"`
+	// Urgency or severity of the issue
+	Severity string `json:",omitempty"`
+	// Short description
+	Description string `json:",omitempty"`
+	// Affected versions
+	Ranges []AdvisoryRange `json:",omitempty"`
+	// Explicitly listed affected versions
+	Versions []string `json:",omitempty"`
+}
+
+// Affects checks whether source package version is affected, returning version fixing the issue
+// (empty if there is no fix yet)
+func (advisory *Advisory) Affects(version string) (affected bool, fixed string) {
+	for _, r := range advisory.Ranges {
+		if r.Contains(version) {
+			return true, r.Fixed
+		}
+	}
+
+	for _, v := range advisory.Versions {
+		if CompareVersions(version, v) == 0 {
+			return true, ""
+		}
+	}
+
+	return false, ""
+}
+
+// AdvisoryDB is a set of advisories indexed by source package name
+type AdvisoryDB struct {
+	advisories map[string][]*Advisory
+	count      int
+}
+
+// Len returns number of advisories in the database
+func (db *AdvisoryDB) Len() int {
+	return db.count
+}
+
+// ForSource returns advisories for the source package
+func (db *AdvisoryDB) ForSource(source string) []*Advisory {
+	return db.advisories[source]
+}
+
+func (db *AdvisoryDB) add(advisory *Advisory) {
+	db.advisories[advisory.Source] = append(db.advisories[advisory.Source], advisory)
+	db.count++
+}
+
+// debianReleaseVersions maps Debian codenames to version numbers used as OSV ecosystems
+var debianReleaseVersions = map[string]string{
+	"stretch":  "9",
+	"buster":   "10",
+	"bullseye": "11",
+	"bookworm": "12",
+	"trixie":   "13",
+	"forky":    "14",
+}
+
+// LoadAdvisories loads advisories from the local copy of Debian Security Tracker JSON
+// (https://security-tracker.debian.org/tracker/data/json) or OSV data (single JSON entry,
+// JSON array, zip archive or directory of JSON files)
+//
+// If release is set, only advisories for the release (distribution codename or
+// OSV ecosystem version, e.g. bookworm or 12) are loaded.
+func LoadAdvisories(path string, release string) (*AdvisoryDB, error) {
+	db := &AdvisoryDB{advisories: map[string][]*Advisory{}}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		err = filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || filepath.Ext(filePath) != ".json" {
+				return nil
+			}
+
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				return err
+			}
+
+			return db.loadJSON(data, filePath, release)
+		})
+	} else if filepath.Ext(path) == ".zip" {
+		err = db.loadZip(path, release)
+	} else {
+		var data []byte
+		data, err = os.ReadFile(path)
+		if err == nil {
+			err = db.loadJSON(data, path, release)
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to load advisories: %s", err)
+	}
+
+	for _, advisories := range db.advisories {
+		sort.Slice(advisories, func(i, j int) bool {
+			if advisories[i].ID != advisories[j].ID {
+				return advisories[i].ID < advisories[j].ID
+			}
+			return advisories[i].Release < advisories[j].Release
+		})
+	}
+
+	return db, nil
+}
+
+func (db *AdvisoryDB) loadZip(path string, release string) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = archive.Close() }()
+
+	for _, file := range archive.File {
+		if filepath.Ext(file.Name) != ".json" {
+			continue
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			return err
+		}
+
+		data, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+
+		if err = db.loadJSON(data, file.Name, release); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadJSON detects format of advisory data and loads it
+func (db *AdvisoryDB) loadJSON(data []byte, name string, release string) error {
+	data = bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(data, []byte("[")) {
+		var entries []osvEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+
+		for i := range entries {
+			entries[i].load(db, release)
+		}
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+
+	if _, ok := fields["affected"]; ok {
+		var entry osvEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+
+		entry.load(db, release)
+		return nil
+	}
+
+	for source, raw := range fields {
+		var issues map[string]trackerIssue
+		if err := json.Unmarshal(raw, &issues); err != nil {
+			return fmt.Errorf("%s: package %s: %s", name, source, err)
+		}
+
+		for id, issue := range issues {
+			issue.load(db, source, id, release)
+		}
+	}
+
+	return nil
+}
+
+type trackerRelease struct {
+	Status       string `json:"status"`
+	FixedVersion string `json:"fixed_version"`
+	Urgency      string `json:"urgency"`
+}
+
+type trackerIssue struct {
+	Description string                    `json:"description"`
+	Releases    map[string]trackerRelease `json:"releases"`
+}
+
+func (issue *trackerIssue) load(db *AdvisoryDB, source, id, release string) {
+	for codename, r := range issue.Releases {
+		if release != "" && codename != release {
+			continue
+		}
+
+		advisory := &Advisory{
+			ID:          id,
+			Source:      source,
+			Release:     codename,
+			Severity:    r.Urgency,
+			Description: issue.Description,
+		}
+
+		switch r.Status {
+		case "resolved":
+			if r.FixedVersion == "" || r.FixedVersion == "0" {
+				// release was never affected
+				continue
+			}
+			advisory.Ranges = []AdvisoryRange{{Fixed: r.FixedVersion}}
+		case "open", "undetermined":
+			advisory.Ranges = []AdvisoryRange{{}}
+		default:
+			continue
+		}
+
+		db.add(advisory)
+	}
+}
+
+type osvEntry struct {
+	ID       string   `json:"id"`
+	Aliases  []string `json:"aliases"`
+	Summary  string   `json:"summary"`
+	Details  string   `json:"details"`
+	Affected []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string              `json:"type"`
+			Events []map[string]string `json:"events"`
+		} `json:"ranges"`
+		Versions          []string               `json:"versions"`
+		EcosystemSpecific map[string]interface{} `json:"ecosystem_specific"`
+	} `json:"affected"`
+}
+
+// osvReleaseMatches checks whether OSV ecosystem (e.g. Debian:12) is Debian-based and matches release
+func osvReleaseMatches(ecosystem, release string) bool {
+	parts := strings.Split(ecosystem, ":")
+	if parts[0] != "Debian" && parts[0] != "Ubuntu" {
+		return false
+	}
+
+	if release == "" || ecosystem == release {
+		return true
+	}
+
+	return len(parts) > 1 && (parts[1] == release || parts[1] == debianReleaseVersions[release])
+}
+
+func (entry *osvEntry) load(db *AdvisoryDB, release string) {
+	description := entry.Summary
+	if description == "" {
+		description = entry.Details
+	}
+
+	for _, affected := range entry.Affected {
+		if !osvReleaseMatches(affected.Package.Ecosystem, release) {
+			continue
+		}
+
+		advisory := &Advisory{
+			ID:          entry.ID,
+			Aliases:     entry.Aliases,
+			Source:      affected.Package.Name,
+			Release:     affected.Package.Ecosystem,
+			Description: description,
+			Versions:    affected.Versions,
+		}
+		if urgency, ok := affected.EcosystemSpecific["urgency"].(string); ok {
+			advisory.Severity = urgency
+		}
+
+		for _, r := range affected.Ranges {
+			if r.Type != "ECOSYSTEM" {
+				continue
+			}
+
+			var current *AdvisoryRange
+			for _, event := range r.Events {
+				if introduced, ok := event["introduced"]; ok {
+					advisory.Ranges = append(advisory.Ranges, AdvisoryRange{Introduced: introduced})
+					current = &advisory.Ranges[len(advisory.Ranges)-1]
+				} else if current != nil {
+					current.Fixed = event["fixed"]
+					current.LastAffected = event["last_affected"]
+					current = nil
+				}
+			}
+		}
+
+		if len(advisory.Ranges) == 0 && len(advisory.Versions) == 0 {
+			continue
+		}
+
+		db.add(advisory)
+	}
+}
+
+// AuditFix is a version fixing the issue available in the mirror
+type AuditFix struct {
+	Mirror  string
+	Version string
+}
+
+// AuditFinding is a package affected by the advisory
+type AuditFinding struct {
+	// Affected package
+	Package      string
+	Version      string
+	Architecture string
+	// Source package, advisories are matched by source package name and version
+	Source        string
+	SourceVersion string
+	// Advisory details
+	Advisory    string
+	Aliases     []string `json:",omitempty"`
+	Severity    string   `json:",omitempty"`
+	Description string   `json:",omitempty"`
+	// Version fixing the issue, empty if there is no fix yet
+	FixedVersion string
+	// Fixed versions of the package available in the mirrors
+	AvailableFixes []AuditFix
+
+	advisory *Advisory
+}
+
+// AuditReport lists packages affected by known vulnerabilities
+type AuditReport struct {
+	// Number of audited packages
+	Packages int
+	// Number of affected packages
+	AffectedPackages int
+	// Vulnerabilities found
+	Findings []*AuditFinding
+}
+
+// sourceNameVersion returns source package name and version of the package
+func sourceNameVersion(p *Package) (string, string) {
+	if p.IsSource {
+		return p.Name, p.Version
+	}
+
+	return p.GetField("$Source"), p.GetField("$SourceVersion")
+}
+
+// AuditPackageList matches packages of the list against advisories
+//
+// Fixed versions are looked up in all the mirrors except for skipMirror (name of the mirror being audited).
+func AuditPackageList(list *PackageList, advisories *AdvisoryDB, collectionFactory *CollectionFactory, skipMirror string) (*AuditReport, error) {
+	report := &AuditReport{Packages: list.Len(), Findings: []*AuditFinding{}}
+
+	affectedPackages := map[string]bool{}
+	_ = list.ForEach(func(p *Package) error {
+		source, sourceVersion := sourceNameVersion(p)
+
+		seen := map[string]bool{}
+		for _, advisory := range advisories.ForSource(source) {
+			if seen[advisory.ID] {
+				continue
+			}
+
+			affected, fixed := advisory.Affects(sourceVersion)
+			if !affected {
+				continue
+			}
+			seen[advisory.ID] = true
+
+			report.Findings = append(report.Findings, &AuditFinding{
+				Package:        p.Name,
+				Version:        p.Version,
+				Architecture:   p.Architecture,
+				Source:         source,
+				SourceVersion:  sourceVersion,
+				Advisory:       advisory.ID,
+				Aliases:        advisory.Aliases,
+				Severity:       advisory.Severity,
+				Description:    advisory.Description,
+				FixedVersion:   fixed,
+				AvailableFixes: []AuditFix{},
+				advisory:       advisory,
+			})
+			affectedPackages[string(p.Key(""))] = true
+		}
+
+		return nil
+	})
+
+	report.AffectedPackages = len(affectedPackages)
+
+	sort.Slice(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Architecture != b.Architecture {
+			return a.Architecture < b.Architecture
+		}
+		if a.Version != b.Version {
+			return CompareVersions(a.Version, b.Version) < 0
+		}
+		return a.Advisory < b.Advisory
+	})
+
+	if len(report.Findings) > 0 {
+		if err := findAvailableFixes(report.Findings, collectionFactory, skipMirror); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// findAvailableFixes looks up versions of affected packages in the mirrors which are not affected anymore,
+// candidates are matched against advisory by source version (as advisories are)
+func findAvailableFixes(findings []*AuditFinding, collectionFactory *CollectionFactory, skipMirror string) error {
+	type mirrorPackage struct {
+		mirror string
+		key    []byte
+	}
+
+	wanted := map[string]bool{}
+	for _, finding := range findings {
+		wanted[finding.Architecture+" "+finding.Package] = true
+	}
+
+	available := map[string][]mirrorPackage{}
+	repoCollection := collectionFactory.RemoteRepoCollection()
+
+	err := repoCollection.ForEach(func(repo *RemoteRepo) error {
+		if repo.Name == skipMirror {
+			return nil
+		}
+
+		if err := repoCollection.LoadComplete(repo); err != nil {
+			// fixed versions are informational only, so broken mirror shouldn't fail the whole audit
+			log.Warn().Err(err).Msgf("Unable to load packages of mirror %s while looking up fixed versions", repo.Name)
+			return nil
+		}
+		if repo.RefList() == nil {
+			return nil
+		}
+
+		return repo.RefList().ForEach(func(key []byte) error {
+			arch, name, _ := parsePackageKey(string(key))
+			if wanted[arch+" "+name] {
+				available[arch+" "+name] = append(available[arch+" "+name], mirrorPackage{repo.Name, key})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	packageCollection := collectionFactory.PackageCollection()
+
+	for _, finding := range findings {
+		for _, candidate := range available[finding.Architecture+" "+finding.Package] {
+			p, err := packageCollection.ByKey(candidate.key)
+			if err != nil {
+				log.Warn().Err(err).Msgf("Unable to load package %s of mirror %s while looking up fixed versions",
+					candidate.key, candidate.mirror)
+				continue
+			}
+
+			source, sourceVersion := sourceNameVersion(p)
+			if source != finding.Source || CompareVersions(sourceVersion, finding.SourceVersion) <= 0 {
+				continue
+			}
+			if affected, _ := finding.advisory.Affects(sourceVersion); affected {
+				continue
+			}
+
+			finding.AvailableFixes = append(finding.AvailableFixes, AuditFix{Mirror: candidate.mirror, Version: p.Version})
+		}
+
+		sort.Slice(finding.AvailableFixes, func(i, j int) bool {
+			a, b := finding.AvailableFixes[i], finding.AvailableFixes[j]
+			if a.Mirror != b.Mirror {
+				return a.Mirror < b.Mirror
+			}
+			return CompareVersions(a.Version, b.Version) < 0
+		})
+	}
+
+	return nil
+}
+
+// AuditRelease derives release advisories should be matched for from the distribution:
+// suite suffixes like -security or /updates are stripped, e.g. bookworm-security becomes bookworm
+func AuditRelease(distribution string) string {
+	distribution, _, _ = strings.Cut(distribution, "/")
+	distribution, _, _ = strings.Cut(distribution, "-")
+
+	return distribution
+}
+
+// SnapshotDistribution returns distribution of the mirrors (codename from the Release file) and local repos
+// snapshot has been created from (following source snapshots), empty string is returned if distributions
+// are not known or different
+func SnapshotDistribution(snapshot *Snapshot, collectionFactory *CollectionFactory) string {
+	distributions := map[string]bool{}
+
+	var walk func(snapshot *Snapshot) bool
+	walk = func(snapshot *Snapshot) bool {
+		for _, uuid := range snapshot.SourceIDs {
+			switch snapshot.SourceKind {
+			case SourceRemoteRepo:
+				repo, err := collectionFactory.RemoteRepoCollection().ByUUID(uuid)
+				if err != nil {
+					return false
+				}
+				distributions[repo.Codename()] = true
+			case SourceLocalRepo:
+				repo, err := collectionFactory.LocalRepoCollection().ByUUID(uuid)
+				if err != nil {
+					return false
+				}
+				distributions[repo.DefaultDistribution] = true
+			case SourceSnapshot:
+				source, err := collectionFactory.SnapshotCollection().ByUUID(uuid)
+				if err != nil || !walk(source) {
+					return false
+				}
+			default:
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if !walk(snapshot) || len(distributions) != 1 {
+		return ""
+	}
+
+	for distribution := range distributions {
+		return distribution
+	}
+
+	return ""
+}
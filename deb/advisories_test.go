@@ -0,0 +1,276 @@
+package deb
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+const trackerAdvisories = `{
+  "openssl": {
+    "CVE-2026-0001": {
+      "description": "buffer overflow in X.509 parsing",
+      "releases": {
+        "bookworm": {"status": "resolved", "fixed_version": "3.0.11-1~deb12u2", "urgency": "high"},
+        "bullseye": {"status": "resolved", "fixed_version": "1.1.1w-0+deb11u1", "urgency": "high"},
+        "sid": {"status": "resolved", "fixed_version": "0", "urgency": "high"}
+      }
+    },
+    "CVE-2026-0002": {
+      "description": "timing side channel",
+      "releases": {
+        "bookworm": {"status": "open", "urgency": "low"}
+      }
+    }
+  },
+  "zlib": {
+    "CVE-2026-0003": {
+      "releases": {
+        "bookworm": {"status": "resolved", "fixed_version": "1:1.2.13.dfsg-1+deb12u1", "urgency": "medium"}
+      }
+    }
+  }
+}`
+
+const osvAdvisory = `{
+  "id": "DSA-6000-1",
+  "aliases": ["CVE-2026-0004"],
+  "summary": "curl security update",
+  "affected": [
+    {
+      "package": {"ecosystem": "Debian:12", "name": "curl"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "7.88.1-10+deb12u5"}]}],
+      "ecosystem_specific": {"urgency": "medium"}
+    },
+    {
+      "package": {"ecosystem": "Debian:11", "name": "curl"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "7.74.0-1"}, {"last_affected": "7.74.0-1.3+deb11u7"}]}]
+    },
+    {
+      "package": {"ecosystem": "PyPI", "name": "curl"},
+      "versions": ["7.88.1-10"]
+    }
+  ]
+}`
+
+type AdvisoriesSuite struct {
+	db                database.Storage
+	collectionFactory *CollectionFactory
+	dir               string
+}
+
+var _ = Suite(&AdvisoriesSuite{})
+
+func (s *AdvisoriesSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collectionFactory = NewCollectionFactory(s.db)
+	s.dir = c.MkDir()
+
+	c.Assert(os.WriteFile(filepath.Join(s.dir, "tracker.json"), []byte(trackerAdvisories), 0644), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(s.dir, "osv"), 0755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(s.dir, "osv", "DSA-6000-1.json"), []byte(osvAdvisory), 0644), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(s.dir, "osv", "README"), []byte("not an advisory"), 0644), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(s.dir, "osv.json"), []byte("["+osvAdvisory+"]"), 0644), IsNil)
+
+	f, err := os.Create(filepath.Join(s.dir, "all.zip"))
+	c.Assert(err, IsNil)
+	archive := zip.NewWriter(f)
+	w, err := archive.Create("DSA-6000-1.json")
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte(osvAdvisory))
+	c.Assert(err, IsNil)
+	c.Assert(archive.Close(), IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *AdvisoriesSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *AdvisoriesSuite) TestRange(c *C) {
+	c.Check(AdvisoryRange{}.Contains("1.0"), Equals, true)
+	c.Check(AdvisoryRange{Introduced: "0", Fixed: "1.0-2"}.Contains("1.0-1"), Equals, true)
+	c.Check(AdvisoryRange{Introduced: "0", Fixed: "1.0-2"}.Contains("1.0-2"), Equals, false)
+	c.Check(AdvisoryRange{Introduced: "1.0", Fixed: "1.2"}.Contains("0.9"), Equals, false)
+	c.Check(AdvisoryRange{Introduced: "1.0", LastAffected: "1.2"}.Contains("1.2"), Equals, true)
+	c.Check(AdvisoryRange{Introduced: "1.0", LastAffected: "1.2"}.Contains("1.2+b1"), Equals, false)
+}
+
+func (s *AdvisoriesSuite) TestLoadTracker(c *C) {
+	db, err := LoadAdvisories(filepath.Join(s.dir, "tracker.json"), "")
+	c.Assert(err, IsNil)
+	c.Check(db.Len(), Equals, 4)
+
+	db, err = LoadAdvisories(filepath.Join(s.dir, "tracker.json"), "bookworm")
+	c.Assert(err, IsNil)
+	c.Check(db.Len(), Equals, 3)
+
+	advisories := db.ForSource("openssl")
+	c.Assert(advisories, HasLen, 2)
+	c.Check(advisories[0].ID, Equals, "CVE-2026-0001")
+	c.Check(advisories[0].Severity, Equals, "high")
+
+	affected, fixed := advisories[0].Affects("3.0.11-1~deb12u1")
+	c.Check([]interface{}{affected, fixed}, DeepEquals, []interface{}{true, "3.0.11-1~deb12u2"})
+	affected, _ = advisories[0].Affects("3.0.11-1~deb12u2")
+	c.Check(affected, Equals, false)
+
+	affected, fixed = advisories[1].Affects("3.0.11-1~deb12u2")
+	c.Check([]interface{}{affected, fixed}, DeepEquals, []interface{}{true, ""})
+
+	affected, _ = db.ForSource("zlib")[0].Affects("1.2.13.dfsg-1")
+	c.Check(affected, Equals, true)
+}
+
+func (s *AdvisoriesSuite) TestLoadOSV(c *C) {
+	for _, name := range []string{"osv", "osv.json", "all.zip", "osv/DSA-6000-1.json"} {
+		db, err := LoadAdvisories(filepath.Join(s.dir, name), "")
+		c.Assert(err, IsNil)
+		c.Check(db.Len(), Equals, 2, Commentf("%s", name))
+
+		db, err = LoadAdvisories(filepath.Join(s.dir, name), "bullseye")
+		c.Assert(err, IsNil)
+		c.Assert(db.Len(), Equals, 1)
+
+		advisory := db.ForSource("curl")[0]
+		c.Check(advisory.ID, Equals, "DSA-6000-1")
+		c.Check(advisory.Aliases, DeepEquals, []string{"CVE-2026-0004"})
+		c.Check(advisory.Release, Equals, "Debian:11")
+
+		affected, _ := advisory.Affects("7.74.0-1.3+deb11u7")
+		c.Check(affected, Equals, true)
+		affected, _ = advisory.Affects("7.74.0-1.3+deb11u8")
+		c.Check(affected, Equals, false)
+	}
+
+	db, err := LoadAdvisories(filepath.Join(s.dir, "osv.json"), "Debian:12")
+	c.Assert(err, IsNil)
+	c.Check(db.ForSource("curl")[0].Severity, Equals, "medium")
+}
+
+func (s *AdvisoriesSuite) TestLoadErrors(c *C) {
+	_, err := LoadAdvisories(filepath.Join(s.dir, "missing.json"), "")
+	c.Check(err, NotNil)
+
+	c.Assert(os.WriteFile(filepath.Join(s.dir, "broken.json"), []byte("{\"openssl\": []}"), 0644), IsNil)
+	_, err = LoadAdvisories(filepath.Join(s.dir, "broken.json"), "")
+	c.Check(err, ErrorMatches, "unable to load advisories: .*broken.json: package openssl: .*")
+}
+
+func (s *AdvisoriesSuite) TestAudit(c *C) {
+	newPackage := func(name, version, source string) *Package {
+		stanza := packageStanza.Copy()
+		stanza["Package"], stanza["Version"], stanza["Source"] = name, version, source
+		stanza["Filename"] = "pool/main/" + name + "_" + version + "_i386.deb"
+		return NewPackageFromControlFile(stanza)
+	}
+
+	list := NewPackageList()
+	_ = list.Add(newPackage("libssl3", "3.0.11-1~deb12u1", "openssl"))
+	_ = list.Add(newPackage("zlib1g", "1:1.2.13.dfsg-1+b1", "zlib (1:1.2.13.dfsg-1)"))
+	_ = list.Add(newPackage("curl", "7.88.1-10+deb12u5", ""))
+	_ = list.Add(newPackage("openssl-tools", "5.0", "openssl (3.0.11-1~deb12u1)"))
+
+	fixes := NewPackageList()
+	_ = fixes.Add(newPackage("libssl3", "3.0.11-1~deb12u2", "openssl"))
+	// binary versions are not related to source versions: rebuild of vulnerable source is not a fix
+	_ = fixes.Add(newPackage("openssl-tools", "5.0+b1", "openssl (3.0.11-1~deb12u1)"))
+	_ = fixes.Add(newPackage("openssl-tools", "5.1", "openssl (3.0.11-1~deb12u2)"))
+	c.Assert(fixes.ForEach(func(p *Package) error { return s.collectionFactory.PackageCollection().Update(p) }), IsNil)
+	security, _ := NewRemoteRepo("bookworm-security", "http://security.debian.org/debian-security", "bookworm-security", []string{"main"}, []string{}, false, false, false, false)
+	security.packageRefs = NewPackageRefListFromPackageList(fixes)
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Add(security), IsNil)
+
+	db, err := LoadAdvisories(filepath.Join(s.dir, "tracker.json"), "bookworm")
+	c.Assert(err, IsNil)
+
+	report, err := AuditPackageList(list, db, s.collectionFactory, "")
+	c.Assert(err, IsNil)
+	c.Check(report.Packages, Equals, 4)
+	c.Check(report.AffectedPackages, Equals, 3)
+	c.Assert(report.Findings, HasLen, 5)
+
+	c.Check(report.Findings[0].Package, Equals, "libssl3")
+	c.Check(report.Findings[0].Advisory, Equals, "CVE-2026-0001")
+	c.Check(report.Findings[0].FixedVersion, Equals, "3.0.11-1~deb12u2")
+	c.Check(report.Findings[0].AvailableFixes, DeepEquals, []AuditFix{{Mirror: "bookworm-security", Version: "3.0.11-1~deb12u2"}})
+
+	c.Check(report.Findings[1].Advisory, Equals, "CVE-2026-0002")
+	c.Check(report.Findings[1].FixedVersion, Equals, "")
+	c.Check(report.Findings[1].AvailableFixes, HasLen, 0)
+
+	c.Check(report.Findings[2].Package, Equals, "openssl-tools")
+	c.Check(report.Findings[2].Advisory, Equals, "CVE-2026-0001")
+	c.Check(report.Findings[2].AvailableFixes, DeepEquals, []AuditFix{{Mirror: "bookworm-security", Version: "5.1"}})
+
+	c.Check(report.Findings[4].Package, Equals, "zlib1g")
+	c.Check(report.Findings[4].Source, Equals, "zlib")
+	c.Check(report.Findings[4].SourceVersion, Equals, "1:1.2.13.dfsg-1")
+
+	// mirror being audited is skipped when looking up fixes
+	report, err = AuditPackageList(list, db, s.collectionFactory, "bookworm-security")
+	c.Assert(err, IsNil)
+	c.Check(report.Findings[0].AvailableFixes, HasLen, 0)
+}
+
+func (s *AdvisoriesSuite) TestAuditRelease(c *C) {
+	c.Check(AuditRelease("bookworm"), Equals, "bookworm")
+	c.Check(AuditRelease("bookworm-security"), Equals, "bookworm")
+	c.Check(AuditRelease("wheezy/updates"), Equals, "wheezy")
+	c.Check(AuditRelease(""), Equals, "")
+}
+
+func (s *AdvisoriesSuite) TestSnapshotDistribution(c *C) {
+	main, _ := NewRemoteRepo("bookworm-main", "http://deb.debian.org/debian", "bookworm", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Add(main), IsNil)
+	security, _ := NewRemoteRepo("bookworm-security", "http://security.debian.org/debian-security", "bookworm-security", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Add(security), IsNil)
+
+	local := NewLocalRepo("local", "")
+	local.DefaultDistribution = "bookworm"
+	c.Assert(s.collectionFactory.LocalRepoCollection().Add(local), IsNil)
+
+	fromMirror := NewSnapshotFromRefList("from-mirror", nil, NewPackageRefList(), "")
+	fromMirror.SourceKind, fromMirror.SourceIDs = SourceRemoteRepo, []string{main.UUID}
+	c.Assert(s.collectionFactory.SnapshotCollection().Add(fromMirror), IsNil)
+	c.Check(SnapshotDistribution(fromMirror, s.collectionFactory), Equals, "bookworm")
+
+	fromRepo := NewSnapshotFromRefList("from-repo", nil, NewPackageRefList(), "")
+	fromRepo.SourceKind, fromRepo.SourceIDs = SourceLocalRepo, []string{local.UUID}
+	c.Assert(s.collectionFactory.SnapshotCollection().Add(fromRepo), IsNil)
+
+	merged := NewSnapshotFromRefList("merged", []*Snapshot{fromMirror, fromRepo}, NewPackageRefList(), "")
+	c.Check(SnapshotDistribution(merged, s.collectionFactory), Equals, "bookworm")
+
+	fromSecurity := NewSnapshotFromRefList("from-security", nil, NewPackageRefList(), "")
+	fromSecurity.SourceKind, fromSecurity.SourceIDs = SourceRemoteRepo, []string{security.UUID}
+	c.Assert(s.collectionFactory.SnapshotCollection().Add(fromSecurity), IsNil)
+
+	// codename from the Release file is used for mirrors of suites
+	stable, _ := NewRemoteRepo("stable-main", "http://deb.debian.org/debian", "stable", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Add(stable), IsNil)
+	fromStable := NewSnapshotFromRefList("from-stable", nil, NewPackageRefList(), "")
+	fromStable.SourceKind, fromStable.SourceIDs = SourceRemoteRepo, []string{stable.UUID}
+	c.Assert(s.collectionFactory.SnapshotCollection().Add(fromStable), IsNil)
+	c.Check(SnapshotDistribution(fromStable, s.collectionFactory), Equals, "stable")
+
+	stable.Meta = Stanza{"Codename": "bookworm"}
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Update(stable), IsNil)
+	c.Check(SnapshotDistribution(fromStable, s.collectionFactory), Equals, "bookworm")
+
+	merged = NewSnapshotFromRefList("merged", []*Snapshot{fromMirror, fromStable}, NewPackageRefList(), "")
+	c.Check(SnapshotDistribution(merged, s.collectionFactory), Equals, "bookworm")
+
+	// sources with different distributions
+	merged = NewSnapshotFromRefList("merged", []*Snapshot{fromMirror, fromSecurity}, NewPackageRefList(), "")
+	c.Check(SnapshotDistribution(merged, s.collectionFactory), Equals, "")
+
+	// source is gone
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Drop(main), IsNil)
+	c.Check(SnapshotDistribution(fromMirror, s.collectionFactory), Equals, "")
+}
@@ -0,0 +1,107 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ChangelogEntry is a single entry of Debian changelog
+type ChangelogEntry struct {
+	// Source package name
+	Source string
+	// Source package version
+	Version string
+	// Target distributions
+	Distributions string
+	// Urgency of the upload
+	Urgency string `json:",omitempty"`
+	// Maintainer responsible for the upload
+	Maintainer string
+	// Date of the upload
+	Date string
+	// Changes, with the leading indentation stripped
+	Changes string
+}
+
+// String returns header line of the changelog entry
+func (entry ChangelogEntry) String() string {
+	result := fmt.Sprintf("%s (%s) %s", entry.Source, entry.Version, entry.Distributions)
+	if entry.Urgency != "" {
+		result += "; urgency=" + entry.Urgency
+	}
+
+	return result
+}
+
+var (
+	changelogHeaderRegexp  = regexp.MustCompile(`^(\S+) \(([^)]+)\)\s+([^;]*);(.*)$`)
+	changelogTrailerRegexp = regexp.MustCompile(`^ -- (.+?)  (.+)$`)
+	changelogUrgencyRegexp = regexp.MustCompile(`(?:^|,)\s*urgency=([^,\s]+)`)
+)
+
+// ParseChangelog parses Debian changelog (as in debian/changelog) into list of entries,
+// newest first
+func ParseChangelog(data []byte) ([]ChangelogEntry, error) {
+	var (
+		result  []ChangelogEntry
+		current *ChangelogEntry
+		changes []string
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), " \t")
+
+		if current == nil {
+			if line == "" {
+				continue
+			}
+
+			matches := changelogHeaderRegexp.FindStringSubmatch(line)
+			if matches == nil {
+				if len(result) > 0 {
+					// old entries in free-form format, stop parsing
+					break
+				}
+				return nil, fmt.Errorf("malformed changelog header at line %d: %s", lineNo, line)
+			}
+
+			current = &ChangelogEntry{
+				Source:        matches[1],
+				Version:       matches[2],
+				Distributions: strings.TrimSpace(matches[3]),
+			}
+			if urgency := changelogUrgencyRegexp.FindStringSubmatch(matches[4]); urgency != nil {
+				current.Urgency = urgency[1]
+			}
+			changes = nil
+
+			continue
+		}
+
+		if matches := changelogTrailerRegexp.FindStringSubmatch(line); matches != nil {
+			current.Maintainer = matches[1]
+			current.Date = matches[2]
+			current.Changes = strings.Trim(strings.Join(changes, "\n"), "\n")
+			result = append(result, *current)
+			current = nil
+
+			continue
+		}
+
+		changes = append(changes, strings.TrimPrefix(line, "  "))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("changelog entry %s is missing trailer line", current)
+	}
+
+	return result, nil
+}
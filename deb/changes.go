@@ -216,6 +216,32 @@ func (c *Changes) PackageQuery() PackageQuery {
 	return &AndQuery{L: archQuery, R: nameQuery}
 }
 
+// Components returns sorted list of components files of .changes file go to,
+// based on section of each file ("contrib/utils" goes to "contrib", "utils" goes to "main")
+func (c *Changes) Components() []string {
+	components := []string{}
+
+	for _, line := range strings.Split(c.Stanza["Files"], "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 5 {
+			continue
+		}
+
+		component := "main"
+		if pos := strings.Index(parts[2], "/"); pos != -1 {
+			component = parts[2][:pos]
+		}
+
+		if !utils.StrSliceHasItem(components, component) {
+			components = append(components, component)
+		}
+	}
+
+	sort.Strings(components)
+
+	return components
+}
+
 // GetField implements PackageLike interface
 func (c *Changes) GetField(field string) string {
 	return c.Stanza[field]
@@ -290,7 +316,7 @@ func CollectChangesFiles(locations []string, reporter aptly.ResultReporter) (cha
 
 // ImportChangesFiles imports referenced files in changes files into local repository
 func ImportChangesFiles(changesFiles []string, reporter aptly.ResultReporter, acceptUnsigned, ignoreSignatures, forceReplace, noRemoveFiles bool,
-	verifier pgp.Verifier, repoTemplate *template.Template, progress aptly.Progress, localRepoCollection *LocalRepoCollection, packageCollection *PackageCollection,
+	verifier pgp.Verifier, verifierProvider VerifierProvider, repoTemplate *template.Template, progress aptly.Progress, localRepoCollection *LocalRepoCollection, packageCollection *PackageCollection,
 	pool aptly.PackagePool, checksumStorageProvider aptly.ChecksumStorageProvider, uploaders *Uploaders, parseQuery parseQuery) (processedFiles []string, failedFiles []string, err error) {
 
 	for _, path := range changesFiles {
@@ -341,16 +367,24 @@ func ImportChangesFiles(changesFiles []string, reporter aptly.ResultReporter, ac
 		currentUploaders := uploaders
 		if repo.Uploaders != nil {
 			currentUploaders = repo.Uploaders
-			for i := range currentUploaders.Rules {
-				currentUploaders.Rules[i].CompiledCondition, err = parseQuery(currentUploaders.Rules[i].Condition)
-				if err != nil {
-					return nil, nil, fmt.Errorf("error parsing query %s: %s", currentUploaders.Rules[i].Condition, err)
-				}
+			if err = currentUploaders.Compile(parseQuery); err != nil {
+				return nil, nil, err
 			}
 		}
 
+		err = localRepoCollection.LoadComplete(repo)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to load repo: %s", err)
+		}
+
+		var list *PackageList
+		list, err = NewPackageListFromRefList(repo.RefList(), packageCollection, progress)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to load packages: %s", err)
+		}
+
 		if currentUploaders != nil {
-			if err = currentUploaders.IsAllowed(changes); err != nil {
+			if err = currentUploaders.Explain(changes, currentSourceVersion(list, changes.Source)).Err(); err != nil {
 				failedFiles = append(failedFiles, path)
 				reporter.Warning("changes file skipped due to uploaders config: %s, keys %#v: %s",
 					changes.ChangesName, changes.SignatureKeys, err)
@@ -359,15 +393,10 @@ func ImportChangesFiles(changesFiles []string, reporter aptly.ResultReporter, ac
 			}
 		}
 
-		err = localRepoCollection.LoadComplete(repo)
+		var validation *Validation
+		validation, err = repo.Validation.Prepare(verifierProvider)
 		if err != nil {
-			return nil, nil, fmt.Errorf("unable to load repo: %s", err)
-		}
-
-		var list *PackageList
-		list, err = NewPackageListFromRefList(repo.RefList(), packageCollection, progress)
-		if err != nil {
-			return nil, nil, fmt.Errorf("unable to load packages: %s", err)
+			return nil, nil, err
 		}
 
 		packageFiles, otherFiles, _ := CollectPackageFiles([]string{changes.TempDir}, reporter)
@@ -376,7 +405,7 @@ func ImportChangesFiles(changesFiles []string, reporter aptly.ResultReporter, ac
 		var processedFiles2, failedFiles2 []string
 
 		processedFiles2, failedFiles2, err = ImportPackageFiles(list, packageFiles, forceReplace, verifier, pool,
-			packageCollection, reporter, restriction, checksumStorageProvider)
+			packageCollection, reporter, restriction, validation, checksumStorageProvider)
 
 		if err != nil {
 			return nil, nil, fmt.Errorf("unable to import package files: %s", err)
@@ -422,3 +451,110 @@ func ImportChangesFiles(changesFiles []string, reporter aptly.ResultReporter, ac
 
 	return processedFiles, failedFiles, nil
 }
+
+// currentSourceVersion returns the highest version of source package in the list (taking into account
+// binary packages built from it), empty if there's none
+func currentSourceVersion(list *PackageList, source string) string {
+	current := ""
+
+	_ = list.ForEach(func(p *Package) error {
+		version := ""
+		if p.IsSource {
+			if p.Name == source {
+				version = p.Version
+			}
+		} else if p.GetField("$Source") == source {
+			version = p.GetField("$SourceVersion")
+		}
+
+		if version != "" && (current == "" || CompareVersions(version, current) > 0) {
+			current = version
+		}
+
+		return nil
+	})
+
+	return current
+}
+
+// ChangesDecision is result of checking .changes file against uploaders rules
+type ChangesDecision struct {
+	// Path to .changes file
+	ChangesFile string
+	// Local repo upload is directed to
+	Repo string
+	// Key IDs of valid signatures
+	SignatureKeys []pgp.Key
+	// Highest version of the source package in the repo
+	CurrentVersion string
+	*UploadersDecision
+}
+
+// ExplainChangesFiles verifies .changes files and checks them against uploaders rules without
+// importing anything, explaining decision for each file
+func ExplainChangesFiles(changesFiles []string, acceptUnsigned, ignoreSignatures bool, verifier pgp.Verifier,
+	repoTemplate *template.Template, localRepoCollection *LocalRepoCollection, packageCollection *PackageCollection,
+	uploaders *Uploaders, parseQuery parseQuery) ([]*ChangesDecision, error) {
+	result := []*ChangesDecision{}
+
+	for _, path := range changesFiles {
+		decision := &ChangesDecision{ChangesFile: path, UploadersDecision: &UploadersDecision{}}
+		result = append(result, decision)
+
+		changes, err := NewChanges(path)
+		if err != nil {
+			decision.Reason = err.Error()
+			continue
+		}
+
+		err = changes.VerifyAndParse(acceptUnsigned, ignoreSignatures, verifier)
+		_ = changes.Cleanup()
+		if err != nil {
+			decision.Reason = err.Error()
+			continue
+		}
+		decision.SignatureKeys = changes.SignatureKeys
+
+		repoName := &bytes.Buffer{}
+		err = repoTemplate.Execute(repoName, changes.Stanza)
+		if err != nil {
+			return nil, fmt.Errorf("error applying template to repo: %s", err)
+		}
+		decision.Repo = repoName.String()
+
+		repo, err := localRepoCollection.ByName(decision.Repo)
+		if err != nil {
+			decision.Reason = err.Error()
+			continue
+		}
+
+		currentUploaders := uploaders
+		if repo.Uploaders != nil {
+			currentUploaders = repo.Uploaders
+			if err = currentUploaders.Compile(parseQuery); err != nil {
+				return nil, err
+			}
+		}
+
+		if currentUploaders == nil {
+			decision.Allowed = true
+			decision.Reason = "allowed as no uploaders rules are configured"
+			continue
+		}
+
+		err = localRepoCollection.LoadComplete(repo)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load repo: %s", err)
+		}
+
+		list, err := NewPackageListFromRefList(repo.RefList(), packageCollection, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load packages: %s", err)
+		}
+
+		decision.CurrentVersion = currentSourceVersion(list, changes.Source)
+		decision.UploadersDecision = currentUploaders.Explain(changes, decision.CurrentVersion)
+	}
+
+	return result, nil
+}
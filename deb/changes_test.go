@@ -123,7 +123,7 @@ func (s *ChangesSuite) TestImportChangesFiles(c *C) {
 
 	processedFiles, failedFiles, err := ImportChangesFiles(
 		append(changesFiles, "testdata/changes/notexistent.changes"),
-		s.Reporter, true, true, false, false, &NullVerifier{},
+		s.Reporter, true, true, false, false, &NullVerifier{}, nil,
 		template.Must(template.New("test").Parse("test")), s.progress, s.localRepoCollection, s.packageCollection, s.packagePool, func(database.ReaderWriter) aptly.ChecksumStorage { return s.checksumStorage },
 		nil, nil)
 	c.Assert(err, IsNil)
@@ -141,7 +141,7 @@ func (s *ChangesSuite) TestImportDbgsymWithVersionedSourceField(c *C) {
 	c.Check(failedFiles, HasLen, 0)
 
 	_, failedFiles, err := ImportChangesFiles(
-		changesFiles, s.Reporter, true, true, false, true, &NullVerifier{},
+		changesFiles, s.Reporter, true, true, false, true, &NullVerifier{}, nil,
 		template.Must(template.New("test").Parse("test")), s.progress, s.localRepoCollection, s.packageCollection, s.packagePool, func(database.ReaderWriter) aptly.ChecksumStorage { return s.checksumStorage },
 		nil, nil)
 	c.Assert(err, IsNil)
@@ -169,3 +169,57 @@ func (s *ChangesSuite) TestPackageQuery(c *C) {
 	c.Check(q.String(), Equals,
 		"(($Architecture (= amd64)) | (($Architecture (= source)) | ($Architecture (= )))), ((($PackageType (= source)), (Name (= calamares))) | ((!($PackageType (= source))), (((Name (= calamares-dbg)) | (Name (= calamares))) | ((Name (= calamares-dbg-dbgsym)) | (Name (= calamares-dbgsym))))))")
 }
+
+func (s *ChangesSuite) TestComponents(c *C) {
+	changes, err := NewChanges(s.Path)
+	c.Assert(err, IsNil)
+
+	c.Assert(changes.VerifyAndParse(true, true, &NullVerifier{}), IsNil)
+	c.Check(changes.Components(), DeepEquals, []string{"main"})
+
+	changes.Stanza["Files"] = "\n 05fd8f3ffe8f362c5ef9bad2f936a56e 1106 contrib/devel optional calamares.dsc\n" +
+		" e6f8ce70f564d1f68cb57758b15b13e3 12835902 debug optional calamares-dbg.deb"
+	c.Check(changes.Components(), DeepEquals, []string{"contrib", "main"})
+}
+
+func (s *ChangesSuite) TestCurrentSourceVersion(c *C) {
+	list := NewPackageList()
+	c.Assert(list.Add(&Package{Name: "hardlink", Version: "0.2.0", Architecture: "i386"}), IsNil)
+	c.Assert(list.Add(&Package{Name: "hardlink-doc", Version: "0.2.1", Architecture: "all", Source: "hardlink (0.2.1+b1)"}), IsNil)
+	c.Assert(list.Add(&Package{Name: "hardlink", Version: "0.1", Architecture: "source", IsSource: true}), IsNil)
+
+	c.Check(currentSourceVersion(list, "hardlink"), Equals, "0.2.1+b1")
+	c.Check(currentSourceVersion(list, "calamares"), Equals, "")
+}
+
+func (s *ChangesSuite) TestExplainChangesFiles(c *C) {
+	repo := NewLocalRepo("unstable", "")
+	c.Assert(s.localRepoCollection.Add(repo), IsNil)
+
+	changesFiles := []string{"testdata/changes/hardlink_0.2.1_amd64.changes", "testdata/changes/calamares.changes"}
+
+	decisions, err := ExplainChangesFiles(changesFiles, true, true, &NullVerifier{},
+		template.Must(template.New("test").Parse("{{.Distribution}}")), s.localRepoCollection, s.packageCollection, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(decisions, HasLen, 2)
+	c.Check(decisions[0].Allowed, Equals, true)
+	c.Check(decisions[0].Repo, Equals, "unstable")
+	c.Check(decisions[1].Allowed, Equals, false)
+	c.Check(decisions[1].Repo, Equals, "sid")
+	c.Check(decisions[1].Reason, Equals, "local repo with name sid not found")
+
+	repo.Uploaders = &Uploaders{Rules: []UploadersRule{{Sources: []string{"hardlink"}, Allow: []string{"*"}}}}
+	c.Assert(s.localRepoCollection.Update(repo), IsNil)
+
+	// unsigned .changes file has no keys to be allowed
+	decisions, err = ExplainChangesFiles(changesFiles[:1], true, true, &NullVerifier{},
+		template.Must(template.New("test").Parse("{{.Distribution}}")), s.localRepoCollection, s.packageCollection, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(decisions[0].Allowed, Equals, false)
+	c.Check(decisions[0].Reason, Equals, "denied as no rule matches")
+	c.Check(decisions[0].Trace, DeepEquals, []string{`rule #1 {"condition":"","allow":["*"],"deny":null,"sources":["hardlink"]}: skipped, no key is allowed`})
+
+	// uploaded files are left intact
+	_, err = os.Stat("testdata/changes/hardlink_0.2.1_amd64.deb")
+	c.Check(err, IsNil)
+}
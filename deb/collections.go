@@ -17,6 +17,10 @@ type CollectionFactory struct {
 	localRepos     *LocalRepoCollection
 	publishedRepos *PublishedRepoCollection
 	checksums      *ChecksumCollection
+	mirrorHistory  *MirrorHistoryCollection
+	packageAges    *PackageAgeCollection
+	incomingAudit  *IncomingAuditCollection
+	promotions     *PromotionCollection
 }
 
 // NewCollectionFactory creates new factory
@@ -91,6 +95,54 @@ func (factory *CollectionFactory) PublishedRepoCollection() *PublishedRepoCollec
 	return factory.publishedRepos
 }
 
+// MirrorHistoryCollection returns (or creates) new MirrorHistoryCollection
+func (factory *CollectionFactory) MirrorHistoryCollection() *MirrorHistoryCollection {
+	factory.Lock()
+	defer factory.Unlock()
+
+	if factory.mirrorHistory == nil {
+		factory.mirrorHistory = NewMirrorHistoryCollection(factory.db)
+	}
+
+	return factory.mirrorHistory
+}
+
+// PackageAgeCollection returns (or creates) new PackageAgeCollection
+func (factory *CollectionFactory) PackageAgeCollection() *PackageAgeCollection {
+	factory.Lock()
+	defer factory.Unlock()
+
+	if factory.packageAges == nil {
+		factory.packageAges = NewPackageAgeCollection(factory.db)
+	}
+
+	return factory.packageAges
+}
+
+// IncomingAuditCollection returns (or creates) new IncomingAuditCollection
+func (factory *CollectionFactory) IncomingAuditCollection() *IncomingAuditCollection {
+	factory.Lock()
+	defer factory.Unlock()
+
+	if factory.incomingAudit == nil {
+		factory.incomingAudit = NewIncomingAuditCollection(factory.db)
+	}
+
+	return factory.incomingAudit
+}
+
+// PromotionCollection returns (or creates) new PromotionCollection
+func (factory *CollectionFactory) PromotionCollection() *PromotionCollection {
+	factory.Lock()
+	defer factory.Unlock()
+
+	if factory.promotions == nil {
+		factory.promotions = NewPromotionCollection(factory.db)
+	}
+
+	return factory.promotions
+}
+
 // ChecksumCollection returns (or creates) new ChecksumCollection
 func (factory *CollectionFactory) ChecksumCollection(db database.ReaderWriter) aptly.ChecksumStorage {
 	factory.Lock()
@@ -118,4 +170,8 @@ func (factory *CollectionFactory) Flush() {
 	factory.publishedRepos = nil
 	factory.packages = nil
 	factory.checksums = nil
+	factory.mirrorHistory = nil
+	factory.packageAges = nil
+	factory.incomingAudit = nil
+	factory.promotions = nil
 }
@@ -149,14 +149,108 @@ func GetControlFileFromDsc(dscFile string, verifier pgp.Verifier) (Stanza, error
 
 // GetContentsFromDeb returns list of files installed by .deb package
 func GetContentsFromDeb(file io.Reader, packageFile string) ([]string, error) {
+	var results []string
+
+	err := walkDataTar(file, packageFile, func(tarHeader *tar.Header, _ *tar.Reader) (bool, error) {
+		if tarHeader.Typeflag == tar.TypeDir {
+			return false, nil
+		}
+
+		results = append(results, strings.TrimPrefix(tarHeader.Name[2:], "./"))
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetChangelogFromDeb returns changelog (changelog.Debian.gz or changelog.gz from
+// /usr/share/doc/<name>) shipped in .deb package, or nil if package has no changelog
+func GetChangelogFromDeb(file io.Reader, packageFile string, name string) ([]byte, error) {
+	var changelog []byte
+
+	candidates := []string{
+		"usr/share/doc/" + name + "/changelog.Debian.gz",
+		"usr/share/doc/" + name + "/changelog.gz",
+	}
+
+	err := walkDataTar(file, packageFile, func(tarHeader *tar.Header, untar *tar.Reader) (bool, error) {
+		if tarHeader.Typeflag != tar.TypeReg {
+			return false, nil
+		}
+
+		path := strings.TrimPrefix(strings.TrimPrefix(tarHeader.Name, "."), "/")
+		if path != candidates[0] && (path != candidates[1] || changelog != nil) {
+			return false, nil
+		}
+
+		ungzip, err := gzip.NewReader(untar)
+		if err != nil {
+			return true, errors.Wrapf(err, "unable to ungzip %s from %s", path, packageFile)
+		}
+		defer func() { _ = ungzip.Close() }()
+
+		changelog, err = io.ReadAll(ungzip)
+		if err != nil {
+			return true, errors.Wrapf(err, "unable to read %s from %s", path, packageFile)
+		}
+
+		// changelog.Debian.gz takes precedence over changelog.gz
+		return path == candidates[0], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changelog, nil
+}
+
+// GetCopyrightFromDeb returns copyright file (/usr/share/doc/<name>/copyright or, if missing,
+// first /usr/share/doc/*/copyright) shipped in .deb package, or nil if package has no copyright file
+func GetCopyrightFromDeb(file io.Reader, packageFile string, name string) ([]byte, error) {
+	var copyright []byte
+
+	preferred := "usr/share/doc/" + name + "/copyright"
+
+	err := walkDataTar(file, packageFile, func(tarHeader *tar.Header, untar *tar.Reader) (bool, error) {
+		if tarHeader.Typeflag != tar.TypeReg {
+			return false, nil
+		}
+
+		path := strings.TrimPrefix(strings.TrimPrefix(tarHeader.Name, "."), "/")
+		if path != preferred && (copyright != nil || !strings.HasPrefix(path, "usr/share/doc/") ||
+			strings.Count(path, "/") != 4 || !strings.HasSuffix(path, "/copyright")) {
+			return false, nil
+		}
+
+		data, err := io.ReadAll(untar)
+		if err != nil {
+			return true, errors.Wrapf(err, "unable to read %s from %s", path, packageFile)
+		}
+		copyright = data
+
+		// copyright of the package itself takes precedence
+		return path == preferred, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return copyright, nil
+}
+
+// walkDataTar calls fn for every entry of data.tar.* part of .deb package, until fn asks to stop
+func walkDataTar(file io.Reader, packageFile string, fn func(tarHeader *tar.Header, untar *tar.Reader) (stop bool, err error)) error {
 	library := ar.NewReader(file)
 	for {
 		header, err := library.Next()
 		if err == io.EOF {
-			return nil, fmt.Errorf("unable to find data.tar.* part in %s", packageFile)
+			return fmt.Errorf("unable to find data.tar.* part in %s", packageFile)
 		}
 		if err != nil {
-			return nil, errors.Wrapf(err, "unable to read .deb archive from %s", packageFile)
+			return errors.Wrapf(err, "unable to read .deb archive from %s", packageFile)
 		}
 
 		if strings.HasPrefix(header.Name, "data.tar") {
@@ -179,7 +273,7 @@ func GetContentsFromDeb(file io.Reader, packageFile string) ([]string, error) {
 				} else {
 					ungzip, err := gzip.NewReader(bufReader)
 					if err != nil {
-						return nil, errors.Wrapf(err, "unable to ungzip data.tar.gz from %s", packageFile)
+						return errors.Wrapf(err, "unable to ungzip data.tar.gz from %s", packageFile)
 					}
 					defer func() { _ = ungzip.Close() }()
 					tarInput = ungzip
@@ -189,7 +283,7 @@ func GetContentsFromDeb(file io.Reader, packageFile string) ([]string, error) {
 			case "data.tar.xz":
 				unxz, err := xz.NewReader(bufReader)
 				if err != nil {
-					return nil, errors.Wrapf(err, "unable to unxz data.tar.xz from %s", packageFile)
+					return errors.Wrapf(err, "unable to unxz data.tar.xz from %s", packageFile)
 				}
 				defer func() { _ = unxz.Close() }()
 				tarInput = unxz
@@ -200,31 +294,28 @@ func GetContentsFromDeb(file io.Reader, packageFile string) ([]string, error) {
 			case "data.tar.zst":
 				unzstd, err := zstd.NewReader(bufReader)
 				if err != nil {
-					return nil, errors.Wrapf(err, "unable to unzstd %s from %s", header.Name, packageFile)
+					return errors.Wrapf(err, "unable to unzstd %s from %s", header.Name, packageFile)
 				}
 				defer unzstd.Close()
 				tarInput = unzstd
 			default:
-				return nil, fmt.Errorf("unsupported tar compression in %s: %s", packageFile, header.Name)
+				return fmt.Errorf("unsupported tar compression in %s: %s", packageFile, header.Name)
 			}
 
 			untar := tar.NewReader(tarInput)
-			var results []string
 			for {
 				tarHeader, err := untar.Next()
 				if err == io.EOF {
-					return results, nil
+					return nil
 				}
 				if err != nil {
-					return nil, errors.Wrapf(err, "unable to read .tar archive from %s", packageFile)
+					return errors.Wrapf(err, "unable to read .tar archive from %s", packageFile)
 				}
 
-				if tarHeader.Typeflag == tar.TypeDir {
-					continue
+				stop, err := fn(tarHeader, untar)
+				if err != nil || stop {
+					return err
 				}
-
-				tarHeader.Name = strings.TrimPrefix(tarHeader.Name[2:], "./")
-				results = append(results, tarHeader.Name)
 			}
 		}
 	}
@@ -94,3 +94,36 @@ func (s *DebSuite) TestGetContentsFromDeb(c *C) {
 		"usr/share/doc/hardlink/changelog.gz", "usr/share/doc/hardlink/copyright", "usr/share/doc/hardlink/NEWS.Debian.gz"})
 	c.Assert(f.Close(), IsNil)
 }
+
+func (s *DebSuite) TestGetChangelogFromDeb(c *C) {
+	f, err := os.Open(s.debFile2)
+	c.Assert(err, IsNil)
+	changelog, err := GetChangelogFromDeb(f, s.debFile2, "hardlink")
+	c.Check(err, IsNil)
+	c.Check(string(changelog), Matches, "(?s)^hardlink \\(0.2.1\\) unstable;.*")
+	c.Assert(f.Close(), IsNil)
+
+	f, err = os.Open(s.debFile2)
+	c.Assert(err, IsNil)
+	changelog, err = GetChangelogFromDeb(f, s.debFile2, "other")
+	c.Check(err, IsNil)
+	c.Check(changelog, IsNil)
+	c.Assert(f.Close(), IsNil)
+}
+
+func (s *DebSuite) TestGetCopyrightFromDeb(c *C) {
+	f, err := os.Open(s.debFile2)
+	c.Assert(err, IsNil)
+	copyright, err := GetCopyrightFromDeb(f, s.debFile2, "hardlink")
+	c.Check(err, IsNil)
+	c.Check(string(copyright), Matches, "(?s)^Format: .*License: Expat.*")
+	c.Assert(f.Close(), IsNil)
+
+	// copyright of other package is used if package doesn't have its own
+	f, err = os.Open(s.debFile2)
+	c.Assert(err, IsNil)
+	other, err := GetCopyrightFromDeb(f, s.debFile2, "other")
+	c.Check(err, IsNil)
+	c.Check(other, DeepEquals, copyright)
+	c.Assert(f.Close(), IsNil)
+}
@@ -0,0 +1,299 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	ar "github.com/mkrautz/goar"
+	"github.com/pkg/errors"
+
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// debSignaturePrefix is a prefix of ar member names holding dpkg-sig signatures
+const debSignaturePrefix = "_gpg"
+
+// DebSignature is a valid signature embedded into .deb file in dpkg-sig format
+type DebSignature struct {
+	// Role of the signature, e.g. builder
+	Role string
+	// Signer and Date fields of signed message
+	Signer, Date string
+	// Key IDs which made the signature
+	Keys []pgp.Key
+}
+
+// debMemberDigest is a digest of ar member as listed in dpkg-sig signed message
+type debMemberDigest struct {
+	MD5, SHA1 string
+	Size      int64
+}
+
+// String returns digest in dpkg-sig format
+func (digest debMemberDigest) String() string {
+	return fmt.Sprintf("%s %s %d", digest.MD5, digest.SHA1, digest.Size)
+}
+
+// readDebArchive walks over ar members of .deb file, calculating digests of regular members and
+// collecting contents of signature members, every member is passed to cb (if not nil)
+func readDebArchive(r io.Reader, packageFile string, cb func(header *ar.Header, contents io.Reader) error) (
+	names []string, digests map[string]debMemberDigest, signatures map[string][]byte, err error) {
+	digests = map[string]debMemberDigest{}
+	signatures = map[string][]byte{}
+
+	library := ar.NewReader(r)
+	for {
+		var header *ar.Header
+
+		header, err = library.Next()
+		if err == io.EOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			err = errors.Wrapf(err, "unable to read .deb archive from %s", packageFile)
+			return
+		}
+
+		name := strings.TrimSuffix(header.Name, "/")
+
+		if strings.HasPrefix(name, debSignaturePrefix) {
+			var contents []byte
+			contents, err = io.ReadAll(library)
+			if err != nil {
+				err = errors.Wrapf(err, "unable to read %s from %s", name, packageFile)
+				return
+			}
+			signatures[strings.TrimPrefix(name, debSignaturePrefix)] = contents
+
+			if cb != nil {
+				if err = cb(header, bytes.NewReader(contents)); err != nil {
+					return
+				}
+			}
+			continue
+		}
+
+		checksums := utils.NewChecksumWriter()
+		contents := io.TeeReader(library, checksums)
+
+		if cb != nil {
+			if err = cb(header, contents); err != nil {
+				return
+			}
+		}
+
+		// consume the rest of the member to calculate checksums
+		if _, err = io.Copy(io.Discard, contents); err != nil {
+			err = errors.Wrapf(err, "unable to read %s from %s", name, packageFile)
+			return
+		}
+
+		sum := checksums.Sum()
+		names = append(names, name)
+		digests[name] = debMemberDigest{MD5: sum.MD5, SHA1: sum.SHA1, Size: sum.Size}
+	}
+}
+
+// parseDebSignedMessage parses fields of dpkg-sig signed message
+func parseDebSignedMessage(text io.Reader) (fields map[string]string, files map[string]debMemberDigest, err error) {
+	fields = map[string]string{}
+	files = map[string]debMemberDigest{}
+
+	scanner := bufio.NewScanner(text)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			parts := strings.Fields(line)
+			if len(parts) != 4 {
+				return nil, nil, fmt.Errorf("malformed file entry %#v", strings.TrimSpace(line))
+			}
+
+			size, err := strconv.ParseInt(parts[2], 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("malformed file entry %#v: %s", strings.TrimSpace(line), err)
+			}
+
+			files[parts[3]] = debMemberDigest{MD5: parts[0], SHA1: parts[1], Size: size}
+			continue
+		}
+
+		if name, value, found := strings.Cut(line, ":"); found {
+			fields[name] = strings.TrimSpace(value)
+		}
+	}
+
+	return fields, files, scanner.Err()
+}
+
+// VerifyDebSignatures verifies dpkg-sig signatures embedded into .deb file
+//
+// All the valid signatures are returned, if any of the signatures is not valid or doesn't
+// match contents of the package, error is returned.
+func VerifyDebSignatures(r io.Reader, packageFile string, verifier pgp.Verifier) ([]*DebSignature, error) {
+	names, digests, signatures, err := readDebArchive(r, packageFile, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*DebSignature{}
+
+	for role, contents := range signatures {
+		keyInfo, err := verifier.VerifyClearsigned(bytes.NewReader(contents), false)
+		if err != nil {
+			return nil, fmt.Errorf("signature %s of %s is not valid: %s", role, packageFile, err)
+		}
+		if len(keyInfo.GoodKeys) == 0 {
+			return nil, fmt.Errorf("signature %s of %s is not made by trusted key", role, packageFile)
+		}
+
+		text, err := verifier.ExtractClearsigned(bytes.NewReader(contents))
+		if err != nil {
+			return nil, fmt.Errorf("unable to extract signed message %s from %s: %s", role, packageFile, err)
+		}
+
+		fields, files, err := parseDebSignedMessage(text)
+		_ = text.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse signed message %s from %s: %s", role, packageFile, err)
+		}
+
+		if fields["Role"] != role {
+			return nil, fmt.Errorf("signature %s of %s has mismatched role %#v", role, packageFile, fields["Role"])
+		}
+
+		if len(files) != len(names) {
+			return nil, fmt.Errorf("signature %s of %s doesn't cover all the package contents", role, packageFile)
+		}
+
+		for _, name := range names {
+			if files[name] != digests[name] {
+				return nil, fmt.Errorf("signature %s of %s doesn't match %s", role, packageFile, name)
+			}
+		}
+
+		result = append(result, &DebSignature{
+			Role:   role,
+			Signer: fields["Signer"],
+			Date:   fields["Date"],
+			Keys:   keyInfo.GoodKeys,
+		})
+	}
+
+	return result, nil
+}
+
+// writeDebMember writes ar member, padding data to even size
+//
+// ar.Writer doesn't track data written, so padding is done here.
+func writeDebMember(output io.Writer, writer *ar.Writer, header *ar.Header, contents io.Reader) error {
+	if err := writer.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(writer, contents); err != nil {
+		return err
+	}
+
+	if header.Size%2 != 0 {
+		_, err := output.Write([]byte{'\n'})
+		return err
+	}
+
+	return nil
+}
+
+// SignDeb adds dpkg-sig signature with specified role to .deb file, replacing
+// signature of the same role if it exists
+func SignDeb(path string, role string, signer pgp.Signer, now time.Time) error {
+	if role == "" || strings.ContainsAny(role, "/ \t\n") {
+		return fmt.Errorf("invalid signature role %#v", role)
+	}
+
+	tempDir, err := os.MkdirTemp("", "aptly-debsig")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	input, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = input.Close() }()
+
+	output, err := os.CreateTemp(filepath.Dir(path), ".aptly-debsig")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(output.Name()) }()
+
+	writer := ar.NewWriter(output)
+
+	var lastHeader ar.Header
+
+	names, digests, _, err := readDebArchive(input, path, func(header *ar.Header, contents io.Reader) error {
+		lastHeader = *header
+
+		if strings.TrimSuffix(header.Name, "/") == debSignaturePrefix+role {
+			return nil
+		}
+
+		return writeDebMember(output, writer, header, contents)
+	})
+	if err != nil {
+		_ = output.Close()
+		return err
+	}
+
+	message := &bytes.Buffer{}
+	fmt.Fprintf(message, "Version: 4\nSigner: aptly\nDate: %s\nRole: %s\nFiles: \n", now.UTC().Format(time.ANSIC), role)
+	for _, name := range names {
+		fmt.Fprintf(message, "\t%s %s\n", digests[name], name)
+	}
+
+	messageFile := filepath.Join(tempDir, "message")
+	if err = os.WriteFile(messageFile, message.Bytes(), 0600); err != nil {
+		_ = output.Close()
+		return err
+	}
+
+	if err = signer.ClearSign(messageFile, messageFile+".asc"); err != nil {
+		_ = output.Close()
+		return fmt.Errorf("unable to sign %s: %s", path, err)
+	}
+
+	signature, err := os.ReadFile(messageFile + ".asc")
+	if err != nil {
+		_ = output.Close()
+		return err
+	}
+
+	err = writeDebMember(output, writer, &ar.Header{
+		Name:  debSignaturePrefix + role,
+		Mode:  0100644,
+		Size:  int64(len(signature)),
+		Mtime: now.Unix(),
+		Uid:   lastHeader.Uid,
+		Gid:   lastHeader.Gid,
+	}, bytes.NewReader(signature))
+	if err != nil {
+		_ = output.Close()
+		return fmt.Errorf("unable to write %s: %s", path, err)
+	}
+
+	if err = output.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(output.Name(), path)
+}
@@ -0,0 +1,133 @@
+package deb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type DebSigSuite struct {
+	debFile  string
+	signer   pgp.Signer
+	verifier pgp.Verifier
+	now      time.Time
+}
+
+var _ = Suite(&DebSigSuite{})
+
+func (s *DebSigSuite) SetUpTest(c *C) {
+	s.debFile = filepath.Join(c.MkDir(), "hardlink_0.2.1_amd64.deb")
+	c.Assert(utils.CopyFile("testdata/changes/hardlink_0.2.1_amd64.deb", s.debFile), IsNil)
+
+	s.signer = &pgp.GoSigner{}
+	s.signer.SetBatch(true)
+	s.signer.SetKey("21DBB89C16DB3E6D")
+	s.signer.SetKeyRing("../system/files/aptly.pub", "../system/files/aptly.sec")
+	c.Assert(s.signer.Init(), IsNil)
+
+	s.verifier = &pgp.GoVerifier{}
+	s.verifier.AddKeyring("../system/files/aptly.pub")
+	c.Assert(s.verifier.InitKeyring(false), IsNil)
+
+	s.now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func (s *DebSigSuite) verify(c *C, verifier pgp.Verifier) ([]*DebSignature, error) {
+	f, err := os.Open(s.debFile)
+	c.Assert(err, IsNil)
+	defer func() { _ = f.Close() }()
+
+	return VerifyDebSignatures(f, filepath.Base(s.debFile), verifier)
+}
+
+func (s *DebSigSuite) TestUnsigned(c *C) {
+	signatures, err := s.verify(c, s.verifier)
+	c.Check(err, IsNil)
+	c.Check(signatures, HasLen, 0)
+}
+
+func (s *DebSigSuite) TestSignAndVerify(c *C) {
+	c.Assert(SignDeb(s.debFile, "builder", s.signer, s.now), IsNil)
+
+	signatures, err := s.verify(c, s.verifier)
+	c.Assert(err, IsNil)
+	c.Assert(signatures, HasLen, 1)
+	c.Check(signatures[0].Role, Equals, "builder")
+	c.Check(signatures[0].Date, Equals, "Fri Mar  1 12:00:00 2024")
+	c.Check(signatures[0].Keys, DeepEquals, []pgp.Key{"21DBB89C16DB3E6D"})
+
+	// package is still readable
+	stanza, err := GetControlFileFromDeb(s.debFile)
+	c.Assert(err, IsNil)
+	c.Check(stanza["Package"], Equals, "hardlink")
+
+	// signing again with the same role replaces signature
+	c.Assert(SignDeb(s.debFile, "builder", s.signer, s.now), IsNil)
+	c.Assert(SignDeb(s.debFile, "qa", s.signer, s.now), IsNil)
+
+	signatures, err = s.verify(c, s.verifier)
+	c.Assert(err, IsNil)
+	c.Check(signatures, HasLen, 2)
+
+	c.Check(SignDeb(s.debFile, "", s.signer, s.now), ErrorMatches, "invalid signature role \"\"")
+}
+
+func (s *DebSigSuite) TestUntrustedKey(c *C) {
+	c.Assert(SignDeb(s.debFile, "builder", s.signer, s.now), IsNil)
+
+	verifier := &pgp.GoVerifier{}
+	verifier.AddKeyring("../system/files/aptly_passphrase.pub")
+	c.Assert(verifier.InitKeyring(false), IsNil)
+
+	_, err := s.verify(c, verifier)
+	c.Check(err, ErrorMatches, "signature builder of hardlink_0.2.1_amd64.deb is not valid: .*")
+}
+
+func (s *DebSigSuite) TestParseDebSignedMessage(c *C) {
+	fields, files, err := parseDebSignedMessage(strings.NewReader("Version: 4\nRole: builder\nFiles: \n\tabc def 4 debian-binary\n"))
+	c.Assert(err, IsNil)
+	c.Check(fields["Role"], Equals, "builder")
+	c.Check(files, DeepEquals, map[string]debMemberDigest{"debian-binary": {MD5: "abc", SHA1: "def", Size: 4}})
+
+	_, _, err = parseDebSignedMessage(strings.NewReader("Files: \n\tabc def debian-binary\n"))
+	c.Check(err, ErrorMatches, "malformed file entry \"abc def debian-binary\"")
+}
+
+func (s *DebSigSuite) TestValidateSignature(c *C) {
+	validation, err := NewValidation([]string{"signature"}, nil, 0, []string{"../system/files/aptly.pub"}, true)
+	c.Assert(err, IsNil)
+
+	_, err = validation.Prepare(nil)
+	c.Check(err, ErrorMatches, "verifying package signatures is not supported")
+
+	validation, err = validation.Prepare(func(keyrings []string) (pgp.Verifier, error) {
+		c.Check(keyrings, DeepEquals, []string{"../system/files/aptly.pub"})
+		return s.verifier, nil
+	})
+	c.Assert(err, IsNil)
+
+	stanza, err := GetControlFileFromDeb(s.debFile)
+	c.Assert(err, IsNil)
+	p := NewPackageFromControlFile(stanza)
+
+	file, err := NewValidatedFile(s.debFile)
+	c.Assert(err, IsNil)
+
+	errs := validation.Validate(p, file)
+	c.Assert(errs, HasLen, 1)
+	c.Check(errs[0], ErrorMatches, "signature: package hardlink_0.2.1_amd64.deb is not signed")
+
+	c.Assert(SignDeb(s.debFile, "builder", s.signer, s.now), IsNil)
+	file, err = NewValidatedFile(s.debFile)
+	c.Assert(err, IsNil)
+	c.Check(validation.Validate(p, file), HasLen, 0)
+
+	_, err = NewValidation(nil, nil, 0, nil, true)
+	c.Check(err, ErrorMatches, "requiring signature needs validator \"signature\" to be enabled")
+}
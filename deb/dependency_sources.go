@@ -0,0 +1,230 @@
+package deb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+)
+
+// errDependencySourceMissing is returned when dependency source mirror or snapshot was dropped
+var errDependencySourceMissing = errors.New("dependency source is missing")
+
+// DependencySource is a mirror or snapshot used to resolve dependencies which
+// are missing in the filtered mirror
+type DependencySource struct {
+	// Kind of the source: SourceRemoteRepo or SourceSnapshot
+	Kind string
+	// UUID of the mirror or snapshot
+	UUID string
+}
+
+// ParseDependencySource resolves dependency source specified as mirror:<name> or snapshot:<name>
+func ParseDependencySource(spec string, collectionFactory *CollectionFactory) (DependencySource, error) {
+	kind, name, found := strings.Cut(spec, ":")
+	if !found || name == "" {
+		return DependencySource{}, fmt.Errorf("wrong dependency source %#v, expected mirror:<name> or snapshot:<name>", spec)
+	}
+
+	switch kind {
+	case "mirror":
+		repo, err := collectionFactory.RemoteRepoCollection().ByName(name)
+		if err != nil {
+			return DependencySource{}, err
+		}
+		return DependencySource{Kind: SourceRemoteRepo, UUID: repo.UUID}, nil
+	case "snapshot":
+		snapshot, err := collectionFactory.SnapshotCollection().ByName(name)
+		if err != nil {
+			return DependencySource{}, err
+		}
+		return DependencySource{Kind: SourceSnapshot, UUID: snapshot.UUID}, nil
+	}
+
+	return DependencySource{}, fmt.Errorf("wrong dependency source kind %#v, expected mirror or snapshot", kind)
+}
+
+// SetDependencySources resolves and sets dependency sources of the mirror, empty list
+// (or list with single empty value) clears dependency sources
+func (repo *RemoteRepo) SetDependencySources(specs []string, collectionFactory *CollectionFactory) error {
+	if len(specs) == 0 || (len(specs) == 1 && specs[0] == "") {
+		repo.DependencySources = nil
+		return nil
+	}
+
+	sources := make([]DependencySource, len(specs))
+	for i, spec := range specs {
+		source, err := ParseDependencySource(spec, collectionFactory)
+		if err != nil {
+			return err
+		}
+		if source.UUID == repo.UUID {
+			return fmt.Errorf("mirror %s can't be dependency source of itself", repo.Name)
+		}
+		sources[i] = source
+	}
+
+	repo.DependencySources = sources
+	return nil
+}
+
+// exists checks whether mirror or snapshot referenced by the dependency source is still present
+func (source DependencySource) exists(collectionFactory *CollectionFactory) (bool, error) {
+	var key []byte
+
+	switch source.Kind {
+	case SourceRemoteRepo:
+		key = (&RemoteRepo{UUID: source.UUID}).Key()
+	case SourceSnapshot:
+		key = (&Snapshot{UUID: source.UUID}).Key()
+	default:
+		return false, fmt.Errorf("unknown dependency source kind %#v", source.Kind)
+	}
+
+	_, err := collectionFactory.db.Get(key)
+	if err == database.ErrNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// load returns description and package list of the dependency source,
+// errDependencySourceMissing is returned if the source was dropped
+func (source DependencySource) load(collectionFactory *CollectionFactory, progress aptly.Progress) (string, *PackageList, error) {
+	var (
+		description string
+		refList     *PackageRefList
+	)
+
+	exists, err := source.exists(collectionFactory)
+	if err != nil {
+		return "", nil, err
+	}
+	if !exists {
+		return "", nil, errDependencySourceMissing
+	}
+
+	switch source.Kind {
+	case SourceRemoteRepo:
+		repo, err := collectionFactory.RemoteRepoCollection().ByUUID(source.UUID)
+		if err != nil {
+			return "", nil, fmt.Errorf("dependency source: %s", err)
+		}
+		err = collectionFactory.RemoteRepoCollection().LoadComplete(repo)
+		if err != nil {
+			return "", nil, err
+		}
+		description, refList = repo.String(), repo.RefList()
+	case SourceSnapshot:
+		snapshot, err := collectionFactory.SnapshotCollection().ByUUID(source.UUID)
+		if err != nil {
+			return "", nil, fmt.Errorf("dependency source: %s", err)
+		}
+		err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+		if err != nil {
+			return "", nil, err
+		}
+		description, refList = snapshot.String(), snapshot.RefList()
+	default:
+		return "", nil, fmt.Errorf("unknown dependency source kind %#v", source.Kind)
+	}
+
+	list, err := NewPackageListFromRefList(refList, collectionFactory.PackageCollection(), progress)
+	if err != nil {
+		return "", nil, err
+	}
+	list.PrepareIndex()
+
+	return description, list, nil
+}
+
+// Describe returns human-readable description of the dependency source
+func (source DependencySource) Describe(collectionFactory *CollectionFactory) string {
+	switch source.Kind {
+	case SourceRemoteRepo:
+		if repo, err := collectionFactory.RemoteRepoCollection().ByUUID(source.UUID); err == nil {
+			return "mirror:" + repo.Name
+		}
+	case SourceSnapshot:
+		if snapshot, err := collectionFactory.SnapshotCollection().ByUUID(source.UUID); err == nil {
+			return "snapshot:" + snapshot.Name
+		}
+	}
+
+	return fmt.Sprintf("%s:%s (missing)", source.Kind, source.UUID)
+}
+
+// ExternalDependency is a dependency of filtered mirror satisfied by the dependency source
+type ExternalDependency struct {
+	// Dependency which is missing in the mirror
+	Dependency Dependency
+	// Package satisfying the dependency
+	Package *Package
+	// Description of the dependency source
+	Source string
+}
+
+// String returns human-readable description of the external dependency
+func (dep ExternalDependency) String() string {
+	return fmt.Sprintf("%s: %s from %s", dep.Dependency.String(), dep.Package, dep.Source)
+}
+
+// ResolveExternalDependencies looks up dependencies missing in the filtered mirror in dependency sources
+//
+// Should be called after ApplyFilter, dependencies which can't be satisfied by any
+// dependency source are returned as unresolved. Resolution is not transitive: dependencies
+// of packages found in dependency sources are not looked up. Dependency sources which were
+// dropped are reported and skipped.
+func (repo *RemoteRepo) ResolveExternalDependencies(dependencyOptions int, collectionFactory *CollectionFactory,
+	progress aptly.Progress) (external []ExternalDependency, unresolved []Dependency, err error) {
+	if repo.packageList == nil {
+		return nil, nil, fmt.Errorf("package list is empty, please (re)download package indexes")
+	}
+
+	missing, err := repo.packageList.VerifyDependencies(dependencyOptions, repo.Architectures, repo.packageList, progress)
+	if err != nil || len(missing) == 0 {
+		return nil, nil, err
+	}
+
+	sort.Slice(missing, func(i, j int) bool { return missing[i].String() < missing[j].String() })
+
+	descriptions := make([]string, 0, len(repo.DependencySources))
+	lists := make([]*PackageList, 0, len(repo.DependencySources))
+	for _, source := range repo.DependencySources {
+		description, list, e := source.load(collectionFactory, progress)
+		if e == errDependencySourceMissing {
+			if progress != nil {
+				progress.ColoredPrintf("@y[!]@| @!Dependency source %s was dropped, skipping@|", source.Describe(collectionFactory))
+			}
+			continue
+		}
+		if e != nil {
+			return nil, nil, e
+		}
+
+		descriptions = append(descriptions, description)
+		lists = append(lists, list)
+	}
+
+	for _, dep := range missing {
+		satisfied := false
+
+		for i := range lists {
+			if p := lists[i].Search(dep, false, true); p != nil {
+				external = append(external, ExternalDependency{Dependency: dep, Package: p[0], Source: descriptions[i]})
+				satisfied = true
+				break
+			}
+		}
+
+		if !satisfied {
+			unresolved = append(unresolved, dep)
+		}
+	}
+
+	return external, unresolved, nil
+}
@@ -0,0 +1,144 @@
+package deb
+
+import (
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type DependencySourcesSuite struct {
+	db                database.Storage
+	collectionFactory *CollectionFactory
+	repo              *RemoteRepo
+	main              *RemoteRepo
+	snapshot          *Snapshot
+}
+
+var _ = Suite(&DependencySourcesSuite{})
+
+func dependencySourcePackage(name, version, depends, provides string) *Package {
+	stanza := packageStanza.Copy()
+	stanza["Package"] = name
+	stanza["Version"] = version
+	stanza["Depends"] = depends
+	stanza["Provides"] = provides
+	delete(stanza, "Pre-Depends")
+	delete(stanza, "Suggests")
+	delete(stanza, "Recommends")
+	stanza["Filename"] = "pool/main/" + name + "_" + version + "_i386.deb"
+
+	return NewPackageFromControlFile(stanza)
+}
+
+func (s *DependencySourcesSuite) savePackages(c *C, packages ...*Package) *PackageList {
+	list := NewPackageList()
+	for _, p := range packages {
+		c.Assert(s.collectionFactory.PackageCollection().Update(p), IsNil)
+		c.Assert(list.Add(p), IsNil)
+	}
+
+	return list
+}
+
+func (s *DependencySourcesSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collectionFactory = NewCollectionFactory(s.db)
+
+	s.main, _ = NewRemoteRepo("main", "http://mirror.yandex.ru/debian", "bookworm", []string{"main"}, []string{"i386"}, false, false, false, false)
+	s.main.packageRefs = NewPackageRefListFromPackageList(s.savePackages(c,
+		dependencySourcePackage("libc6", "2.36-9", "", ""),
+		dependencySourcePackage("libssl3", "3.0.11-1", "libc6 (>= 2.34)", "")))
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Add(s.main), IsNil)
+
+	s.snapshot = NewSnapshotFromPackageList("extras", nil, s.savePackages(c,
+		dependencySourcePackage("postfix", "3.7.6-0", "libc6", "mail-transport-agent")), "Snapshot from package list")
+	c.Assert(s.collectionFactory.SnapshotCollection().Add(s.snapshot), IsNil)
+
+	s.repo, _ = NewRemoteRepo("backports", "http://mirror.yandex.ru/debian", "bookworm-backports", []string{"main"}, []string{"i386"}, false, false, false, false)
+	s.repo.FilterWithDeps = true
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Add(s.repo), IsNil)
+
+	s.repo.packageList = s.savePackages(c,
+		dependencySourcePackage("app", "1.0~bpo12", "libapp (= 1.0~bpo12), libssl3 (>= 3.0), mail-transport-agent, unknown-lib", ""),
+		dependencySourcePackage("libapp", "1.0~bpo12", "libc6 (>= 2.36)", ""))
+}
+
+func (s *DependencySourcesSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *DependencySourcesSuite) TestParseDependencySource(c *C) {
+	source, err := ParseDependencySource("mirror:main", s.collectionFactory)
+	c.Assert(err, IsNil)
+	c.Check(source, Equals, DependencySource{Kind: SourceRemoteRepo, UUID: s.main.UUID})
+	c.Check(source.Describe(s.collectionFactory), Equals, "mirror:main")
+
+	source, err = ParseDependencySource("snapshot:extras", s.collectionFactory)
+	c.Assert(err, IsNil)
+	c.Check(source, Equals, DependencySource{Kind: SourceSnapshot, UUID: s.snapshot.UUID})
+	c.Check(source.Describe(s.collectionFactory), Equals, "snapshot:extras")
+
+	_, err = ParseDependencySource("mirror:none", s.collectionFactory)
+	c.Check(err, ErrorMatches, "mirror with name none not found")
+
+	_, err = ParseDependencySource("repo:main", s.collectionFactory)
+	c.Check(err, ErrorMatches, "wrong dependency source kind \"repo\".*")
+
+	_, err = ParseDependencySource("main", s.collectionFactory)
+	c.Check(err, ErrorMatches, "wrong dependency source \"main\".*")
+
+	c.Check(DependencySource{Kind: SourceSnapshot, UUID: "abcd"}.Describe(s.collectionFactory), Equals, "snapshot:abcd (missing)")
+}
+
+func (s *DependencySourcesSuite) TestSetDependencySources(c *C) {
+	c.Assert(s.repo.SetDependencySources([]string{"mirror:main", "snapshot:extras"}, s.collectionFactory), IsNil)
+	c.Check(s.repo.DependencySources, DeepEquals, []DependencySource{
+		{Kind: SourceRemoteRepo, UUID: s.main.UUID},
+		{Kind: SourceSnapshot, UUID: s.snapshot.UUID},
+	})
+
+	c.Check(s.repo.SetDependencySources([]string{"mirror:backports"}, s.collectionFactory), ErrorMatches,
+		"mirror backports can't be dependency source of itself")
+	c.Check(s.repo.DependencySources, HasLen, 2)
+
+	c.Assert(s.repo.SetDependencySources([]string{""}, s.collectionFactory), IsNil)
+	c.Check(s.repo.DependencySources, IsNil)
+}
+
+func (s *DependencySourcesSuite) TestResolveExternalDependencies(c *C) {
+	c.Assert(s.repo.SetDependencySources([]string{"mirror:main", "snapshot:extras"}, s.collectionFactory), IsNil)
+
+	external, unresolved, err := s.repo.ResolveExternalDependencies(0, s.collectionFactory, nil)
+	c.Assert(err, IsNil)
+
+	result := []string{}
+	for _, dep := range external {
+		result = append(result, dep.String())
+	}
+	c.Check(result, DeepEquals, []string{
+		"libc6 (>= 2.36) [i386]: libc6_2.36-9_i386 from [main]: http://mirror.yandex.ru/debian/ bookworm",
+		"libssl3 (>= 3.0) [i386]: libssl3_3.0.11-1_i386 from [main]: http://mirror.yandex.ru/debian/ bookworm",
+		"mail-transport-agent [i386]: postfix_3.7.6-0_i386 from [extras]: Snapshot from package list",
+	})
+
+	c.Assert(unresolved, HasLen, 1)
+	c.Check(unresolved[0].String(), Equals, "unknown-lib [i386]")
+}
+
+func (s *DependencySourcesSuite) TestResolveExternalDependenciesNoPackageList(c *C) {
+	s.repo.packageList = nil
+
+	_, _, err := s.repo.ResolveExternalDependencies(0, s.collectionFactory, nil)
+	c.Check(err, ErrorMatches, "package list is empty.*")
+}
+
+func (s *DependencySourcesSuite) TestResolveExternalDependenciesDroppedSource(c *C) {
+	c.Assert(s.repo.SetDependencySources([]string{"mirror:main", "snapshot:extras"}, s.collectionFactory), IsNil)
+	c.Assert(s.collectionFactory.SnapshotCollection().Drop(s.snapshot), IsNil)
+
+	external, unresolved, err := s.repo.ResolveExternalDependencies(0, s.collectionFactory, nil)
+	c.Assert(err, IsNil)
+	c.Check(external, HasLen, 2)
+	c.Check(unresolved, HasLen, 2)
+}
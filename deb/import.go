@@ -70,9 +70,11 @@ func CollectPackageFiles(locations []string, reporter aptly.ResultReporter) (pac
 }
 
 // ImportPackageFiles imports files into local repository
+//
+// Packages failing validation (if not nil) are reported and skipped.
 func ImportPackageFiles(list *PackageList, packageFiles []string, forceReplace bool, verifier pgp.Verifier,
 	pool aptly.PackagePool, collection *PackageCollection, reporter aptly.ResultReporter, restriction PackageQuery,
-	checksumStorageProvider aptly.ChecksumStorageProvider) (processedFiles []string, failedFiles []string, err error) {
+	validation *Validation, checksumStorageProvider aptly.ChecksumStorageProvider) (processedFiles []string, failedFiles []string, err error) {
 	if forceReplace {
 		list.PrepareIndex()
 	}
@@ -130,6 +132,22 @@ func ImportPackageFiles(list *PackageList, packageFiles []string, forceReplace b
 			continue
 		}
 
+		if validation != nil {
+			var validatedFile *ValidatedFile
+			validatedFile, err = NewValidatedFile(file)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if errs := validation.Validate(p, validatedFile); len(errs) > 0 {
+				for _, validationErr := range errs {
+					reporter.Warning("Package %s failed validation: %s", file, validationErr)
+				}
+				failedFiles = append(failedFiles, file)
+				continue
+			}
+		}
+
 		var files PackageFiles
 
 		if isSourcePackage {
@@ -223,6 +241,14 @@ func ImportPackageFiles(list *PackageList, packageFiles []string, forceReplace b
 			continue
 		}
 
+		if !p.IsSource {
+			err = importLicenses(p, file, collection)
+			if err != nil {
+				// licenses would be extracted later from the package pool
+				reporter.Warning("Unable to extract licenses of %s: %s", p, err)
+			}
+		}
+
 		reporter.Added("%s added", p)
 		processedFiles = append(processedFiles, candidateProcessedFiles...)
 	}
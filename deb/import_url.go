@@ -0,0 +1,123 @@
+package deb
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// PackageURL is a location of package file to be downloaded and imported into local repo
+type PackageURL struct {
+	// URL of the file
+	URL string
+	// Expected SHA256 checksum of the file, optional
+	SHA256 string `json:",omitempty"`
+}
+
+// ParsePackageURL parses package URL, expected SHA256 checksum might be
+// specified in URL fragment: <url>#sha256=<checksum>
+func ParsePackageURL(value string) (PackageURL, error) {
+	result := PackageURL{URL: value}
+
+	if location, fragment, found := strings.Cut(value, "#"); found {
+		checksum, ok := strings.CutPrefix(fragment, "sha256=")
+		if !ok {
+			return PackageURL{}, fmt.Errorf("unsupported URL fragment %#v, expected sha256=<checksum>", fragment)
+		}
+
+		result = PackageURL{URL: location, SHA256: checksum}
+	}
+
+	return result, result.Validate()
+}
+
+// Validate checks that URL is absolute and refers to a file, and that checksum is well-formed
+func (pkgURL PackageURL) Validate() error {
+	u, err := url.Parse(pkgURL.URL)
+	if err != nil {
+		return fmt.Errorf("unable to parse URL %#v: %s", pkgURL.URL, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL %#v, expected http or https URL", pkgURL.URL)
+	}
+
+	if name := path.Base(u.Path); name == "." || name == "/" {
+		return fmt.Errorf("URL %#v doesn't refer to a file", pkgURL.URL)
+	}
+
+	if pkgURL.SHA256 != "" {
+		if decoded, err := hex.DecodeString(pkgURL.SHA256); err != nil || len(decoded) != 32 {
+			return fmt.Errorf("malformed SHA256 checksum %#v for %s", pkgURL.SHA256, pkgURL.URL)
+		}
+	}
+
+	return nil
+}
+
+// filename returns name of the file URL refers to
+func (pkgURL PackageURL) filename() string {
+	u, _ := url.Parse(pkgURL.URL)
+	return path.Base(u.Path)
+}
+
+// DownloadPackageURLs downloads package files to the directory, verifying checksums when specified
+//
+// All the files are downloaded into the same directory, so that source packages could be imported
+// along with their files. URLs which failed to download are reported and returned as failed.
+func DownloadPackageURLs(ctx context.Context, downloader aptly.Downloader, urls []PackageURL, destination string,
+	reporter aptly.ResultReporter) (failedURLs []string) {
+	seen := map[string]bool{}
+
+	for _, pkgURL := range urls {
+		err := pkgURL.Validate()
+		if err == nil && seen[pkgURL.filename()] {
+			err = fmt.Errorf("duplicate file name %s", pkgURL.filename())
+		}
+		if err == nil {
+			err = downloadPackageURL(ctx, downloader, pkgURL, filepath.Join(destination, pkgURL.filename()))
+		}
+
+		if err != nil {
+			reporter.Warning("Unable to download %s: %s", pkgURL.URL, err)
+			failedURLs = append(failedURLs, pkgURL.URL)
+			continue
+		}
+
+		seen[pkgURL.filename()] = true
+	}
+
+	return
+}
+
+// downloadPackageURL downloads single file and verifies its checksum
+func downloadPackageURL(ctx context.Context, downloader aptly.Downloader, pkgURL PackageURL, destination string) error {
+	err := downloader.Download(ctx, pkgURL.URL, destination)
+	if err != nil {
+		return err
+	}
+
+	if pkgURL.SHA256 == "" {
+		return nil
+	}
+
+	checksums, err := utils.ChecksumsForFile(destination)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(checksums.SHA256, pkgURL.SHA256) {
+		_ = os.Remove(destination)
+		return fmt.Errorf("sha256 hash mismatch %#v != %#v", checksums.SHA256, strings.ToLower(pkgURL.SHA256))
+	}
+
+	return nil
+}
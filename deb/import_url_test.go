@@ -0,0 +1,85 @@
+package deb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/http"
+
+	. "gopkg.in/check.v1"
+)
+
+type ImportURLSuite struct {
+	downloader *http.FakeDownloader
+	reporter   *aptly.RecordingResultReporter
+	dir        string
+}
+
+var _ = Suite(&ImportURLSuite{})
+
+// sha256 of "xyz"
+const xyzSHA256 = "3608bca1e44ea6c4d268eb6db02260269892c0b42b86bbf1e77a6fa16c3c9282"
+
+func (s *ImportURLSuite) SetUpTest(c *C) {
+	s.downloader = http.NewFakeDownloader()
+	s.reporter = &aptly.RecordingResultReporter{}
+	s.dir = c.MkDir()
+}
+
+func (s *ImportURLSuite) TestParsePackageURL(c *C) {
+	pkgURL, err := ParsePackageURL("https://ci.example.com/app_1.0_amd64.deb")
+	c.Check(err, IsNil)
+	c.Check(pkgURL, Equals, PackageURL{URL: "https://ci.example.com/app_1.0_amd64.deb"})
+
+	pkgURL, err = ParsePackageURL("https://ci.example.com/app_1.0_amd64.deb#sha256=" + xyzSHA256)
+	c.Check(err, IsNil)
+	c.Check(pkgURL, Equals, PackageURL{URL: "https://ci.example.com/app_1.0_amd64.deb", SHA256: xyzSHA256})
+
+	_, err = ParsePackageURL("https://ci.example.com/app_1.0_amd64.deb#md5=abcd")
+	c.Check(err, ErrorMatches, "unsupported URL fragment \"md5=abcd\".*")
+
+	_, err = ParsePackageURL("https://ci.example.com/app_1.0_amd64.deb#sha256=abcd")
+	c.Check(err, ErrorMatches, "malformed SHA256 checksum \"abcd\".*")
+
+	_, err = ParsePackageURL("/tmp/app_1.0_amd64.deb")
+	c.Check(err, ErrorMatches, "unsupported URL \"/tmp/app_1.0_amd64.deb\", expected http or https URL")
+
+	_, err = ParsePackageURL("https://ci.example.com/")
+	c.Check(err, ErrorMatches, "URL \"https://ci.example.com/\" doesn't refer to a file")
+}
+
+func (s *ImportURLSuite) TestDownloadPackageURLs(c *C) {
+	s.downloader.ExpectResponse("https://ci.example.com/app_1.0_amd64.deb", "xyz")
+	s.downloader.ExpectResponse("https://ci.example.com/lib_1.0_amd64.deb", "abc")
+	s.downloader.ExpectError("https://ci.example.com/missing_1.0_amd64.deb", &http.Error{Code: 404})
+	s.downloader.ExpectResponse("https://ci.example.com/tool_1.0_amd64.deb", "xyz")
+
+	failed := DownloadPackageURLs(context.Background(), s.downloader, []PackageURL{
+		{URL: "https://ci.example.com/app_1.0_amd64.deb", SHA256: xyzSHA256},
+		{URL: "https://ci.example.com/lib_1.0_amd64.deb", SHA256: xyzSHA256},
+		{URL: "https://ci.example.com/missing_1.0_amd64.deb"},
+		{URL: "https://ci.example.com/tool_1.0_amd64.deb"},
+		{URL: "https://mirror.example.com/app_1.0_amd64.deb"},
+		{URL: "file:///tmp/app_1.0_amd64.deb"},
+	}, s.dir, s.reporter)
+
+	c.Check(failed, DeepEquals, []string{
+		"https://ci.example.com/lib_1.0_amd64.deb",
+		"https://ci.example.com/missing_1.0_amd64.deb",
+		"https://mirror.example.com/app_1.0_amd64.deb",
+		"file:///tmp/app_1.0_amd64.deb",
+	})
+	c.Check(s.reporter.Warnings, HasLen, 4)
+	c.Check(s.reporter.Warnings[0], Matches, "Unable to download https://ci.example.com/lib_1.0_amd64.deb: sha256 hash mismatch.*")
+	c.Check(s.reporter.Warnings[2], Equals, "Unable to download https://mirror.example.com/app_1.0_amd64.deb: duplicate file name app_1.0_amd64.deb")
+	c.Check(s.downloader.Empty(), Equals, true)
+
+	files, _ := filepath.Glob(filepath.Join(s.dir, "*"))
+	c.Check(files, DeepEquals, []string{filepath.Join(s.dir, "app_1.0_amd64.deb"), filepath.Join(s.dir, "tool_1.0_amd64.deb")})
+
+	content, err := os.ReadFile(filepath.Join(s.dir, "app_1.0_amd64.deb"))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "xyz")
+}
@@ -0,0 +1,407 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// IncomingAuditRecord records outcome of processing single upload from incoming queue
+type IncomingAuditRecord struct {
+	// Sequential number of the record in the queue, starting with 1
+	ID int
+	// Name of the incoming queue
+	Queue string
+	// Time of processing
+	Time time.Time
+	// Name of .changes file
+	ChangesFile string
+	// Fields of .changes file
+	Source, Version, Distribution, ChangedBy string
+	// Key IDs of valid signatures of .changes file
+	SignatureKeys []string `json:",omitempty"`
+	// Local repo upload was directed to
+	Repo string
+	// Whether upload was included into local repo
+	Accepted bool
+	// Reason of rejection
+	Reason string `json:",omitempty"`
+	// Packages added to the repo
+	Added []string `json:",omitempty"`
+	// Warnings reported while processing upload
+	Warnings []string `json:",omitempty"`
+}
+
+// String returns short summary of the record
+func (record *IncomingAuditRecord) String() string {
+	status := "accepted"
+	if !record.Accepted {
+		status = "rejected"
+	}
+
+	result := fmt.Sprintf("#%d %s: %s %s", record.ID, record.Time.Format("2006-01-02 15:04:05 MST"), record.ChangesFile, status)
+	if record.Repo != "" {
+		result += fmt.Sprintf(" [%s]", record.Repo)
+	}
+	if record.Reason != "" {
+		result += ": " + record.Reason
+	}
+
+	return result
+}
+
+// Key is a unique id in DB
+func (record *IncomingAuditRecord) Key() []byte {
+	return []byte(fmt.Sprintf("I%s/%08d", record.Queue, record.ID))
+}
+
+// Encode does msgpack encoding of IncomingAuditRecord
+func (record *IncomingAuditRecord) Encode() []byte {
+	var buf bytes.Buffer
+
+	encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+	_ = encoder.Encode(record)
+
+	return buf.Bytes()
+}
+
+// Decode decodes msgpack representation into IncomingAuditRecord
+func (record *IncomingAuditRecord) Decode(input []byte) error {
+	decoder := codec.NewDecoderBytes(input, &codec.MsgpackHandle{})
+	return decoder.Decode(record)
+}
+
+// incomingAuditPrefix is a DB prefix of all audit records of the queue
+func incomingAuditPrefix(queue string) []byte {
+	return []byte("I" + queue + "/")
+}
+
+// IncomingAuditCollection does listing and adding of incoming queue audit records
+type IncomingAuditCollection struct {
+	db database.Storage
+}
+
+// NewIncomingAuditCollection creates IncomingAuditCollection bound to database
+func NewIncomingAuditCollection(db database.Storage) *IncomingAuditCollection {
+	return &IncomingAuditCollection{
+		db: db,
+	}
+}
+
+// Add assigns next sequential ID to the record and saves it
+func (collection *IncomingAuditCollection) Add(record *IncomingAuditRecord) error {
+	record.ID = 1
+
+	keys := collection.db.KeysByPrefix(incomingAuditPrefix(record.Queue))
+	if len(keys) > 0 {
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+
+		last := &IncomingAuditRecord{}
+		encoded, err := collection.db.Get(keys[len(keys)-1])
+		if err != nil {
+			return err
+		}
+		if err = last.Decode(encoded); err != nil {
+			return err
+		}
+
+		record.ID = last.ID + 1
+	}
+
+	return collection.db.Put(record.Key(), record.Encode())
+}
+
+// ForQueue returns all audit records of the queue ordered by ID
+func (collection *IncomingAuditCollection) ForQueue(queue string) ([]*IncomingAuditRecord, error) {
+	result := []*IncomingAuditRecord{}
+
+	err := collection.db.ProcessByPrefix(incomingAuditPrefix(queue), func(_, blob []byte) error {
+		record := &IncomingAuditRecord{}
+		if err := record.Decode(blob); err != nil {
+			return err
+		}
+
+		result = append(result, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, nil
+}
+
+// changesFileReferences returns sizes of files referenced from Files field of .changes file
+//
+// File is not verified or fully parsed, as it is used only to check whether upload is complete.
+func changesFileReferences(path string) (map[string]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	result := map[string]int64{}
+	inFiles := false
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			inFiles = strings.HasPrefix(line, "Files:")
+			continue
+		}
+
+		if !inFiles {
+			continue
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) < 3 {
+			continue
+		}
+
+		size, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse size of %s in %s: %s", parts[len(parts)-1], path, err)
+		}
+
+		result[parts[len(parts)-1]] = size
+	}
+
+	return result, scanner.Err()
+}
+
+// IncomingUploads returns sorted list of .changes files in incoming directory
+// with all the referenced files completely uploaded
+//
+// Only top level of directory is scanned, incomplete uploads are skipped, so that
+// they're picked up when upload finishes. Malformed .changes files are returned as is.
+func IncomingUploads(directory string) ([]string, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".changes") {
+			continue
+		}
+
+		path := filepath.Join(directory, entry.Name())
+
+		references, err := changesFileReferences(path)
+		if err != nil {
+			// malformed .changes file is returned, so that it gets rejected
+			result = append(result, path)
+			continue
+		}
+
+		complete := len(references) > 0
+		for name, size := range references {
+			info, err := os.Stat(filepath.Join(directory, name))
+			if err != nil || info.Size() != size {
+				complete = false
+				break
+			}
+		}
+
+		if complete {
+			result = append(result, path)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// moveFile moves file to another directory, falling back to copying when rename fails
+func moveFile(path, destination string) error {
+	target := filepath.Join(destination, filepath.Base(path))
+
+	if err := os.Rename(path, target); err == nil {
+		return nil
+	}
+
+	if err := utils.CopyFile(path, target); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// rejectIncomingFiles moves files of the upload to reject directory and writes reason file next to them
+func rejectIncomingFiles(rejectDirectory string, files []string, changesName, reason string) error {
+	err := os.MkdirAll(rejectDirectory, 0777)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if _, err = os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+
+		if err = moveFile(file, rejectDirectory); err != nil {
+			return fmt.Errorf("unable to move %s to reject directory: %s", file, err)
+		}
+	}
+
+	return os.WriteFile(filepath.Join(rejectDirectory, changesName+".reason"), []byte(reason+"\n"), 0666)
+}
+
+// ProcessIncomingQueue includes complete uploads from incoming queue into local repos
+//
+// Accepted files are removed from incoming directory, rejected uploads are moved to
+// reject directory along with .reason file. Every processed upload gets an audit record.
+func ProcessIncomingQueue(name string, queue utils.IncomingQueue, verifier pgp.Verifier, verifierProvider VerifierProvider, progress aptly.Progress,
+	collectionFactory *CollectionFactory, pool aptly.PackagePool, parseQuery parseQuery, now time.Time) ([]*IncomingAuditRecord, error) {
+	repoTemplate, err := template.New("repo").Parse(queue.GetRepo())
+	if err != nil {
+		return nil, fmt.Errorf("error parsing repo template of incoming queue %s: %s", name, err)
+	}
+
+	var uploaders *Uploaders
+	if queue.UploadersFile != "" {
+		uploaders, err = NewUploadersFromFile(queue.UploadersFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = uploaders.Compile(parseQuery); err != nil {
+			return nil, err
+		}
+	}
+
+	uploads, err := IncomingUploads(queue.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan incoming queue %s: %s", name, err)
+	}
+
+	records := []*IncomingAuditRecord{}
+
+	for _, path := range uploads {
+		record := &IncomingAuditRecord{
+			Queue:       name,
+			Time:        now,
+			ChangesFile: filepath.Base(path),
+		}
+
+		files := []string{path}
+		if references, err := changesFileReferences(path); err == nil {
+			for reference := range references {
+				files = append(files, filepath.Join(queue.Directory, filepath.Base(reference)))
+			}
+		}
+		sort.Strings(files[1:])
+
+		if progress != nil {
+			progress.Printf("Processing %s from incoming queue %s...\n", record.ChangesFile, name)
+		}
+
+		var failedFiles, processedFiles []string
+
+		changes, err := NewChanges(path)
+		if err == nil {
+			err = changes.VerifyAndParse(queue.AcceptUnsigned, queue.IgnoreSignatures, verifier)
+
+			record.Source = changes.Source
+			record.Version = changes.Stanza["Version"]
+			record.Distribution = changes.Distribution
+			record.ChangedBy = changes.Stanza["Changed-By"]
+			for _, key := range changes.SignatureKeys {
+				record.SignatureKeys = append(record.SignatureKeys, string(key))
+			}
+
+			_ = changes.Cleanup()
+		}
+
+		if err == nil {
+			repoName := &bytes.Buffer{}
+			err = repoTemplate.Execute(repoName, changes.Stanza)
+			if err != nil {
+				err = fmt.Errorf("error applying template to repo: %s", err)
+			}
+			record.Repo = repoName.String()
+		}
+
+		if err == nil {
+
+			reporter := &aptly.RecordingResultReporter{
+				Warnings:     []string{},
+				AddedLines:   []string{},
+				RemovedLines: []string{},
+			}
+
+			processedFiles, failedFiles, err = ImportChangesFiles(
+				[]string{path}, reporter, queue.AcceptUnsigned, queue.IgnoreSignatures, queue.ForceReplace, true, verifier,
+				verifierProvider, repoTemplate, progress, collectionFactory.LocalRepoCollection(), collectionFactory.PackageCollection(),
+				pool, collectionFactory.ChecksumCollection, uploaders, parseQuery)
+
+			record.Added = reporter.AddedLines
+			record.Warnings = reporter.Warnings
+
+			// failed upload is rejected, so that it doesn't block the queue and isn't retried
+			if err == nil {
+				record.Accepted = utils.StrSliceHasItem(processedFiles, path)
+
+				if !record.Accepted {
+					err = fmt.Errorf("%s", strings.Join(reporter.Warnings, "\n"))
+				}
+			}
+		}
+
+		if err != nil {
+			record.Reason = err.Error()
+
+			err = rejectIncomingFiles(queue.GetRejectDirectory(), files, record.ChangesFile, record.Reason)
+			if err != nil {
+				return records, err
+			}
+		} else {
+			for _, file := range utils.StrSliceDeduplicate(processedFiles) {
+				if err = os.Remove(file); err != nil {
+					return records, fmt.Errorf("unable to remove file: %s", err)
+				}
+			}
+
+			if len(failedFiles) > 0 {
+				record.Reason = fmt.Sprintf("some files were not included: %s", strings.Join(record.Warnings, "\n"))
+
+				err = rejectIncomingFiles(queue.GetRejectDirectory(), failedFiles, record.ChangesFile, record.Reason)
+				if err != nil {
+					return records, err
+				}
+			}
+		}
+
+		err = collectionFactory.IncomingAuditCollection().Add(record)
+		if err != nil {
+			return records, fmt.Errorf("unable to save audit record: %s", err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
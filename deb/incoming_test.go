@@ -0,0 +1,198 @@
+package deb
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type IncomingQueueSuite struct {
+	db                database.Storage
+	collectionFactory *CollectionFactory
+	queue             utils.IncomingQueue
+	now               time.Time
+}
+
+var _ = Suite(&IncomingQueueSuite{})
+
+func (s *IncomingQueueSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collectionFactory = NewCollectionFactory(s.db)
+	s.now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	s.queue = utils.IncomingQueue{
+		Directory:        c.MkDir(),
+		AcceptUnsigned:   true,
+		IgnoreSignatures: true,
+	}
+
+	for _, name := range []string{
+		"calamares.changes",
+		"hardlink_0.2.1-invalidfiles_amd64.changes",
+		"hardlink_0.2.1_amd64.changes",
+		"hardlink_0.2.1.dsc",
+		"hardlink_0.2.1.tar.gz",
+		"hardlink_0.2.1_amd64.deb",
+		"hardlink_0.2.0_i386.deb",
+		"hardlink_0.2.1_amd64.buildinfo",
+	} {
+		c.Assert(utils.CopyFile(filepath.Join("testdata/changes", name), filepath.Join(s.queue.Directory, name)), IsNil)
+	}
+
+	// file of matching size, but wrong checksum
+	c.Assert(os.WriteFile(filepath.Join(s.queue.Directory, "invalidhardlink_0.2.1.dsc"), make([]byte, 949), 0644), IsNil)
+}
+
+func (s *IncomingQueueSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *IncomingQueueSuite) listDir(c *C, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	c.Assert(err, IsNil)
+
+	result := []string{}
+	for _, entry := range entries {
+		result = append(result, entry.Name())
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func (s *IncomingQueueSuite) TestIncomingUploads(c *C) {
+	uploads, err := IncomingUploads(s.queue.Directory)
+	c.Assert(err, IsNil)
+	c.Check(uploads, DeepEquals, []string{
+		filepath.Join(s.queue.Directory, "hardlink_0.2.1-invalidfiles_amd64.changes"),
+		filepath.Join(s.queue.Directory, "hardlink_0.2.1_amd64.changes"),
+	})
+
+	// upload in progress
+	c.Assert(os.Truncate(filepath.Join(s.queue.Directory, "hardlink_0.2.1_amd64.deb"), 100), IsNil)
+
+	uploads, err = IncomingUploads(s.queue.Directory)
+	c.Assert(err, IsNil)
+	c.Check(uploads, DeepEquals, []string{
+		filepath.Join(s.queue.Directory, "hardlink_0.2.1-invalidfiles_amd64.changes"),
+	})
+
+	_, err = IncomingUploads(filepath.Join(s.queue.Directory, "missing"))
+	c.Check(err, NotNil)
+}
+
+func (s *IncomingQueueSuite) TestProcessIncomingQueue(c *C) {
+	c.Assert(s.collectionFactory.LocalRepoCollection().Add(NewLocalRepo("unstable", "")), IsNil)
+
+	records, err := ProcessIncomingQueue("uploads", s.queue, &NullVerifier{}, nil, nil, s.collectionFactory,
+		files.NewPackagePool(c.MkDir(), false), nil, s.now)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 2)
+
+	c.Check(records[0].ID, Equals, 1)
+	c.Check(records[0].ChangesFile, Equals, "hardlink_0.2.1-invalidfiles_amd64.changes")
+	c.Check(records[0].Accepted, Equals, false)
+	c.Check(records[0].Reason, Matches, "(?s).*checksum mismatch.*")
+
+	c.Check(records[1].ID, Equals, 2)
+	c.Check(records[1].ChangesFile, Equals, "hardlink_0.2.1_amd64.changes")
+	c.Check(records[1].Accepted, Equals, true)
+	c.Check(records[1].Source, Equals, "hardlink")
+	c.Check(records[1].Version, Equals, "0.2.1")
+	c.Check(records[1].Repo, Equals, "unstable")
+	c.Check(records[1].Time.Equal(s.now), Equals, true)
+	c.Check(records[1].Added, HasLen, 2)
+	c.Check(records[1].Reason, Matches, "some files were not included: .*hardlink_0.2.0_i386.*")
+
+	repo, err := s.collectionFactory.LocalRepoCollection().ByName("unstable")
+	c.Assert(err, IsNil)
+	c.Assert(s.collectionFactory.LocalRepoCollection().LoadComplete(repo), IsNil)
+	c.Check(repo.NumPackages(), Equals, 2)
+
+	// incomplete upload is left in place
+	c.Check(s.listDir(c, s.queue.Directory), DeepEquals, []string{"calamares.changes", "reject"})
+	c.Check(s.listDir(c, s.queue.GetRejectDirectory()), DeepEquals, []string{
+		"hardlink_0.2.0_i386.deb",
+		"hardlink_0.2.1-invalidfiles_amd64.changes",
+		"hardlink_0.2.1-invalidfiles_amd64.changes.reason",
+		"hardlink_0.2.1_amd64.changes.reason",
+		"invalidhardlink_0.2.1.dsc",
+	})
+
+	reason, err := os.ReadFile(filepath.Join(s.queue.GetRejectDirectory(), "hardlink_0.2.1-invalidfiles_amd64.changes.reason"))
+	c.Assert(err, IsNil)
+	c.Check(string(reason), Equals, records[0].Reason+"\n")
+
+	stored, err := s.collectionFactory.IncomingAuditCollection().ForQueue("uploads")
+	c.Assert(err, IsNil)
+	c.Check(stored, HasLen, 2)
+	c.Check(stored[1].Added, DeepEquals, records[1].Added)
+
+	stored, err = s.collectionFactory.IncomingAuditCollection().ForQueue("other")
+	c.Assert(err, IsNil)
+	c.Check(stored, HasLen, 0)
+}
+
+func (s *IncomingQueueSuite) TestProcessIncomingQueueMissingRepo(c *C) {
+	s.queue.Repo = "{{.Distribution}}-uploads"
+	s.queue.RejectDirectory = c.MkDir()
+
+	records, err := ProcessIncomingQueue("uploads", s.queue, &NullVerifier{}, nil, nil, s.collectionFactory,
+		files.NewPackagePool(c.MkDir(), false), nil, s.now)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 2)
+
+	c.Check(records[1].Accepted, Equals, false)
+	c.Check(records[1].Repo, Equals, "unstable-uploads")
+	c.Check(records[1].Reason, Matches, ".*local repo with name unstable-uploads not found")
+	c.Check(records[1].String(), Matches, "#2 2024-03-01 12:00:00 UTC: hardlink_0.2.1_amd64.changes rejected \\[unstable-uploads\\]: .*")
+
+	c.Check(s.listDir(c, s.queue.Directory), DeepEquals, []string{"calamares.changes"})
+	c.Check(s.listDir(c, s.queue.RejectDirectory), HasLen, 10)
+}
+
+func (s *IncomingQueueSuite) TestProcessIncomingQueueBrokenUploads(c *C) {
+	c.Assert(s.collectionFactory.LocalRepoCollection().Add(NewLocalRepo("unstable", "")), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(s.queue.Directory, "broken.changes"), []byte("Files:\n 00 size main optional broken.deb\n"), 0644), IsNil)
+
+	uploads, err := IncomingUploads(s.queue.Directory)
+	c.Assert(err, IsNil)
+	c.Check(uploads, HasLen, 3)
+
+	records, err := ProcessIncomingQueue("uploads", s.queue, &NullVerifier{}, nil, nil, s.collectionFactory,
+		files.NewPackagePool(c.MkDir(), false), nil, s.now)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 3)
+
+	c.Check(records[0].ChangesFile, Equals, "broken.changes")
+	c.Check(records[0].Accepted, Equals, false)
+	c.Check(records[2].ChangesFile, Equals, "hardlink_0.2.1_amd64.changes")
+	c.Check(records[2].Accepted, Equals, true)
+
+	// broken upload is not picked up again
+	c.Check(s.listDir(c, s.queue.Directory), DeepEquals, []string{"calamares.changes", "reject"})
+
+	// failure to apply repo template rejects the upload
+	c.Assert(utils.CopyFile("testdata/changes/hardlink_0.2.1_amd64.changes", filepath.Join(s.queue.Directory, "hardlink_0.2.1_amd64.changes")), IsNil)
+	for _, name := range []string{"hardlink_0.2.1.dsc", "hardlink_0.2.1.tar.gz", "hardlink_0.2.1_amd64.deb", "hardlink_0.2.0_i386.deb",
+		"hardlink_0.2.1_amd64.buildinfo"} {
+		c.Assert(utils.CopyFile(filepath.Join("testdata/changes", name), filepath.Join(s.queue.Directory, name)), IsNil)
+	}
+	s.queue.Repo = "{{index .Distribution 100}}"
+
+	records, err = ProcessIncomingQueue("uploads", s.queue, &NullVerifier{}, nil, nil, s.collectionFactory,
+		files.NewPackagePool(c.MkDir(), false), nil, s.now)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 1)
+	c.Check(records[0].Accepted, Equals, false)
+	c.Check(records[0].Reason, Matches, "error applying template to repo.*")
+	c.Check(s.listDir(c, s.queue.Directory), DeepEquals, []string{"calamares.changes", "reject"})
+}
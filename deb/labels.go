@@ -0,0 +1,164 @@
+package deb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Labels are arbitrary key/value pairs attached to snapshots, local repos and mirrors,
+// e.g. team=infra or release=2026.10
+type Labels map[string]string
+
+var labelKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_./-]*$`)
+
+// ValidateLabelKey checks that label key is non-empty and contains only letters, digits, '_', '.', '/' and '-'
+func ValidateLabelKey(key string) error {
+	if !labelKeyRegexp.MatchString(key) {
+		return fmt.Errorf("wrong label key %#v, expected letters, digits, '_', '.', '/' or '-'", key)
+	}
+
+	return nil
+}
+
+// ParseLabel parses label in key=value form
+func ParseLabel(label string) (key, value string, err error) {
+	key, value, found := strings.Cut(label, "=")
+	if !found {
+		return "", "", fmt.Errorf("wrong label %#v, expected key=value", label)
+	}
+
+	if err = ValidateLabelKey(key); err != nil {
+		return "", "", err
+	}
+
+	return key, value, nil
+}
+
+// Validate checks that all the label keys are valid
+func (labels Labels) Validate() error {
+	for key := range labels {
+		if err := ValidateLabelKey(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Apply updates labels from the list of key=value pairs, empty value removes the label
+//
+// Resulting labels are returned, as receiver might be nil
+func (labels Labels) Apply(pairs []string) (Labels, error) {
+	for _, pair := range pairs {
+		key, value, err := ParseLabel(pair)
+		if err != nil {
+			return nil, err
+		}
+
+		if value == "" {
+			delete(labels, key)
+			continue
+		}
+
+		if labels == nil {
+			labels = Labels{}
+		}
+		labels[key] = value
+	}
+
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	return labels, nil
+}
+
+// String returns labels as comma-separated list of key=value pairs sorted by key
+func (labels Labels) String() string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + labels[key]
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// LabelSelector matches objects by their labels
+//
+// Supported forms are key=value (label is set to value), key!=value (label
+// is missing or set to another value) and key (label is set to any value)
+type LabelSelector struct {
+	Key      string
+	Value    string
+	Negate   bool
+	Presence bool
+}
+
+// ParseLabelSelector parses label selector
+func ParseLabelSelector(selector string) (*LabelSelector, error) {
+	result := &LabelSelector{}
+
+	if key, value, found := strings.Cut(selector, "!="); found {
+		result.Key, result.Value, result.Negate = key, value, true
+	} else if key, value, found := strings.Cut(selector, "="); found {
+		result.Key, result.Value = key, value
+	} else {
+		result.Key, result.Presence = selector, true
+	}
+
+	if err := ValidateLabelKey(result.Key); err != nil {
+		return nil, fmt.Errorf("wrong label selector %#v: %s", selector, err)
+	}
+
+	return result, nil
+}
+
+// Matches checks whether labels are matched by the selector
+func (selector *LabelSelector) Matches(labels Labels) bool {
+	value, ok := labels[selector.Key]
+
+	switch {
+	case selector.Presence:
+		return ok
+	case selector.Negate:
+		return !ok || value != selector.Value
+	default:
+		return ok && value == selector.Value
+	}
+}
+
+// LabelSelectors is a list of selectors, all of them should match
+type LabelSelectors []*LabelSelector
+
+// ParseLabelSelectors parses list of label selectors
+func ParseLabelSelectors(selectors []string) (LabelSelectors, error) {
+	result := make(LabelSelectors, 0, len(selectors))
+	for _, s := range selectors {
+		selector, err := ParseLabelSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, selector)
+	}
+
+	return result, nil
+}
+
+// Matches checks whether labels are matched by all the selectors
+func (selectors LabelSelectors) Matches(labels Labels) bool {
+	for _, selector := range selectors {
+		if !selector.Matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,76 @@
+package deb
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type LabelsSuite struct{}
+
+var _ = Suite(&LabelsSuite{})
+
+func (s *LabelsSuite) TestParseLabel(c *C) {
+	key, value, err := ParseLabel("release=2026.10")
+	c.Assert(err, IsNil)
+	c.Check(key, Equals, "release")
+	c.Check(value, Equals, "2026.10")
+
+	key, value, err = ParseLabel("note=a=b")
+	c.Assert(err, IsNil)
+	c.Check(key, Equals, "note")
+	c.Check(value, Equals, "a=b")
+
+	_, _, err = ParseLabel("release")
+	c.Check(err, ErrorMatches, "wrong label \"release\", expected key=value")
+
+	_, _, err = ParseLabel("bad key=1")
+	c.Check(err, ErrorMatches, "wrong label key \"bad key\".*")
+}
+
+func (s *LabelsSuite) TestApply(c *C) {
+	labels, err := Labels(nil).Apply([]string{"team=infra", "release=2026.10"})
+	c.Assert(err, IsNil)
+	c.Check(labels, DeepEquals, Labels{"team": "infra", "release": "2026.10"})
+	c.Check(labels.String(), Equals, "release=2026.10,team=infra")
+
+	labels, err = labels.Apply([]string{"team=", "approved=true"})
+	c.Assert(err, IsNil)
+	c.Check(labels, DeepEquals, Labels{"approved": "true", "release": "2026.10"})
+
+	labels, err = labels.Apply([]string{"approved=", "release="})
+	c.Assert(err, IsNil)
+	c.Check(labels, IsNil)
+
+	_, err = labels.Apply([]string{"=1"})
+	c.Check(err, ErrorMatches, "wrong label key \"\".*")
+
+	c.Check(Labels{"ok": "1"}.Validate(), IsNil)
+	c.Check(Labels{"not ok": "1"}.Validate(), NotNil)
+}
+
+func (s *LabelsSuite) TestSelectors(c *C) {
+	labels := Labels{"team": "infra", "approved": "true"}
+
+	for selector, expected := range map[string]bool{
+		"team=infra":    true,
+		"team=web":      false,
+		"team!=web":     true,
+		"team!=infra":   false,
+		"release!=2026": true,
+		"approved":      true,
+		"release":       false,
+		"release=":      false,
+	} {
+		parsed, err := ParseLabelSelector(selector)
+		c.Assert(err, IsNil)
+		c.Check(parsed.Matches(labels), Equals, expected, Commentf("%s", selector))
+	}
+
+	selectors, err := ParseLabelSelectors([]string{"team=infra", "approved=true"})
+	c.Assert(err, IsNil)
+	c.Check(selectors.Matches(labels), Equals, true)
+	c.Check(selectors.Matches(nil), Equals, false)
+	c.Check(LabelSelectors(nil).Matches(nil), Equals, true)
+
+	_, err = ParseLabelSelectors([]string{"team=infra", "!=x"})
+	c.Check(err, ErrorMatches, "wrong label selector \"!=x\": .*")
+}
@@ -0,0 +1,305 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+)
+
+// PackageLicenses is license information extracted from the copyright file of the package
+type PackageLicenses struct {
+	// Package ships copyright file
+	HasCopyright bool
+	// Copyright file is in machine-readable (DEP-5) format
+	MachineReadable bool
+	// Expressions are values of License fields, e.g. "GPL-2+ or Artistic"
+	Expressions []string `json:",omitempty"`
+	// Licenses are short names of all the licenses mentioned in expressions, e.g. "GPL-2+", "Artistic"
+	Licenses []string `json:",omitempty"`
+	// Error is set if package file couldn't be read to extract licenses
+	Error string `json:",omitempty"`
+}
+
+// String returns license expressions or explains why there are none
+func (l *PackageLicenses) String() string {
+	switch {
+	case l == nil:
+		return "licenses not extracted"
+	case l.Error != "":
+		return "extraction failed: " + l.Error
+	case !l.HasCopyright:
+		return "no copyright file"
+	case !l.MachineReadable:
+		return "copyright file is not machine-readable"
+	case len(l.Expressions) == 0:
+		return "no licenses listed"
+	}
+
+	return strings.Join(l.Expressions, "; ")
+}
+
+var licenseSeparator = regexp.MustCompile(`(?i)\s*(?:,|\band/or\b|\band\b|\bor\b)\s*`)
+
+// splitLicenseExpression returns short names of the licenses in DEP-5 license expression
+func splitLicenseExpression(expression string) []string {
+	expression = strings.NewReplacer("(", " ", ")", " ").Replace(expression)
+
+	result := []string{}
+	for _, license := range licenseSeparator.Split(expression, -1) {
+		if license = strings.Join(strings.Fields(license), " "); license != "" {
+			result = append(result, license)
+		}
+	}
+
+	return result
+}
+
+// ParseCopyright extracts licenses from the copyright file
+//
+// Only machine-readable copyright files (https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/)
+// are parsed: licenses are taken from License fields of the header paragraph and Files paragraphs.
+func ParseCopyright(data []byte) *PackageLicenses {
+	result := &PackageLicenses{HasCopyright: true}
+
+	paragraphs := []map[string]string{}
+	paragraph := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.TrimSpace(line) == "" {
+			if len(paragraph) > 0 {
+				paragraphs = append(paragraphs, paragraph)
+				paragraph = map[string]string{}
+			}
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' || line[0] == '#' {
+			// continuation lines carry only full text of the license
+			continue
+		}
+
+		pos := strings.IndexByte(line, ':')
+		if pos == -1 {
+			if len(paragraphs) == 0 {
+				// free-form copyright file
+				return result
+			}
+			continue
+		}
+
+		paragraph[strings.ToLower(strings.TrimSpace(line[:pos]))] = strings.TrimSpace(line[pos+1:])
+	}
+	if len(paragraph) > 0 {
+		paragraphs = append(paragraphs, paragraph)
+	}
+
+	if len(paragraphs) == 0 {
+		return result
+	}
+
+	format := paragraphs[0]["format"]
+	if format == "" {
+		format = paragraphs[0]["format-specification"]
+	}
+	format = strings.ToLower(format)
+	if !strings.Contains(format, "copyright-format") && !strings.Contains(format, "dep5") && !strings.Contains(format, "dep-5") {
+		return result
+	}
+
+	result.MachineReadable = true
+
+	expressions := map[string]bool{}
+	licenses := map[string]bool{}
+	for i, p := range paragraphs {
+		_, isFiles := p["files"]
+		if !isFiles && i != 0 {
+			// stand-alone license paragraph
+			continue
+		}
+
+		expression := p["license"]
+		if expression == "" {
+			continue
+		}
+
+		if !expressions[expression] {
+			expressions[expression] = true
+			result.Expressions = append(result.Expressions, expression)
+		}
+
+		for _, license := range splitLicenseExpression(expression) {
+			if !licenses[license] {
+				licenses[license] = true
+				result.Licenses = append(result.Licenses, license)
+			}
+		}
+	}
+
+	sort.Strings(result.Licenses)
+
+	return result
+}
+
+// GetLicensesFromDeb extracts licenses from the copyright file shipped in .deb package
+func GetLicensesFromDeb(file io.Reader, packageFile string, name string) (*PackageLicenses, error) {
+	copyright, err := GetCopyrightFromDeb(file, packageFile, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if copyright == nil {
+		return &PackageLicenses{}, nil
+	}
+
+	return ParseCopyright(copyright), nil
+}
+
+// PackageLicensesEntry is an entry of license inventory
+type PackageLicensesEntry struct {
+	Package      string
+	Name         string
+	Version      string
+	Architecture string
+	Source       string
+	// Licenses is nil if licenses haven't been extracted
+	Licenses *PackageLicenses
+}
+
+// LicenseInventory lists licenses of binary packages in the list, ordered by package name
+func LicenseInventory(list *PackageList) []PackageLicensesEntry {
+	result := []PackageLicensesEntry{}
+
+	list.PrepareIndex()
+	_ = list.ForEachIndexed(func(p *Package) error {
+		if p.IsSource {
+			return nil
+		}
+
+		result = append(result, PackageLicensesEntry{
+			Package:      p.String(),
+			Name:         p.Name,
+			Version:      p.Version,
+			Architecture: p.Architecture,
+			Source:       p.GetField("$Source"),
+			Licenses:     p.Licenses(),
+		})
+		return nil
+	})
+
+	return result
+}
+
+// ExtractLicenses extracts licenses of binary packages in the reflist which haven't been extracted yet,
+// package files are read from the package pool
+//
+// Packages which can't be read are recorded with the error, so that extraction isn't retried
+// over and over again.
+func ExtractLicenses(refList *PackageRefList, packageCollection *PackageCollection, packagePool aptly.PackagePool,
+	progress aptly.Progress) (extracted int, err error) {
+	pending := [][]byte{}
+
+	err = refList.ForEach(func(key []byte) error {
+		if bytes.HasPrefix(key, []byte("Psource ")) {
+			return nil
+		}
+
+		_, e := packageCollection.LicensesByKey(key)
+		if e == database.ErrNotFound {
+			pending = append(pending, key)
+			return nil
+		}
+
+		return e
+	})
+	if err != nil || len(pending) == 0 {
+		return
+	}
+
+	if progress != nil {
+		progress.InitBar(int64(len(pending)), false, aptly.BarGeneralBuildPackageList)
+		defer progress.ShutdownBar()
+	}
+
+	for _, key := range pending {
+		if progress != nil {
+			progress.AddBar(1)
+		}
+
+		var p *Package
+		p, err = packageCollection.ByKey(key)
+		if err != nil {
+			return
+		}
+
+		licenses, e := licensesFromPool(p, packagePool)
+		if e != nil {
+			log.Debug().Err(e).Msgf("Unable to extract licenses of %s", p)
+			licenses = &PackageLicenses{Error: e.Error()}
+		} else {
+			extracted++
+		}
+
+		err = packageCollection.UpdateLicenses(p, licenses)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// importLicenses extracts licenses from package file being imported, unless they are already known
+func importLicenses(p *Package, packageFile string, collection *PackageCollection) error {
+	_, err := collection.LicensesByKey(p.Key(""))
+	if err != database.ErrNotFound {
+		return err
+	}
+
+	file, err := os.Open(packageFile)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	licenses, err := GetLicensesFromDeb(file, packageFile, p.Name)
+	if err != nil {
+		return err
+	}
+
+	return collection.UpdateLicenses(p, licenses)
+}
+
+// licensesFromPool extracts licenses from the package file in the package pool
+func licensesFromPool(p *Package, packagePool aptly.PackagePool) (*PackageLicenses, error) {
+	files := p.Files()
+	if len(files) == 0 {
+		return nil, fmt.Errorf("package has no files")
+	}
+
+	poolPath, err := files[0].GetPoolPath(packagePool)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := packagePool.Open(poolPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	return GetLicensesFromDeb(reader, files[0].Filename, p.Name)
+}
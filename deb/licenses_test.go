@@ -0,0 +1,183 @@
+package deb
+
+import (
+	"path/filepath"
+	"runtime"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type LicensesSuite struct {
+	db          database.Storage
+	collection  *PackageCollection
+	packagePool aptly.PackagePool
+	debFile     string
+}
+
+var _ = Suite(&LicensesSuite{})
+
+func (s *LicensesSuite) SetUpTest(c *C) {
+	_, _File, _, _ := runtime.Caller(0)
+	s.debFile = filepath.Join(filepath.Dir(_File), "../system/changes/hardlink_0.2.1_amd64.deb")
+
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collection = NewPackageCollection(s.db)
+	s.packagePool = files.NewPackagePool(c.MkDir(), false)
+}
+
+func (s *LicensesSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *LicensesSuite) TestParseCopyright(c *C) {
+	licenses := ParseCopyright([]byte(`Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/
+Upstream-Name: example
+License: GPL-2+ or Artistic
+
+Files: *
+Copyright: 2020 Jane Doe <jane@example.com>
+License: GPL-2+ or Artistic
+
+Files: lib/*
+Copyright: 2021 John Doe
+License: (MIT or BSD-3-clause) and/or LGPL-2.1
+ License: text which is not a field
+
+Files: debian/*
+Copyright: 2022 Debian Maintainer
+License: GPL-3+ with OpenSSL exception
+
+License: Artistic
+ Stand-alone license paragraph with full text.
+ .
+ It is not parsed.
+
+License: Other
+ Not referenced from any Files paragraph.
+`))
+
+	c.Check(licenses.HasCopyright, Equals, true)
+	c.Check(licenses.MachineReadable, Equals, true)
+	c.Check(licenses.Expressions, DeepEquals, []string{"GPL-2+ or Artistic", "(MIT or BSD-3-clause) and/or LGPL-2.1",
+		"GPL-3+ with OpenSSL exception"})
+	c.Check(licenses.Licenses, DeepEquals, []string{"Artistic", "BSD-3-clause", "GPL-2+", "GPL-3+ with OpenSSL exception",
+		"LGPL-2.1", "MIT"})
+	c.Check(licenses.String(), Equals, "GPL-2+ or Artistic; (MIT or BSD-3-clause) and/or LGPL-2.1; GPL-3+ with OpenSSL exception")
+
+	licenses = ParseCopyright([]byte("This package was debianized by Jane Doe.\n\nIt is licensed under GPL-2.\n"))
+	c.Check(licenses.HasCopyright, Equals, true)
+	c.Check(licenses.MachineReadable, Equals, false)
+	c.Check(licenses.Licenses, IsNil)
+	c.Check(licenses.String(), Equals, "copyright file is not machine-readable")
+
+	licenses = ParseCopyright([]byte("Upstream-Name: example\nLicense: GPL-2\n"))
+	c.Check(licenses.MachineReadable, Equals, false)
+
+	licenses = ParseCopyright([]byte("Format-Specification: http://svn.debian.org/wsvn/dep/web/deps/dep5.mdwn?rev=135\n\n" +
+		"Files: *\nLicense: GPL-2\n"))
+	c.Check(licenses.MachineReadable, Equals, true)
+	c.Check(licenses.Licenses, DeepEquals, []string{"GPL-2"})
+
+	licenses = ParseCopyright(nil)
+	c.Check(licenses.MachineReadable, Equals, false)
+}
+
+func (s *LicensesSuite) TestString(c *C) {
+	var licenses *PackageLicenses
+	c.Check(licenses.String(), Equals, "licenses not extracted")
+	c.Check((&PackageLicenses{}).String(), Equals, "no copyright file")
+	c.Check((&PackageLicenses{Error: "package has no files"}).String(), Equals, "extraction failed: package has no files")
+	c.Check((&PackageLicenses{HasCopyright: true, MachineReadable: true}).String(), Equals, "no licenses listed")
+}
+
+func (s *LicensesSuite) TestExtractLicenses(c *C) {
+	stanza, err := GetControlFileFromDeb(s.debFile)
+	c.Assert(err, IsNil)
+
+	checksums, err := utils.ChecksumsForFile(s.debFile)
+	c.Assert(err, IsNil)
+
+	p := NewPackageFromControlFile(stanza)
+	file := PackageFile{Filename: filepath.Base(s.debFile), Checksums: checksums}
+	file.PoolPath, err = s.packagePool.Import(s.debFile, file.Filename, &file.Checksums, false, files.NewMockChecksumStorage())
+	c.Assert(err, IsNil)
+	p.UpdateFiles(PackageFiles{file})
+	c.Assert(s.collection.Update(p), IsNil)
+
+	missing := NewPackageFromControlFile(packageStanza.Copy())
+	c.Assert(s.collection.Update(missing), IsNil)
+
+	source, err := NewSourcePackageFromControlFile(Stanza{"Package": "hardlink", "Version": "0.2.1", "Architecture": "any",
+		"Directory": "pool/main/h/hardlink", "Files": " 00 1 hardlink_0.2.1.dsc\n"})
+	c.Assert(err, IsNil)
+	c.Assert(s.collection.Update(source), IsNil)
+
+	list := NewPackageList()
+	c.Assert(list.Add(p), IsNil)
+	c.Assert(list.Add(missing), IsNil)
+	c.Assert(list.Add(source), IsNil)
+	refList := NewPackageRefListFromPackageList(list)
+
+	extracted, err := ExtractLicenses(refList, s.collection, s.packagePool, nil)
+	c.Check(err, IsNil)
+	c.Check(extracted, Equals, 1)
+
+	p, err = s.collection.ByKey(p.Key(""))
+	c.Assert(err, IsNil)
+	c.Check(p.Licenses(), DeepEquals, &PackageLicenses{HasCopyright: true, MachineReadable: true,
+		Expressions: []string{"Expat"}, Licenses: []string{"Expat"}})
+	c.Check(p.GetField("$License"), Equals, "Expat")
+
+	// package file is missing in the pool, failure is recorded
+	missing, err = s.collection.ByKey(missing.Key(""))
+	c.Assert(err, IsNil)
+	c.Check(missing.Licenses().HasCopyright, Equals, false)
+	c.Check(missing.Licenses().String(), Matches, "extraction failed: .+")
+	c.Check(missing.GetField("$License"), Equals, "")
+
+	// licenses are extracted only once, failures are not retried
+	extracted, err = ExtractLicenses(refList, s.collection, s.packagePool, nil)
+	c.Check(err, IsNil)
+	c.Check(extracted, Equals, 0)
+
+	inventory := LicenseInventory(list)
+	c.Assert(inventory, HasLen, 2)
+	c.Check(inventory[0].Package, Equals, "alien-arena-common_7.40-2_i386")
+	c.Check(inventory[0].Licenses.Error, Not(Equals), "")
+	c.Check(inventory[1].Package, Equals, "hardlink_0.2.1_amd64")
+	c.Check(inventory[1].Source, Equals, "hardlink")
+	c.Check(inventory[1].Licenses.Licenses, DeepEquals, []string{"Expat"})
+
+	// licenses are removed with the package
+	c.Assert(s.collection.DeleteByKey(p.Key(""), s.db), IsNil)
+	_, err = s.collection.LicensesByKey(p.Key(""))
+	c.Check(err, Equals, database.ErrNotFound)
+}
+
+func (s *LicensesSuite) TestLicenseQuery(c *C) {
+	p := NewPackageFromControlFile(packageStanza.Copy())
+	c.Assert(s.collection.Update(p), IsNil)
+	c.Assert(s.collection.UpdateLicenses(p, &PackageLicenses{HasCopyright: true, MachineReadable: true,
+		Expressions: []string{"GPL-3+ or Artistic"}, Licenses: []string{"Artistic", "GPL-3+"}}), IsNil)
+
+	p, err := s.collection.ByKey(p.Key(""))
+	c.Assert(err, IsNil)
+	c.Check(p.GetField("$License"), Equals, "Artistic, GPL-3+")
+
+	c.Check((&FieldQuery{Field: "$License", Relation: VersionEqual, Value: "GPL-3+"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$License", Relation: VersionEqual, Value: "GPL-3"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "$License", Relation: VersionPatternMatch, Value: "GPL-3*"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$License", Relation: VersionPatternMatch, Value: "GPL-2*"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "$License", Relation: VersionRegexp, Value: "^Art"}).Matches(p), Equals, true)
+	c.Check((&FieldQuery{Field: "$License", Relation: VersionRegexp, Value: "^GPL-2"}).Matches(p), Equals, false)
+	c.Check((&FieldQuery{Field: "$License", Relation: VersionDontCare}).Matches(p), Equals, true)
+
+	c.Check((&FieldQuery{Field: "$License", Relation: VersionDontCare}).Matches(NewPackageFromControlFile(packageStanza.Copy())),
+		Equals, false)
+}
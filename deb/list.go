@@ -1,6 +1,7 @@
 package deb
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"sort"
@@ -344,15 +345,78 @@ func depSliceDeduplicate(s []Dependency) []Dependency {
 //
 // Analysis would be performed for each architecture, in specified sources
 func (l *PackageList) VerifyDependencies(options int, architectures []string, sources *PackageList, progress aptly.Progress) ([]Dependency, error) {
-	l.PrepareIndex()
 	missing := make([]Dependency, 0, 128)
 
+	err := l.walkMissingDependencies(options, architectures, sources, progress, func(_ *Package, _ string, deps []Dependency) {
+		missing = append(missing, deps...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	missing = depSliceDeduplicate(missing)
+
+	if options&DepVerboseResolve == DepVerboseResolve && progress != nil {
+		missingStr := make([]string, len(missing))
+		for i := range missing {
+			missingStr[i] = missing[i].String()
+		}
+		progress.ColoredPrintf("@{y}Missing dependencies:@| %s", strings.Join(missingStr, ", "))
+	}
+
+	return missing, nil
+}
+
+// UnresolvedDependency is a dependency of the package which can't be satisfied
+type UnresolvedDependency struct {
+	// Package with the dependency
+	Package string
+	// Dependency which can't be satisfied
+	Dependency string
+	// Architecture dependency has been resolved for
+	Architecture string
+}
+
+// UnresolvedDependencies looks for missing dependencies in package list, like VerifyDependencies,
+// but reports every package with missing dependencies, sorted by package, dependency and architecture
+func (l *PackageList) UnresolvedDependencies(options int, architectures []string, sources *PackageList, progress aptly.Progress) ([]UnresolvedDependency, error) {
+	result := []UnresolvedDependency{}
+
+	err := l.walkMissingDependencies(options, architectures, sources, progress, func(p *Package, arch string, deps []Dependency) {
+		for _, dep := range deps {
+			result = append(result, UnresolvedDependency{Package: p.String(), Dependency: dep.String(), Architecture: arch})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Package != result[j].Package {
+			return result[i].Package < result[j].Package
+		}
+		if result[i].Dependency != result[j].Dependency {
+			return result[i].Dependency < result[j].Dependency
+		}
+		return result[i].Architecture < result[j].Architecture
+	})
+
+	return result, nil
+}
+
+// walkMissingDependencies resolves dependencies of packages for each architecture in sources,
+// calling missed for every package with some dependencies missing
+func (l *PackageList) walkMissingDependencies(options int, architectures []string, sources *PackageList, progress aptly.Progress,
+	missed func(p *Package, arch string, deps []Dependency)) error {
+	l.PrepareIndex()
+
 	if progress != nil {
 		progress.InitBar(int64(l.Len())*int64(len(architectures)), false, aptly.BarGeneralVerifyDependencies)
+		defer progress.ShutdownBar()
 	}
 
 	if len(architectures) == 0 {
-		return nil, fmt.Errorf("no architectures defined, cannot verify dependencies")
+		return fmt.Errorf("no architectures defined, cannot verify dependencies")
 	}
 	for _, arch := range architectures {
 		cache := make(map[string]bool, 2048)
@@ -366,10 +430,12 @@ func (l *PackageList) VerifyDependencies(options int, architectures []string, so
 				continue
 			}
 
+			var packageMissing []Dependency
+
 			for _, dep := range p.GetDependencies(options) {
 				variants, err := ParseDependencyVariants(dep)
 				if err != nil {
-					return nil, fmt.Errorf("unable to process package %s: %s", p, err)
+					return fmt.Errorf("unable to process package %s: %s", p, err)
 				}
 
 				variants = depSliceDeduplicate(variants)
@@ -398,26 +464,16 @@ func (l *PackageList) VerifyDependencies(options int, architectures []string, so
 					}
 				}
 
-				missing = append(missing, variantsMissing...)
+				packageMissing = append(packageMissing, variantsMissing...)
 			}
-		}
-	}
-
-	missing = depSliceDeduplicate(missing)
-
-	if progress != nil {
-		progress.ShutdownBar()
-	}
 
-	if options&DepVerboseResolve == DepVerboseResolve && progress != nil {
-		missingStr := make([]string, len(missing))
-		for i := range missing {
-			missingStr[i] = missing[i].String()
+			if len(packageMissing) > 0 {
+				missed(p, arch, packageMissing)
+			}
 		}
-		progress.ColoredPrintf("@{y}Missing dependencies:@| %s", strings.Join(missingStr, ", "))
 	}
 
-	return missing, nil
+	return nil
 }
 
 // Swap swaps two packages in index
@@ -668,3 +724,43 @@ func (l *PackageList) Filter(options FilterOptions) (*PackageList, error) {
 
 	return result, nil
 }
+
+// ErrNoArchitectures is returned when list of architectures to follow dependencies can't be determined
+var ErrNoArchitectures = errors.New("unable to determine list of architectures, please specify explicitly")
+
+// FilterForCopy selects packages matching queries to be copied (or moved) from the list into
+// destination list, as done by repo copy and repo move
+//
+// If withDeps is set, missing dependencies are pulled as well, with architectures defaulting to
+// architectures of destination list.
+func (l *PackageList) FilterForCopy(dstList *PackageList, queries []PackageQuery, withDeps bool, architectures []string,
+	dependencyOptions int, progress aptly.Progress) (*PackageList, error) {
+	l.PrepareIndex()
+
+	var architecturesList []string
+
+	if withDeps {
+		dstList.PrepareIndex()
+
+		if len(architectures) > 0 {
+			architecturesList = append([]string(nil), architectures...)
+		} else {
+			architecturesList = dstList.Architectures(false)
+		}
+
+		sort.Strings(architecturesList)
+
+		if len(architecturesList) == 0 {
+			return nil, ErrNoArchitectures
+		}
+	}
+
+	return l.Filter(FilterOptions{
+		Queries:           queries,
+		WithDependencies:  withDeps,
+		Source:            dstList,
+		DependencyOptions: dependencyOptions,
+		Architectures:     architecturesList,
+		Progress:          progress,
+	})
+}
@@ -494,6 +494,23 @@ func (s *PackageListSuite) TestVerifyDependencies(c *C) {
 	c.Check(err, ErrorMatches, "unable to process package app_1.0_s390:.*")
 }
 
+func (s *PackageListSuite) TestUnresolvedDependencies(c *C) {
+	unresolved, err := s.il.UnresolvedDependencies(0, []string{"i386"}, s.il, nil)
+	c.Check(err, IsNil)
+	c.Check(unresolved, DeepEquals, []UnresolvedDependency{})
+
+	unresolved, err = s.il.UnresolvedDependencies(DepFollowAllVariants, []string{"amd64", "arm"}, s.il, nil)
+	c.Check(err, IsNil)
+	c.Check(unresolved, DeepEquals, []UnresolvedDependency{
+		{Package: "app_1.1~bp1_amd64", Dependency: "lib (>> 0.9) [amd64]", Architecture: "amd64"},
+		{Package: "app_1.1~bp1_arm", Dependency: "lib (>> 0.9) [arm]", Architecture: "arm"},
+		{Package: "app_1.1~bp1_arm", Dependency: "mail-agent [arm]", Architecture: "arm"},
+	})
+
+	_, err = s.il.UnresolvedDependencies(0, nil, s.il, nil)
+	c.Check(err, ErrorMatches, "no architectures defined, cannot verify dependencies")
+}
+
 func (s *PackageListSuite) TestArchitectures(c *C) {
 	archs := s.il.Architectures(true)
 	sort.Strings(archs)
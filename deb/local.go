@@ -19,12 +19,18 @@ type LocalRepo struct {
 	Name string
 	// Comment
 	Comment string
+	// Arbitrary key/value metadata
+	Labels Labels `codec:",omitempty" json:",omitempty"`
 	// DefaultDistribution
 	DefaultDistribution string `codec:",omitempty"`
 	// DefaultComponent
 	DefaultComponent string `codec:",omitempty"`
 	// Uploaders configuration
 	Uploaders *Uploaders `codec:"Uploaders,omitempty" json:"-"`
+	// Retention policy for package versions
+	Retention *RetentionPolicy `codec:",omitempty" json:",omitempty"`
+	// Checks packages should pass to be added
+	Validation *Validation `codec:",omitempty" json:",omitempty"`
 	// "Snapshot" of current list of packages
 	packageRefs *PackageRefList
 }
@@ -249,5 +255,6 @@ func (collection *LocalRepoCollection) Drop(repo *LocalRepo) error {
 	batch := collection.db.CreateBatch()
 	_ = batch.Delete(repo.Key())
 	_ = batch.Delete(repo.RefKey())
+	_ = batch.Delete(packageAgesKey(repo.UUID))
 	return batch.Write()
 }
@@ -0,0 +1,372 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/ugorji/go/codec"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+)
+
+// MirrorHistoryChange is a single change in mirror package list, encoded as
+// pair of package keys: Left is package before update, Right is package after update
+//
+// Package which was added has nil Left, package which was removed has nil Right.
+type MirrorHistoryChange struct {
+	Left, Right *string
+}
+
+// Describe returns architecture, name and versions (before and after update) of changed package,
+// missing version is returned as "-"
+func (change MirrorHistoryChange) Describe() (arch, name, leftVersion, rightVersion string) {
+	leftVersion, rightVersion = "-", "-"
+
+	if change.Left != nil {
+		arch, name, leftVersion = parsePackageKey(*change.Left)
+	}
+	if change.Right != nil {
+		arch, name, rightVersion = parsePackageKey(*change.Right)
+	}
+
+	return
+}
+
+// parsePackageKey extracts architecture, name and version from package key
+// (see Package.Key)
+func parsePackageKey(key string) (arch, name, version string) {
+	parts := strings.SplitN(strings.TrimPrefix(key, "P"), " ", 4)
+	if len(parts) < 3 {
+		return "", key, ""
+	}
+
+	return parts[0], parts[1], parts[2]
+}
+
+// MirrorHistoryEntry records changes of mirror contents made by single update
+type MirrorHistoryEntry struct {
+	// Sequential number of the update, starting with 1
+	ID int
+	// UUID of the mirror
+	MirrorUUID string
+	// Time of the update
+	Time time.Time
+	// Date from Release file
+	ReleaseDate string
+	// Number of packages in the mirror after the update
+	NumPackages int
+	// Number of added, removed and updated packages
+	Added, Removed, Updated int
+	// Changes in package list
+	Changes []MirrorHistoryChange `json:",omitempty"`
+}
+
+// NewMirrorHistoryEntry creates history entry for the mirror from the diff of
+// reflists before and after update
+func NewMirrorHistoryEntry(repo *RemoteRepo, diff PackageDiffs) *MirrorHistoryEntry {
+	entry := &MirrorHistoryEntry{
+		MirrorUUID:  repo.UUID,
+		Time:        repo.LastDownloadDate,
+		ReleaseDate: repo.Meta["Date"],
+		NumPackages: repo.NumPackages(),
+		Changes:     make([]MirrorHistoryChange, len(diff)),
+	}
+
+	for i, pdiff := range diff {
+		if pdiff.Left != nil {
+			entry.Changes[i].Left = pointer.ToString(string(pdiff.Left.Key("")))
+		}
+		if pdiff.Right != nil {
+			entry.Changes[i].Right = pointer.ToString(string(pdiff.Right.Key("")))
+		}
+
+		switch {
+		case pdiff.Left == nil:
+			entry.Added++
+		case pdiff.Right == nil:
+			entry.Removed++
+		default:
+			entry.Updated++
+		}
+	}
+
+	return entry
+}
+
+// String returns short summary of the entry
+func (entry *MirrorHistoryEntry) String() string {
+	return fmt.Sprintf("#%d %s: %d packages (+%d -%d !%d)", entry.ID, entry.Time.Format("2006-01-02 15:04:05 MST"),
+		entry.NumPackages, entry.Added, entry.Removed, entry.Updated)
+}
+
+// Key is a unique id in DB
+func (entry *MirrorHistoryEntry) Key() []byte {
+	return []byte(fmt.Sprintf("H%s/%08d", entry.MirrorUUID, entry.ID))
+}
+
+// Encode does msgpack encoding of MirrorHistoryEntry
+func (entry *MirrorHistoryEntry) Encode() []byte {
+	var buf bytes.Buffer
+
+	encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+	_ = encoder.Encode(entry)
+
+	return buf.Bytes()
+}
+
+// Decode decodes msgpack representation into MirrorHistoryEntry
+func (entry *MirrorHistoryEntry) Decode(input []byte) error {
+	decoder := codec.NewDecoderBytes(input, &codec.MsgpackHandle{})
+	return decoder.Decode(entry)
+}
+
+// mirrorHistoryPrefix is a DB prefix of all history entries of the mirror
+func mirrorHistoryPrefix(mirrorUUID string) []byte {
+	return []byte("H" + mirrorUUID + "/")
+}
+
+// MirrorHistoryCollection does listing and adding of mirror history entries
+type MirrorHistoryCollection struct {
+	db database.Storage
+}
+
+// NewMirrorHistoryCollection creates MirrorHistoryCollection bound to database
+func NewMirrorHistoryCollection(db database.Storage) *MirrorHistoryCollection {
+	return &MirrorHistoryCollection{
+		db: db,
+	}
+}
+
+// Add assigns next sequential ID to the entry and saves it
+func (collection *MirrorHistoryCollection) Add(entry *MirrorHistoryEntry) error {
+	return collection.AddInTransaction(entry, collection.db)
+}
+
+// AddInTransaction assigns next sequential ID to the entry and saves it in the context of the outer transaction
+func (collection *MirrorHistoryCollection) AddInTransaction(entry *MirrorHistoryEntry, dbw database.Writer) error {
+	entry.ID = 1
+
+	keys := collection.db.KeysByPrefix(mirrorHistoryPrefix(entry.MirrorUUID))
+	if len(keys) > 0 {
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+
+		last := &MirrorHistoryEntry{}
+		encoded, err := collection.db.Get(keys[len(keys)-1])
+		if err != nil {
+			return err
+		}
+		if err = last.Decode(encoded); err != nil {
+			return err
+		}
+
+		entry.ID = last.ID + 1
+	}
+
+	return dbw.Put(entry.Key(), entry.Encode())
+}
+
+// ForMirror returns all history entries of the mirror ordered by ID,
+// if withChanges is false, list of changes is not returned
+func (collection *MirrorHistoryCollection) ForMirror(mirrorUUID string, withChanges bool) ([]*MirrorHistoryEntry, error) {
+	result := []*MirrorHistoryEntry{}
+
+	err := collection.db.ProcessByPrefix(mirrorHistoryPrefix(mirrorUUID), func(_, blob []byte) error {
+		entry := &MirrorHistoryEntry{}
+		if err := entry.Decode(blob); err != nil {
+			return err
+		}
+
+		if !withChanges {
+			entry.Changes = nil
+		}
+
+		result = append(result, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, nil
+}
+
+// ByID looks up history entry of the mirror by ID
+func (collection *MirrorHistoryCollection) ByID(mirrorUUID string, id int) (*MirrorHistoryEntry, error) {
+	encoded, err := collection.db.Get((&MirrorHistoryEntry{MirrorUUID: mirrorUUID, ID: id}).Key())
+	if err == database.ErrNotFound {
+		return nil, fmt.Errorf("history entry #%d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &MirrorHistoryEntry{}
+	return entry, entry.Decode(encoded)
+}
+
+// asOfLayouts are time formats accepted by ParseAsOf, time zone defaults to UTC
+var asOfLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ParseAsOf parses point in time for mirror snapshots, e.g. 2026-09-01T00:00Z or 2026-09-01
+func ParseAsOf(value string) (time.Time, error) {
+	for _, layout := range asOfLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("wrong time %#v, expected RFC3339 time like 2026-09-01T00:00Z or date like 2026-09-01", value)
+}
+
+// refListFromKeys builds sorted reflist from the set of package keys
+func refListFromKeys(keys map[string]bool) *PackageRefList {
+	result := NewPackageRefList()
+	result.Refs = make([][]byte, 0, len(keys))
+	for key := range keys {
+		result.Refs = append(result.Refs, []byte(key))
+	}
+	sort.Sort(result)
+
+	return result
+}
+
+// RefListAsOf reconstructs package list of the mirror as it was at the moment asOf
+//
+// Mirror should be loaded complete, history entries recorded after asOf are reverted starting
+// with the latest one. Package list can't be reconstructed before the first recorded update.
+func (collection *MirrorHistoryCollection) RefListAsOf(repo *RemoteRepo, asOf time.Time) (*PackageRefList, error) {
+	if repo.packageRefs == nil {
+		return nil, fmt.Errorf("mirror not updated")
+	}
+
+	entries, err := collection.ForMirror(repo.UUID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("mirror %s has no update history", repo.Name)
+	}
+	if asOf.Before(entries[0].Time) {
+		return nil, fmt.Errorf("history of mirror %s starts at %s, unable to reconstruct it as of %s", repo.Name,
+			entries[0].Time.Format(time.RFC3339), asOf.Format(time.RFC3339))
+	}
+
+	keys := map[string]bool{}
+	_ = repo.packageRefs.ForEach(func(key []byte) error {
+		keys[string(key)] = true
+		return nil
+	})
+
+	for i := len(entries) - 1; i >= 0 && entries[i].Time.After(asOf); i-- {
+		for _, change := range entries[i].Changes {
+			if change.Right != nil {
+				delete(keys, *change.Right)
+			}
+		}
+		for _, change := range entries[i].Changes {
+			if change.Left != nil {
+				keys[*change.Left] = true
+			}
+		}
+	}
+
+	return refListFromKeys(keys), nil
+}
+
+// RetainedRefList returns packages which are required to reconstruct mirror contents
+// at any moment during the retention period (e.g. 90d) before now
+//
+// These are packages removed or replaced by updates recorded during retention period,
+// current packages of the mirror are not included. Empty retention disables history retention.
+func (collection *MirrorHistoryCollection) RetainedRefList(mirrorUUID string, retention string, now time.Time) (*PackageRefList, error) {
+	if retention == "" {
+		return NewPackageRefList(), nil
+	}
+
+	age, err := parseRetentionAge(retention)
+	if err != nil {
+		return nil, fmt.Errorf("mirror history retention: %s", err)
+	}
+
+	entries, err := collection.ForMirror(mirrorUUID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	since := now.Add(-age)
+	keys := map[string]bool{}
+
+	for _, entry := range entries {
+		if !entry.Time.After(since) {
+			continue
+		}
+
+		for _, change := range entry.Changes {
+			if change.Left != nil {
+				keys[*change.Left] = true
+			}
+		}
+	}
+
+	return refListFromKeys(keys), nil
+}
+
+// NewSnapshotFromRepositoryAsOf creates snapshot of mirror contents as they were at the moment asOf
+//
+// All the packages should be still present in the database and their files in the package pool,
+// as they might have been removed by db cleanup since then.
+func NewSnapshotFromRepositoryAsOf(name string, repo *RemoteRepo, asOf time.Time, collectionFactory *CollectionFactory,
+	packagePool aptly.PackagePool) (*Snapshot, error) {
+	refList, err := collectionFactory.MirrorHistoryCollection().RefListAsOf(repo, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	packageCollection := collectionFactory.PackageCollection()
+	checksumStorage := collectionFactory.ChecksumCollection(nil)
+
+	// current packages of the mirror are known to be available
+	err = refList.Subtract(repo.packageRefs).ForEach(func(key []byte) error {
+		p, err := packageCollection.ByKey(key)
+		if err != nil {
+			return fmt.Errorf("package %s is not available anymore: %s", key, err)
+		}
+
+		ok, err := p.VerifyFiles(packagePool, checksumStorage)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("files of package %s are missing from the package pool", p)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := NewSnapshotFromRepository(name, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.Description = fmt.Sprintf("Snapshot from mirror %s as of %s", repo, asOf.Format(time.RFC3339))
+	snapshot.packageRefs = refList
+
+	return snapshot, nil
+}
@@ -0,0 +1,247 @@
+package deb
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/AlekSi/pointer"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type MirrorHistorySuite struct {
+	PackageListMixinSuite
+	db                database.Storage
+	collectionFactory *CollectionFactory
+	collection        *MirrorHistoryCollection
+	repo              *RemoteRepo
+}
+
+var _ = Suite(&MirrorHistorySuite{})
+
+func (s *MirrorHistorySuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collectionFactory = NewCollectionFactory(s.db)
+	s.collection = s.collectionFactory.MirrorHistoryCollection()
+	s.repo, _ = NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{}, false, false, false, false)
+	s.SetUpPackages()
+
+	for _, p := range []*Package{s.p1, s.p2, s.p3} {
+		c.Assert(s.collectionFactory.PackageCollection().Update(p), IsNil)
+	}
+}
+
+func (s *MirrorHistorySuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *MirrorHistorySuite) TestNewEntry(c *C) {
+	stanza := packageStanza.Copy()
+	stanza["Version"] = "7.41-1"
+	p4 := NewPackageFromControlFile(stanza)
+	c.Assert(s.collectionFactory.PackageCollection().Update(p4), IsNil)
+
+	before := NewPackageList()
+	_ = before.Add(s.p1)
+	_ = before.Add(s.p2)
+	after := NewPackageList()
+	_ = after.Add(p4)
+	_ = after.Add(s.p3)
+
+	diff, err := NewPackageRefListFromPackageList(before).Diff(NewPackageRefListFromPackageList(after), s.collectionFactory.PackageCollection())
+	c.Assert(err, IsNil)
+
+	s.repo.packageRefs = NewPackageRefListFromPackageList(after)
+	s.repo.Meta = Stanza{"Date": "Sat, 17 Oct 2026 08:15:00 UTC"}
+	s.repo.LastDownloadDate = time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+
+	entry := NewMirrorHistoryEntry(s.repo, diff)
+	c.Check(entry.ReleaseDate, Equals, "Sat, 17 Oct 2026 08:15:00 UTC")
+	c.Check(entry.NumPackages, Equals, 2)
+	c.Check([]int{entry.Added, entry.Removed, entry.Updated}, DeepEquals, []int{1, 1, 1})
+	c.Check(entry.String(), Equals, "#0 2026-10-18 10:00:00 UTC: 2 packages (+1 -1 !1)")
+
+	var described [][]string
+	for _, change := range entry.Changes {
+		arch, name, before, after := change.Describe()
+		described = append(described, []string{arch, name, before, after})
+	}
+	c.Check(described, DeepEquals, [][]string{
+		{"i386", "alien-arena-common", "7.40-2", "7.41-1"},
+		{"i386", "lonely-strangers", "-", "7.40-2"},
+		{"i386", "mars-invaders", "7.40-2", "-"},
+	})
+}
+
+func (s *MirrorHistorySuite) TestAddList(c *C) {
+	entries, err := s.collection.ForMirror(s.repo.UUID, true)
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 0)
+
+	for i := 0; i < 11; i++ {
+		entry := &MirrorHistoryEntry{
+			MirrorUUID: s.repo.UUID,
+			Changes:    []MirrorHistoryChange{{Left: pointer.ToString(string(s.p1.Key("")))}},
+		}
+		c.Assert(s.collection.Add(entry), IsNil)
+		c.Check(entry.ID, Equals, i+1)
+	}
+
+	c.Assert(s.collection.Add(&MirrorHistoryEntry{MirrorUUID: "other"}), IsNil)
+
+	entries, err = s.collection.ForMirror(s.repo.UUID, false)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 11)
+	c.Check(entries[10].ID, Equals, 11)
+	c.Check(entries[10].Changes, IsNil)
+
+	entry, err := s.collection.ByID(s.repo.UUID, 10)
+	c.Assert(err, IsNil)
+	c.Check(entry.ID, Equals, 10)
+	c.Check(*entry.Changes[0].Left, Equals, string(s.p1.Key("")))
+
+	_, err = s.collection.ByID(s.repo.UUID, 12)
+	c.Check(err, ErrorMatches, "history entry #12 not found")
+
+	// dropping mirror removes its history
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Add(s.repo), IsNil)
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Drop(s.repo), IsNil)
+
+	entries, err = s.collection.ForMirror(s.repo.UUID, false)
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 0)
+
+	entries, err = s.collection.ForMirror("other", false)
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 1)
+}
+
+// addHistory records mirror update at the moment t which changes mirror contents to packages
+func (s *MirrorHistorySuite) addHistory(c *C, t time.Time, packages ...*Package) {
+	after := NewPackageList()
+	for _, p := range packages {
+		_ = after.Add(p)
+	}
+	refs := NewPackageRefListFromPackageList(after)
+
+	before := s.repo.packageRefs
+	if before == nil {
+		before = NewPackageRefList()
+	}
+
+	diff, err := before.Diff(refs, s.collectionFactory.PackageCollection())
+	c.Assert(err, IsNil)
+
+	s.repo.packageRefs = refs
+	s.repo.LastDownloadDate = t
+	c.Assert(s.collection.Add(NewMirrorHistoryEntry(s.repo, diff)), IsNil)
+}
+
+func (s *MirrorHistorySuite) TestParseAsOf(c *C) {
+	for value, expected := range map[string]time.Time{
+		"2026-09-01T00:00Z":         time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+		"2026-09-01T10:20:30+02:00": time.Date(2026, 9, 1, 8, 20, 30, 0, time.UTC),
+		"2026-09-01T10:20":          time.Date(2026, 9, 1, 10, 20, 0, 0, time.UTC),
+		"2026-09-01":                time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		t, err := ParseAsOf(value)
+		c.Assert(err, IsNil)
+		c.Check(t.Equal(expected), Equals, true, Commentf("%s", value))
+	}
+
+	_, err := ParseAsOf("yesterday")
+	c.Check(err, ErrorMatches, "wrong time \"yesterday\".*")
+}
+
+func (s *MirrorHistorySuite) TestRefListAsOf(c *C) {
+	t1 := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	s.repo.packageRefs = NewPackageRefList()
+	_, err := s.collection.RefListAsOf(s.repo, t2)
+	c.Check(err, ErrorMatches, "mirror yandex has no update history")
+
+	s.repo.packageRefs = nil
+	s.addHistory(c, t1, s.p1, s.p2)
+	s.addHistory(c, t2, s.p2, s.p3)
+
+	_, err = s.collection.RefListAsOf(s.repo, t1.Add(-time.Hour))
+	c.Check(err, ErrorMatches, "history of mirror yandex starts at 2026-09-01T00:00:00Z, unable to reconstruct it as of 2026-08-31T23:00:00Z")
+
+	refs, err := s.collection.RefListAsOf(s.repo, t1)
+	c.Assert(err, IsNil)
+	c.Check(refs.Strings(), DeepEquals, []string{string(s.p1.Key("")), string(s.p2.Key(""))})
+
+	refs, err = s.collection.RefListAsOf(s.repo, t2.Add(-time.Hour))
+	c.Assert(err, IsNil)
+	c.Check(refs.Strings(), DeepEquals, []string{string(s.p1.Key("")), string(s.p2.Key(""))})
+
+	refs, err = s.collection.RefListAsOf(s.repo, t2)
+	c.Assert(err, IsNil)
+	c.Check(refs.Strings(), DeepEquals, s.repo.packageRefs.Strings())
+}
+
+func (s *MirrorHistorySuite) TestRetainedRefList(c *C) {
+	t1 := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	s.addHistory(c, t1, s.p1, s.p2)
+	s.addHistory(c, t2, s.p2, s.p3)
+
+	refs, err := s.collection.RetainedRefList(s.repo.UUID, "", t2)
+	c.Assert(err, IsNil)
+	c.Check(refs.Len(), Equals, 0)
+
+	refs, err = s.collection.RetainedRefList(s.repo.UUID, "30d", t2.Add(24*time.Hour))
+	c.Assert(err, IsNil)
+	c.Check(refs.Strings(), DeepEquals, []string{string(s.p1.Key(""))})
+
+	refs, err = s.collection.RetainedRefList(s.repo.UUID, "30d", t2.Add(31*24*time.Hour))
+	c.Assert(err, IsNil)
+	c.Check(refs.Len(), Equals, 0)
+
+	_, err = s.collection.RetainedRefList(s.repo.UUID, "month", t2)
+	c.Check(err, ErrorMatches, "mirror history retention: wrong age \"month\".*")
+}
+
+func (s *MirrorHistorySuite) TestNewSnapshotAsOf(c *C) {
+	t1 := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tmpFilepath := filepath.Join(c.MkDir(), "file")
+	c.Assert(os.WriteFile(tmpFilepath, []byte("alien-arena"), 0644), IsNil)
+
+	pf := s.p1.Files()[0]
+	checksums, err := utils.ChecksumsForFile(tmpFilepath)
+	c.Assert(err, IsNil)
+	pf.Checksums = checksums
+	s.p1.UpdateFiles(PackageFiles{pf})
+	c.Assert(s.collectionFactory.PackageCollection().Update(s.p1), IsNil)
+
+	s.addHistory(c, t1, s.p1, s.p2)
+	s.addHistory(c, t2, s.p2, s.p3)
+
+	packagePool := files.NewPackagePool(c.MkDir(), false)
+
+	_, err = NewSnapshotFromRepositoryAsOf("snap", s.repo, t1, s.collectionFactory, packagePool)
+	c.Check(err, ErrorMatches, "files of package alien-arena-common_7.40-2_i386 are missing from the package pool")
+
+	_, err = packagePool.Import(tmpFilepath, pf.Filename, &pf.Checksums, false, s.collectionFactory.ChecksumCollection(nil))
+	c.Assert(err, IsNil)
+
+	snapshot, err := NewSnapshotFromRepositoryAsOf("snap", s.repo, t1, s.collectionFactory, packagePool)
+	c.Assert(err, IsNil)
+	c.Check(snapshot.Description, Equals, "Snapshot from mirror [yandex]: http://mirror.yandex.ru/debian/ squeeze as of 2026-09-01T00:00:00Z")
+	c.Check(snapshot.SourceIDs, DeepEquals, []string{s.repo.UUID})
+	c.Check(snapshot.RefList().Strings(), DeepEquals, []string{string(s.p1.Key("")), string(s.p2.Key(""))})
+
+	c.Assert(s.collectionFactory.PackageCollection().DeleteByKey(s.p1.Key(""), s.db), IsNil)
+	_, err = NewSnapshotFromRepositoryAsOf("snap", s.repo, t1, s.collectionFactory, packagePool)
+	c.Check(err, ErrorMatches, "package Pi386 alien-arena-common 7.40-2 .* is not available anymore: .*")
+}
@@ -300,6 +300,11 @@ func (p *Package) GetField(name string) string {
 			return PackageTypeUdeb
 		}
 		return PackageTypeBinary
+	case "$License":
+		if licenses := p.Licenses(); licenses != nil {
+			return strings.Join(licenses.Licenses, ", ")
+		}
+		return ""
 	case "Name":
 		return p.Name
 	case "Version":
@@ -560,6 +565,20 @@ func (p *Package) Contents(packagePool aptly.PackagePool, progress aptly.Progres
 	return p.collection.loadContents(p, packagePool, progress)
 }
 
+// Licenses returns licenses extracted from the package, or nil if licenses haven't been extracted
+func (p *Package) Licenses() *PackageLicenses {
+	if p.collection == nil {
+		return nil
+	}
+
+	licenses, err := p.collection.LicensesByKey(p.Key(""))
+	if err != nil {
+		return nil
+	}
+
+	return licenses
+}
+
 // CalculateContents looks up contents in package file
 func (p *Package) CalculateContents(packagePool aptly.PackagePool, progress aptly.Progress) ([]string, error) {
 	if p.IsSource {
@@ -201,6 +201,37 @@ func (collection *PackageCollection) loadContents(p *Package, packagePool aptly.
 	return contents
 }
 
+// LicensesByKey loads licenses extracted from the package, database.ErrNotFound is returned
+// if licenses haven't been extracted yet
+func (collection *PackageCollection) LicensesByKey(key []byte) (*PackageLicenses, error) {
+	encoded, err := collection.db.Get(append([]byte("xL"), key...))
+	if err != nil {
+		return nil, err
+	}
+
+	licenses := &PackageLicenses{}
+
+	decoder := codec.NewDecoderBytes(encoded, collection.codecHandle)
+	err = decoder.Decode(licenses)
+	if err != nil {
+		return nil, err
+	}
+
+	return licenses, nil
+}
+
+// UpdateLicenses saves licenses extracted from the package
+func (collection *PackageCollection) UpdateLicenses(p *Package, licenses *PackageLicenses) error {
+	var buf bytes.Buffer
+
+	err := codec.NewEncoder(&buf, collection.codecHandle).Encode(licenses)
+	if err != nil {
+		return err
+	}
+
+	return collection.db.Put(p.Key("xL"), buf.Bytes())
+}
+
 // Update adds or updates information about package in DB
 func (collection *PackageCollection) Update(p *Package) error {
 	transaction, err := collection.db.OpenTransaction()
@@ -290,7 +321,8 @@ func (collection *PackageCollection) AllPackageRefs() *PackageRefList {
 
 // DeleteByKey deletes package in DB by key
 func (collection *PackageCollection) DeleteByKey(key []byte, dbw database.Writer) error {
-	for _, key := range [][]byte{key, append([]byte("xF"), key...), append([]byte("xD"), key...), append([]byte("xE"), key...)} {
+	for _, key := range [][]byte{key, append([]byte("xF"), key...), append([]byte("xD"), key...), append([]byte("xE"), key...),
+		append([]byte("xL"), key...)} {
 		err := dbw.Delete(key)
 		if err != nil {
 			return err
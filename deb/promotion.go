@@ -0,0 +1,390 @@
+package deb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Promotion request states
+const (
+	// PromotionPending is a state of request waiting for approvals
+	PromotionPending = "pending"
+	// PromotionApproved is a state of request which collected required approvals, but hasn't been executed yet
+	PromotionApproved = "approved"
+	// PromotionRejected is a state of request rejected by one of the approvers
+	PromotionRejected = "rejected"
+	// PromotionPromoted is a state of request which has been executed
+	PromotionPromoted = "promoted"
+	// PromotionFailed is a state of request which failed to execute
+	PromotionFailed = "failed"
+)
+
+// PromotionEvent is a single record of promotion request audit trail
+type PromotionEvent struct {
+	// Time of the event
+	Time time.Time
+	// Action: created, approved, rejected, promoted or failed
+	Action string
+	// User who performed the action
+	User string `json:",omitempty"`
+	// Comment or error message
+	Comment string `json:",omitempty"`
+}
+
+// PromotionRequest is a request to promote packages along promotion path
+// from one local repo to another
+type PromotionRequest struct {
+	// Sequential number of the request, starting with 1
+	ID int
+	// Name of promotion path
+	Path string
+	// Source and destination local repos, copied from promotion path
+	From, To string
+	// Whether packages are moved instead of being copied
+	Move bool
+	// Package queries
+	Queries []string
+	// Whether dependencies are promoted as well
+	WithDeps bool
+	// Number of approvals required to execute promotion
+	RequiredApprovals int
+	// State of the request
+	State string
+	// User who created the request
+	RequestedBy string
+	// Users who approved the request
+	Approvers []string
+	// Packages promoted
+	Packages []string `json:",omitempty"`
+	// Audit trail
+	Events []PromotionEvent
+}
+
+// NewPromotionRequest creates promotion request along path
+func NewPromotionRequest(pathName string, path utils.PromotionPath, queries []string, withDeps bool, user, comment string, now time.Time) (*PromotionRequest, error) {
+	if path.From == "" || path.To == "" {
+		return nil, fmt.Errorf("promotion path %s should have both from and to repos", pathName)
+	}
+	if path.From == path.To {
+		return nil, fmt.Errorf("promotion path %s has the same source and destination", pathName)
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no package queries specified")
+	}
+	if user == "" {
+		return nil, fmt.Errorf("user is required")
+	}
+
+	request := &PromotionRequest{
+		Path:              pathName,
+		From:              path.From,
+		To:                path.To,
+		Move:              path.Move,
+		Queries:           queries,
+		WithDeps:          withDeps,
+		RequiredApprovals: path.Approvals,
+		State:             PromotionPending,
+		RequestedBy:       user,
+		Approvers:         []string{},
+		Events:            []PromotionEvent{{Time: now, Action: "created", User: user, Comment: comment}},
+	}
+
+	if request.RequiredApprovals <= 0 {
+		request.State = PromotionApproved
+	}
+
+	return request, nil
+}
+
+// String returns short summary of the request
+func (request *PromotionRequest) String() string {
+	return fmt.Sprintf("#%d %s: %s -> %s [%s] (%d/%d approvals)", request.ID, request.Path, request.From, request.To,
+		request.State, len(request.Approvers), request.RequiredApprovals)
+}
+
+// Ready returns true if request collected required approvals and should be executed
+func (request *PromotionRequest) Ready() bool {
+	return request.State == PromotionApproved
+}
+
+// Approve records approval of the request by user
+//
+// Requester can't approve own request and every user is counted once.
+func (request *PromotionRequest) Approve(user, comment string, now time.Time) error {
+	if request.State != PromotionPending {
+		return fmt.Errorf("promotion request #%d is %s", request.ID, request.State)
+	}
+	if user == "" {
+		return fmt.Errorf("user is required")
+	}
+	if user == request.RequestedBy {
+		return fmt.Errorf("promotion request #%d can't be approved by requester", request.ID)
+	}
+	if utils.StrSliceHasItem(request.Approvers, user) {
+		return fmt.Errorf("promotion request #%d has already been approved by %s", request.ID, user)
+	}
+
+	request.Approvers = append(request.Approvers, user)
+	request.Events = append(request.Events, PromotionEvent{Time: now, Action: PromotionApproved, User: user, Comment: comment})
+
+	if len(request.Approvers) >= request.RequiredApprovals {
+		request.State = PromotionApproved
+	}
+
+	return nil
+}
+
+// Reject marks request as rejected by user
+func (request *PromotionRequest) Reject(user, comment string, now time.Time) error {
+	if request.State != PromotionPending {
+		return fmt.Errorf("promotion request #%d is %s", request.ID, request.State)
+	}
+	if user == "" {
+		return fmt.Errorf("user is required")
+	}
+
+	request.State = PromotionRejected
+	request.Events = append(request.Events, PromotionEvent{Time: now, Action: PromotionRejected, User: user, Comment: comment})
+
+	return nil
+}
+
+// Execute copies (or moves) packages matching request queries from source to destination repo
+//
+// Packages should pass validation configured for destination repo, retention policy of destination
+// repo is applied after promotion. Outcome is recorded in the request, which should be saved afterwards,
+// error is returned if promotion failed.
+func (request *PromotionRequest) Execute(collectionFactory *CollectionFactory, parseQuery parseQuery, dependencyOptions int,
+	architectures []string, pool aptly.PackagePool, verifierProvider VerifierProvider, progress aptly.Progress, now time.Time) error {
+	if !request.Ready() {
+		return fmt.Errorf("promotion request #%d is %s with %d/%d approvals", request.ID, request.State,
+			len(request.Approvers), request.RequiredApprovals)
+	}
+
+	packages, removed, err := request.promote(collectionFactory, parseQuery, dependencyOptions, architectures, pool, verifierProvider,
+		progress, now)
+	if err != nil {
+		request.State = PromotionFailed
+		request.Events = append(request.Events, PromotionEvent{Time: now, Action: PromotionFailed, Comment: err.Error()})
+		return err
+	}
+
+	comment := fmt.Sprintf("%d packages promoted from %s to %s", len(packages), request.From, request.To)
+	if len(removed) > 0 {
+		comment += fmt.Sprintf(", %d packages removed from %s by retention policy", len(removed), request.To)
+	}
+
+	request.State = PromotionPromoted
+	request.Packages = packages
+	request.Events = append(request.Events, PromotionEvent{Time: now, Action: PromotionPromoted, Comment: comment})
+
+	return nil
+}
+
+// promote does actual copying (moving) of packages, returning list of promoted packages and
+// packages removed from destination repo by its retention policy
+func (request *PromotionRequest) promote(collectionFactory *CollectionFactory, parseQuery parseQuery, dependencyOptions int,
+	architectures []string, pool aptly.PackagePool, verifierProvider VerifierProvider, progress aptly.Progress,
+	now time.Time) ([]string, []string, error) {
+	localRepoCollection := collectionFactory.LocalRepoCollection()
+
+	srcRepo, err := localRepoCollection.ByName(request.From)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = localRepoCollection.LoadComplete(srcRepo); err != nil {
+		return nil, nil, err
+	}
+
+	dstRepo, err := localRepoCollection.ByName(request.To)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = localRepoCollection.LoadComplete(dstRepo); err != nil {
+		return nil, nil, err
+	}
+
+	srcList, err := NewPackageListFromRefList(srcRepo.RefList(), collectionFactory.PackageCollection(), progress)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load packages: %s", err)
+	}
+
+	dstList, err := NewPackageListFromRefList(dstRepo.RefList(), collectionFactory.PackageCollection(), progress)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load packages: %s", err)
+	}
+
+	queries := make([]PackageQuery, len(request.Queries))
+	for i, value := range request.Queries {
+		queries[i], err = parseQuery(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to parse query %s: %s", value, err)
+		}
+	}
+
+	toProcess, err := srcList.FilterForCopy(dstList, queries, request.WithDeps, architectures, dependencyOptions, progress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if toProcess.Len() == 0 {
+		return nil, nil, fmt.Errorf("no packages found in %s matching queries", request.From)
+	}
+
+	validation, err := dstRepo.Validation.Prepare(verifierProvider)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	packages := []string{}
+
+	err = toProcess.ForEach(func(p *Package) error {
+		if errs := validation.Validate(p, NewValidatedPoolFile(p, pool)); len(errs) > 0 {
+			return fmt.Errorf("package %s failed validation: %s", p, errors.Join(errs...))
+		}
+
+		if err := dstList.Add(p); err != nil {
+			return err
+		}
+
+		if request.Move {
+			srcList.Remove(p)
+		}
+
+		packages = append(packages, p.String())
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(packages)
+
+	removed, err := ApplyRetentionPolicy(dstRepo.Retention, dstRepo.UUID, dstList, collectionFactory, now)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to apply retention policy: %s", err)
+	}
+
+	dstRepo.UpdateRefList(NewPackageRefListFromPackageList(dstList))
+	if err = localRepoCollection.Update(dstRepo); err != nil {
+		return nil, nil, fmt.Errorf("unable to save: %s", err)
+	}
+
+	if request.Move {
+		srcRepo.UpdateRefList(NewPackageRefListFromPackageList(srcList))
+		if err = localRepoCollection.Update(srcRepo); err != nil {
+			return nil, nil, fmt.Errorf("unable to save: %s", err)
+		}
+	}
+
+	return packages, removed, nil
+}
+
+// Key is a unique id in DB
+func (request *PromotionRequest) Key() []byte {
+	return []byte(fmt.Sprintf("M%08d", request.ID))
+}
+
+// Encode does msgpack encoding of PromotionRequest
+func (request *PromotionRequest) Encode() []byte {
+	var buf bytes.Buffer
+
+	encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+	_ = encoder.Encode(request)
+
+	return buf.Bytes()
+}
+
+// Decode decodes msgpack representation into PromotionRequest
+func (request *PromotionRequest) Decode(input []byte) error {
+	decoder := codec.NewDecoderBytes(input, &codec.MsgpackHandle{})
+	return decoder.Decode(request)
+}
+
+// PromotionCollection does listing, adding and updating of promotion requests
+type PromotionCollection struct {
+	db database.Storage
+}
+
+// NewPromotionCollection creates PromotionCollection bound to database
+func NewPromotionCollection(db database.Storage) *PromotionCollection {
+	return &PromotionCollection{
+		db: db,
+	}
+}
+
+// Add assigns next sequential ID to the request and saves it
+func (collection *PromotionCollection) Add(request *PromotionRequest) error {
+	request.ID = 1
+
+	keys := collection.db.KeysByPrefix([]byte("M"))
+	if len(keys) > 0 {
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+
+		last := &PromotionRequest{}
+		encoded, err := collection.db.Get(keys[len(keys)-1])
+		if err != nil {
+			return err
+		}
+		if err = last.Decode(encoded); err != nil {
+			return err
+		}
+
+		request.ID = last.ID + 1
+	}
+
+	return collection.db.Put(request.Key(), request.Encode())
+}
+
+// Update stores updated request
+func (collection *PromotionCollection) Update(request *PromotionRequest) error {
+	return collection.db.Put(request.Key(), request.Encode())
+}
+
+// ByID looks up promotion request by ID
+func (collection *PromotionCollection) ByID(id int) (*PromotionRequest, error) {
+	encoded, err := collection.db.Get((&PromotionRequest{ID: id}).Key())
+	if err == database.ErrNotFound {
+		return nil, fmt.Errorf("promotion request #%d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	request := &PromotionRequest{}
+	return request, request.Decode(encoded)
+}
+
+// List returns promotion requests ordered by ID, if state is not empty,
+// only requests in that state are returned
+func (collection *PromotionCollection) List(state string) ([]*PromotionRequest, error) {
+	result := []*PromotionRequest{}
+
+	err := collection.db.ProcessByPrefix([]byte("M"), func(_, blob []byte) error {
+		request := &PromotionRequest{}
+		if err := request.Decode(blob); err != nil {
+			return err
+		}
+
+		if state == "" || request.State == state {
+			result = append(result, request)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, nil
+}
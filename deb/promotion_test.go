@@ -0,0 +1,247 @@
+package deb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type PromotionSuite struct {
+	db                database.Storage
+	collectionFactory *CollectionFactory
+	dev, staging      *LocalRepo
+	packagePool       aptly.PackagePool
+	now               time.Time
+	parseQuery        func(string) (PackageQuery, error)
+}
+
+var _ = Suite(&PromotionSuite{})
+
+func (s *PromotionSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collectionFactory = NewCollectionFactory(s.db)
+	s.packagePool = files.NewPackagePool(c.MkDir(), false)
+	s.now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.parseQuery = func(q string) (PackageQuery, error) {
+		return &FieldQuery{Field: "Name", Relation: VersionEqual, Value: q}, nil
+	}
+
+	list := NewPackageList()
+	for _, name := range []string{"alien-arena-common", "alien-arena-server"} {
+		stanza := packageStanza.Copy()
+		stanza["Package"] = name
+		p := NewPackageFromControlFile(stanza)
+		c.Assert(s.collectionFactory.PackageCollection().Update(p), IsNil)
+		c.Assert(list.Add(p), IsNil)
+	}
+
+	s.dev = NewLocalRepo("dev", "")
+	s.dev.UpdateRefList(NewPackageRefListFromPackageList(list))
+	c.Assert(s.collectionFactory.LocalRepoCollection().Add(s.dev), IsNil)
+
+	s.staging = NewLocalRepo("staging", "")
+	c.Assert(s.collectionFactory.LocalRepoCollection().Add(s.staging), IsNil)
+}
+
+func (s *PromotionSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *PromotionSuite) repoPackages(c *C, name string) []string {
+	collection := s.collectionFactory.LocalRepoCollection()
+
+	repo, err := collection.ByName(name)
+	c.Assert(err, IsNil)
+	c.Assert(collection.LoadComplete(repo), IsNil)
+
+	list, err := NewPackageListFromRefList(repo.RefList(), s.collectionFactory.PackageCollection(), nil)
+	c.Assert(err, IsNil)
+
+	return list.FullNames()
+}
+
+func (s *PromotionSuite) TestNewPromotionRequest(c *C) {
+	path := utils.PromotionPath{From: "dev", To: "staging", Approvals: 2}
+
+	request, err := NewPromotionRequest("staging", path, []string{"alien-arena-common"}, false, "alice", "please", s.now)
+	c.Assert(err, IsNil)
+	c.Check(request.State, Equals, PromotionPending)
+	c.Check(request.RequiredApprovals, Equals, 2)
+	c.Check(request.Events, DeepEquals, []PromotionEvent{{Time: s.now, Action: "created", User: "alice", Comment: "please"}})
+
+	request, err = NewPromotionRequest("staging", utils.PromotionPath{From: "dev", To: "staging"}, []string{"a"}, false, "alice", "", s.now)
+	c.Assert(err, IsNil)
+	c.Check(request.State, Equals, PromotionApproved)
+	c.Check(request.Ready(), Equals, true)
+
+	_, err = NewPromotionRequest("staging", utils.PromotionPath{From: "dev"}, []string{"a"}, false, "alice", "", s.now)
+	c.Check(err, ErrorMatches, "promotion path staging should have both from and to repos")
+
+	_, err = NewPromotionRequest("staging", utils.PromotionPath{From: "dev", To: "dev"}, []string{"a"}, false, "alice", "", s.now)
+	c.Check(err, ErrorMatches, "promotion path staging has the same source and destination")
+
+	_, err = NewPromotionRequest("staging", path, nil, false, "alice", "", s.now)
+	c.Check(err, ErrorMatches, "no package queries specified")
+
+	_, err = NewPromotionRequest("staging", path, []string{"a"}, false, "", "", s.now)
+	c.Check(err, ErrorMatches, "user is required")
+}
+
+func (s *PromotionSuite) TestApproveReject(c *C) {
+	request, _ := NewPromotionRequest("staging", utils.PromotionPath{From: "dev", To: "staging", Approvals: 2},
+		[]string{"a"}, false, "alice", "", s.now)
+	request.ID = 1
+
+	c.Check(request.Approve("alice", "", s.now), ErrorMatches, "promotion request #1 can't be approved by requester")
+	c.Check(request.Approve("", "", s.now), ErrorMatches, "user is required")
+
+	c.Assert(request.Approve("bob", "lgtm", s.now), IsNil)
+	c.Check(request.State, Equals, PromotionPending)
+	c.Check(request.Ready(), Equals, false)
+	c.Check(request.Approve("bob", "", s.now), ErrorMatches, "promotion request #1 has already been approved by bob")
+
+	c.Assert(request.Approve("carol", "", s.now), IsNil)
+	c.Check(request.State, Equals, PromotionApproved)
+	c.Check(request.Ready(), Equals, true)
+	c.Check(request.Approvers, DeepEquals, []string{"bob", "carol"})
+
+	c.Check(request.Approve("dave", "", s.now), ErrorMatches, "promotion request #1 is approved")
+	c.Check(request.Reject("dave", "", s.now), ErrorMatches, "promotion request #1 is approved")
+
+	request, _ = NewPromotionRequest("staging", utils.PromotionPath{From: "dev", To: "staging", Approvals: 1},
+		[]string{"a"}, false, "alice", "", s.now)
+	request.ID = 2
+
+	c.Assert(request.Reject("bob", "not yet", s.now), IsNil)
+	c.Check(request.State, Equals, PromotionRejected)
+	c.Check(request.Events[1], DeepEquals, PromotionEvent{Time: s.now, Action: PromotionRejected, User: "bob", Comment: "not yet"})
+	c.Check(request.Approve("carol", "", s.now), ErrorMatches, "promotion request #2 is rejected")
+}
+
+func (s *PromotionSuite) TestExecuteCopy(c *C) {
+	request, _ := NewPromotionRequest("staging", utils.PromotionPath{From: "dev", To: "staging", Approvals: 1},
+		[]string{"alien-arena-common"}, false, "alice", "", s.now)
+	request.ID = 1
+
+	c.Check(request.Execute(s.collectionFactory, s.parseQuery, 0, nil, s.packagePool, nil, nil, s.now), ErrorMatches,
+		"promotion request #1 is pending with 0/1 approvals")
+
+	c.Assert(request.Approve("bob", "", s.now), IsNil)
+	c.Assert(request.Execute(s.collectionFactory, s.parseQuery, 0, nil, s.packagePool, nil, nil, s.now), IsNil)
+
+	c.Check(request.State, Equals, PromotionPromoted)
+	c.Check(request.Packages, DeepEquals, []string{"alien-arena-common_7.40-2_i386"})
+	c.Check(request.Events[2].Action, Equals, PromotionPromoted)
+	c.Check(request.Events[2].Comment, Equals, "1 packages promoted from dev to staging")
+
+	c.Check(s.repoPackages(c, "staging"), DeepEquals, []string{"alien-arena-common_7.40-2_i386"})
+	c.Check(s.repoPackages(c, "dev"), HasLen, 2)
+}
+
+func (s *PromotionSuite) TestExecuteMove(c *C) {
+	request, _ := NewPromotionRequest("staging", utils.PromotionPath{From: "dev", To: "staging", Move: true},
+		[]string{"alien-arena-server"}, false, "alice", "", s.now)
+
+	c.Assert(request.Execute(s.collectionFactory, s.parseQuery, 0, nil, s.packagePool, nil, nil, s.now), IsNil)
+
+	c.Check(s.repoPackages(c, "staging"), DeepEquals, []string{"alien-arena-server_7.40-2_i386"})
+	c.Check(s.repoPackages(c, "dev"), DeepEquals, []string{"alien-arena-common_7.40-2_i386"})
+}
+
+func (s *PromotionSuite) TestExecuteFailed(c *C) {
+	request, _ := NewPromotionRequest("staging", utils.PromotionPath{From: "dev", To: "staging"},
+		[]string{"missing"}, false, "alice", "", s.now)
+
+	c.Check(request.Execute(s.collectionFactory, s.parseQuery, 0, nil, s.packagePool, nil, nil, s.now), ErrorMatches,
+		"no packages found in dev matching queries")
+	c.Check(request.State, Equals, PromotionFailed)
+	c.Check(request.Events[1], DeepEquals, PromotionEvent{Time: s.now, Action: PromotionFailed,
+		Comment: "no packages found in dev matching queries"})
+
+	request, _ = NewPromotionRequest("prod", utils.PromotionPath{From: "staging", To: "prod"},
+		[]string{"a"}, false, "alice", "", s.now)
+
+	c.Check(request.Execute(s.collectionFactory, func(string) (PackageQuery, error) { return nil, fmt.Errorf("bad query") },
+		0, nil, s.packagePool, nil, nil, s.now), ErrorMatches, "local repo with name prod not found")
+}
+
+func (s *PromotionSuite) TestExecuteValidation(c *C) {
+	var err error
+	s.staging.Validation, err = NewValidation([]string{ValidatorArchitecture}, []string{"amd64"}, 0, nil, false)
+	c.Assert(err, IsNil)
+	c.Assert(s.collectionFactory.LocalRepoCollection().Update(s.staging), IsNil)
+
+	request, _ := NewPromotionRequest("staging", utils.PromotionPath{From: "dev", To: "staging"},
+		[]string{"alien-arena-common"}, false, "alice", "", s.now)
+
+	c.Check(request.Execute(s.collectionFactory, s.parseQuery, 0, nil, s.packagePool, nil, nil, s.now), ErrorMatches,
+		"package alien-arena-common_7.40-2_i386 failed validation: .*")
+	c.Check(request.State, Equals, PromotionFailed)
+	c.Check(s.repoPackages(c, "staging"), HasLen, 0)
+}
+
+func (s *PromotionSuite) TestExecuteRetention(c *C) {
+	stanza := packageStanza.Copy()
+	stanza["Version"] = "7.40-1"
+	old := NewPackageFromControlFile(stanza)
+	c.Assert(s.collectionFactory.PackageCollection().Update(old), IsNil)
+
+	list := NewPackageList()
+	c.Assert(list.Add(old), IsNil)
+
+	var err error
+	s.staging.Retention, err = NewRetentionPolicy(1, "")
+	c.Assert(err, IsNil)
+	s.staging.UpdateRefList(NewPackageRefListFromPackageList(list))
+	c.Assert(s.collectionFactory.LocalRepoCollection().Update(s.staging), IsNil)
+
+	request, _ := NewPromotionRequest("staging", utils.PromotionPath{From: "dev", To: "staging"},
+		[]string{"alien-arena-common"}, false, "alice", "", s.now)
+
+	c.Assert(request.Execute(s.collectionFactory, s.parseQuery, 0, nil, s.packagePool, nil, nil, s.now), IsNil)
+	c.Check(request.Events[1].Comment, Equals, "1 packages promoted from dev to staging, 1 packages removed from staging by retention policy")
+	c.Check(s.repoPackages(c, "staging"), DeepEquals, []string{"alien-arena-common_7.40-2_i386"})
+}
+
+func (s *PromotionSuite) TestCollection(c *C) {
+	collection := s.collectionFactory.PromotionCollection()
+
+	list, err := collection.List("")
+	c.Assert(err, IsNil)
+	c.Check(list, HasLen, 0)
+
+	_, err = collection.ByID(1)
+	c.Check(err, ErrorMatches, "promotion request #1 not found")
+
+	for i := 0; i < 3; i++ {
+		request, _ := NewPromotionRequest("staging", utils.PromotionPath{From: "dev", To: "staging", Approvals: 1},
+			[]string{"a"}, false, "alice", "", s.now)
+		c.Assert(collection.Add(request), IsNil)
+		c.Check(request.ID, Equals, i+1)
+	}
+
+	request, err := collection.ByID(2)
+	c.Assert(err, IsNil)
+	c.Assert(request.Reject("bob", "", s.now), IsNil)
+	c.Assert(collection.Update(request), IsNil)
+
+	list, err = collection.List("")
+	c.Assert(err, IsNil)
+	c.Check(list, HasLen, 3)
+	c.Check(list[0].ID, Equals, 1)
+	c.Check(list[2].ID, Equals, 3)
+
+	list, err = collection.List(PromotionRejected)
+	c.Assert(err, IsNil)
+	c.Assert(list, HasLen, 1)
+	c.Check(list[0].ID, Equals, 2)
+	c.Check(list[0].Events, HasLen, 2)
+	c.Check(list[0].String(), Equals, "#2 staging: dev -> staging [rejected] (0/1 approvals)")
+}
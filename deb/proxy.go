@@ -0,0 +1,457 @@
+package deb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// proxyFile is a reference to file of the package from the remote repository
+type proxyFile struct {
+	pkg      *Package
+	filename string
+}
+
+// lookup returns pointer to the file entry of the package
+func (file proxyFile) lookup() (*PackageFile, error) {
+	files := file.pkg.Files()
+	for i := range files {
+		if files[i].Filename == file.filename {
+			return &files[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("file %s not found in package %s", file.filename, file.pkg)
+}
+
+// releaseRecorder is a downloader which keeps contents of Release files
+// downloaded while fetching Release file of the mirror
+type releaseRecorder struct {
+	aptly.Downloader
+
+	names    map[string]string
+	contents map[string][]byte
+}
+
+func newReleaseRecorder(downloader aptly.Downloader, repo *RemoteRepo) *releaseRecorder {
+	recorder := &releaseRecorder{
+		Downloader: downloader,
+		names:      map[string]string{},
+		contents:   map[string][]byte{},
+	}
+
+	for _, name := range []string{"InRelease", "Release", "Release.gpg"} {
+		recorder.names[repo.ReleaseURL(name).String()] = name
+	}
+
+	return recorder
+}
+
+// Download implements aptly.Downloader
+func (recorder *releaseRecorder) Download(ctx context.Context, url string, destination string) error {
+	return recorder.DownloadWithChecksum(ctx, url, destination, nil, false)
+}
+
+// DownloadWithChecksum implements aptly.Downloader
+func (recorder *releaseRecorder) DownloadWithChecksum(ctx context.Context, url string, destination string,
+	expected *utils.ChecksumInfo, ignoreMismatch bool) error {
+	err := recorder.Downloader.DownloadWithChecksum(ctx, url, destination, expected, ignoreMismatch)
+	if err != nil {
+		return err
+	}
+
+	if name, ok := recorder.names[url]; ok {
+		recorder.contents[name], err = os.ReadFile(destination)
+	}
+
+	return err
+}
+
+// verified returns contents of Release files which were used by successful fetch
+func (recorder *releaseRecorder) verified() map[string][]byte {
+	if _, ok := recorder.contents["Release.gpg"]; ok {
+		// InRelease was downloaded, but failed verification
+		delete(recorder.contents, "InRelease")
+	}
+
+	return recorder.contents
+}
+
+// MirrorProxy is a pull-through caching proxy for the remote repository
+//
+// Proxy serves index files (dists/) and package files (pool/) of the mirror to apt clients.
+// Release files are served as they were fetched and verified during last refresh, other index
+// files are fetched from upstream on each request and verified against checksums from
+// signed Release file. Package files are fetched from upstream on the first request, verified
+// against checksums from package indexes and imported into the package pool, package is added
+// to the mirror when all its files are cached.
+type MirrorProxy struct {
+	repo              *RemoteRepo
+	collectionFactory *CollectionFactory
+	packagePool       aptly.PackagePool
+	downloader        aptly.Downloader
+	verifier          pgp.Verifier
+	ignoreSignatures  bool
+	refreshInterval   time.Duration
+	releaseDir        string
+
+	// serializes refreshes, which are performed without holding mu
+	refreshMu sync.Mutex
+
+	// protects all the fields below, the mirror and access to the database
+	mu          sync.Mutex
+	files       map[string]proxyFile
+	release     map[string][]byte
+	lastRefresh time.Time
+}
+
+// NewMirrorProxy creates proxy for the remote repository, mirror should be loaded completely
+//
+// Release file and package indexes are re-fetched from upstream when clients request Release file,
+// but not more often than refreshInterval.
+func NewMirrorProxy(repo *RemoteRepo, collectionFactory *CollectionFactory, packagePool aptly.PackagePool,
+	downloader aptly.Downloader, verifier pgp.Verifier, ignoreSignatures bool, refreshInterval time.Duration) *MirrorProxy {
+	return &MirrorProxy{
+		repo:              repo,
+		collectionFactory: collectionFactory,
+		packagePool:       packagePool,
+		downloader:        downloader,
+		verifier:          verifier,
+		ignoreSignatures:  ignoreSignatures,
+		refreshInterval:   refreshInterval,
+		releaseDir:        strings.TrimPrefix(repo.IndexesRootURL().Path, repo.archiveRootURL.Path),
+	}
+}
+
+// Refresh fetches Release file and package indexes from upstream
+//
+// Mirror is marked as being updated while refresh is in progress.
+func (proxy *MirrorProxy) Refresh(ctx context.Context, progress aptly.Progress) error {
+	proxy.refreshMu.Lock()
+	defer proxy.refreshMu.Unlock()
+
+	return proxy.refresh(ctx, progress)
+}
+
+// refreshIfStale refreshes the mirror unless it was refreshed within refresh interval
+func (proxy *MirrorProxy) refreshIfStale(ctx context.Context) error {
+	proxy.refreshMu.Lock()
+	defer proxy.refreshMu.Unlock()
+
+	proxy.mu.Lock()
+	stale := time.Since(proxy.lastRefresh) > proxy.refreshInterval
+	proxy.mu.Unlock()
+
+	if !stale {
+		return nil
+	}
+
+	return proxy.refresh(ctx, nil)
+}
+
+func (proxy *MirrorProxy) refresh(ctx context.Context, progress aptly.Progress) error {
+	collection := proxy.collectionFactory.RemoteRepoCollection()
+
+	proxy.mu.Lock()
+	proxy.repo.MarkAsUpdating()
+	err := collection.Update(proxy.repo)
+	// network operations are performed on the copy of the mirror, so that clients are served meanwhile
+	repo := *proxy.repo
+	proxy.mu.Unlock()
+
+	defer func() {
+		proxy.mu.Lock()
+		defer proxy.mu.Unlock()
+
+		proxy.repo.MarkAsIdle()
+		_ = collection.Update(proxy.repo)
+	}()
+
+	if err != nil {
+		return err
+	}
+
+	recorder := newReleaseRecorder(proxy.downloader, &repo)
+
+	err = repo.Fetch(ctx, recorder, proxy.verifier, proxy.ignoreSignatures)
+	if err != nil {
+		return err
+	}
+
+	err = repo.DownloadPackageIndexes(ctx, progress, proxy.downloader, proxy.verifier, proxy.collectionFactory, proxy.ignoreSignatures, false)
+	if err != nil {
+		return err
+	}
+
+	// package list is owned by the proxy, mirror keeps list of cached packages only
+	files := make(map[string]proxyFile, repo.packageList.Len())
+	_ = repo.packageList.ForEach(func(p *Package) error {
+		for _, f := range p.Files() {
+			if _, exists := files[f.DownloadURL()]; !exists {
+				files[f.DownloadURL()] = proxyFile{pkg: p, filename: f.Filename}
+			}
+		}
+		return nil
+	})
+
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+
+	proxy.repo.Meta = repo.Meta
+	proxy.repo.ReleaseFiles = repo.ReleaseFiles
+	proxy.repo.Architectures = repo.Architectures
+	proxy.repo.Components = repo.Components
+
+	proxy.files = files
+	proxy.release = recorder.verified()
+	proxy.lastRefresh = time.Now()
+
+	return nil
+}
+
+// ServeHTTP implements http.Handler
+func (proxy *MirrorProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestPath := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+
+	if strings.HasPrefix(requestPath, proxy.releaseDir) {
+		name := strings.TrimPrefix(requestPath, proxy.releaseDir)
+
+		switch name {
+		case "InRelease", "Release", "Release.gpg":
+			proxy.serveRelease(w, r, name)
+			return
+		}
+
+		proxy.mu.Lock()
+		checksums, found := proxy.repo.ReleaseFiles[name]
+		if !found {
+			checksums, found = proxy.lookupByHash(name)
+		}
+		proxy.mu.Unlock()
+
+		if found {
+			proxy.serveIndex(w, r, name, checksums)
+			return
+		}
+	}
+
+	proxy.mu.Lock()
+	file, found := proxy.files[requestPath]
+	proxy.mu.Unlock()
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	proxy.servePackageFile(w, r, file)
+}
+
+// lookupByHash resolves by-hash path (<dir>/by-hash/<algorithm>/<checksum>) to
+// checksums of the file listed in Release file
+func (proxy *MirrorProxy) lookupByHash(name string) (utils.ChecksumInfo, bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) < 3 || parts[len(parts)-3] != "by-hash" {
+		return utils.ChecksumInfo{}, false
+	}
+
+	dir := strings.Join(parts[:len(parts)-3], "/")
+	algorithm, sum := parts[len(parts)-2], parts[len(parts)-1]
+
+	for filename, checksums := range proxy.repo.ReleaseFiles {
+		if path.Dir(filename) != dir && !(dir == "" && path.Dir(filename) == ".") {
+			continue
+		}
+
+		var candidate string
+		switch algorithm {
+		case "MD5Sum":
+			candidate = checksums.MD5
+		case "SHA1":
+			candidate = checksums.SHA1
+		case "SHA256":
+			candidate = checksums.SHA256
+		case "SHA512":
+			candidate = checksums.SHA512
+		}
+
+		if candidate != "" && candidate == sum {
+			return checksums, true
+		}
+	}
+
+	return utils.ChecksumInfo{}, false
+}
+
+// serveRelease serves Release file verified during last refresh, refreshing package indexes if required
+func (proxy *MirrorProxy) serveRelease(w http.ResponseWriter, r *http.Request, name string) {
+	err := proxy.refreshIfStale(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unable to refresh mirror: %s", err), http.StatusBadGateway)
+		return
+	}
+
+	proxy.mu.Lock()
+	contents, found := proxy.release[name]
+	proxy.mu.Unlock()
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(contents))
+}
+
+// serveIndex downloads index file from upstream to temporary location and serves it,
+// verifying checksums from Release file
+func (proxy *MirrorProxy) serveIndex(w http.ResponseWriter, r *http.Request, name string, checksums utils.ChecksumInfo) {
+	upstreamURL := proxy.repo.IndexesRootURL().ResolveReference(&url.URL{Path: name})
+
+	tempDir, err := os.MkdirTemp("", "aptly-proxy")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	tempPath := path.Join(tempDir, path.Base(upstreamURL.Path))
+
+	err = proxy.downloader.DownloadWithChecksum(r.Context(), upstreamURL.String(), tempPath, &checksums, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	f, err := os.Open(tempPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	http.ServeContent(w, r, path.Base(upstreamURL.Path), time.Time{}, f)
+}
+
+// servePackageFile serves package file from the pool, caching it first if required
+func (proxy *MirrorProxy) servePackageFile(w http.ResponseWriter, r *http.Request, file proxyFile) {
+	poolPath, err := proxy.cachePackageFile(r.Context(), file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	f, err := proxy.packagePool.Open(poolPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	http.ServeContent(w, r, path.Base(poolPath), time.Time{}, f)
+}
+
+// cachePackageFile makes sure package file is in the pool and returns pool path
+func (proxy *MirrorProxy) cachePackageFile(ctx context.Context, file proxyFile) (string, error) {
+	checksumStorage := proxy.collectionFactory.ChecksumCollection(nil)
+
+	proxy.mu.Lock()
+	packageFile, err := file.lookup()
+	if err != nil {
+		proxy.mu.Unlock()
+		return "", err
+	}
+	exists, err := packageFile.Verify(proxy.packagePool, checksumStorage)
+	poolPath, checksums, filename, downloadURL := packageFile.PoolPath, packageFile.Checksums, packageFile.Filename, packageFile.DownloadURL()
+	proxy.mu.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return poolPath, nil
+	}
+
+	var tempPath string
+	if pp, ok := proxy.packagePool.(aptly.LocalPackagePool); ok {
+		tempPath, err = pp.GenerateTempPath(filename)
+	} else {
+		var f *os.File
+		f, err = os.CreateTemp("", filename)
+		if err == nil {
+			tempPath = f.Name()
+			_ = f.Close()
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(tempPath) }()
+
+	err = proxy.downloader.DownloadWithChecksum(ctx, proxy.repo.PackageURL(downloadURL).String(), tempPath, &checksums, false)
+	if err != nil {
+		return "", err
+	}
+
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+
+	poolPath, err = proxy.packagePool.Import(tempPath, filename, &checksums, true, checksumStorage)
+	if err != nil {
+		return "", fmt.Errorf("unable to import file: %s", err)
+	}
+
+	packageFile, err = file.lookup()
+	if err != nil {
+		return "", err
+	}
+	packageFile.PoolPath = poolPath
+	packageFile.Checksums = checksums
+
+	return poolPath, proxy.addPackage(file.pkg)
+}
+
+// addPackage adds package to the mirror if all its files are cached
+func (proxy *MirrorProxy) addPackage(p *Package) error {
+	if proxy.repo.packageRefs != nil && proxy.repo.packageRefs.Has(p) {
+		return nil
+	}
+
+	complete, err := p.VerifyFiles(proxy.packagePool, proxy.collectionFactory.ChecksumCollection(nil))
+	if err != nil || !complete {
+		return err
+	}
+
+	p.UpdateFiles(p.Files())
+
+	err = proxy.collectionFactory.PackageCollection().Update(p)
+	if err != nil {
+		return err
+	}
+
+	list := NewPackageList()
+	_ = list.Add(p)
+
+	if proxy.repo.packageRefs == nil {
+		proxy.repo.packageRefs = NewPackageRefList()
+	}
+	proxy.repo.packageRefs = proxy.repo.packageRefs.Merge(NewPackageRefListFromPackageList(list), false, true)
+
+	return proxy.collectionFactory.RemoteRepoCollection().Update(proxy.repo)
+}
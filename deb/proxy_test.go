@@ -0,0 +1,172 @@
+package deb
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type MirrorProxySuite struct {
+	db                database.Storage
+	collectionFactory *CollectionFactory
+	packagePool       aptly.PackagePool
+	downloader        *http.FakeDownloader
+	repo              *RemoteRepo
+	proxy             *MirrorProxy
+}
+
+var _ = Suite(&MirrorProxySuite{})
+
+func (s *MirrorProxySuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collectionFactory = NewCollectionFactory(s.db)
+	s.packagePool = files.NewPackagePool(c.MkDir(), false)
+
+	s.repo, _ = NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian", "squeeze", []string{"main"}, []string{"i386"}, false, false, false, false)
+	c.Assert(s.collectionFactory.RemoteRepoCollection().Add(s.repo), IsNil)
+
+	s.downloader = http.NewFakeDownloader()
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", exampleReleaseFile)
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.bz2", &http.Error{Code: 404})
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+
+	s.proxy = NewMirrorProxy(s.repo, s.collectionFactory, s.packagePool, s.downloader, nil, true, time.Hour)
+	c.Assert(s.proxy.Refresh(context.Background(), nil), IsNil)
+	c.Assert(s.downloader.Empty(), Equals, true)
+}
+
+func (s *MirrorProxySuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *MirrorProxySuite) get(path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.proxy.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func (s *MirrorProxySuite) TestPackageFile(c *C) {
+	const path = "/pool/main/a/amanda/amanda-client_3.3.1-3~bpo60+1_amd64.deb"
+
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian"+path, "xyz")
+
+	w := s.get(path)
+	c.Check(w.Code, Equals, 200)
+	c.Check(w.Body.String(), Equals, "xyz")
+	c.Check(s.downloader.Empty(), Equals, true)
+
+	// package is added to the mirror
+	repo, err := s.collectionFactory.RemoteRepoCollection().ByName("yandex")
+	c.Assert(err, IsNil)
+	c.Assert(s.collectionFactory.RemoteRepoCollection().LoadComplete(repo), IsNil)
+	c.Assert(repo.NumPackages(), Equals, 1)
+
+	pkg, err := s.collectionFactory.PackageCollection().ByKey(repo.RefList().Refs[0])
+	c.Assert(err, IsNil)
+	c.Check(pkg.Name, Equals, "amanda-client")
+	c.Check(pkg.Files()[0].PoolPath, Not(Equals), "")
+
+	// second request is served from the pool
+	w = s.get(path)
+	c.Check(w.Code, Equals, 200)
+	c.Check(w.Body.String(), Equals, "xyz")
+}
+
+func (s *MirrorProxySuite) TestPackageFileChecksumMismatch(c *C) {
+	const path = "/pool/main/a/amanda/amanda-client_3.3.1-3~bpo60+1_amd64.deb"
+
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian"+path, "abc")
+
+	w := s.get(path)
+	c.Check(w.Code, Equals, 502)
+	c.Check(w.Body.String(), Matches, "checksums don't match.*\n")
+	c.Check(s.repo.NumPackages(), Equals, 0)
+}
+
+func (s *MirrorProxySuite) TestIndexFiles(c *C) {
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+
+	w := s.get("/dists/squeeze/main/binary-i386/Packages")
+	c.Check(w.Code, Equals, 200)
+	c.Check(w.Body.String(), Equals, examplePackagesFile)
+
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", "tampered")
+
+	w = s.get("/dists/squeeze/main/binary-i386/Packages")
+	c.Check(w.Code, Equals, 502)
+
+	// Release file fetched during refresh is served, indexes were refreshed recently
+	w = s.get("/dists/squeeze/Release")
+	c.Check(w.Code, Equals, 200)
+	c.Check(w.Body.String(), Equals, exampleReleaseFile)
+
+	c.Check(s.get("/dists/squeeze/InRelease").Code, Equals, 404)
+
+	c.Check(s.get("/dists/squeeze/main/binary-amd64/Packages.xz").Code, Equals, 404)
+	c.Check(s.get("/pool/main/h/hello/hello_2.10-3_i386.deb").Code, Equals, 404)
+	c.Check(s.downloader.Empty(), Equals, true)
+}
+
+func (s *MirrorProxySuite) TestLookupByHash(c *C) {
+	s.repo.ReleaseFiles = map[string]utils.ChecksumInfo{
+		"main/binary-i386/Packages.gz": {Size: 10, SHA256: "aaaa"},
+		"main/binary-amd64/Packages":   {Size: 20, SHA256: "bbbb"},
+	}
+
+	checksums, found := s.proxy.lookupByHash("main/binary-i386/by-hash/SHA256/aaaa")
+	c.Check(found, Equals, true)
+	c.Check(checksums.Size, Equals, int64(10))
+
+	_, found = s.proxy.lookupByHash("main/binary-i386/by-hash/SHA256/bbbb")
+	c.Check(found, Equals, false)
+
+	_, found = s.proxy.lookupByHash("main/binary-i386/by-hash/MD5Sum/aaaa")
+	c.Check(found, Equals, false)
+
+	_, found = s.proxy.lookupByHash("main/binary-i386/Packages.gz")
+	c.Check(found, Equals, false)
+}
+
+func (s *MirrorProxySuite) TestRefresh(c *C) {
+	releaseFiles := s.repo.ReleaseFiles
+
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/Release", &http.Error{Code: 404})
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/InRelease", &http.Error{Code: 404})
+
+	c.Check(s.proxy.Refresh(context.Background(), nil), ErrorMatches, ".*HTTP code 404.*")
+	c.Check(s.downloader.Empty(), Equals, true)
+	c.Check(s.repo.Status, Equals, MirrorIdle)
+
+	// failed refresh keeps previous state of the mirror
+	w := s.get("/dists/squeeze/Release")
+	c.Check(w.Code, Equals, 200)
+	c.Check(w.Body.String(), Equals, exampleReleaseFile)
+	c.Check(s.repo.ReleaseFiles, DeepEquals, releaseFiles)
+}
+
+func (s *MirrorProxySuite) TestReleaseRecorder(c *C) {
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/InRelease", "tampered")
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", "release")
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release.gpg", "signature")
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", "packages")
+
+	recorder := newReleaseRecorder(s.downloader, s.repo)
+	dir := c.MkDir()
+	for _, name := range []string{"InRelease", "Release", "Release.gpg", "main/binary-i386/Packages"} {
+		c.Assert(recorder.Download(context.Background(), "http://mirror.yandex.ru/debian/dists/squeeze/"+name,
+			filepath.Join(dir, "file")), IsNil)
+	}
+
+	c.Check(recorder.verified(), DeepEquals, map[string][]byte{"Release": []byte("release"), "Release.gpg": []byte("signature")})
+}
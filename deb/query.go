@@ -164,6 +164,22 @@ func (q *FieldQuery) Matches(pkg PackageLike) bool {
 
 	field := pkg.GetField(q.Field)
 
+	if q.Field == "$License" && field != "" &&
+		(q.Relation == VersionEqual || q.Relation == VersionPatternMatch || q.Relation == VersionRegexp) {
+		// package matches if any of its licenses matches
+		for _, license := range strings.Split(field, ", ") {
+			if q.matchesValue(license) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return q.matchesValue(field)
+}
+
+// matchesValue compares field value according to the relation
+func (q *FieldQuery) matchesValue(field string) bool {
 	switch q.Relation {
 	case VersionDontCare:
 		return field != ""
@@ -185,6 +185,12 @@ type PackageDiffs []PackageDiff
 
 // Diff calculates difference between two reflists
 func (l *PackageRefList) Diff(r *PackageRefList, packageCollection *PackageCollection) (result PackageDiffs, err error) {
+	return l.diff(r, packageCollection.ByKey, packageCollection.ByKey)
+}
+
+// diff calculates difference between two reflists, loading packages on the left
+// and on the right with separate functions
+func (l *PackageRefList) diff(r *PackageRefList, loadLeft, loadRight func(key []byte) (*Package, error)) (result PackageDiffs, err error) {
 	result = make(PackageDiffs, 0, 128)
 
 	// pointer to left and right reflists
@@ -220,14 +226,14 @@ func (l *PackageRefList) Diff(r *PackageRefList, packageCollection *PackageColle
 		} else {
 			// load pl & pr if they haven't been loaded before
 			if pl == nil && rl != nil {
-				pl, err = packageCollection.ByKey(rl)
+				pl, err = loadLeft(rl)
 				if err != nil {
 					return nil, err
 				}
 			}
 
 			if pr == nil && rr != nil {
-				pr, err = packageCollection.ByKey(rr)
+				pr, err = loadRight(rr)
 				if err != nil {
 					return nil, err
 				}
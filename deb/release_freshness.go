@@ -0,0 +1,109 @@
+package deb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reasons for rejecting Release file
+const (
+	// ReleaseExpired means Release file is past its Valid-Until date
+	ReleaseExpired = "expired"
+	// ReleaseOutdated means Release file is older than the one accepted previously
+	ReleaseOutdated = "outdated"
+)
+
+// ReleaseFreshnessError is returned when Release file of the mirror is rejected
+// as expired or older than the one fetched on previous update (possible replay)
+type ReleaseFreshnessError struct {
+	// Mirror description
+	Mirror string
+	// Reason: ReleaseExpired or ReleaseOutdated
+	Reason string
+	// Date from Release file
+	Date time.Time
+	// Valid-Until from Release file (ReleaseExpired)
+	ValidUntil time.Time
+	// Date of Release file accepted previously (ReleaseOutdated)
+	PreviousDate time.Time
+}
+
+// Error returns human-readable description of the error
+func (e *ReleaseFreshnessError) Error() string {
+	if e.Reason == ReleaseExpired {
+		return fmt.Sprintf("Release file of %s has expired on %s, use -ignore-valid-until to override",
+			e.Mirror, e.ValidUntil.UTC().Format(time.RFC1123))
+	}
+
+	return fmt.Sprintf("Release file of %s dated %s is older than previously fetched one dated %s, use -force-release-date to override",
+		e.Mirror, e.Date.UTC().Format(time.RFC1123), e.PreviousDate.UTC().Format(time.RFC1123))
+}
+
+// Details returns machine-readable description of the error
+func (e *ReleaseFreshnessError) Details() map[string]interface{} {
+	details := map[string]interface{}{
+		"reason": e.Reason,
+	}
+
+	if !e.Date.IsZero() {
+		details["date"] = e.Date
+	}
+	if !e.ValidUntil.IsZero() {
+		details["validUntil"] = e.ValidUntil
+	}
+	if !e.PreviousDate.IsZero() {
+		details["previousDate"] = e.PreviousDate
+	}
+
+	return details
+}
+
+// parseReleaseDate parses Date and Valid-Until fields of Release file
+//
+// Format is RFC 2822, but whitespace is normalized first, as some archives
+// pad single-digit hours with spaces
+func parseReleaseDate(value string) (time.Time, error) {
+	value = strings.Join(strings.Fields(value), " ")
+
+	var (
+		result time.Time
+		err    error
+	)
+
+	for _, layout := range []string{"Mon, 2 Jan 2006 15:04:05 MST", "Mon, 2 Jan 2006 15:04:05 -0700"} {
+		result, err = time.Parse(layout, value)
+		if err == nil {
+			return result, nil
+		}
+	}
+
+	return result, fmt.Errorf("unable to parse date %#v", value)
+}
+
+// checkReleaseFreshness verifies that Release file hasn't expired and is not older
+// than the one fetched previously
+func (repo *RemoteRepo) checkReleaseFreshness(stanza Stanza, now time.Time) error {
+	// malformed or missing Date only disables replay check
+	date, dateErr := parseReleaseDate(stanza["Date"])
+
+	if stanza["Valid-Until"] != "" && !repo.IgnoreValidUntil {
+		validUntil, err := parseReleaseDate(stanza["Valid-Until"])
+		if err != nil {
+			return fmt.Errorf("malformed Valid-Until in Release file: %s", err)
+		}
+
+		if now.After(validUntil) {
+			return &ReleaseFreshnessError{Mirror: repo.String(), Reason: ReleaseExpired, Date: date, ValidUntil: validUntil}
+		}
+	}
+
+	if dateErr == nil && repo.Meta["Date"] != "" && !repo.ForceReleaseDate {
+		previousDate, err := parseReleaseDate(repo.Meta["Date"])
+		if err == nil && date.Before(previousDate) {
+			return &ReleaseFreshnessError{Mirror: repo.String(), Reason: ReleaseOutdated, Date: date, PreviousDate: previousDate}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package deb
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type ReleaseFreshnessSuite struct {
+	repo *RemoteRepo
+	now  time.Time
+}
+
+var _ = Suite(&ReleaseFreshnessSuite{})
+
+func (s *ReleaseFreshnessSuite) SetUpTest(c *C) {
+	s.repo, _ = NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{}, false, false, false, false)
+	s.now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func (s *ReleaseFreshnessSuite) TestParseReleaseDate(c *C) {
+	date, err := parseReleaseDate("Thu, 05 Dec 2013  8:14:32 UTC")
+	c.Assert(err, IsNil)
+	c.Check(date.Equal(time.Date(2013, 12, 5, 8, 14, 32, 0, time.UTC)), Equals, true)
+
+	date, err = parseReleaseDate("Sat, 10 Feb 2024 09:30:00 +0100")
+	c.Assert(err, IsNil)
+	c.Check(date.Equal(time.Date(2024, 2, 10, 8, 30, 0, 0, time.UTC)), Equals, true)
+
+	_, err = parseReleaseDate("yesterday")
+	c.Check(err, ErrorMatches, "unable to parse date \"yesterday\"")
+}
+
+func (s *ReleaseFreshnessSuite) TestExpired(c *C) {
+	stanza := Stanza{"Date": "Sat, 10 Feb 2024 09:30:00 UTC", "Valid-Until": "Sat, 17 Feb 2024 09:30:00 UTC"}
+
+	err := s.repo.checkReleaseFreshness(stanza, s.now)
+	c.Assert(err, FitsTypeOf, &ReleaseFreshnessError{})
+	c.Check(err, ErrorMatches, "Release file of .* has expired on Sat, 17 Feb 2024 09:30:00 UTC, use -ignore-valid-until to override")
+	c.Check(err.(*ReleaseFreshnessError).Details(), DeepEquals, map[string]interface{}{
+		"reason":     ReleaseExpired,
+		"date":       time.Date(2024, 2, 10, 9, 30, 0, 0, time.UTC),
+		"validUntil": time.Date(2024, 2, 17, 9, 30, 0, 0, time.UTC),
+	})
+
+	c.Check(s.repo.checkReleaseFreshness(stanza, time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC)), IsNil)
+
+	s.repo.IgnoreValidUntil = true
+	c.Check(s.repo.checkReleaseFreshness(stanza, s.now), IsNil)
+
+	s.repo.IgnoreValidUntil = false
+	stanza["Valid-Until"] = "never"
+	c.Check(s.repo.checkReleaseFreshness(stanza, s.now), ErrorMatches, "malformed Valid-Until in Release file: .*")
+}
+
+func (s *ReleaseFreshnessSuite) TestOutdated(c *C) {
+	stanza := Stanza{"Date": "Sat, 10 Feb 2024 09:30:00 UTC"}
+
+	// first fetch
+	c.Check(s.repo.checkReleaseFreshness(stanza, s.now), IsNil)
+
+	s.repo.Meta = Stanza{"Date": "Sat, 10 Feb 2024 09:30:00 UTC"}
+	c.Check(s.repo.checkReleaseFreshness(stanza, s.now), IsNil)
+
+	s.repo.Meta = Stanza{"Date": "Sun, 11 Feb 2024 09:30:00 UTC"}
+	err := s.repo.checkReleaseFreshness(stanza, s.now)
+	c.Assert(err, FitsTypeOf, &ReleaseFreshnessError{})
+	c.Check(err.(*ReleaseFreshnessError).Reason, Equals, ReleaseOutdated)
+	c.Check(err, ErrorMatches, "Release file of .* dated Sat, 10 Feb 2024 09:30:00 UTC is older than previously fetched one dated Sun, 11 Feb 2024 09:30:00 UTC, use -force-release-date to override")
+
+	s.repo.ForceReleaseDate = true
+	c.Check(s.repo.checkReleaseFreshness(stanza, s.now), IsNil)
+
+	// unparseable date disables the check
+	s.repo.ForceReleaseDate = false
+	c.Check(s.repo.checkReleaseFreshness(Stanza{"Date": "yesterday"}, s.now), IsNil)
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -49,6 +50,8 @@ type RemoteRepo struct {
 	Architectures []string
 	// Meta-information about repository
 	Meta Stanza
+	// Arbitrary key/value metadata
+	Labels Labels `codec:",omitempty" json:",omitempty"`
 	// Last update date
 	LastDownloadDate time.Time
 	// Checksums for release files
@@ -61,6 +64,10 @@ type RemoteRepo struct {
 	WorkerPID int
 	// FilterWithDeps to include dependencies from filter query
 	FilterWithDeps bool
+	// Mirrors and snapshots to look up dependencies missing in the mirror when filtering with dependencies
+	DependencySources []DependencySource `codec:",omitempty" json:",omitempty"`
+	// Retention policy for package versions
+	Retention *RetentionPolicy `codec:",omitempty" json:",omitempty"`
 	// SkipComponentCheck skips component list verification
 	SkipComponentCheck bool
 	// SkipArchitectureCheck skips architecture list verification
@@ -73,6 +80,12 @@ type RemoteRepo struct {
 	DownloadInstaller bool
 	// Should we download AppStream (DEP-11) metadata?
 	DownloadAppStream bool
+	// Name of authentication settings (mirrorAuth section of configuration) to access archive
+	Auth string `codec:",omitempty" json:",omitempty"`
+	// Accept Release files past their Valid-Until date
+	IgnoreValidUntil bool `codec:",omitempty" json:",omitempty"`
+	// Accept Release file older than the one fetched previously (not persisted)
+	ForceReleaseDate bool `codec:"-" json:"-"`
 	// AppStream files: relative path (e.g. "main/dep11/Components-amd64.yml.gz") → pool path
 	AppStreamFiles map[string]string `codec:"AppStreamFiles" json:"-"`
 	// Packages for json output
@@ -88,6 +101,11 @@ type RemoteRepo struct {
 // NewRemoteRepo creates new instance of Debian remote repository with specified params
 func NewRemoteRepo(name string, archiveRoot string, distribution string, components []string,
 	architectures []string, downloadSources bool, downloadUdebs bool, downloadInstaller bool, downloadAppStream bool) (*RemoteRepo, error) {
+	archiveRoot, err := normalizeArchiveRoot(archiveRoot)
+	if err != nil {
+		return nil, err
+	}
+
 	result := &RemoteRepo{
 		UUID:              uuid.NewString(),
 		Name:              name,
@@ -101,7 +119,7 @@ func NewRemoteRepo(name string, archiveRoot string, distribution string, compone
 		DownloadAppStream: downloadAppStream,
 	}
 
-	err := result.prepare()
+	err = result.prepare()
 	if err != nil {
 		return nil, err
 	}
@@ -128,10 +146,29 @@ func NewRemoteRepo(name string, archiveRoot string, distribution string, compone
 
 // SetArchiveRoot of remote repo
 func (repo *RemoteRepo) SetArchiveRoot(archiveRoot string) {
+	if normalized, err := normalizeArchiveRoot(archiveRoot); err == nil {
+		archiveRoot = normalized
+	}
 	repo.ArchiveRoot = archiveRoot
 	_ = repo.prepare()
 }
 
+// normalizeArchiveRoot converts plain local paths into file:// URLs,
+// other archive roots are returned as is
+func normalizeArchiveRoot(archiveRoot string) (string, error) {
+	u, err := url.Parse(archiveRoot)
+	if err != nil || u.Scheme != "" || archiveRoot == "" {
+		return archiveRoot, nil
+	}
+
+	absPath, err := filepath.Abs(archiveRoot)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve archive root %s: %s", archiveRoot, err)
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}).String(), nil
+}
+
 func (repo *RemoteRepo) prepare() error {
 	var err error
 
@@ -173,6 +210,16 @@ func (repo *RemoteRepo) IsFlat() bool {
 	return repo.Distribution == "" || (strings.HasPrefix(repo.Distribution, ".") && strings.HasSuffix(repo.Distribution, "/"))
 }
 
+// Codename returns codename of the distribution from the Release file (e.g. bookworm
+// for stable), falling back to distribution name if mirror hasn't been fetched
+func (repo *RemoteRepo) Codename() string {
+	if codename := repo.Meta["Codename"]; codename != "" {
+		return codename
+	}
+
+	return repo.Distribution
+}
+
 // NumPackages return number of packages retrieved from remote repo
 func (repo *RemoteRepo) NumPackages() int {
 	if repo.packageRefs == nil {
@@ -446,6 +493,11 @@ ok:
 		return err
 	}
 
+	err = repo.checkReleaseFreshness(stanza, time.Now())
+	if err != nil {
+		return err
+	}
+
 	if len(stanza["Architectures"]) > 0 {
 		architectures := strings.Split(stanza["Architectures"], " ")
 		sort.Strings(architectures)
@@ -769,7 +821,22 @@ func (repo *RemoteRepo) BuildDownloadQueue(packagePool aptly.PackagePool, packag
 }
 
 // FinalizeDownload swaps for final value of package refs
+//
+// Changes against previous package refs are recorded in mirror history in the same transaction.
 func (repo *RemoteRepo) FinalizeDownload(collectionFactory *CollectionFactory, progress aptly.Progress) error {
+	previousRefs := repo.packageRefs
+	if previousRefs == nil {
+		previous := &RemoteRepo{UUID: repo.UUID}
+		err := collectionFactory.RemoteRepoCollection().LoadComplete(previous)
+		if err != nil {
+			return err
+		}
+		previousRefs = previous.packageRefs
+	}
+	if previousRefs == nil {
+		previousRefs = NewPackageRefList()
+	}
+
 	transaction, err := collectionFactory.PackageCollection().db.OpenTransaction()
 	if err != nil {
 		return err
@@ -795,11 +862,6 @@ func (repo *RemoteRepo) FinalizeDownload(collectionFactory *CollectionFactory, p
 		return collectionFactory.PackageCollection().UpdateInTransaction(p, transaction)
 	})
 
-	if err == nil {
-		repo.packageRefs = NewPackageRefListFromPackageList(repo.packageList)
-		repo.packageList = nil
-	}
-
 	if progress != nil {
 		progress.ShutdownBar()
 	}
@@ -807,6 +869,33 @@ func (repo *RemoteRepo) FinalizeDownload(collectionFactory *CollectionFactory, p
 	if err != nil {
 		return err
 	}
+
+	// new packages are not committed yet, so they are looked up in the package list
+	updated := make(map[string]*Package, repo.packageList.Len())
+	_ = repo.packageList.ForEach(func(p *Package) error {
+		updated[string(p.Key(""))] = p
+		return nil
+	})
+
+	packageRefs := NewPackageRefListFromPackageList(repo.packageList)
+	diff, err := previousRefs.diff(packageRefs, collectionFactory.PackageCollection().ByKey, func(key []byte) (*Package, error) {
+		if p, ok := updated[string(key)]; ok {
+			return p, nil
+		}
+		return collectionFactory.PackageCollection().ByKey(key)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to calculate mirror history: %s", err)
+	}
+
+	repo.packageRefs = packageRefs
+	repo.packageList = nil
+
+	err = collectionFactory.MirrorHistoryCollection().AddInTransaction(NewMirrorHistoryEntry(repo, diff), transaction)
+	if err != nil {
+		return err
+	}
+
 	return transaction.Commit()
 }
 
@@ -1050,5 +1139,9 @@ func (collection *RemoteRepoCollection) Drop(repo *RemoteRepo) error {
 	batch := collection.db.CreateBatch()
 	_ = batch.Delete(repo.Key())
 	_ = batch.Delete(repo.RefKey())
+	_ = batch.Delete(packageAgesKey(repo.UUID))
+	for _, key := range collection.db.KeysByPrefix(mirrorHistoryPrefix(repo.UUID)) {
+		_ = batch.Delete(key)
+	}
 	return batch.Write()
 }
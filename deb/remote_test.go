@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"sort"
+	"strings"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/console"
@@ -116,6 +117,23 @@ func (s *RemoteRepoSuite) TestInvalidURL(c *C) {
 	c.Assert(err, ErrorMatches, ".*(hexadecimal escape in host|percent-encoded characters in host|invalid URL escape).*")
 }
 
+func (s *RemoteRepoSuite) TestLocalArchiveRoot(c *C) {
+	repo, err := NewRemoteRepo("dvd", "/media/cdrom", "bookworm", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(err, IsNil)
+	c.Check(repo.ArchiveRoot, Equals, "file:///media/cdrom/")
+	c.Check(repo.ReleaseURL("InRelease").String(), Equals, "file:///media/cdrom/dists/bookworm/InRelease")
+	c.Check(repo.PackageURL("pool/main/a/app/app_1.0+b1_amd64.deb").String(), Equals,
+		"file:///media/cdrom/pool/main/a/app/app_1.0+b1_amd64.deb")
+
+	repo, err = NewRemoteRepo("dvd", "file:///media/cdrom/", "bookworm", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(err, IsNil)
+	c.Check(repo.ArchiveRoot, Equals, "file:///media/cdrom/")
+
+	repo.SetArchiveRoot("/srv/mirror with space")
+	c.Check(repo.ArchiveRoot, Equals, "file:///srv/mirror%20with%20space/")
+	c.Check(repo.ReleaseURL("Release").String(), Equals, "file:///srv/mirror%20with%20space/dists/bookworm/Release")
+}
+
 func (s *RemoteRepoSuite) TestFlatCreation(c *C) {
 	c.Check(s.flat.IsFlat(), Equals, true)
 	c.Check(s.flat.Distribution, Equals, "./")
@@ -279,6 +297,39 @@ func (s *RemoteRepoSuite) TestFetchNullVerifier2(c *C) {
 	c.Assert(downloader.Empty(), Equals, true)
 }
 
+func (s *RemoteRepoSuite) TestFetchReleaseFreshness(c *C) {
+	expired := strings.Replace(exampleReleaseFile, "Architectures:", "Valid-Until: Thu, 12 Dec 2013  8:14:32 UTC\nArchitectures:", 1)
+
+	downloader := http.NewFakeDownloader()
+	downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/InRelease", expired)
+
+	err := s.repo.Fetch(s.ctx, downloader, &NullVerifier{}, false)
+	c.Assert(err, FitsTypeOf, &ReleaseFreshnessError{})
+	c.Check(err.(*ReleaseFreshnessError).Reason, Equals, ReleaseExpired)
+	c.Check(s.repo.Meta, IsNil)
+
+	s.repo.IgnoreValidUntil = true
+	downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/InRelease", expired)
+
+	err = s.repo.Fetch(s.ctx, downloader, &NullVerifier{}, false)
+	c.Assert(err, IsNil)
+
+	// Release file older than the one fetched previously
+	s.repo.Meta["Date"] = "Fri, 06 Dec 2013 10:00:00 UTC"
+	downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/InRelease", exampleReleaseFile)
+
+	err = s.repo.Fetch(s.ctx, downloader, &NullVerifier{}, false)
+	c.Assert(err, FitsTypeOf, &ReleaseFreshnessError{})
+	c.Check(err.(*ReleaseFreshnessError).Reason, Equals, ReleaseOutdated)
+
+	s.repo.ForceReleaseDate = true
+	downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/InRelease", exampleReleaseFile)
+
+	err = s.repo.Fetch(s.ctx, downloader, &NullVerifier{}, false)
+	c.Assert(err, IsNil)
+	c.Check(s.repo.Meta["Date"], Equals, "Thu, 05 Dec 2013  8:14:32 UTC")
+}
+
 func (s *RemoteRepoSuite) TestFetchWrongArchitecture(c *C) {
 	s.repo, _ = NewRemoteRepo("s", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{"xyz"}, false, false, false, false)
 	err := s.repo.Fetch(s.ctx, s.downloader, nil, true)
@@ -339,6 +390,14 @@ func (s *RemoteRepoSuite) TestDownload(c *C) {
 
 	c.Check(pkg.Name, Equals, "amanda-client")
 
+	history, err := s.collectionFactory.MirrorHistoryCollection().ForMirror(s.repo.UUID, true)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 1)
+	c.Check(history[0].ID, Equals, 1)
+	c.Check(history[0].NumPackages, Equals, 1)
+	c.Check(history[0].Added, Equals, 1)
+	c.Check(*history[0].Changes[0].Right, Equals, string(s.repo.packageRefs.Refs[0]))
+
 	// Next call must return an empty download list with option "skip-existing-packages"
 	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", exampleReleaseFile)
 	err = s.repo.Fetch(s.ctx, s.downloader, nil, true)
@@ -360,6 +419,12 @@ func (s *RemoteRepoSuite) TestDownload(c *C) {
 	_ = s.repo.FinalizeDownload(s.collectionFactory, nil)
 	c.Assert(s.repo.packageRefs, NotNil)
 
+	history, err = s.collectionFactory.MirrorHistoryCollection().ForMirror(s.repo.UUID, true)
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+	c.Check(history[1].ID, Equals, 2)
+	c.Check(history[1].Changes, HasLen, 0)
+
 	// Next call must return the download list without option "skip-existing-packages"
 	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", exampleReleaseFile)
 	err = s.repo.Fetch(s.ctx, s.downloader, nil, true)
@@ -902,9 +967,18 @@ func (s *RemoteRepoCollectionSuite) TestDrop(c *C) {
 	r1, _ := s.collection.ByUUID(repo1.UUID)
 	c.Check(r1, Equals, repo1)
 
+	history := NewMirrorHistoryCollection(s.db)
+	c.Assert(history.Add(&MirrorHistoryEntry{MirrorUUID: repo1.UUID}), IsNil)
+	c.Assert(history.Add(&MirrorHistoryEntry{MirrorUUID: repo2.UUID}), IsNil)
+
 	err := s.collection.Drop(repo1)
 	c.Check(err, IsNil)
 
+	entries, _ := history.ForMirror(repo1.UUID, false)
+	c.Check(entries, HasLen, 0)
+	entries, _ = history.ForMirror(repo2.UUID, false)
+	c.Check(entries, HasLen, 1)
+
 	_, err = s.collection.ByUUID(repo1.UUID)
 	c.Check(err, ErrorMatches, "mirror .* not found")
 
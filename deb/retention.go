@@ -0,0 +1,305 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+)
+
+// RetentionPolicy limits package versions kept in the mirror or local repo
+//
+// Package version is kept if it's one of KeepLatest latest versions of the package (per name
+// and architecture), or if it was first seen in the repository less than KeepAge ago, or if
+// it's referenced by any published repository. Versions which don't match any of these are pruned.
+type RetentionPolicy struct {
+	// Number of latest versions to keep per package name and architecture, 0 to disable
+	KeepLatest int `codec:",omitempty" json:",omitempty"`
+	// Keep versions first seen less than KeepAge ago (e.g. 12h or 30d), empty to disable
+	KeepAge string `codec:",omitempty" json:",omitempty"`
+}
+
+// NewRetentionPolicy validates and creates retention policy, if both rules are
+// disabled, nil policy is returned
+func NewRetentionPolicy(keepLatest int, keepAge string) (*RetentionPolicy, error) {
+	if keepLatest < 0 {
+		return nil, fmt.Errorf("number of versions to keep should be positive: %d", keepLatest)
+	}
+
+	if keepAge != "" {
+		if _, err := parseRetentionAge(keepAge); err != nil {
+			return nil, err
+		}
+	}
+
+	if keepLatest == 0 && keepAge == "" {
+		return nil, nil
+	}
+
+	return &RetentionPolicy{KeepLatest: keepLatest, KeepAge: keepAge}, nil
+}
+
+// parseRetentionAge parses age as Go duration, with additional support for days (e.g. 30d)
+func parseRetentionAge(value string) (time.Duration, error) {
+	var (
+		age time.Duration
+		err error
+	)
+
+	if days, found := strings.CutSuffix(value, "d"); found {
+		var n int
+		n, err = strconv.Atoi(days)
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		age, err = time.ParseDuration(value)
+	}
+
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("wrong age %#v, expected positive duration like 12h or 30d", value)
+	}
+
+	return age, nil
+}
+
+// String returns human-readable description of the policy
+func (policy *RetentionPolicy) String() string {
+	rules := []string{}
+	if policy.KeepLatest > 0 {
+		rules = append(rules, fmt.Sprintf("keep latest %d versions", policy.KeepLatest))
+	}
+	if policy.KeepAge != "" {
+		rules = append(rules, fmt.Sprintf("keep versions younger than %s", policy.KeepAge))
+	}
+
+	return strings.Join(rules, ", ")
+}
+
+// Prune returns packages from the list which are not retained by the policy
+//
+// firstSeen maps package keys to the time package was first seen in the repository,
+// protected is a set of package keys which should be always kept.
+func (policy *RetentionPolicy) Prune(list *PackageList, firstSeen map[string]time.Time, protected map[string]struct{}, now time.Time) []*Package {
+	var age time.Duration
+	if policy.KeepAge != "" {
+		age, _ = parseRetentionAge(policy.KeepAge)
+	}
+
+	groups := map[string][]*Package{}
+	_ = list.ForEach(func(p *Package) error {
+		group := p.Architecture + " " + p.Name
+		groups[group] = append(groups[group], p)
+		return nil
+	})
+
+	result := []*Package{}
+
+	for _, packages := range groups {
+		sort.Slice(packages, func(i, j int) bool { return CompareVersions(packages[i].Version, packages[j].Version) > 0 })
+
+		for i, p := range packages {
+			key := string(p.Key(""))
+
+			if _, ok := protected[key]; ok {
+				continue
+			}
+
+			if policy.KeepLatest > 0 && i < policy.KeepLatest {
+				continue
+			}
+
+			if age > 0 {
+				seen, ok := firstSeen[key]
+				if !ok || now.Sub(seen) < age {
+					continue
+				}
+			}
+
+			if policy.KeepLatest == 0 && age == 0 {
+				continue
+			}
+
+			result = append(result, p)
+		}
+	}
+
+	return result
+}
+
+// ApplyRetentionPolicy removes package versions which are not retained by the policy from
+// the package list of the repository (mirror or local repo) identified by UUID
+//
+// Time packages were first seen in the repository is tracked starting with the first run of
+// the policy. Stamps of removed packages are kept while the packages are in the list passed in
+// (mirror keeps getting pruned versions from upstream, and they shouldn't look new again),
+// stamps are dropped once packages disappear from the list. Keys of removed packages are
+// returned sorted.
+func ApplyRetentionPolicy(policy *RetentionPolicy, repoUUID string, list *PackageList,
+	collectionFactory *CollectionFactory, now time.Time) ([]string, error) {
+	if policy == nil {
+		return nil, nil
+	}
+
+	collection := collectionFactory.PackageAgeCollection()
+
+	firstSeen, err := collection.Load(repoUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	protected, err := publishedPackageKeys(collectionFactory)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedFirstSeen := make(map[string]time.Time, list.Len())
+	_ = list.ForEach(func(p *Package) error {
+		key := string(p.Key(""))
+		if seen, ok := firstSeen[key]; ok {
+			updatedFirstSeen[key] = seen
+		} else {
+			updatedFirstSeen[key] = now
+		}
+		return nil
+	})
+
+	removed := []string{}
+	for _, p := range policy.Prune(list, updatedFirstSeen, protected, now) {
+		key := string(p.Key(""))
+
+		list.Remove(p)
+		removed = append(removed, key)
+	}
+
+	sort.Strings(removed)
+
+	return removed, collection.Save(repoUUID, updatedFirstSeen)
+}
+
+// ApplyRetention prunes package list of the mirror being updated according to its retention
+// policy, should be called after ApplyFilter and before BuildDownloadQueue
+func (repo *RemoteRepo) ApplyRetention(collectionFactory *CollectionFactory, now time.Time) ([]string, error) {
+	if repo.packageList == nil {
+		return nil, fmt.Errorf("package list is empty, please (re)download package indexes")
+	}
+
+	return ApplyRetentionPolicy(repo.Retention, repo.UUID, repo.packageList, collectionFactory, now)
+}
+
+// ApplyRetention prunes package versions of the local repo according to its retention policy,
+// repo should be loaded completely, changes are not saved
+func (repo *LocalRepo) ApplyRetention(collectionFactory *CollectionFactory, now time.Time, progress aptly.Progress) ([]string, error) {
+	if repo.Retention == nil {
+		return nil, nil
+	}
+
+	list, err := NewPackageListFromRefList(repo.RefList(), collectionFactory.PackageCollection(), progress)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := ApplyRetentionPolicy(repo.Retention, repo.UUID, list, collectionFactory, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(removed) > 0 {
+		repo.UpdateRefList(NewPackageRefListFromPackageList(list))
+	}
+
+	return removed, nil
+}
+
+// publishedPackageKeys returns set of package keys referenced by published repositories
+func publishedPackageKeys(collectionFactory *CollectionFactory) (map[string]struct{}, error) {
+	result := map[string]struct{}{}
+	collection := collectionFactory.PublishedRepoCollection()
+
+	err := collection.ForEach(func(published *PublishedRepo) error {
+		err := collection.LoadComplete(published, collectionFactory)
+		if err != nil {
+			return err
+		}
+
+		for _, component := range published.Components() {
+			refList := published.RefList(component)
+			if refList == nil {
+				continue
+			}
+
+			for _, ref := range refList.Refs {
+				result[string(ref)] = struct{}{}
+			}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// packageAgesKey is a DB key of package ages of the repository
+func packageAgesKey(repoUUID string) []byte {
+	return []byte("A" + repoUUID)
+}
+
+// PackageAgeCollection tracks time packages were first seen in mirrors and local repos
+type PackageAgeCollection struct {
+	db database.Storage
+}
+
+// NewPackageAgeCollection creates PackageAgeCollection bound to database
+func NewPackageAgeCollection(db database.Storage) *PackageAgeCollection {
+	return &PackageAgeCollection{
+		db: db,
+	}
+}
+
+// Load returns time packages were first seen in the repository, indexed by package key
+func (collection *PackageAgeCollection) Load(repoUUID string) (map[string]time.Time, error) {
+	result := map[string]time.Time{}
+
+	encoded, err := collection.db.Get(packageAgesKey(repoUUID))
+	if err == database.ErrNotFound {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stamps map[string]int64
+
+	decoder := codec.NewDecoderBytes(encoded, &codec.MsgpackHandle{})
+	if err = decoder.Decode(&stamps); err != nil {
+		return nil, err
+	}
+
+	for key, stamp := range stamps {
+		result[key] = time.Unix(stamp, 0)
+	}
+
+	return result, nil
+}
+
+// Save stores time packages were first seen in the repository
+func (collection *PackageAgeCollection) Save(repoUUID string, firstSeen map[string]time.Time) error {
+	stamps := make(map[string]int64, len(firstSeen))
+	for key, seen := range firstSeen {
+		stamps[key] = seen.Unix()
+	}
+
+	var buf bytes.Buffer
+
+	encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+	if err := encoder.Encode(stamps); err != nil {
+		return err
+	}
+
+	return collection.db.Put(packageAgesKey(repoUUID), buf.Bytes())
+}
@@ -0,0 +1,190 @@
+package deb
+
+import (
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type RetentionPolicySuite struct {
+	db                database.Storage
+	collectionFactory *CollectionFactory
+	list              *PackageList
+	now               time.Time
+}
+
+var _ = Suite(&RetentionPolicySuite{})
+
+func (s *RetentionPolicySuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collectionFactory = NewCollectionFactory(s.db)
+	s.now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	s.list = NewPackageList()
+	for _, p := range []*Package{
+		{Name: "app", Version: "1.0", Architecture: "i386"},
+		{Name: "app", Version: "1.10", Architecture: "i386"},
+		{Name: "app", Version: "1.9", Architecture: "i386"},
+		{Name: "app", Version: "1.0", Architecture: "amd64"},
+		{Name: "lib", Version: "2.0", Architecture: "i386"},
+	} {
+		c.Assert(s.list.Add(p), IsNil)
+	}
+}
+
+func (s *RetentionPolicySuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *RetentionPolicySuite) pruned(policy *RetentionPolicy, firstSeen map[string]time.Time, protected map[string]struct{}) []string {
+	result := []string{}
+	for _, p := range policy.Prune(s.list, firstSeen, protected, s.now) {
+		result = append(result, p.String())
+	}
+
+	return result
+}
+
+func (s *RetentionPolicySuite) TestNewRetentionPolicy(c *C) {
+	policy, err := NewRetentionPolicy(0, "")
+	c.Check(err, IsNil)
+	c.Check(policy, IsNil)
+
+	policy, err = NewRetentionPolicy(3, "30d")
+	c.Check(err, IsNil)
+	c.Check(policy, DeepEquals, &RetentionPolicy{KeepLatest: 3, KeepAge: "30d"})
+	c.Check(policy.String(), Equals, "keep latest 3 versions, keep versions younger than 30d")
+
+	policy, err = NewRetentionPolicy(0, "12h")
+	c.Check(err, IsNil)
+	c.Check(policy.String(), Equals, "keep versions younger than 12h")
+
+	_, err = NewRetentionPolicy(-1, "")
+	c.Check(err, ErrorMatches, "number of versions to keep should be positive: -1")
+
+	_, err = NewRetentionPolicy(0, "month")
+	c.Check(err, ErrorMatches, "wrong age \"month\".*")
+
+	_, err = NewRetentionPolicy(0, "-5d")
+	c.Check(err, ErrorMatches, "wrong age \"-5d\".*")
+}
+
+func (s *RetentionPolicySuite) TestPruneKeepLatest(c *C) {
+	c.Check(s.pruned(&RetentionPolicy{KeepLatest: 1}, nil, nil), DeepEquals, []string{"app_1.9_i386", "app_1.0_i386"})
+	c.Check(s.pruned(&RetentionPolicy{KeepLatest: 2}, nil, nil), DeepEquals, []string{"app_1.0_i386"})
+	c.Check(s.pruned(&RetentionPolicy{KeepLatest: 3}, nil, nil), DeepEquals, []string{})
+
+	c.Check(s.pruned(&RetentionPolicy{KeepLatest: 1}, nil, map[string]struct{}{"Pi386 app 1.0": {}}), DeepEquals, []string{"app_1.9_i386"})
+}
+
+func (s *RetentionPolicySuite) TestPruneKeepAge(c *C) {
+	firstSeen := map[string]time.Time{
+		"Pi386 app 1.0":  s.now.Add(-48 * time.Hour),
+		"Pi386 app 1.9":  s.now.Add(-36 * time.Hour),
+		"Pi386 app 1.10": s.now.Add(-time.Hour),
+		"Pamd64 app 1.0": s.now.Add(-72 * time.Hour),
+		"Pi386 lib 2.0":  s.now.Add(-72 * time.Hour),
+	}
+
+	c.Check(s.pruned(&RetentionPolicy{KeepAge: "1d"}, firstSeen, nil), HasLen, 4)
+	c.Check(s.pruned(&RetentionPolicy{KeepAge: "40h"}, firstSeen, nil), HasLen, 3)
+
+	// latest version of each package is kept, plus versions younger than 40h
+	c.Check(s.pruned(&RetentionPolicy{KeepLatest: 1, KeepAge: "40h"}, firstSeen, nil), DeepEquals, []string{"app_1.0_i386"})
+}
+
+func (s *RetentionPolicySuite) TestApplyRetentionPolicy(c *C) {
+	policy := &RetentionPolicy{KeepLatest: 1, KeepAge: "1d"}
+
+	// on first run all the packages are new
+	removed, err := ApplyRetentionPolicy(policy, "uuid", s.list, s.collectionFactory, s.now)
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{})
+	c.Check(s.list.Len(), Equals, 5)
+
+	firstSeen, err := s.collectionFactory.PackageAgeCollection().Load("uuid")
+	c.Assert(err, IsNil)
+	c.Check(firstSeen, HasLen, 5)
+	c.Check(firstSeen["Pi386 app 1.0"].Equal(s.now), Equals, true)
+
+	// version referenced by published repo is kept
+	localRepo := NewLocalRepo("published", "")
+	localRepo.UpdateRefList(NewPackageRefListFromPackageList(NewPackageList()))
+	localRepo.packageRefs.Refs = [][]byte{[]byte("Pi386 app 1.0")}
+	c.Assert(s.collectionFactory.LocalRepoCollection().Add(localRepo), IsNil)
+
+	published, err := NewPublishedRepo("", "", "stable", []string{"i386"}, []string{"main"}, []interface{}{localRepo}, s.collectionFactory, false)
+	c.Assert(err, IsNil)
+	c.Assert(s.collectionFactory.PublishedRepoCollection().Add(published), IsNil)
+
+	c.Assert(s.list.Add(&Package{Name: "lib", Version: "2.1", Architecture: "i386"}), IsNil)
+
+	removed, err = ApplyRetentionPolicy(policy, "uuid", s.list, s.collectionFactory, s.now.Add(48*time.Hour))
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{"Pi386 app 1.9", "Pi386 lib 2.0"})
+	c.Check(s.list.Len(), Equals, 4)
+
+	// ages of removed packages are kept, new packages are tracked
+	firstSeen, err = s.collectionFactory.PackageAgeCollection().Load("uuid")
+	c.Assert(err, IsNil)
+	c.Check(firstSeen, HasLen, 6)
+	c.Check(firstSeen["Pi386 lib 2.1"].Equal(s.now.Add(48*time.Hour)), Equals, true)
+	c.Check(firstSeen["Pi386 app 1.0"].Equal(s.now), Equals, true)
+	c.Check(firstSeen["Pi386 app 1.9"].Equal(s.now), Equals, true)
+
+	// mirror update: upstream still lists pruned versions, they are not new and are pruned again
+	upstream := NewPackageList()
+	c.Assert(s.list.ForEach(func(p *Package) error { return upstream.Add(p) }), IsNil)
+	c.Assert(upstream.Add(&Package{Name: "app", Version: "1.9", Architecture: "i386"}), IsNil)
+	c.Assert(upstream.Add(&Package{Name: "lib", Version: "2.0", Architecture: "i386"}), IsNil)
+
+	removed, err = ApplyRetentionPolicy(policy, "uuid", upstream, s.collectionFactory, s.now.Add(49*time.Hour))
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{"Pi386 app 1.9", "Pi386 lib 2.0"})
+
+	// ages are forgotten once packages disappear from the list
+	removed, err = ApplyRetentionPolicy(policy, "uuid", s.list, s.collectionFactory, s.now.Add(50*time.Hour))
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{})
+
+	firstSeen, err = s.collectionFactory.PackageAgeCollection().Load("uuid")
+	c.Assert(err, IsNil)
+	c.Check(firstSeen, HasLen, 4)
+
+	removed, err = ApplyRetentionPolicy(nil, "uuid", s.list, s.collectionFactory, s.now.Add(96*time.Hour))
+	c.Assert(err, IsNil)
+	c.Check(removed, IsNil)
+}
+
+func (s *RetentionPolicySuite) TestLocalRepoApplyRetention(c *C) {
+	for _, p := range []*Package{
+		{Name: "app", Version: "1.0", Architecture: "i386", deps: &PackageDependencies{}},
+		{Name: "app", Version: "1.1", Architecture: "i386", deps: &PackageDependencies{}},
+	} {
+		c.Assert(s.collectionFactory.PackageCollection().Update(p), IsNil)
+	}
+
+	repo := NewLocalRepo("ci", "")
+	repo.UpdateRefList(&PackageRefList{Refs: [][]byte{[]byte("Pi386 app 1.0"), []byte("Pi386 app 1.1")}})
+	c.Assert(s.collectionFactory.LocalRepoCollection().Add(repo), IsNil)
+
+	removed, err := repo.ApplyRetention(s.collectionFactory, s.now, nil)
+	c.Assert(err, IsNil)
+	c.Check(removed, IsNil)
+
+	repo.Retention = &RetentionPolicy{KeepLatest: 1}
+	removed, err = repo.ApplyRetention(s.collectionFactory, s.now, nil)
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{"Pi386 app 1.0"})
+	c.Check(repo.RefList().Refs, DeepEquals, [][]byte{[]byte("Pi386 app 1.1")})
+
+	_, err = s.db.Get(packageAgesKey(repo.UUID))
+	c.Check(err, IsNil)
+
+	c.Assert(s.collectionFactory.LocalRepoCollection().Drop(repo), IsNil)
+	_, err = s.db.Get(packageAgesKey(repo.UUID))
+	c.Check(err, Equals, database.ErrNotFound)
+}
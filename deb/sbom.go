@@ -0,0 +1,429 @@
+package deb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/google/uuid"
+)
+
+// Supported SBOM formats
+const (
+	SBOMFormatSPDX      = "spdx-json"
+	SBOMFormatCycloneDX = "cyclonedx-json"
+)
+
+// SBOMFormats is a list of supported SBOM formats
+var SBOMFormats = []string{SBOMFormatSPDX, SBOMFormatCycloneDX}
+
+// SBOMContentType returns MIME type of the SBOM format
+func SBOMContentType(format string) string {
+	if format == SBOMFormatCycloneDX {
+		return "application/vnd.cyclonedx+json"
+	}
+
+	return "application/spdx+json"
+}
+
+// SBOMOptions control generation of SBOM document
+type SBOMOptions struct {
+	// Name of the document, e.g. snapshot name
+	Name string
+	// Distribution is added to package URLs as distro qualifier, if set
+	Distribution string
+	// PurlNamespace is vendor in package URLs, "debian" by default
+	PurlNamespace string
+	// Created is document creation time, current time by default
+	Created time.Time
+	// UUID makes document namespace (SPDX) and serial number (CycloneDX) unique, random by default
+	UUID string
+}
+
+// sbomPackage is a package as listed in SBOM
+type sbomPackage struct {
+	id            string
+	component     string
+	name          string
+	version       string
+	architecture  string
+	source        string
+	sourceVersion string
+	maintainer    string
+	filename      string
+	checksums     utils.ChecksumInfo
+	purl          string
+	isSource      bool
+}
+
+var sbomIDInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// packageURL builds package URL (purl) for the Debian package
+//
+// See https://github.com/package-url/purl-spec for the specification.
+func packageURL(namespace, name, version, architecture, distribution string) string {
+	purl := fmt.Sprintf("pkg:deb/%s/%s@%s?arch=%s", url.PathEscape(namespace), url.QueryEscape(name),
+		url.QueryEscape(version), url.QueryEscape(architecture))
+	if distribution != "" {
+		purl += "&distro=" + url.QueryEscape(distribution)
+	}
+
+	return purl
+}
+
+// splitMaintainer splits "Name <email>" into name and email
+func splitMaintainer(maintainer string) (string, string) {
+	if start := strings.LastIndex(maintainer, "<"); start != -1 {
+		if end := strings.LastIndex(maintainer, ">"); end > start {
+			return strings.TrimSpace(maintainer[:start]), strings.TrimSpace(maintainer[start+1 : end])
+		}
+	}
+
+	return strings.TrimSpace(maintainer), ""
+}
+
+// collectSBOMPackages flattens package lists by component into sorted list of packages with unique IDs
+func collectSBOMPackages(lists map[string]*PackageList, options *SBOMOptions) []*sbomPackage {
+	packages := []*sbomPackage{}
+
+	for component, list := range lists {
+		_ = list.ForEach(func(p *Package) error {
+			pkg := &sbomPackage{
+				component:    component,
+				name:         p.Name,
+				version:      p.Version,
+				architecture: p.Architecture,
+				maintainer:   p.GetField("Maintainer"),
+				isSource:     p.IsSource,
+				purl:         packageURL(options.PurlNamespace, p.Name, p.Version, p.Architecture, options.Distribution),
+			}
+			if !p.IsSource {
+				pkg.source, pkg.sourceVersion = sourceNameVersion(p)
+			}
+			if file := mainPackageFile(p); file != nil {
+				pkg.filename, pkg.checksums = file.Filename, file.Checksums
+			}
+
+			packages = append(packages, pkg)
+			return nil
+		})
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		a, b := packages[i], packages[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if a.version != b.version {
+			return CompareVersions(a.version, b.version) < 0
+		}
+		if a.architecture != b.architecture {
+			return a.architecture < b.architecture
+		}
+		return a.component < b.component
+	})
+
+	seen := map[string]int{}
+	for _, pkg := range packages {
+		id := sbomIDInvalidChars.ReplaceAllString(pkg.name+"-"+pkg.version+"-"+pkg.architecture, "-")
+		seen[id]++
+		if seen[id] > 1 {
+			id = fmt.Sprintf("%s-%d", id, seen[id])
+		}
+		pkg.id = id
+	}
+
+	return packages
+}
+
+// WriteSBOM writes software bill of materials listing packages in the given format
+//
+// refLists maps component name to list of packages in the component, snapshots are listed
+// as a single component with empty name. Packages are loaded from packageCollection.
+func WriteSBOM(w io.Writer, format string, refLists map[string]*PackageRefList, packageCollection *PackageCollection, options SBOMOptions) error {
+	lists := map[string]*PackageList{}
+	for component, refList := range refLists {
+		list, err := NewPackageListFromRefList(refList, packageCollection, nil)
+		if err != nil {
+			return fmt.Errorf("unable to load packages: %s", err)
+		}
+		lists[component] = list
+	}
+
+	if options.PurlNamespace == "" {
+		options.PurlNamespace = "debian"
+	}
+	if options.Created.IsZero() {
+		options.Created = time.Now()
+	}
+	if options.UUID == "" {
+		options.UUID = uuid.NewString()
+	}
+
+	packages := collectSBOMPackages(lists, &options)
+
+	var document interface{}
+	switch format {
+	case SBOMFormatSPDX:
+		document = newSPDXDocument(packages, &options)
+	case SBOMFormatCycloneDX:
+		document = newCycloneDXDocument(packages, &options)
+	default:
+		return fmt.Errorf("unknown SBOM format %#v, expected one of: %s", format, strings.Join(SBOMFormats, ", "))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+
+	return encoder.Encode(document)
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo"`
+	PackageFileName  string            `json:"packageFileName,omitempty"`
+	Supplier         string            `json:"supplier"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	Comment          string            `json:"comment,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+func sbomToolVersion() string {
+	if aptly.Version == "" {
+		return "aptly"
+	}
+
+	return "aptly-" + aptly.Version
+}
+
+func newSPDXDocument(packages []*sbomPackage, options *SBOMOptions) *spdxDocument {
+	document := &spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              options.Name,
+		DocumentNamespace: fmt.Sprintf("https://www.aptly.info/spdx/%s-%s", url.PathEscape(options.Name), options.UUID),
+		CreationInfo: spdxCreationInfo{
+			Created:  options.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + sbomToolVersion()},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+
+	sources := map[string]string{}
+	for _, pkg := range packages {
+		if pkg.isSource {
+			sources[pkg.component+" "+pkg.name+" "+pkg.version] = "SPDXRef-Package-" + pkg.id
+		}
+	}
+
+	for _, pkg := range packages {
+		spdxPkg := spdxPackage{
+			SPDXID:           "SPDXRef-Package-" + pkg.id,
+			Name:             pkg.name,
+			VersionInfo:      pkg.version,
+			PackageFileName:  pkg.filename,
+			Supplier:         "NOASSERTION",
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+			ExternalRefs: []spdxExternalRef{
+				{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: pkg.purl},
+			},
+		}
+
+		if name, email := splitMaintainer(pkg.maintainer); name != "" {
+			spdxPkg.Supplier = "Person: " + name
+			if email != "" {
+				spdxPkg.Supplier += " (" + email + ")"
+			}
+		}
+
+		for _, checksum := range []spdxChecksum{
+			{"MD5", pkg.checksums.MD5}, {"SHA1", pkg.checksums.SHA1},
+			{"SHA256", pkg.checksums.SHA256}, {"SHA512", pkg.checksums.SHA512}} {
+			if checksum.ChecksumValue != "" {
+				spdxPkg.Checksums = append(spdxPkg.Checksums, checksum)
+			}
+		}
+
+		if pkg.source != "" {
+			spdxPkg.SourceInfo = fmt.Sprintf("built package from: %s %s", pkg.source, pkg.sourceVersion)
+		}
+		if pkg.component != "" {
+			spdxPkg.Comment = "component: " + pkg.component
+		}
+
+		document.Packages = append(document.Packages, spdxPkg)
+		document.Relationships = append(document.Relationships, spdxRelationship{
+			SPDXElementID: document.SPDXID, RelationshipType: "DESCRIBES", RelatedSPDXElement: spdxPkg.SPDXID})
+
+		if sourceID, ok := sources[pkg.component+" "+pkg.source+" "+pkg.sourceVersion]; ok && !pkg.isSource {
+			document.Relationships = append(document.Relationships, spdxRelationship{
+				SPDXElementID: spdxPkg.SPDXID, RelationshipType: "GENERATED_FROM", RelatedSPDXElement: sourceID})
+		}
+	}
+
+	return document
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXContact struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+type cycloneDXSupplier struct {
+	Name    string             `json:"name"`
+	Contact []cycloneDXContact `json:"contact,omitempty"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef     string              `json:"bom-ref,omitempty"`
+	Type       string              `json:"type"`
+	Supplier   *cycloneDXSupplier  `json:"supplier,omitempty"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	Hashes     []cycloneDXHash     `json:"hashes,omitempty"`
+	Purl       string              `json:"purl,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXTools struct {
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     cycloneDXTools     `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXDocument struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cycloneDXMetadata    `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+}
+
+func newCycloneDXDocument(packages []*sbomPackage, options *SBOMOptions) *cycloneDXDocument {
+	document := &cycloneDXDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + options.UUID,
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: options.Created.UTC().Format(time.RFC3339),
+			Tools: cycloneDXTools{Components: []cycloneDXComponent{
+				{Type: "application", Name: "aptly", Version: aptly.Version},
+			}},
+			Component: cycloneDXComponent{Type: "operating-system", Name: options.Name},
+		},
+		Components: []cycloneDXComponent{},
+	}
+
+	for _, pkg := range packages {
+		component := cycloneDXComponent{
+			BOMRef:  pkg.id,
+			Type:    "library",
+			Name:    pkg.name,
+			Version: pkg.version,
+			Purl:    pkg.purl,
+			Properties: []cycloneDXProperty{
+				{Name: "aptly:architecture", Value: pkg.architecture},
+			},
+		}
+
+		if name, email := splitMaintainer(pkg.maintainer); name != "" {
+			component.Supplier = &cycloneDXSupplier{Name: name}
+			if email != "" {
+				component.Supplier.Contact = []cycloneDXContact{{Name: name, Email: email}}
+			}
+		}
+
+		for _, hash := range []cycloneDXHash{
+			{"MD5", pkg.checksums.MD5}, {"SHA-1", pkg.checksums.SHA1},
+			{"SHA-256", pkg.checksums.SHA256}, {"SHA-512", pkg.checksums.SHA512}} {
+			if hash.Content != "" {
+				component.Hashes = append(component.Hashes, hash)
+			}
+		}
+
+		if pkg.source != "" {
+			component.Properties = append(component.Properties,
+				cycloneDXProperty{Name: "aptly:source", Value: pkg.source},
+				cycloneDXProperty{Name: "aptly:source_version", Value: pkg.sourceVersion})
+		}
+		if pkg.filename != "" {
+			component.Properties = append(component.Properties, cycloneDXProperty{Name: "aptly:filename", Value: pkg.filename})
+		}
+		if pkg.component != "" {
+			component.Properties = append(component.Properties, cycloneDXProperty{Name: "aptly:component", Value: pkg.component})
+		}
+
+		document.Components = append(document.Components, component)
+	}
+
+	return document
+}
@@ -0,0 +1,137 @@
+package deb
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type SBOMSuite struct {
+	db                database.Storage
+	packageCollection *PackageCollection
+	refLists          map[string]*PackageRefList
+	options           SBOMOptions
+}
+
+var _ = Suite(&SBOMSuite{})
+
+func (s *SBOMSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.packageCollection = NewPackageCollection(s.db)
+
+	sourceStanza, err := NewControlFileReader(bytes.NewBufferString(sourcePackageMeta), false, false).ReadStanza()
+	c.Assert(err, IsNil)
+	source, err := NewSourcePackageFromControlFile(sourceStanza)
+	c.Assert(err, IsNil)
+
+	stanza := packageStanza.Copy()
+	stanza["Package"], stanza["Version"], stanza["Source"] = "libaccess-modifier-checker-java", "1.0-4", "access-modifier-checker"
+	stanza["Architecture"], stanza["Maintainer"] = "all", "Nobody"
+	stanza["Filename"] = "pool/main/a/access-modifier-checker/libaccess-modifier-checker-java_1.0-4_all.deb"
+	binary := NewPackageFromControlFile(stanza)
+
+	main := NewPackageList()
+	c.Assert(main.Add(source), IsNil)
+	c.Assert(main.Add(binary), IsNil)
+
+	stanza = packageStanza.Copy()
+	stanza["Version"] = "1:7.40-2+b1"
+	contrib := NewPackageList()
+	c.Assert(contrib.Add(NewPackageFromControlFile(stanza)), IsNil)
+
+	for _, list := range []*PackageList{main, contrib} {
+		c.Assert(list.ForEach(s.packageCollection.Update), IsNil)
+	}
+
+	s.refLists = map[string]*PackageRefList{"main": NewPackageRefListFromPackageList(main),
+		"contrib": NewPackageRefListFromPackageList(contrib)}
+	s.options = SBOMOptions{Name: "wheezy", Distribution: "wheezy", Created: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		UUID: "8c1f3f1e-5c2b-4f4e-9d1a-3c1e6a2b7f00"}
+}
+
+func (s *SBOMSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *SBOMSuite) TestPackageURL(c *C) {
+	c.Check(packageURL("debian", "curl", "7.88.1-10+deb12u5", "amd64", ""), Equals,
+		"pkg:deb/debian/curl@7.88.1-10%2Bdeb12u5?arch=amd64")
+	c.Check(packageURL("ubuntu", "libstdc++6", "1:2.0~rc1", "source", "jammy"), Equals,
+		"pkg:deb/ubuntu/libstdc%2B%2B6@1%3A2.0~rc1?arch=source&distro=jammy")
+}
+
+func (s *SBOMSuite) TestSPDX(c *C) {
+	var buf bytes.Buffer
+	c.Assert(WriteSBOM(&buf, SBOMFormatSPDX, s.refLists, s.packageCollection, s.options), IsNil)
+
+	var document spdxDocument
+	c.Assert(json.Unmarshal(buf.Bytes(), &document), IsNil)
+	c.Check(document.SPDXVersion, Equals, "SPDX-2.3")
+	c.Check(document.Name, Equals, "wheezy")
+	c.Check(document.DocumentNamespace, Equals, "https://www.aptly.info/spdx/wheezy-8c1f3f1e-5c2b-4f4e-9d1a-3c1e6a2b7f00")
+	c.Check(document.CreationInfo.Created, Equals, "2026-10-01T12:00:00Z")
+
+	c.Assert(document.Packages, HasLen, 3)
+	c.Check(document.Packages[0].Name, Equals, "access-modifier-checker")
+	c.Check(document.Packages[0].SPDXID, Equals, "SPDXRef-Package-access-modifier-checker-1.0-4-source")
+	c.Check(document.Packages[0].PackageFileName, Equals, "access-modifier-checker_1.0-4.dsc")
+	c.Check(document.Packages[0].Checksums[0], DeepEquals, spdxChecksum{Algorithm: "MD5", ChecksumValue: "900150983cd24fb0d6963f7d28e17f72"})
+	c.Check(document.Packages[0].Supplier, Equals, "Person: Debian Java Maintainers (pkg-java-maintainers@lists.alioth.debian.org)")
+	c.Check(document.Packages[0].Comment, Equals, "component: main")
+
+	alien := document.Packages[1]
+	c.Check(alien.SPDXID, Equals, "SPDXRef-Package-alien-arena-common-1-7.40-2-b1-i386")
+	c.Check(alien.VersionInfo, Equals, "1:7.40-2+b1")
+	c.Check(alien.SourceInfo, Equals, "built package from: alien-arena 1:7.40-2+b1")
+	c.Check(alien.Checksums, DeepEquals, []spdxChecksum{
+		{Algorithm: "MD5", ChecksumValue: "1e8cba92c41420aa7baa8a5718d67122"},
+		{Algorithm: "SHA1", ChecksumValue: "46955e48cad27410a83740a21d766ce362364024"},
+		{Algorithm: "SHA256", ChecksumValue: "eb4afb9885cba6dc70cccd05b910b2dbccc02c5900578be5e99f0d3dbf9d76a5"},
+	})
+	c.Check(alien.ExternalRefs, DeepEquals, []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl",
+		ReferenceLocator: "pkg:deb/debian/alien-arena-common@1%3A7.40-2%2Bb1?arch=i386&distro=wheezy"}})
+
+	c.Check(document.Packages[2].Supplier, Equals, "Person: Nobody")
+
+	c.Check(document.Relationships, HasLen, 4)
+	c.Check(document.Relationships[3], DeepEquals, spdxRelationship{SPDXElementID: "SPDXRef-Package-libaccess-modifier-checker-java-1.0-4-all",
+		RelationshipType: "GENERATED_FROM", RelatedSPDXElement: "SPDXRef-Package-access-modifier-checker-1.0-4-source"})
+}
+
+func (s *SBOMSuite) TestCycloneDX(c *C) {
+	var buf bytes.Buffer
+	c.Assert(WriteSBOM(&buf, SBOMFormatCycloneDX, s.refLists, s.packageCollection, s.options), IsNil)
+
+	var document cycloneDXDocument
+	c.Assert(json.Unmarshal(buf.Bytes(), &document), IsNil)
+	c.Check(document.BOMFormat, Equals, "CycloneDX")
+	c.Check(document.SerialNumber, Equals, "urn:uuid:8c1f3f1e-5c2b-4f4e-9d1a-3c1e6a2b7f00")
+	c.Check(document.Metadata.Timestamp, Equals, "2026-10-01T12:00:00Z")
+	c.Check(document.Metadata.Component.Name, Equals, "wheezy")
+
+	c.Assert(document.Components, HasLen, 3)
+	alien := document.Components[1]
+	c.Check(alien.Name, Equals, "alien-arena-common")
+	c.Check(alien.Purl, Equals, "pkg:deb/debian/alien-arena-common@1%3A7.40-2%2Bb1?arch=i386&distro=wheezy")
+	c.Check(alien.Supplier, DeepEquals, &cycloneDXSupplier{Name: "Debian Games Team",
+		Contact: []cycloneDXContact{{Name: "Debian Games Team", Email: "pkg-games-devel@lists.alioth.debian.org"}}})
+	c.Check(alien.Hashes, HasLen, 3)
+	c.Check(alien.Hashes[2], DeepEquals, cycloneDXHash{Alg: "SHA-256", Content: "eb4afb9885cba6dc70cccd05b910b2dbccc02c5900578be5e99f0d3dbf9d76a5"})
+	c.Check(alien.Properties, DeepEquals, []cycloneDXProperty{
+		{Name: "aptly:architecture", Value: "i386"},
+		{Name: "aptly:source", Value: "alien-arena"},
+		{Name: "aptly:source_version", Value: "1:7.40-2+b1"},
+		{Name: "aptly:filename", Value: "alien-arena-common_7.40-2_i386.deb"},
+		{Name: "aptly:component", Value: "contrib"},
+	})
+}
+
+func (s *SBOMSuite) TestUnknownFormat(c *C) {
+	c.Check(WriteSBOM(&bytes.Buffer{}, "spdx-tag-value", s.refLists, s.packageCollection, s.options), ErrorMatches,
+		"unknown SBOM format \"spdx-tag-value\", expected one of: spdx-json, cyclonedx-json")
+}
@@ -0,0 +1,427 @@
+package deb
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+)
+
+// Kinds of package changes in semantic diff
+const (
+	// ChangeAdded is a package present only in the new list
+	ChangeAdded = "added"
+	// ChangeRemoved is a package present only in the old list
+	ChangeRemoved = "removed"
+	// ChangeUpgraded is a package with greater version in the new list
+	ChangeUpgraded = "upgraded"
+	// ChangeDowngraded is a package with smaller version in the new list
+	ChangeDowngraded = "downgraded"
+	// ChangeRebuilt is a package with the same version, but different contents
+	ChangeRebuilt = "rebuilt"
+)
+
+// changeKinds lists kinds of changes in the order of reporting
+var changeKinds = []string{ChangeAdded, ChangeUpgraded, ChangeDowngraded, ChangeRebuilt, ChangeRemoved}
+
+// ErrWrongPackageSource is returned when package source specification is malformed
+var ErrWrongPackageSource = errors.New("wrong package source")
+
+// ResolvePackageRefs looks up list of package refs of the package source specified as:
+//
+//	snapshot:<name> (or simply <name>), mirror:<name>, repo:<name> (local repo),
+//	publish:[<storage>:]<prefix>/<distribution> (published repository, all components)
+//
+// Snapshot with name matching whole specification takes precedence, so that snapshots
+// with colons in names could be still referenced without snapshot: prefix.
+//
+// Normalized specification is returned as description of the source, malformed
+// specification is reported with error wrapping ErrWrongPackageSource
+func ResolvePackageRefs(spec string, collectionFactory *CollectionFactory) (string, *PackageRefList, error) {
+	if snapshot, err := collectionFactory.SnapshotCollection().ByName(spec); err == nil {
+		return snapshotPackageRefs("snapshot:"+spec, snapshot, collectionFactory)
+	}
+
+	kind, name, found := strings.Cut(spec, ":")
+	if !found {
+		kind, name = "snapshot", spec
+	}
+
+	if name == "" {
+		return "", nil, fmt.Errorf("%w %#v, name is missing", ErrWrongPackageSource, spec)
+	}
+
+	description := kind + ":" + name
+
+	switch kind {
+	case "snapshot":
+		snapshot, err := collectionFactory.SnapshotCollection().ByName(name)
+		if err != nil {
+			return "", nil, err
+		}
+		return snapshotPackageRefs(description, snapshot, collectionFactory)
+	case "mirror":
+		repo, err := collectionFactory.RemoteRepoCollection().ByName(name)
+		if err != nil {
+			return "", nil, err
+		}
+		err = collectionFactory.RemoteRepoCollection().LoadComplete(repo)
+		if err != nil {
+			return "", nil, err
+		}
+		if repo.RefList() == nil {
+			return description, NewPackageRefList(), nil
+		}
+		return description, repo.RefList(), nil
+	case "repo":
+		repo, err := collectionFactory.LocalRepoCollection().ByName(name)
+		if err != nil {
+			return "", nil, err
+		}
+		err = collectionFactory.LocalRepoCollection().LoadComplete(repo)
+		if err != nil {
+			return "", nil, err
+		}
+		if repo.RefList() == nil {
+			return description, NewPackageRefList(), nil
+		}
+		return description, repo.RefList(), nil
+	case "publish":
+		prefix, distribution := ".", name
+		if i := strings.LastIndex(name, "/"); i != -1 {
+			prefix, distribution = name[:i], name[i+1:]
+		}
+
+		storage, prefix := ParsePrefix(prefix)
+
+		published, err := collectionFactory.PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, distribution)
+		if err != nil {
+			return "", nil, err
+		}
+		err = collectionFactory.PublishedRepoCollection().LoadComplete(published, collectionFactory)
+		if err != nil {
+			return "", nil, err
+		}
+
+		refs := NewPackageRefList()
+		for _, component := range published.Components() {
+			if componentRefs := published.RefList(component); componentRefs != nil {
+				refs = refs.Merge(componentRefs, false, true)
+			}
+		}
+		return description, refs, nil
+	}
+
+	return "", nil, fmt.Errorf("%w kind %#v, expected snapshot, mirror, repo or publish", ErrWrongPackageSource, kind)
+}
+
+// snapshotPackageRefs loads complete snapshot and returns its package refs
+func snapshotPackageRefs(description string, snapshot *Snapshot, collectionFactory *CollectionFactory) (string, *PackageRefList, error) {
+	err := collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+	if err != nil {
+		return "", nil, err
+	}
+	return description, snapshot.RefList(), nil
+}
+
+// PackageChange is a change of single package between two package lists
+type PackageChange struct {
+	// Kind of the change: added, removed, upgraded, downgraded or rebuilt
+	Kind string
+	// Package name
+	Name string
+	// Package architecture
+	Architecture string
+	// Version in the old list
+	OldVersion string `json:",omitempty"`
+	// Version in the new list
+	NewVersion string `json:",omitempty"`
+	// Package key in the old list
+	OldKey string `json:",omitempty"`
+	// Package key in the new list
+	NewKey string `json:",omitempty"`
+
+	old, new *Package
+}
+
+// SourceChanges groups changes of packages built from the same source package
+type SourceChanges struct {
+	// Source package name
+	Source string
+	// Changes of binary and source packages
+	Changes []PackageChange
+	// Changelog entries covered by the change, newest first
+	Changelog []ChangelogEntry `json:",omitempty"`
+}
+
+// SemanticDiff is a difference between two package lists with changes classified and
+// grouped by source package
+type SemanticDiff struct {
+	// Description of the old package list
+	From string
+	// Description of the new package list
+	To string
+	// Number of changes of each kind
+	Summary map[string]int
+	// Changes grouped by source package, sorted by source name
+	Sources []*SourceChanges
+}
+
+// sourceName returns name of the source package package has been built from
+func sourceName(p *Package) string {
+	if p.IsSource {
+		return p.Name
+	}
+
+	return p.GetField("$Source")
+}
+
+// NewSemanticDiff classifies package diffs (as returned by PackageRefList.Diff)
+func NewSemanticDiff(from, to string, diffs PackageDiffs) *SemanticDiff {
+	result := &SemanticDiff{
+		From:    from,
+		To:      to,
+		Summary: map[string]int{},
+		Sources: []*SourceChanges{},
+	}
+
+	for _, kind := range changeKinds {
+		result.Summary[kind] = 0
+	}
+
+	sources := map[string]*SourceChanges{}
+
+	for _, pdiff := range diffs {
+		change := PackageChange{old: pdiff.Left, new: pdiff.Right}
+
+		var p *Package
+
+		switch {
+		case pdiff.Left == nil:
+			p = pdiff.Right
+			change.Kind = ChangeAdded
+		case pdiff.Right == nil:
+			p = pdiff.Left
+			change.Kind = ChangeRemoved
+		default:
+			p = pdiff.Right
+			switch rel := CompareVersions(pdiff.Left.Version, pdiff.Right.Version); {
+			case rel < 0:
+				change.Kind = ChangeUpgraded
+			case rel > 0:
+				change.Kind = ChangeDowngraded
+			default:
+				change.Kind = ChangeRebuilt
+			}
+		}
+
+		change.Name, change.Architecture = p.Name, p.Architecture
+		if pdiff.Left != nil {
+			change.OldVersion, change.OldKey = pdiff.Left.Version, string(pdiff.Left.Key(""))
+		}
+		if pdiff.Right != nil {
+			change.NewVersion, change.NewKey = pdiff.Right.Version, string(pdiff.Right.Key(""))
+		}
+
+		source := sourceName(p)
+		if sources[source] == nil {
+			sources[source] = &SourceChanges{Source: source}
+			result.Sources = append(result.Sources, sources[source])
+		}
+
+		sources[source].Changes = append(sources[source].Changes, change)
+		result.Summary[change.Kind]++
+	}
+
+	sort.Slice(result.Sources, func(i, j int) bool { return result.Sources[i].Source < result.Sources[j].Source })
+
+	for _, source := range result.Sources {
+		sort.SliceStable(source.Changes, func(i, j int) bool {
+			if source.Changes[i].Name != source.Changes[j].Name {
+				return source.Changes[i].Name < source.Changes[j].Name
+			}
+			return source.Changes[i].Architecture < source.Changes[j].Architecture
+		})
+	}
+
+	return result
+}
+
+// Len returns total number of changes
+func (d *SemanticDiff) Len() int {
+	result := 0
+	for _, count := range d.Summary {
+		result += count
+	}
+
+	return result
+}
+
+// SummaryString returns human-readable summary of changes
+func (d *SemanticDiff) SummaryString() string {
+	parts := make([]string, len(changeKinds))
+	for i, kind := range changeKinds {
+		parts[i] = fmt.Sprintf("%d %s", d.Summary[kind], kind)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// changelogSource picks package changelog is read from and range of versions
+// covered by the change of source package: entries newer than fromVersion, up to toVersion
+func (source *SourceChanges) changelogSource() (p *Package, fromVersion, toVersion string, ok bool) {
+	var fallback *PackageChange
+
+	for i := range source.Changes {
+		change := &source.Changes[i]
+
+		switch change.Kind {
+		case ChangeUpgraded:
+			if !change.new.IsSource {
+				return change.new, change.old.GetField("$SourceVersion"), change.new.GetField("$SourceVersion"), true
+			}
+		case ChangeDowngraded:
+			// changes being reverted are listed in the changelog of the old package
+			if !change.old.IsSource {
+				return change.old, change.new.GetField("$SourceVersion"), change.old.GetField("$SourceVersion"), true
+			}
+		case ChangeAdded, ChangeRebuilt:
+			if fallback == nil && !change.new.IsSource {
+				fallback = change
+			}
+		}
+	}
+
+	if fallback != nil {
+		// only the latest entry is reported for new and rebuilt packages
+		return fallback.new, "", fallback.new.GetField("$SourceVersion"), true
+	}
+
+	return nil, "", "", false
+}
+
+// LoadChangelogs fills in changelog entries covered by the changes of each source package,
+// reading changelogs from package files in the pool. Packages missing from the pool
+// (e.g. mirrors downloaded without packages) are skipped
+func (d *SemanticDiff) LoadChangelogs(packagePool aptly.PackagePool, progress aptly.Progress) {
+	for _, source := range d.Sources {
+		p, fromVersion, toVersion, ok := source.changelogSource()
+		if !ok {
+			continue
+		}
+
+		entries, err := packageChangelog(p, packagePool)
+		if err != nil {
+			if progress != nil {
+				progress.ColoredPrintf("@y[!]@| @!Unable to read changelog of %s:@| %s", p, err)
+			}
+			continue
+		}
+
+		for _, entry := range entries {
+			if CompareVersions(entry.Version, toVersion) > 0 {
+				continue
+			}
+
+			if fromVersion == "" {
+				source.Changelog = append(source.Changelog, entry)
+				break
+			}
+
+			if CompareVersions(entry.Version, fromVersion) <= 0 {
+				break
+			}
+
+			source.Changelog = append(source.Changelog, entry)
+		}
+	}
+}
+
+// packageChangelog reads and parses changelog from package file in the pool
+func packageChangelog(p *Package, packagePool aptly.PackagePool) ([]ChangelogEntry, error) {
+	files := p.Files()
+	if len(files) == 0 {
+		return nil, fmt.Errorf("package has no files")
+	}
+
+	poolPath, err := files[0].GetPoolPath(packagePool)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := packagePool.Open(poolPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	data, err := GetChangelogFromDeb(reader, files[0].Filename, p.Name)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	return ParseChangelog(data)
+}
+
+// WriteCSV writes changes as CSV with header line
+func (d *SemanticDiff) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write([]string{"Source", "Package", "Architecture", "Change", "OldVersion", "NewVersion"})
+	if err != nil {
+		return err
+	}
+
+	for _, source := range d.Sources {
+		for _, change := range source.Changes {
+			err = writer.Write([]string{source.Source, change.Name, change.Architecture, change.Kind, change.OldVersion, change.NewVersion})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteChangelog writes human-readable summary of changes with changelog entries
+func (d *SemanticDiff) WriteChangelog(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Changes from %s to %s: %s\n", d.From, d.To, d.SummaryString())
+
+	for _, source := range d.Sources {
+		fmt.Fprintf(&b, "\n%s\n", source.Source)
+
+		for _, change := range source.Changes {
+			switch change.Kind {
+			case ChangeAdded:
+				fmt.Fprintf(&b, "  %s %s [%s] %s\n", change.Kind, change.Name, change.Architecture, change.NewVersion)
+			case ChangeRemoved:
+				fmt.Fprintf(&b, "  %s %s [%s] %s\n", change.Kind, change.Name, change.Architecture, change.OldVersion)
+			default:
+				fmt.Fprintf(&b, "  %s %s [%s] %s -> %s\n", change.Kind, change.Name, change.Architecture, change.OldVersion, change.NewVersion)
+			}
+		}
+
+		for _, entry := range source.Changelog {
+			fmt.Fprintf(&b, "\n  %s\n", entry)
+			for _, line := range strings.Split(entry.Changes, "\n") {
+				if line == "" {
+					b.WriteString("\n")
+				} else {
+					fmt.Fprintf(&b, "    %s\n", line)
+				}
+			}
+			fmt.Fprintf(&b, "    -- %s  %s\n", entry.Maintainer, entry.Date)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,258 @@
+package deb
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"runtime"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+
+	. "gopkg.in/check.v1"
+)
+
+type SemanticDiffSuite struct {
+	db                database.Storage
+	collectionFactory *CollectionFactory
+}
+
+var _ = Suite(&SemanticDiffSuite{})
+
+func (s *SemanticDiffSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collectionFactory = NewCollectionFactory(s.db)
+}
+
+func (s *SemanticDiffSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *SemanticDiffSuite) newPackage(name, version, arch, source, md5 string) *Package {
+	stanza := packageStanza.Copy()
+	stanza["Package"] = name
+	stanza["Version"] = version
+	stanza["Architecture"] = arch
+	stanza["MD5sum"] = md5
+	if source != "" {
+		stanza["Source"] = source
+	} else {
+		delete(stanza, "Source")
+	}
+
+	return NewPackageFromControlFile(stanza)
+}
+
+func (s *SemanticDiffSuite) refList(c *C, packages ...*Package) *PackageRefList {
+	list := NewPackageList()
+	for _, p := range packages {
+		c.Assert(s.collectionFactory.PackageCollection().Update(p), IsNil)
+		c.Assert(list.Add(p), IsNil)
+	}
+
+	return NewPackageRefListFromPackageList(list)
+}
+
+func (s *SemanticDiffSuite) TestParseChangelog(c *C) {
+	entries, err := ParseChangelog([]byte(`hello (2.0-1) unstable experimental; urgency=high, binary-only=yes
+
+  * New upstream release.
+
+  [ Jane Doe ]
+  * Fix build.
+
+ -- John Doe <john@example.com>  Mon, 01 Jan 2024 10:00:00 +0000
+
+hello (1.0-1) unstable; urgency=low
+
+  * Initial release.
+
+ -- John Doe <john@example.com>  Sun, 01 Jan 2023 10:00:00 +0000
+
+Old Changelog:
+  free-form text
+`))
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Check(entries[0], DeepEquals, ChangelogEntry{
+		Source:        "hello",
+		Version:       "2.0-1",
+		Distributions: "unstable experimental",
+		Urgency:       "high",
+		Maintainer:    "John Doe <john@example.com>",
+		Date:          "Mon, 01 Jan 2024 10:00:00 +0000",
+		Changes:       "* New upstream release.\n\n[ Jane Doe ]\n* Fix build.",
+	})
+	c.Check(entries[1].String(), Equals, "hello (1.0-1) unstable; urgency=low")
+
+	_, err = ParseChangelog([]byte("not a changelog\n"))
+	c.Check(err, ErrorMatches, "malformed changelog header at line 1: not a changelog")
+
+	_, err = ParseChangelog([]byte("hello (1.0) unstable; urgency=low\n\n  * Truncated\n"))
+	c.Check(err, ErrorMatches, "changelog entry hello \\(1.0\\) unstable; urgency=low is missing trailer line")
+}
+
+func (s *SemanticDiffSuite) TestNewSemanticDiff(c *C) {
+	left := s.refList(c,
+		s.newPackage("app", "1.0", "amd64", "", "a1"),
+		s.newPackage("libfoo1", "2.0", "amd64", "foo", "f1"),
+		s.newPackage("foo-utils", "2.0", "amd64", "foo (2.0)", "f2"),
+		s.newPackage("legacy", "0.1", "all", "", "l1"),
+		s.newPackage("tool", "3.0", "i386", "", "t1"),
+	)
+	right := s.refList(c,
+		s.newPackage("app", "1.0", "amd64", "", "a2"),
+		s.newPackage("libfoo1", "2.1", "amd64", "foo", "f3"),
+		s.newPackage("foo-utils", "2.1", "amd64", "foo (2.1)", "f4"),
+		s.newPackage("newcomer", "1.0", "all", "", "n1"),
+		s.newPackage("tool", "2.9", "i386", "", "t2"),
+	)
+
+	diffs, err := left.Diff(right, s.collectionFactory.PackageCollection())
+	c.Assert(err, IsNil)
+
+	diff := NewSemanticDiff("snapshot:old", "mirror:new", diffs)
+	c.Check(diff.Len(), Equals, 6)
+	c.Check(diff.SummaryString(), Equals, "1 added, 2 upgraded, 1 downgraded, 1 rebuilt, 1 removed")
+
+	c.Assert(diff.Sources, HasLen, 5)
+	c.Check(diff.Sources[0].Source, Equals, "app")
+	c.Check(diff.Sources[0].Changes[0].Kind, Equals, ChangeRebuilt)
+	c.Check(diff.Sources[1].Source, Equals, "foo")
+	c.Check(diff.Sources[1].Changes, HasLen, 2)
+	c.Check(diff.Sources[1].Changes[0].Name, Equals, "foo-utils")
+	c.Check(diff.Sources[1].Changes[0].Kind, Equals, ChangeUpgraded)
+	c.Check(diff.Sources[1].Changes[0].OldVersion, Equals, "2.0")
+	c.Check(diff.Sources[1].Changes[0].NewVersion, Equals, "2.1")
+	c.Check(diff.Sources[1].Changes[0].NewKey, Matches, "Pamd64 foo-utils 2.1 [0-9a-f]+")
+	c.Check(diff.Sources[2].Changes[0].Kind, Equals, ChangeRemoved)
+	c.Check(diff.Sources[2].Changes[0].NewVersion, Equals, "")
+	c.Check(diff.Sources[3].Changes[0].Kind, Equals, ChangeAdded)
+	c.Check(diff.Sources[4].Changes[0].Kind, Equals, ChangeDowngraded)
+
+	var buf bytes.Buffer
+	c.Assert(diff.WriteCSV(&buf), IsNil)
+	c.Check(buf.String(), Equals, "Source,Package,Architecture,Change,OldVersion,NewVersion\n"+
+		"app,app,amd64,rebuilt,1.0,1.0\n"+
+		"foo,foo-utils,amd64,upgraded,2.0,2.1\n"+
+		"foo,libfoo1,amd64,upgraded,2.0,2.1\n"+
+		"legacy,legacy,all,removed,0.1,\n"+
+		"newcomer,newcomer,all,added,,1.0\n"+
+		"tool,tool,i386,downgraded,3.0,2.9\n")
+
+	buf.Reset()
+	c.Assert(diff.WriteChangelog(&buf), IsNil)
+	c.Check(buf.String(), Equals, "Changes from snapshot:old to mirror:new: 1 added, 2 upgraded, 1 downgraded, 1 rebuilt, 1 removed\n"+
+		"\napp\n  rebuilt app [amd64] 1.0 -> 1.0\n"+
+		"\nfoo\n  upgraded foo-utils [amd64] 2.0 -> 2.1\n  upgraded libfoo1 [amd64] 2.0 -> 2.1\n"+
+		"\nlegacy\n  removed legacy [all] 0.1\n"+
+		"\nnewcomer\n  added newcomer [all] 1.0\n"+
+		"\ntool\n  downgraded tool [i386] 3.0 -> 2.9\n")
+}
+
+func (s *SemanticDiffSuite) TestLoadChangelogs(c *C) {
+	_, _File, _, _ := runtime.Caller(0)
+
+	pool := files.NewPackagePool(c.MkDir(), false)
+	reporter := &aptly.RecordingResultReporter{}
+
+	refLists := make([]*PackageRefList, 2)
+	for i, version := range []string{"1.49.0.1", "1.62.0.1"} {
+		list := NewPackageList()
+		packageFile := filepath.Join(filepath.Dir(_File), "../system/files/libboost-program-options-dev_"+version+"_i386.deb")
+
+		_, failedFiles, err := ImportPackageFiles(list, []string{packageFile}, false, &NullVerifier{}, pool,
+			s.collectionFactory.PackageCollection(), reporter, nil, nil, s.collectionFactory.ChecksumCollection)
+		c.Assert(err, IsNil)
+		c.Assert(failedFiles, HasLen, 0)
+
+		refLists[i] = NewPackageRefListFromPackageList(list)
+	}
+
+	diffs, err := refLists[0].Diff(refLists[1], s.collectionFactory.PackageCollection())
+	c.Assert(err, IsNil)
+
+	diff := NewSemanticDiff("snapshot:old", "snapshot:new", diffs)
+	diff.LoadChangelogs(pool, nil)
+
+	c.Assert(diff.Sources, HasLen, 1)
+	c.Check(diff.Sources[0].Source, Equals, "boost-defaults")
+	c.Check(diff.Sources[0].Changes[0].Kind, Equals, ChangeUpgraded)
+
+	versions := []string{}
+	for _, entry := range diff.Sources[0].Changelog {
+		versions = append(versions, entry.Version)
+	}
+	c.Check(versions, DeepEquals, []string{"1.62.0.1", "1.61.0.2", "1.61.0.1", "1.60.0.1", "1.58.0.2",
+		"1.58.0.1", "1.55.0.2", "1.55.0.1", "1.54.0.1", "1.49.0.2"})
+	c.Check(diff.Sources[0].Changelog[0].Changes, Equals, "* Set default boost to 1.62.")
+
+	// new package: only the latest entry
+	diffs, err = NewPackageRefList().Diff(refLists[0], s.collectionFactory.PackageCollection())
+	c.Assert(err, IsNil)
+
+	diff = NewSemanticDiff("snapshot:empty", "snapshot:old", diffs)
+	diff.LoadChangelogs(pool, nil)
+	c.Assert(diff.Sources[0].Changelog, HasLen, 1)
+	c.Check(diff.Sources[0].Changelog[0].Version, Equals, "1.49.0.1")
+
+	// package files missing in the pool are skipped
+	diff = NewSemanticDiff("snapshot:empty", "snapshot:old", diffs)
+	diff.LoadChangelogs(files.NewPackagePool(c.MkDir(), false), nil)
+	c.Check(diff.Sources[0].Changelog, HasLen, 0)
+}
+
+func (s *SemanticDiffSuite) TestResolvePackageRefs(c *C) {
+	refs := s.refList(c, s.newPackage("app", "1.0", "amd64", "", "a1"))
+
+	snapshot := NewSnapshotFromRefList("snap", nil, refs, "")
+	c.Assert(s.collectionFactory.SnapshotCollection().Add(snapshot), IsNil)
+
+	colon := NewSnapshotFromRefList("release:2024", nil, NewPackageRefList(), "")
+	c.Assert(s.collectionFactory.SnapshotCollection().Add(colon), IsNil)
+
+	repo := NewLocalRepo("local", "")
+	repo.UpdateRefList(refs)
+	c.Assert(s.collectionFactory.LocalRepoCollection().Add(repo), IsNil)
+
+	published, err := NewPublishedRepo("", "ppa", "stable", nil, []string{"main"}, []interface{}{snapshot}, s.collectionFactory, false)
+	c.Assert(err, IsNil)
+	c.Assert(s.collectionFactory.PublishedRepoCollection().Add(published), IsNil)
+
+	for spec, description := range map[string]string{
+		"snap":               "snapshot:snap",
+		"snapshot:snap":      "snapshot:snap",
+		"repo:local":         "repo:local",
+		"publish:ppa/stable": "publish:ppa/stable",
+	} {
+		desc, result, err := ResolvePackageRefs(spec, s.collectionFactory)
+		c.Assert(err, IsNil, Commentf("%s", spec))
+		c.Check(desc, Equals, description)
+		c.Check(result.Strings(), DeepEquals, refs.Strings())
+	}
+
+	// snapshot named with colon is found without prefix
+	desc, result, err := ResolvePackageRefs("release:2024", s.collectionFactory)
+	c.Assert(err, IsNil)
+	c.Check(desc, Equals, "snapshot:release:2024")
+	c.Check(result.Len(), Equals, 0)
+
+	_, _, err = ResolvePackageRefs("mirror:missing", s.collectionFactory)
+	c.Check(err, ErrorMatches, "mirror with name missing not found")
+
+	_, _, err = ResolvePackageRefs("publish:stable", s.collectionFactory)
+	c.Check(err, ErrorMatches, "published repo with storage:prefix/distribution ./stable not found")
+
+	_, _, err = ResolvePackageRefs("package:app", s.collectionFactory)
+	c.Check(err, ErrorMatches, "wrong package source kind \"package\", expected snapshot, mirror, repo or publish")
+	c.Check(errors.Is(err, ErrWrongPackageSource), Equals, true)
+
+	_, _, err = ResolvePackageRefs("repo:", s.collectionFactory)
+	c.Check(err, ErrorMatches, "wrong package source \"repo:\", name is missing")
+	c.Check(errors.Is(err, ErrWrongPackageSource), Equals, true)
+
+	_, _, err = ResolvePackageRefs("snap-no", s.collectionFactory)
+	c.Check(errors.Is(err, ErrWrongPackageSource), Equals, false)
+}
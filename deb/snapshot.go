@@ -36,6 +36,9 @@ type Snapshot struct {
 	// Description of how snapshot was created
 	Description string
 
+	// Arbitrary key/value metadata
+	Labels Labels `codec:",omitempty" json:",omitempty"`
+
 	Origin               string
 	NotAutomatic         string
 	ButAutomaticUpgrades string
@@ -371,6 +374,9 @@ func (collection *SnapshotCollection) ForEach(handler func(*Snapshot) error) err
 }
 
 // ForEachSorted runs method for each snapshot following some sort order
+//
+// Sort method is one of name, time or label:<key> (by value of the label, snapshots
+// without the label come first)
 func (collection *SnapshotCollection) ForEachSorted(sortMethod string, handler func(*Snapshot) error) error {
 	blobs := collection.db.FetchByPrefix([]byte("S"))
 	list := make([]*Snapshot, 0, len(blobs))
@@ -427,11 +433,13 @@ func (collection *SnapshotCollection) Drop(snapshot *Snapshot) error {
 const (
 	SortName = iota
 	SortTime
+	SortLabel
 )
 
 type snapshotSorter struct {
 	list       []*Snapshot
 	sortMethod int
+	labelKey   string
 }
 
 func newSnapshotSorter(sortMethod string, list []*Snapshot) (*snapshotSorter, error) {
@@ -443,7 +451,12 @@ func newSnapshotSorter(sortMethod string, list []*Snapshot) (*snapshotSorter, er
 	case "name", "Name":
 		s.sortMethod = SortName
 	default:
-		return nil, fmt.Errorf("sorting method \"%s\" unknown", sortMethod)
+		key, found := strings.CutPrefix(sortMethod, "label:")
+		if !found || ValidateLabelKey(key) != nil {
+			return nil, fmt.Errorf("sorting method \"%s\" unknown", sortMethod)
+		}
+		s.sortMethod = SortLabel
+		s.labelKey = key
 	}
 
 	sort.Sort(s)
@@ -461,6 +474,12 @@ func (s *snapshotSorter) Less(i, j int) bool {
 		return s.list[i].Name < s.list[j].Name
 	case SortTime:
 		return s.list[i].CreatedAt.Before(s.list[j].CreatedAt)
+	case SortLabel:
+		vi, vj := s.list[i].Labels[s.labelKey], s.list[j].Labels[s.labelKey]
+		if vi == vj {
+			return s.list[i].Name < s.list[j].Name
+		}
+		return vi < vj
 	}
 	panic("unknown sort method")
 }
@@ -0,0 +1,545 @@
+package deb
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Snapshot bundle is a tar archive which transfers snapshot with package metadata
+// and package files between aptly instances:
+//
+//	manifest.json               list of bundle members with sizes and SHA256 checksums
+//	manifest.json.gpg           detached signature of the manifest (optional)
+//	snapshot.json               snapshot record
+//	Packages, Packages.udeb,    stanzas of binary, udeb and source packages
+//	Sources
+//	pool/<sha256>/<filename>    package files
+//
+// Members are written exactly in this order, so that bundle could be streamed.
+const (
+	bundleManifest  = "manifest.json"
+	bundleSignature = "manifest.json.gpg"
+	bundleSnapshot  = "snapshot.json"
+	bundlePool      = "pool/"
+
+	// SnapshotBundleVersion is current version of the bundle format
+	SnapshotBundleVersion = 1
+)
+
+var bundleIndexes = []string{"Packages", "Packages.udeb", "Sources"}
+
+// SnapshotBundleFile describes single member of the bundle
+type SnapshotBundleFile struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// SnapshotBundleManifest lists all the members of the bundle, it is signed to protect bundle contents
+type SnapshotBundleManifest struct {
+	Version   int
+	Snapshot  string
+	CreatedAt time.Time
+	Files     []SnapshotBundleFile
+}
+
+// bundleMember is metadata member of the bundle kept in memory
+type bundleMember struct {
+	name string
+	data []byte
+}
+
+// bundleIndex returns name of the bundle member holding package stanza
+func bundleIndex(p *Package) string {
+	if p.IsSource {
+		return "Sources"
+	}
+	if p.IsUdeb {
+		return "Packages.udeb"
+	}
+
+	return "Packages"
+}
+
+// bundlePoolPath returns name of the bundle member holding package file
+func bundlePoolPath(f *PackageFile) string {
+	return bundlePool + f.Checksums.SHA256 + "/" + f.Filename
+}
+
+func writeBundleMember(tw *tar.Writer, name string, size int64, modTime time.Time, r io.Reader) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(tw, r, size)
+	return err
+}
+
+// ExportSnapshotBundle writes snapshot, its packages and package files as a bundle to w
+//
+// If signer is not nil, bundle manifest is signed.
+func ExportSnapshotBundle(w io.Writer, snapshot *Snapshot, collectionFactory *CollectionFactory, packagePool aptly.PackagePool,
+	signer pgp.Signer, progress aptly.Progress) error {
+	err := collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+	if err != nil {
+		return err
+	}
+
+	type poolFile struct {
+		path     string
+		poolPath string
+		size     int64
+		sha256   string
+	}
+
+	indexes := map[string]*bytes.Buffer{}
+	poolFiles := []poolFile{}
+	seen := map[string]bool{}
+
+	if progress != nil {
+		progress.Printf("Collecting %d packages...\n", snapshot.NumPackages())
+	}
+
+	packageCollection := collectionFactory.PackageCollection()
+	err = snapshot.RefList().ForEach(func(key []byte) error {
+		p, err2 := packageCollection.ByKey(key)
+		if err2 != nil {
+			return fmt.Errorf("unable to load package with key %s: %s", key, err2)
+		}
+
+		if p.IsInstaller {
+			return fmt.Errorf("installer package %s can't be exported", p)
+		}
+
+		index := bundleIndex(p)
+		if indexes[index] == nil {
+			indexes[index] = &bytes.Buffer{}
+		}
+		buf := bufio.NewWriter(indexes[index])
+		if err2 = p.Stanza().WriteTo(buf, p.IsSource, false, false); err2 != nil {
+			return err2
+		}
+		if err2 = buf.WriteByte('\n'); err2 != nil {
+			return err2
+		}
+		if err2 = buf.Flush(); err2 != nil {
+			return err2
+		}
+
+		files := p.Files()
+		for i := range files {
+			if files[i].Checksums.SHA256 == "" {
+				return fmt.Errorf("file %s of package %s has no SHA256 checksum", files[i].Filename, p)
+			}
+
+			path := bundlePoolPath(&files[i])
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			poolPath, err2 := files[i].GetPoolPath(packagePool)
+			if err2 != nil {
+				return err2
+			}
+
+			size, err2 := packagePool.Size(poolPath)
+			if err2 != nil {
+				return err2
+			}
+			if size != files[i].Checksums.Size {
+				return fmt.Errorf("file %s in the package pool has wrong size %d, expected %d", poolPath, size, files[i].Checksums.Size)
+			}
+
+			poolFiles = append(poolFiles, poolFile{path: path, poolPath: poolPath, size: size, sha256: files[i].Checksums.SHA256})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshotData, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	manifest := SnapshotBundleManifest{
+		Version:   SnapshotBundleVersion,
+		Snapshot:  snapshot.Name,
+		CreatedAt: time.Now(),
+	}
+
+	metadata := []bundleMember{{bundleSnapshot, snapshotData}}
+	for _, index := range bundleIndexes {
+		if indexes[index] != nil {
+			metadata = append(metadata, bundleMember{index, indexes[index].Bytes()})
+		}
+	}
+
+	for _, member := range metadata {
+		manifest.Files = append(manifest.Files, SnapshotBundleFile{
+			Path:   member.name,
+			Size:   int64(len(member.data)),
+			SHA256: fmt.Sprintf("%x", sha256.Sum256(member.data)),
+		})
+	}
+	for _, f := range poolFiles {
+		manifest.Files = append(manifest.Files, SnapshotBundleFile{
+			Path:   f.path,
+			Size:   f.size,
+			SHA256: f.sha256,
+		})
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var signature []byte
+	if signer != nil {
+		signature, err = signBundleManifest(manifestData, signer)
+		if err != nil {
+			return fmt.Errorf("unable to sign bundle manifest: %s", err)
+		}
+	}
+
+	tw := tar.NewWriter(w)
+
+	err = writeBundleMember(tw, bundleManifest, int64(len(manifestData)), manifest.CreatedAt, bytes.NewReader(manifestData))
+	if err != nil {
+		return err
+	}
+
+	if signature != nil {
+		err = writeBundleMember(tw, bundleSignature, int64(len(signature)), manifest.CreatedAt, bytes.NewReader(signature))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, member := range metadata {
+		err = writeBundleMember(tw, member.name, int64(len(member.data)), manifest.CreatedAt, bytes.NewReader(member.data))
+		if err != nil {
+			return err
+		}
+	}
+
+	if progress != nil {
+		progress.Printf("Writing %d package files...\n", len(poolFiles))
+	}
+
+	for _, f := range poolFiles {
+		err = func() error {
+			reader, err2 := packagePool.Open(f.poolPath)
+			if err2 != nil {
+				return err2
+			}
+			defer func() { _ = reader.Close() }()
+
+			return writeBundleMember(tw, f.path, f.size, manifest.CreatedAt, reader)
+		}()
+		if err != nil {
+			return fmt.Errorf("unable to write %s to bundle: %s", f.poolPath, err)
+		}
+	}
+
+	return tw.Close()
+}
+
+// signBundleManifest returns detached signature of the manifest
+func signBundleManifest(manifestData []byte, signer pgp.Signer) ([]byte, error) {
+	tempDir, err := os.MkdirTemp("", "aptly-bundle")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	manifestPath := filepath.Join(tempDir, bundleManifest)
+	if err = os.WriteFile(manifestPath, manifestData, 0644); err != nil {
+		return nil, err
+	}
+
+	if err = signer.DetachedSign(manifestPath, manifestPath+".gpg"); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(manifestPath + ".gpg")
+}
+
+// readBundleMember reads metadata member of the bundle checking it against the manifest
+func readBundleMember(r io.Reader, header *tar.Header, files map[string]SnapshotBundleFile) ([]byte, error) {
+	expected, ok := files[header.Name]
+	if !ok {
+		return nil, fmt.Errorf("bundle member %s is not listed in the manifest", header.Name)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) != expected.Size || fmt.Sprintf("%x", sha256.Sum256(data)) != expected.SHA256 {
+		return nil, fmt.Errorf("checksum mismatch for bundle member %s", header.Name)
+	}
+
+	return data, nil
+}
+
+// ImportSnapshotBundle reads bundle from r, imports packages and package files and creates snapshot
+//
+// If name is empty, snapshot name is taken from the bundle. If verifier is not nil, bundle should be signed
+// and signature should be valid. Package files which are already in the package pool are not imported again.
+// Licenses of imported packages are extracted from their copyright files.
+func ImportSnapshotBundle(r io.Reader, name string, collectionFactory *CollectionFactory, packagePool aptly.PackagePool,
+	verifier pgp.Verifier, progress aptly.Progress) (*Snapshot, error) {
+	tr := tar.NewReader(r)
+
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read bundle: %s", err)
+	}
+	if header.Name != bundleManifest {
+		return nil, fmt.Errorf("not a snapshot bundle: %s should be the first member", bundleManifest)
+	}
+
+	manifestData, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read bundle: %s", err)
+	}
+
+	var manifest SnapshotBundleManifest
+	if err = json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("unable to parse bundle manifest: %s", err)
+	}
+	if manifest.Version != SnapshotBundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", manifest.Version)
+	}
+
+	files := make(map[string]SnapshotBundleFile, len(manifest.Files))
+	for _, f := range manifest.Files {
+		files[f.Path] = f
+	}
+
+	header, err = tr.Next()
+	if err == nil && header.Name == bundleSignature {
+		var signature []byte
+		signature, err = io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read bundle: %s", err)
+		}
+
+		if verifier != nil {
+			err = verifier.VerifyDetachedSignature(bytes.NewReader(signature), bytes.NewReader(manifestData), false)
+			if err != nil {
+				return nil, fmt.Errorf("bundle signature verification failed: %s", err)
+			}
+		}
+
+		header, err = tr.Next()
+	} else if verifier != nil {
+		return nil, fmt.Errorf("bundle is not signed")
+	}
+
+	metadata := map[string][]byte{}
+	for ; err == nil && !strings.HasPrefix(header.Name, bundlePool); header, err = tr.Next() {
+		metadata[header.Name], err = readBundleMember(tr, header, files)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("unable to read bundle: %s", err)
+	}
+
+	snapshotData, ok := metadata[bundleSnapshot]
+	if !ok {
+		return nil, fmt.Errorf("bundle is missing %s", bundleSnapshot)
+	}
+
+	var bundled Snapshot
+	if err2 := json.Unmarshal(snapshotData, &bundled); err2 != nil {
+		return nil, fmt.Errorf("unable to parse bundled snapshot: %s", err2)
+	}
+
+	if name == "" {
+		name = bundled.Name
+	}
+
+	snapshotCollection := collectionFactory.SnapshotCollection()
+	if _, err2 := snapshotCollection.ByName(name); err2 == nil {
+		return nil, fmt.Errorf("snapshot with name %s already exists", name)
+	}
+
+	packages := []*Package{}
+	wanted := map[string]utils.ChecksumInfo{}
+
+	for _, index := range bundleIndexes {
+		data, ok := metadata[index]
+		if !ok {
+			continue
+		}
+
+		reader := NewControlFileReader(bytes.NewReader(data), false, false)
+		for {
+			stanza, err2 := reader.ReadStanza()
+			if err2 != nil {
+				return nil, fmt.Errorf("unable to parse %s: %s", index, err2)
+			}
+			if stanza == nil {
+				break
+			}
+
+			var p *Package
+			switch index {
+			case "Sources":
+				p, err2 = NewSourcePackageFromControlFile(stanza)
+				if err2 != nil {
+					return nil, fmt.Errorf("unable to parse %s: %s", index, err2)
+				}
+			case "Packages.udeb":
+				p = NewUdebPackageFromControlFile(stanza)
+			default:
+				p = NewPackageFromControlFile(stanza)
+			}
+
+			for _, f := range p.Files() {
+				wanted[bundlePoolPath(&f)] = f.Checksums
+			}
+			packages = append(packages, p)
+		}
+	}
+
+	if progress != nil {
+		progress.Printf("Importing %d packages...\n", len(packages))
+	}
+
+	checksumStorage := collectionFactory.ChecksumCollection(nil)
+	poolPaths := map[string]string{}
+
+	for ; err == nil; header, err = tr.Next() {
+		checksums, ok := wanted[header.Name]
+		if !ok {
+			return nil, fmt.Errorf("bundle member %s is not used by any package", header.Name)
+		}
+
+		expected, ok := files[header.Name]
+		if !ok {
+			return nil, fmt.Errorf("bundle member %s is not listed in the manifest", header.Name)
+		}
+		if expected.SHA256 != checksums.SHA256 || expected.Size != checksums.Size {
+			return nil, fmt.Errorf("checksum mismatch for bundle member %s", header.Name)
+		}
+
+		filename := filepath.Base(header.Name)
+
+		poolPath, exists, err2 := packagePool.Verify("", filename, &checksums, checksumStorage)
+		if err2 != nil {
+			return nil, err2
+		}
+		if !exists {
+			poolPath, err2 = importBundlePoolFile(tr, filename, expected, packagePool, checksumStorage)
+			if err2 != nil {
+				return nil, fmt.Errorf("unable to import %s: %s", header.Name, err2)
+			}
+		}
+
+		poolPaths[header.Name] = poolPath
+	}
+	if err != io.EOF {
+		return nil, fmt.Errorf("unable to read bundle: %s", err)
+	}
+
+	for path := range files {
+		if _, ok := metadata[path]; !ok && poolPaths[path] == "" {
+			return nil, fmt.Errorf("bundle is missing %s", path)
+		}
+	}
+
+	list := NewPackageListWithDuplicates(true, len(packages))
+	packageCollection := collectionFactory.PackageCollection()
+
+	for _, p := range packages {
+		pkgFiles := p.Files()
+		for i := range pkgFiles {
+			poolPath, ok := poolPaths[bundlePoolPath(&pkgFiles[i])]
+			if !ok {
+				return nil, fmt.Errorf("bundle is missing file %s of package %s", pkgFiles[i].Filename, p)
+			}
+			pkgFiles[i].PoolPath = poolPath
+		}
+		p.UpdateFiles(pkgFiles)
+
+		if err = packageCollection.Update(p); err != nil {
+			return nil, fmt.Errorf("unable to save package %s: %s", p, err)
+		}
+		if err = list.Add(p); err != nil {
+			return nil, err
+		}
+	}
+
+	snapshot := NewSnapshotFromPackageList(name, nil, list, bundled.Description)
+
+	if _, err = ExtractLicenses(snapshot.RefList(), packageCollection, packagePool, progress); err != nil {
+		return nil, fmt.Errorf("unable to extract licenses: %s", err)
+	}
+
+	snapshot.Labels = bundled.Labels
+	snapshot.Origin = bundled.Origin
+	snapshot.NotAutomatic = bundled.NotAutomatic
+	snapshot.ButAutomaticUpgrades = bundled.ButAutomaticUpgrades
+
+	if err = snapshotCollection.Add(snapshot); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// importBundlePoolFile copies package file from the bundle into the package pool verifying its checksum
+func importBundlePoolFile(r io.Reader, filename string, expected SnapshotBundleFile, packagePool aptly.PackagePool,
+	checksumStorage aptly.ChecksumStorage) (string, error) {
+	temp, err := os.CreateTemp("", "aptly-bundle")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.Remove(temp.Name()) }()
+
+	checksummer := utils.NewChecksumWriter()
+	_, err = io.Copy(io.MultiWriter(temp, checksummer), r)
+	if err1 := temp.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return "", err
+	}
+
+	checksums := checksummer.Sum()
+	if checksums.Size != expected.Size || checksums.SHA256 != expected.SHA256 {
+		return "", fmt.Errorf("checksum mismatch")
+	}
+
+	return packagePool.Import(temp.Name(), filename, &checksums, true, checksumStorage)
+}
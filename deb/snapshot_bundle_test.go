@@ -0,0 +1,184 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type SnapshotBundleSuite struct {
+	db, db2                database.Storage
+	factory, factory2      *CollectionFactory
+	packagePool, pool2     aptly.PackagePool
+	snapshot               *Snapshot
+	tempDir                string
+	packageKeys, poolPaths []string
+}
+
+var _ = Suite(&SnapshotBundleSuite{})
+
+func (s *SnapshotBundleSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.factory = NewCollectionFactory(s.db)
+	s.packagePool = files.NewPackagePool(c.MkDir(), false)
+
+	s.db2, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.factory2 = NewCollectionFactory(s.db2)
+	s.pool2 = files.NewPackagePool(c.MkDir(), false)
+
+	s.tempDir = c.MkDir()
+	s.packageKeys, s.poolPaths = nil, nil
+
+	list := NewPackageList()
+
+	binary := NewPackageFromControlFile(s.stanza(c, "bundle-app_1.0_amd64.deb", "binary contents", Stanza{
+		"Package": "bundle-app", "Version": "1.0", "Architecture": "amd64", "Depends": "libc6",
+		"Description": "bundle test\n multiline description\n"}))
+	c.Assert(s.store(c, binary, list), IsNil)
+
+	source, err := NewSourcePackageFromControlFile(s.stanza(c, "bundle-app_1.0.dsc", "source contents", Stanza{
+		"Package": "bundle-app", "Version": "1.0", "Architecture": "any", "Directory": "pool/main/b/bundle-app"}))
+	c.Assert(err, IsNil)
+	c.Assert(s.store(c, source, list), IsNil)
+
+	s.snapshot = NewSnapshotFromPackageList("bundle", nil, list, "Snapshot to transfer")
+	s.snapshot.Labels = Labels{"team": "infra"}
+	s.snapshot.Origin = "Build"
+	c.Assert(s.factory.SnapshotCollection().Add(s.snapshot), IsNil)
+}
+
+func (s *SnapshotBundleSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+	_ = s.db2.Close()
+}
+
+// stanza writes package file and builds package stanza with file checksums
+func (s *SnapshotBundleSuite) stanza(c *C, filename, contents string, stanza Stanza) Stanza {
+	path := filepath.Join(s.tempDir, filename)
+	c.Assert(os.WriteFile(path, []byte(contents), 0644), IsNil)
+
+	checksums, err := utils.ChecksumsForFile(path)
+	c.Assert(err, IsNil)
+
+	if stanza["Directory"] != "" {
+		stanza["Files"] = fmt.Sprintf(" %s %d %s\n", checksums.MD5, checksums.Size, filename)
+		stanza["Checksums-Sha256"] = fmt.Sprintf(" %s %d %s\n", checksums.SHA256, checksums.Size, filename)
+	} else {
+		stanza["Filename"] = "pool/main/b/bundle-app/" + filename
+		stanza["Size"] = fmt.Sprintf("%d", checksums.Size)
+		stanza["MD5sum"] = checksums.MD5
+		stanza["SHA256"] = checksums.SHA256
+	}
+
+	return stanza
+}
+
+// store imports package files into the pool and saves package
+func (s *SnapshotBundleSuite) store(c *C, p *Package, list *PackageList) error {
+	pkgFiles := p.Files()
+	for i := range pkgFiles {
+		poolPath, err := s.packagePool.Import(filepath.Join(s.tempDir, pkgFiles[i].Filename), pkgFiles[i].Filename,
+			&pkgFiles[i].Checksums, false, s.factory.ChecksumCollection(nil))
+		c.Assert(err, IsNil)
+		pkgFiles[i].PoolPath = poolPath
+		s.poolPaths = append(s.poolPaths, poolPath)
+	}
+	p.UpdateFiles(pkgFiles)
+
+	s.packageKeys = append(s.packageKeys, string(p.Key("")))
+
+	c.Assert(s.factory.PackageCollection().Update(p), IsNil)
+	return list.Add(p)
+}
+
+func (s *SnapshotBundleSuite) export(c *C) []byte {
+	var buf bytes.Buffer
+	c.Assert(ExportSnapshotBundle(&buf, s.snapshot, s.factory, s.packagePool, nil, nil), IsNil)
+
+	return buf.Bytes()
+}
+
+func (s *SnapshotBundleSuite) TestExportImport(c *C) {
+	bundle := s.export(c)
+
+	snapshot, err := ImportSnapshotBundle(bytes.NewReader(bundle), "", s.factory2, s.pool2, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(snapshot.Name, Equals, "bundle")
+	c.Check(snapshot.Description, Equals, "Snapshot to transfer")
+	c.Check(snapshot.Labels, DeepEquals, Labels{"team": "infra"})
+	c.Check(snapshot.Origin, Equals, "Build")
+
+	imported, err := s.factory2.SnapshotCollection().ByName("bundle")
+	c.Assert(err, IsNil)
+	c.Assert(s.factory2.SnapshotCollection().LoadComplete(imported), IsNil)
+	c.Check(imported.RefList().Strings(), DeepEquals, s.snapshot.RefList().Strings())
+
+	for _, key := range s.packageKeys {
+		p, err := s.factory2.PackageCollection().ByKey([]byte(key))
+		c.Assert(err, IsNil)
+
+		for _, f := range p.Files() {
+			exists, err := f.Verify(s.pool2, s.factory2.ChecksumCollection(nil))
+			c.Assert(err, IsNil)
+			c.Check(exists, Equals, true)
+		}
+	}
+
+	p, err := s.factory2.PackageCollection().ByKey([]byte(s.packageKeys[0]))
+	c.Assert(err, IsNil)
+	c.Check(p.Deps().Depends, DeepEquals, []string{"libc6"})
+	c.Check(p.Stanza()["Description"], Equals, "bundle test\n multiline description\n")
+
+	// files already in the pool are reused, snapshot name could be overridden
+	snapshot, err = ImportSnapshotBundle(bytes.NewReader(bundle), "bundle-copy", s.factory2, s.pool2, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(snapshot.Name, Equals, "bundle-copy")
+	c.Check(snapshot.NumPackages(), Equals, 2)
+
+	_, err = ImportSnapshotBundle(bytes.NewReader(bundle), "", s.factory2, s.pool2, nil, nil)
+	c.Check(err, ErrorMatches, "snapshot with name bundle already exists")
+}
+
+func (s *SnapshotBundleSuite) TestImportTampered(c *C) {
+	bundle := s.export(c)
+
+	tampered := bytes.Replace(bundle, []byte("binary contents"), []byte("binary CONTENTS"), 1)
+	c.Assert(tampered, Not(DeepEquals), bundle)
+
+	_, err := ImportSnapshotBundle(bytes.NewReader(tampered), "", s.factory2, s.pool2, nil, nil)
+	c.Check(err, ErrorMatches, "unable to import pool/.*/bundle-app_1.0_amd64.deb: checksum mismatch")
+
+	_, err = s.factory2.SnapshotCollection().ByName("bundle")
+	c.Check(err, NotNil)
+
+	tampered = bytes.Replace(bundle, []byte("multiline description"), []byte("multiline DESCRIPTION"), 1)
+	_, err = ImportSnapshotBundle(bytes.NewReader(tampered), "", s.factory2, s.pool2, nil, nil)
+	c.Check(err, ErrorMatches, "checksum mismatch for bundle member Packages")
+
+	_, err = ImportSnapshotBundle(bytes.NewReader(bundle[:len(bundle)/2]), "", s.factory2, s.pool2, nil, nil)
+	c.Check(err, NotNil)
+}
+
+func (s *SnapshotBundleSuite) TestImportUnsigned(c *C) {
+	_, err := ImportSnapshotBundle(bytes.NewReader(s.export(c)), "", s.factory2, s.pool2, &pgp.GoVerifier{}, nil)
+	c.Check(err, ErrorMatches, "bundle is not signed")
+}
+
+func (s *SnapshotBundleSuite) TestImportNotBundle(c *C) {
+	_, err := ImportSnapshotBundle(bytes.NewReader([]byte("garbage")), "", s.factory2, s.pool2, nil, nil)
+	c.Check(err, ErrorMatches, "unable to read bundle: .*")
+
+	_, err = ImportSnapshotBundle(io.LimitReader(bytes.NewReader(s.export(c)), 0), "", s.factory2, s.pool2, nil, nil)
+	c.Check(err, ErrorMatches, "unable to read bundle: EOF")
+}
@@ -0,0 +1,224 @@
+package deb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// SnapshotGCDecision is result of evaluating retention rules for single snapshot
+type SnapshotGCDecision struct {
+	// Snapshot name
+	Name string
+	// Snapshot creation date
+	CreatedAt time.Time
+	// Should snapshot be dropped
+	Drop bool
+	// Why snapshot is kept or dropped
+	Reason string
+
+	snapshot *Snapshot
+}
+
+type snapshotRetentionRule struct {
+	index     int
+	pattern   string
+	selectors LabelSelectors
+	keepLast  int
+	keepAge   time.Duration
+	rule      utils.SnapshotRetentionRule
+}
+
+func compileSnapshotRetentionRules(rules []utils.SnapshotRetentionRule) ([]*snapshotRetentionRule, error) {
+	result := make([]*snapshotRetentionRule, len(rules))
+
+	for i, rule := range rules {
+		compiled := &snapshotRetentionRule{index: i + 1, pattern: rule.Pattern, keepLast: rule.KeepLast, rule: rule}
+
+		if compiled.pattern == "" {
+			compiled.pattern = "*"
+		}
+		if _, err := filepath.Match(compiled.pattern, ""); err != nil {
+			return nil, fmt.Errorf("snapshot retention rule #%d: wrong pattern %#v: %s", compiled.index, rule.Pattern, err)
+		}
+
+		var err error
+		compiled.selectors, err = ParseLabelSelectors(rule.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot retention rule #%d: %s", compiled.index, err)
+		}
+
+		if rule.KeepLast < 0 {
+			return nil, fmt.Errorf("snapshot retention rule #%d: number of snapshots to keep should be positive: %d", compiled.index, rule.KeepLast)
+		}
+
+		if rule.KeepAge != "" {
+			compiled.keepAge, err = parseRetentionAge(rule.KeepAge)
+			if err != nil {
+				return nil, fmt.Errorf("snapshot retention rule #%d: %s", compiled.index, err)
+			}
+		}
+
+		if rule.KeepLast == 0 && rule.KeepAge == "" {
+			return nil, fmt.Errorf("snapshot retention rule #%d: keepLast or keepAge should be set", compiled.index)
+		}
+
+		result[i] = compiled
+	}
+
+	return result, nil
+}
+
+func (rule *snapshotRetentionRule) matches(snapshot *Snapshot) bool {
+	matched, _ := filepath.Match(rule.pattern, snapshot.Name)
+	return matched && rule.selectors.Matches(snapshot.Labels)
+}
+
+// String returns human-readable description of the rule
+func (rule *snapshotRetentionRule) String() string {
+	description := fmt.Sprintf("rule #%d (%s", rule.index, rule.pattern)
+	if len(rule.rule.Labels) > 0 {
+		description += ", " + strings.Join(rule.rule.Labels, ", ")
+	}
+
+	return description + ")"
+}
+
+// PlanSnapshotGC evaluates snapshot retention rules and decides which snapshots should be dropped
+//
+// Only snapshots matching at least one of the rules are returned. Snapshot is dropped if none of the
+// matching rules keeps it, unless snapshot is published or is a source of another snapshot
+// which is not dropped.
+func PlanSnapshotGC(rules []utils.SnapshotRetentionRule, collectionFactory *CollectionFactory, now time.Time) ([]*SnapshotGCDecision, error) {
+	compiled, err := compileSnapshotRetentionRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []*Snapshot{}
+	err = collectionFactory.SnapshotCollection().ForEach(func(snapshot *Snapshot) error {
+		snapshots = append(snapshots, snapshot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// newest snapshots first
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].CreatedAt.Equal(snapshots[j].CreatedAt) {
+			return snapshots[i].Name < snapshots[j].Name
+		}
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+
+	matched := make([]int, len(compiled))
+	decisions := map[string]*SnapshotGCDecision{}
+
+	for _, snapshot := range snapshots {
+		var decision *SnapshotGCDecision
+
+		for _, rule := range compiled {
+			if !rule.matches(snapshot) {
+				continue
+			}
+
+			rank := matched[rule.index-1]
+			matched[rule.index-1]++
+
+			drop, reason := false, ""
+			if rank < rule.keepLast {
+				reason = fmt.Sprintf("one of %d latest snapshots matching %s", rule.keepLast, rule)
+			} else if rule.keepAge > 0 && now.Sub(snapshot.CreatedAt) < rule.keepAge {
+				reason = fmt.Sprintf("younger than %s, %s", rule.rule.KeepAge, rule)
+			} else {
+				drop, reason = true, fmt.Sprintf("expired by %s", rule)
+			}
+
+			// any of the matching rules could keep the snapshot
+			if decision == nil || (decision.Drop && !drop) {
+				decision = &SnapshotGCDecision{Name: snapshot.Name, CreatedAt: snapshot.CreatedAt, Drop: drop, Reason: reason, snapshot: snapshot}
+			}
+		}
+
+		if decision != nil {
+			decisions[snapshot.UUID] = decision
+		}
+	}
+
+	publishedCollection := collectionFactory.PublishedRepoCollection()
+	for _, decision := range decisions {
+		if !decision.Drop {
+			continue
+		}
+
+		published := publishedCollection.BySnapshot(decision.snapshot)
+		if len(published) > 0 {
+			decision.Drop = false
+			decision.Reason = fmt.Sprintf("published as %s", published[0].GetPath())
+		}
+	}
+
+	// snapshot can't be dropped while it's a source of another snapshot which is kept,
+	// keeping a snapshot might keep its sources in turn
+	usedBy := map[string][]*Snapshot{}
+	for _, snapshot := range snapshots {
+		if snapshot.SourceKind == SourceSnapshot {
+			for _, sourceID := range snapshot.SourceIDs {
+				usedBy[sourceID] = append(usedBy[sourceID], snapshot)
+			}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, snapshot := range snapshots {
+			decision, ok := decisions[snapshot.UUID]
+			if !ok || !decision.Drop {
+				continue
+			}
+
+			for _, user := range usedBy[snapshot.UUID] {
+				if userDecision, ok := decisions[user.UUID]; ok && userDecision.Drop {
+					continue
+				}
+
+				decision.Drop = false
+				decision.Reason = fmt.Sprintf("source of snapshot %s", user.Name)
+				changed = true
+				break
+			}
+		}
+	}
+
+	result := make([]*SnapshotGCDecision, 0, len(decisions))
+	for _, decision := range decisions {
+		result = append(result, decision)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// ApplySnapshotGC drops snapshots marked for removal by PlanSnapshotGC
+func ApplySnapshotGC(decisions []*SnapshotGCDecision, collection *SnapshotCollection) error {
+	for _, decision := range decisions {
+		if !decision.Drop {
+			continue
+		}
+
+		if err := collection.Drop(decision.snapshot); err != nil {
+			return fmt.Errorf("unable to drop snapshot %s: %s", decision.Name, err)
+		}
+	}
+
+	return nil
+}
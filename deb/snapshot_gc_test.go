@@ -0,0 +1,108 @@
+package deb
+
+import (
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type SnapshotGCSuite struct {
+	db                database.Storage
+	collectionFactory *CollectionFactory
+	now               time.Time
+}
+
+var _ = Suite(&SnapshotGCSuite{})
+
+func (s *SnapshotGCSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collectionFactory = NewCollectionFactory(s.db)
+	s.now = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func (s *SnapshotGCSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *SnapshotGCSuite) addSnapshot(c *C, name string, age time.Duration, sources []*Snapshot, labels Labels) *Snapshot {
+	snapshot := NewSnapshotFromRefList(name, sources, NewPackageRefList(), "")
+	snapshot.CreatedAt = s.now.Add(-age)
+	snapshot.Labels = labels
+	c.Assert(s.collectionFactory.SnapshotCollection().Add(snapshot), IsNil)
+
+	return snapshot
+}
+
+func (s *SnapshotGCSuite) TestPlanAndApply(c *C) {
+	day := 24 * time.Hour
+
+	ci0 := s.addSnapshot(c, "ci-0", 40*day, nil, nil)
+	ci1 := s.addSnapshot(c, "ci-1", 30*day, nil, nil)
+	s.addSnapshot(c, "ci-2", 20*day, nil, Labels{"keep": "forever"})
+	ci3 := s.addSnapshot(c, "ci-3", 15*day, nil, nil)
+	s.addSnapshot(c, "ci-4", 5*day, nil, nil)
+	s.addSnapshot(c, "ci-5", 1*day, nil, nil)
+	ci6 := s.addSnapshot(c, "ci-6", 50*day, nil, nil)
+	s.addSnapshot(c, "ci-7", 45*day, []*Snapshot{ci6}, nil)
+	s.addSnapshot(c, "release", 14*day, []*Snapshot{ci3}, nil)
+
+	published, err := NewPublishedRepo("", "ppa", "stable", nil, []string{"main"}, []interface{}{ci1}, s.collectionFactory, false)
+	c.Assert(err, IsNil)
+	c.Assert(s.collectionFactory.PublishedRepoCollection().Add(published), IsNil)
+
+	rules := []utils.SnapshotRetentionRule{
+		{Pattern: "ci-*", KeepLast: 1, KeepAge: "10d"},
+		{Labels: []string{"keep=forever"}, KeepLast: 100},
+	}
+
+	decisions, err := PlanSnapshotGC(rules, s.collectionFactory, s.now)
+	c.Assert(err, IsNil)
+
+	report := map[string]string{}
+	for _, decision := range decisions {
+		action := "keep"
+		if decision.Drop {
+			action = "drop"
+		}
+		report[decision.Name] = action + ": " + decision.Reason
+	}
+
+	c.Check(report, DeepEquals, map[string]string{
+		"ci-0": "drop: expired by rule #1 (ci-*)",
+		"ci-1": "keep: published as ppa/stable",
+		"ci-2": "keep: one of 100 latest snapshots matching rule #2 (*, keep=forever)",
+		"ci-3": "keep: source of snapshot release",
+		"ci-4": "keep: younger than 10d, rule #1 (ci-*)",
+		"ci-5": "keep: one of 1 latest snapshots matching rule #1 (ci-*)",
+		"ci-6": "drop: expired by rule #1 (ci-*)",
+		"ci-7": "drop: expired by rule #1 (ci-*)",
+	})
+
+	c.Assert(ApplySnapshotGC(decisions, s.collectionFactory.SnapshotCollection()), IsNil)
+
+	_, err = s.collectionFactory.SnapshotCollection().ByName(ci0.Name)
+	c.Check(err, ErrorMatches, "snapshot with name ci-0 not found")
+	_, err = s.collectionFactory.SnapshotCollection().ByName("ci-7")
+	c.Check(err, NotNil)
+	c.Check(s.collectionFactory.SnapshotCollection().Len(), Equals, 6)
+}
+
+func (s *SnapshotGCSuite) TestWrongRules(c *C) {
+	for _, test := range []struct {
+		rule utils.SnapshotRetentionRule
+		err  string
+	}{
+		{utils.SnapshotRetentionRule{Pattern: "ci-*"}, "snapshot retention rule #1: keepLast or keepAge should be set"},
+		{utils.SnapshotRetentionRule{Pattern: "ci-[", KeepLast: 1}, "snapshot retention rule #1: wrong pattern \"ci-\\[\": .*"},
+		{utils.SnapshotRetentionRule{KeepLast: -1}, "snapshot retention rule #1: number of snapshots to keep should be positive: -1"},
+		{utils.SnapshotRetentionRule{KeepAge: "soon"}, "snapshot retention rule #1: wrong age \"soon\".*"},
+		{utils.SnapshotRetentionRule{Labels: []string{"=x"}, KeepLast: 1}, "snapshot retention rule #1: wrong label selector.*"},
+	} {
+		_, err := PlanSnapshotGC([]utils.SnapshotRetentionRule{test.rule}, s.collectionFactory, s.now)
+		c.Check(err, ErrorMatches, test.err)
+	}
+}
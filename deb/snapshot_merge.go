@@ -0,0 +1,362 @@
+package deb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultMergePriority is a priority of merge source which has no priority set, same as in apt
+const DefaultMergePriority = 500
+
+// MergeRule selects packages matching Query in the source snapshot
+type MergeRule struct {
+	// Snapshot name, empty matches all sources
+	Source string
+	// Query as specified by user, used in reports
+	QueryString string
+	// Parsed query
+	Query PackageQuery
+}
+
+// String returns rule in <source>:<query> form
+func (rule *MergeRule) String() string {
+	source, query := rule.Source, rule.QueryString
+	if source == "" {
+		source = "*"
+	}
+	if query == "" {
+		query = rule.Query.String()
+	}
+
+	return source + ":" + query
+}
+
+func (rule *MergeRule) matches(source string, p *Package) bool {
+	return (rule.Source == "" || rule.Source == source) && rule.Query.Matches(p)
+}
+
+// MergeRules control resolution of conflicts while merging snapshots, similar to apt pinning
+//
+// Packages matching Excludes are removed from the sources before merge. When several sources
+// have packages with the same name and architecture, packages matching the first of the Pins are
+// preferred, then packages from the sources with the highest priority, then either the latest
+// version (if merging latest versions) or packages from the last source on the list.
+type MergeRules struct {
+	// Priorities of sources by snapshot name, DefaultMergePriority if not set
+	Priorities map[string]int
+	// Pins select preferred packages
+	Pins []*MergeRule
+	// Excludes remove packages from the sources
+	Excludes []*MergeRule
+}
+
+// Empty checks whether there are no rules at all
+func (rules *MergeRules) Empty() bool {
+	return rules == nil || len(rules.Priorities) == 0 && len(rules.Pins) == 0 && len(rules.Excludes) == 0
+}
+
+// Validate checks that rules reference only merge sources
+func (rules *MergeRules) Validate(sources []*Snapshot) error {
+	names := map[string]bool{}
+	for _, source := range sources {
+		names[source.Name] = true
+	}
+
+	for name := range rules.Priorities {
+		if !names[name] {
+			return fmt.Errorf("priority set for snapshot %s which is not a merge source", name)
+		}
+	}
+
+	for _, rule := range append(append([]*MergeRule{}, rules.Pins...), rules.Excludes...) {
+		if rule.Source != "" && !names[rule.Source] {
+			return fmt.Errorf("rule %s references snapshot %s which is not a merge source", rule, rule.Source)
+		}
+	}
+
+	return nil
+}
+
+func (rules *MergeRules) priority(source string) int {
+	if priority, ok := rules.Priorities[source]; ok {
+		return priority
+	}
+
+	return DefaultMergePriority
+}
+
+// MergeCandidate is a package version coming from one of the merge sources
+type MergeCandidate struct {
+	Source  string
+	Version string
+}
+
+// String returns candidate as "<version> from <source>"
+func (candidate MergeCandidate) String() string {
+	return fmt.Sprintf("%s from %s", candidate.Version, candidate.Source)
+}
+
+// MergeConflict explains which source won when several sources provide packages with
+// the same name and architecture
+type MergeConflict struct {
+	Architecture string
+	Name         string
+	// Packages in the merge result
+	Kept []MergeCandidate
+	// Packages which lost the conflict
+	Dropped []MergeCandidate
+	// Why kept packages won
+	Reason string
+}
+
+// MergeExclusion is a package removed from merge source by exclude rule
+type MergeExclusion struct {
+	Architecture string
+	Name         string
+	Version      string
+	Source       string
+	Rule         string
+}
+
+// MergeReport describes decisions made while merging snapshots with rules
+type MergeReport struct {
+	Conflicts []*MergeConflict
+	Excluded  []*MergeExclusion
+}
+
+type mergeCandidate struct {
+	source int
+	key    string
+	arch   string
+	name   string
+	ver    string
+}
+
+// MergeSnapshotsWithRules merges reflists of the source snapshots resolving conflicts according to rules
+//
+// If noRemove is set, packages with the same name and architecture are all kept, so only excludes
+// are applied and packages with the same version are taken from the last source. If latest is set,
+// only the latest version of each package among preferred ones is kept.
+func MergeSnapshotsWithRules(sources []*Snapshot, rules *MergeRules, latest, noRemove bool,
+	packageCollection *PackageCollection) (*PackageRefList, *MergeReport, error) {
+	if rules == nil {
+		rules = &MergeRules{}
+	}
+
+	if err := rules.Validate(sources); err != nil {
+		return nil, nil, err
+	}
+
+	if noRemove && (len(rules.Pins) > 0 || len(rules.Priorities) > 0) {
+		return nil, nil, fmt.Errorf("pins and priorities can't be used when all versions of packages are kept")
+	}
+
+	report := &MergeReport{Conflicts: []*MergeConflict{}, Excluded: []*MergeExclusion{}}
+	groups := map[string][]*mergeCandidate{}
+	groupNames := []string{}
+
+	for i, source := range sources {
+		err := source.RefList().ForEach(func(key []byte) error {
+			candidate := &mergeCandidate{source: i, key: string(key)}
+			candidate.arch, candidate.name, candidate.ver = parsePackageKey(candidate.key)
+
+			if excludedBy, err := rules.excluded(source.Name, key, packageCollection); err != nil {
+				return err
+			} else if excludedBy != nil {
+				report.Excluded = append(report.Excluded, &MergeExclusion{Architecture: candidate.arch, Name: candidate.name,
+					Version: candidate.ver, Source: source.Name, Rule: excludedBy.String()})
+				return nil
+			}
+
+			group := candidate.arch + " " + candidate.name
+			if _, ok := groups[group]; !ok {
+				groupNames = append(groupNames, group)
+			}
+			groups[group] = append(groups[group], candidate)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sort.Strings(groupNames)
+
+	result := NewPackageRefList()
+	for _, group := range groupNames {
+		candidates := groups[group]
+
+		kept, reason, err := rules.resolve(sources, candidates, latest, noRemove, packageCollection)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		keptKeys := map[string]bool{}
+		for _, candidate := range kept {
+			if !keptKeys[candidate.key] {
+				keptKeys[candidate.key] = true
+				result.Refs = append(result.Refs, []byte(candidate.key))
+			}
+		}
+
+		if len(keptKeys) == len(distinctKeys(candidates)) {
+			continue
+		}
+
+		conflict := &MergeConflict{Architecture: candidates[0].arch, Name: candidates[0].name, Reason: reason}
+		for _, candidate := range candidates {
+			description := MergeCandidate{Source: sources[candidate.source].Name, Version: candidate.ver}
+			if keptKeys[candidate.key] {
+				conflict.Kept = append(conflict.Kept, description)
+			} else {
+				conflict.Dropped = append(conflict.Dropped, description)
+			}
+		}
+		report.Conflicts = append(report.Conflicts, conflict)
+	}
+
+	sort.Sort(result)
+
+	return result, report, nil
+}
+
+func distinctKeys(candidates []*mergeCandidate) map[string]bool {
+	keys := map[string]bool{}
+	for _, candidate := range candidates {
+		keys[candidate.key] = true
+	}
+
+	return keys
+}
+
+// excluded returns exclude rule matching package in the source
+func (rules *MergeRules) excluded(source string, key []byte, packageCollection *PackageCollection) (*MergeRule, error) {
+	var p *Package
+
+	for _, rule := range rules.Excludes {
+		if rule.Source != "" && rule.Source != source {
+			continue
+		}
+
+		if p == nil {
+			var err error
+			p, err = packageCollection.ByKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("unable to load package %s: %s", key, err)
+			}
+		}
+
+		if rule.Query.Matches(p) {
+			return rule, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// resolve picks packages to keep among packages with the same name and architecture
+func (rules *MergeRules) resolve(sources []*Snapshot, candidates []*mergeCandidate, latest, noRemove bool,
+	packageCollection *PackageCollection) ([]*mergeCandidate, string, error) {
+	if len(distinctKeys(candidates)) == 1 {
+		return candidates, "", nil
+	}
+
+	if noRemove {
+		// same versions are taken from the last source
+		byVersion := map[string]*mergeCandidate{}
+		for _, candidate := range candidates {
+			byVersion[candidate.ver] = candidate
+		}
+
+		kept := []*mergeCandidate{}
+		for _, candidate := range candidates {
+			if byVersion[candidate.ver].key == candidate.key {
+				kept = append(kept, candidate)
+			}
+		}
+
+		return kept, "same version in the last source on the list", nil
+	}
+
+	reason := ""
+
+	for _, pin := range rules.Pins {
+		pinned := []*mergeCandidate{}
+		for _, candidate := range candidates {
+			if pin.Source != "" && pin.Source != sources[candidate.source].Name {
+				continue
+			}
+
+			p, err := packageCollection.ByKey([]byte(candidate.key))
+			if err != nil {
+				return nil, "", fmt.Errorf("unable to load package %s: %s", candidate.key, err)
+			}
+
+			if pin.matches(sources[candidate.source].Name, p) {
+				pinned = append(pinned, candidate)
+			}
+		}
+
+		if len(pinned) > 0 {
+			candidates, reason = pinned, fmt.Sprintf("pinned by %s", pin)
+			break
+		}
+	}
+
+	maxPriority := rules.priority(sources[candidates[0].source].Name)
+	for _, candidate := range candidates {
+		if priority := rules.priority(sources[candidate.source].Name); priority > maxPriority {
+			maxPriority = priority
+		}
+	}
+
+	prioritized := []*mergeCandidate{}
+	for _, candidate := range candidates {
+		if rules.priority(sources[candidate.source].Name) == maxPriority {
+			prioritized = append(prioritized, candidate)
+		}
+	}
+	if len(prioritized) < len(candidates) && reason == "" {
+		reason = fmt.Sprintf("source priority %d", maxPriority)
+	}
+	candidates = prioritized
+
+	if latest {
+		latestVersion := candidates[0].ver
+		for _, candidate := range candidates {
+			if CompareVersions(candidate.ver, latestVersion) > 0 {
+				latestVersion = candidate.ver
+			}
+		}
+
+		newest := []*mergeCandidate{}
+		for _, candidate := range candidates {
+			if candidate.ver == latestVersion {
+				newest = append(newest, candidate)
+			}
+		}
+		if len(newest) < len(candidates) && reason == "" {
+			reason = "latest version"
+		}
+		candidates = newest
+	}
+
+	// the rest is taken from the last source on the list
+	lastSource := candidates[len(candidates)-1].source
+	last := []*mergeCandidate{}
+	for _, candidate := range candidates {
+		if candidate.source == lastSource {
+			last = append(last, candidate)
+		}
+	}
+	if len(distinctKeys(last)) < len(distinctKeys(candidates)) && reason == "" {
+		reason = "last source on the list"
+	}
+
+	if latest && len(distinctKeys(last)) > 1 {
+		// same version with different files in the same source
+		last = last[len(last)-1:]
+	}
+
+	return last, reason, nil
+}
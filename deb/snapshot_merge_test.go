@@ -0,0 +1,157 @@
+package deb
+
+import (
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type SnapshotMergeSuite struct {
+	db                database.Storage
+	packageCollection *PackageCollection
+	sources           []*Snapshot
+}
+
+var _ = Suite(&SnapshotMergeSuite{})
+
+func (s *SnapshotMergeSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.packageCollection = NewPackageCollection(s.db)
+
+	newSource := func(name string, packages ...[]string) *Snapshot {
+		list := NewPackageList()
+		for _, p := range packages {
+			stanza := packageStanza.Copy()
+			stanza["Package"], stanza["Version"] = p[0], p[1]
+			stanza["Filename"] = "pool/main/" + p[0] + "_" + p[1] + "_i386.deb"
+
+			pkg := NewPackageFromControlFile(stanza)
+			c.Assert(s.packageCollection.Update(pkg), IsNil)
+			c.Assert(list.Add(pkg), IsNil)
+		}
+
+		return NewSnapshotFromPackageList(name, nil, list, "")
+	}
+
+	s.sources = []*Snapshot{
+		newSource("main", []string{"nginx", "1.22-1"}, []string{"curl", "7.88-1"}, []string{"vim", "9.0-1"}),
+		newSource("backports", []string{"nginx", "1.24-1"}, []string{"curl", "8.0-1"}),
+		newSource("security", []string{"curl", "7.88-1+deb12u1"}),
+	}
+}
+
+func (s *SnapshotMergeSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *SnapshotMergeSuite) merge(c *C, rules *MergeRules, latest, noRemove bool) ([]string, *MergeReport) {
+	result, report, err := MergeSnapshotsWithRules(s.sources, rules, latest, noRemove, s.packageCollection)
+	c.Assert(err, IsNil)
+
+	packages := []string{}
+	_ = result.ForEach(func(key []byte) error {
+		_, name, version := parsePackageKey(string(key))
+		packages = append(packages, name+"_"+version)
+		return nil
+	})
+
+	return packages, report
+}
+
+func (s *SnapshotMergeSuite) TestMergeDefault(c *C) {
+	packages, report := s.merge(c, nil, false, false)
+	c.Check(packages, DeepEquals, []string{"curl_7.88-1+deb12u1", "nginx_1.24-1", "vim_9.0-1"})
+	c.Assert(report.Conflicts, HasLen, 2)
+	c.Check(*report.Conflicts[0], DeepEquals, MergeConflict{
+		Architecture: "i386",
+		Name:         "curl",
+		Kept:         []MergeCandidate{{Source: "security", Version: "7.88-1+deb12u1"}},
+		Dropped:      []MergeCandidate{{Source: "main", Version: "7.88-1"}, {Source: "backports", Version: "8.0-1"}},
+		Reason:       "last source on the list",
+	})
+	c.Check(report.Excluded, HasLen, 0)
+
+	// same as plain merge
+	result := s.sources[0].RefList()
+	for _, source := range s.sources[1:] {
+		result = result.Merge(source.RefList(), true, false)
+	}
+	merged, _, err := MergeSnapshotsWithRules(s.sources, nil, false, false, s.packageCollection)
+	c.Assert(err, IsNil)
+	c.Check(merged.Strings(), DeepEquals, result.Strings())
+
+	packages, report = s.merge(c, nil, true, false)
+	c.Check(packages, DeepEquals, []string{"curl_8.0-1", "nginx_1.24-1", "vim_9.0-1"})
+	c.Check(report.Conflicts[0].Reason, Equals, "latest version")
+
+	packages, report = s.merge(c, nil, false, true)
+	c.Check(packages, DeepEquals, []string{"curl_7.88-1", "curl_7.88-1+deb12u1", "curl_8.0-1", "nginx_1.22-1", "nginx_1.24-1", "vim_9.0-1"})
+	c.Check(report.Conflicts, HasLen, 0)
+}
+
+func (s *SnapshotMergeSuite) TestMergePriorities(c *C) {
+	packages, report := s.merge(c, &MergeRules{Priorities: map[string]int{"main": 900}}, true, false)
+	c.Check(packages, DeepEquals, []string{"curl_7.88-1", "nginx_1.22-1", "vim_9.0-1"})
+	c.Check(report.Conflicts[1].Reason, Equals, "source priority 900")
+
+	packages, _ = s.merge(c, &MergeRules{Priorities: map[string]int{"main": 900, "security": 900}}, true, false)
+	c.Check(packages, DeepEquals, []string{"curl_7.88-1+deb12u1", "nginx_1.22-1", "vim_9.0-1"})
+
+	packages, _ = s.merge(c, &MergeRules{Priorities: map[string]int{"security": 100}}, false, false)
+	c.Check(packages, DeepEquals, []string{"curl_8.0-1", "nginx_1.24-1", "vim_9.0-1"})
+}
+
+func (s *SnapshotMergeSuite) TestMergePins(c *C) {
+	rules := &MergeRules{
+		Priorities: map[string]int{"main": 900},
+		Pins: []*MergeRule{
+			{QueryString: "nginx (= 1.24-1)", Query: &DependencyQuery{Dep: Dependency{Pkg: "nginx", Relation: VersionEqual, Version: "1.24-1"}}},
+			{Source: "backports", QueryString: "curl", Query: &DependencyQuery{Dep: Dependency{Pkg: "curl"}}},
+		},
+	}
+
+	packages, report := s.merge(c, rules, false, false)
+	c.Check(packages, DeepEquals, []string{"curl_8.0-1", "nginx_1.24-1", "vim_9.0-1"})
+	c.Check(report.Conflicts[0].Reason, Equals, "pinned by backports:curl")
+	c.Check(report.Conflicts[1].Reason, Equals, "pinned by *:nginx (= 1.24-1)")
+
+	// pin which doesn't match anything is ignored
+	rules.Pins = []*MergeRule{{Source: "security", Query: &DependencyQuery{Dep: Dependency{Pkg: "nginx"}}}}
+	packages, _ = s.merge(c, rules, false, false)
+	c.Check(packages, DeepEquals, []string{"curl_7.88-1", "nginx_1.22-1", "vim_9.0-1"})
+
+	_, _, err := MergeSnapshotsWithRules(s.sources, rules, false, true, s.packageCollection)
+	c.Check(err, ErrorMatches, "pins and priorities can't be used when all versions of packages are kept")
+}
+
+func (s *SnapshotMergeSuite) TestMergeExcludes(c *C) {
+	rules := &MergeRules{
+		Excludes: []*MergeRule{
+			{Source: "backports", QueryString: "curl", Query: &DependencyQuery{Dep: Dependency{Pkg: "curl"}}},
+			{QueryString: "vim", Query: &DependencyQuery{Dep: Dependency{Pkg: "vim"}}},
+		},
+	}
+
+	packages, report := s.merge(c, rules, true, false)
+	c.Check(packages, DeepEquals, []string{"curl_7.88-1+deb12u1", "nginx_1.24-1"})
+	c.Check(report.Excluded, DeepEquals, []*MergeExclusion{
+		{Architecture: "i386", Name: "vim", Version: "9.0-1", Source: "main", Rule: "*:vim"},
+		{Architecture: "i386", Name: "curl", Version: "8.0-1", Source: "backports", Rule: "backports:curl"},
+	})
+
+	packages, _ = s.merge(c, rules, false, true)
+	c.Check(packages, DeepEquals, []string{"curl_7.88-1", "curl_7.88-1+deb12u1", "nginx_1.22-1", "nginx_1.24-1"})
+}
+
+func (s *SnapshotMergeSuite) TestValidate(c *C) {
+	c.Check((*MergeRules)(nil).Empty(), Equals, true)
+	c.Check((&MergeRules{Priorities: map[string]int{"main": 1}}).Empty(), Equals, false)
+
+	_, _, err := MergeSnapshotsWithRules(s.sources, &MergeRules{Priorities: map[string]int{"testing": 1}}, false, false, s.packageCollection)
+	c.Check(err, ErrorMatches, "priority set for snapshot testing which is not a merge source")
+
+	_, _, err = MergeSnapshotsWithRules(s.sources, &MergeRules{Excludes: []*MergeRule{{Source: "testing", QueryString: "curl",
+		Query: &DependencyQuery{Dep: Dependency{Pkg: "curl"}}}}}, false, false, s.packageCollection)
+	c.Check(err, ErrorMatches, "rule testing:curl references snapshot testing which is not a merge source")
+}
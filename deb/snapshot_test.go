@@ -272,6 +272,32 @@ func (s *SnapshotCollectionSuite) TestForEachSorted(c *C) {
 	c.Check(sort.StringsAreSorted(names), Equals, true)
 }
 
+func (s *SnapshotCollectionSuite) TestForEachSortedByLabel(c *C) {
+	s.snapshot1.Labels = Labels{"release": "2026.10"}
+	s.snapshot2.Labels = Labels{"release": "2026.04"}
+	s.snapshot4.Labels = Labels{"release": "2026.04"}
+	_ = s.collection.Add(s.snapshot1)
+	_ = s.collection.Add(s.snapshot2)
+	_ = s.collection.Add(s.snapshot3)
+	_ = s.collection.Add(s.snapshot4)
+
+	names := []string{}
+
+	err := s.collection.ForEachSorted("label:release", func(snapshot *Snapshot) error {
+		names = append(names, snapshot.Name)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{s.snapshot3.Name, s.snapshot2.Name, s.snapshot4.Name, s.snapshot1.Name})
+
+	snapshot, err := NewSnapshotCollection(s.db).ByName(s.snapshot1.Name)
+	c.Assert(err, IsNil)
+	c.Check(snapshot.Labels, DeepEquals, Labels{"release": "2026.10"})
+
+	err = s.collection.ForEachSorted("label:", func(*Snapshot) error { return nil })
+	c.Check(err, ErrorMatches, "sorting method \"label:\" unknown")
+}
+
 func (s *SnapshotCollectionSuite) TestFindByRemoteRepoSource(c *C) {
 	c.Assert(s.collection.Add(s.snapshot1), IsNil)
 	c.Assert(s.collection.Add(s.snapshot2), IsNil)
@@ -2,8 +2,10 @@ package deb
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/DisposaBoy/JsonConfigReader"
 	"github.com/aptly-dev/aptly/pgp"
@@ -11,10 +13,21 @@ import (
 )
 
 // UploadersRule is single rule of format: what packages can group or key upload
+//
+// Rule applies to .changes file if condition matches and upload targets listed source package
+// names, distributions and components (empty list matches anything).
 type UploadersRule struct {
-	Condition         string       `json:"condition"`
-	Allow             []string     `json:"allow"`
-	Deny              []string     `json:"deny"`
+	Condition string   `json:"condition"`
+	Allow     []string `json:"allow"`
+	Deny      []string `json:"deny"`
+	// Source package names (shell patterns) rule applies to
+	Sources []string `json:"sources,omitempty"`
+	// Distributions rule applies to
+	Distributions []string `json:"distributions,omitempty"`
+	// Components rule applies to, all components of upload should be listed
+	Components []string `json:"components,omitempty"`
+	// Deny uploads of versions lower than current version in the repo
+	DenyDowngrade     bool         `json:"denyDowngrade,omitempty"`
 	CompiledCondition PackageQuery `json:"-" codec:"-"`
 }
 
@@ -79,29 +92,148 @@ func (u *Uploaders) ExpandGroups(items []string) []string {
 	return utils.StrSliceDeduplicate(result)
 }
 
-// IsAllowed checks whether listed keys are allowed to upload given .changes file
-func (u *Uploaders) IsAllowed(changes *Changes) error {
-	for _, rule := range u.Rules {
-		if rule.CompiledCondition.Matches(changes) {
-			deny := u.ExpandGroups(rule.Deny)
-			for _, key := range changes.SignatureKeys {
-				for _, item := range deny {
-					if item == "*" || key.Matches(pgp.Key(item)) {
-						return fmt.Errorf("denied according to rule: %s", rule)
-					}
-				}
-			}
+// Compile parses conditions of the rules, empty condition matches any .changes file
+func (u *Uploaders) Compile(parseQuery parseQuery) error {
+	var err error
+
+	for i := range u.Rules {
+		if u.Rules[i].Condition == "" {
+			u.Rules[i].CompiledCondition = nil
+			continue
+		}
+
+		u.Rules[i].CompiledCondition, err = parseQuery(u.Rules[i].Condition)
+		if err != nil {
+			return fmt.Errorf("error parsing query %s: %s", u.Rules[i].Condition, err)
+		}
+	}
+
+	return nil
+}
+
+// IsEmpty checks whether there are no groups and no rules
+func (u *Uploaders) IsEmpty() bool {
+	return len(u.Groups) == 0 && len(u.Rules) == 0
+}
+
+// UploadersDecision explains result of checking .changes file against uploaders rules
+type UploadersDecision struct {
+	Allowed bool
+	// Why upload was allowed or denied
+	Reason string
+	// How each rule was evaluated
+	Trace []string
+}
+
+// Err returns error for denied upload
+func (d *UploadersDecision) Err() error {
+	if d.Allowed {
+		return nil
+	}
+
+	return errors.New(d.Reason)
+}
+
+// matchesPatterns checks whether value matches any of shell patterns, empty list matches anything
+func matchesPatterns(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, value); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applies checks whether rule applies to .changes file, returning explanation if it doesn't
+func (rule *UploadersRule) applies(changes *Changes) (bool, string) {
+	if rule.CompiledCondition != nil && !rule.CompiledCondition.Matches(changes) {
+		return false, "condition doesn't match"
+	}
+
+	if !matchesPatterns(rule.Sources, changes.Source) {
+		return false, fmt.Sprintf("source %s is not in %v", changes.Source, rule.Sources)
+	}
 
-			allow := u.ExpandGroups(rule.Allow)
-			for _, key := range changes.SignatureKeys {
-				for _, item := range allow {
-					if item == "*" || key.Matches(pgp.Key(item)) {
-						return nil
-					}
-				}
+	if len(rule.Distributions) > 0 && !utils.StrSliceHasItem(rule.Distributions, changes.Distribution) {
+		return false, fmt.Sprintf("distribution %s is not in %v", changes.Distribution, rule.Distributions)
+	}
+
+	for _, component := range changes.Components() {
+		if len(rule.Components) > 0 && !utils.StrSliceHasItem(rule.Components, component) {
+			return false, fmt.Sprintf("component %s is not in %v", component, rule.Components)
+		}
+	}
+
+	return true, ""
+}
+
+// matchingKey returns first signature key matching list of keys/groups
+func (u *Uploaders) matchingKey(keys []pgp.Key, items []string) (pgp.Key, bool) {
+	expanded := u.ExpandGroups(items)
+
+	for _, key := range keys {
+		for _, item := range expanded {
+			if item == "*" || key.Matches(pgp.Key(item)) {
+				return key, true
 			}
 		}
 	}
 
-	return fmt.Errorf("denied as no rule matches")
+	return "", false
+}
+
+// Explain checks whether listed keys are allowed to upload given .changes file,
+// explaining how each rule was evaluated
+//
+// currentVersion is the highest version of the source package in the repo, empty if there's none.
+func (u *Uploaders) Explain(changes *Changes, currentVersion string) *UploadersDecision {
+	decision := &UploadersDecision{}
+
+	for i := range u.Rules {
+		rule := &u.Rules[i]
+		prefix := fmt.Sprintf("rule #%d %s", i+1, rule)
+
+		if ok, why := rule.applies(changes); !ok {
+			decision.Trace = append(decision.Trace, fmt.Sprintf("%s: skipped, %s", prefix, why))
+			continue
+		}
+
+		if key, ok := u.matchingKey(changes.SignatureKeys, rule.Deny); ok {
+			decision.Trace = append(decision.Trace, fmt.Sprintf("%s: key %s is denied", prefix, key))
+			decision.Reason = fmt.Sprintf("denied according to rule: %s", rule)
+			return decision
+		}
+
+		key, ok := u.matchingKey(changes.SignatureKeys, rule.Allow)
+		if !ok {
+			decision.Trace = append(decision.Trace, fmt.Sprintf("%s: skipped, no key is allowed", prefix))
+			continue
+		}
+
+		version := changes.Stanza["Version"]
+		if rule.DenyDowngrade && currentVersion != "" && CompareVersions(version, currentVersion) < 0 {
+			decision.Trace = append(decision.Trace, fmt.Sprintf("%s: key %s is allowed, but version %s is lower than current version %s",
+				prefix, key, version, currentVersion))
+			decision.Reason = fmt.Sprintf("denied according to rule: %s: downgrade from %s to %s", rule, currentVersion, version)
+			return decision
+		}
+
+		decision.Trace = append(decision.Trace, fmt.Sprintf("%s: key %s is allowed", prefix, key))
+		decision.Allowed = true
+		decision.Reason = fmt.Sprintf("allowed according to rule: %s", rule)
+		return decision
+	}
+
+	decision.Reason = "denied as no rule matches"
+	return decision
+}
+
+// IsAllowed checks whether listed keys are allowed to upload given .changes file
+func (u *Uploaders) IsAllowed(changes *Changes) error {
+	return u.Explain(changes, "").Err()
 }
@@ -1,6 +1,8 @@
 package deb
 
 import (
+	"fmt"
+
 	"github.com/aptly-dev/aptly/pgp"
 	. "gopkg.in/check.v1"
 )
@@ -79,3 +81,97 @@ func (s *UploadersSuite) TestIsAllowed(c *C) {
 	c.Check(u.IsAllowed(&Changes{SignatureKeys: []pgp.Key{"ABCD1234", "45678901"}, Stanza: Stanza{"Source": "some-calamares"}}),
 		ErrorMatches, "denied according to rule: {\"condition\":\"\",\"allow\":null,\"deny\":\\[\"45678901\",\"12345678\"\\]}")
 }
+
+func (s *UploadersSuite) TestCompile(c *C) {
+	u := &Uploaders{
+		Rules: []UploadersRule{
+			{Condition: "", Allow: []string{"*"}},
+			{Condition: "Source (calamares)", Allow: []string{"*"}},
+		},
+	}
+
+	parseQuery := func(q string) (PackageQuery, error) {
+		return &FieldQuery{Field: "Source", Relation: VersionEqual, Value: q}, nil
+	}
+
+	c.Check(u.Compile(parseQuery), IsNil)
+	c.Check(u.Rules[0].CompiledCondition, IsNil)
+	c.Check(u.Rules[1].CompiledCondition, NotNil)
+
+	c.Check(u.Compile(func(string) (PackageQuery, error) { return nil, fmt.Errorf("bad query") }),
+		ErrorMatches, "error parsing query Source \\(calamares\\): bad query")
+
+	c.Check(u.IsEmpty(), Equals, false)
+	c.Check((&Uploaders{}).IsEmpty(), Equals, true)
+}
+
+func (s *UploadersSuite) TestExplain(c *C) {
+	u := &Uploaders{
+		Groups: map[string][]string{
+			"maintainers": {"37E1C17570096AD1"},
+		},
+		Rules: []UploadersRule{
+			{
+				Sources:       []string{"lib*"},
+				Distributions: []string{"unstable"},
+				Components:    []string{"main", "contrib"},
+				Allow:         []string{"maintainers"},
+				DenyDowngrade: true,
+			},
+			{
+				Allow: []string{"EC4B033C70096AD1"},
+			},
+		},
+	}
+
+	changes := func(source, version, distribution, section string, keys ...pgp.Key) *Changes {
+		return &Changes{
+			Source:        source,
+			Distribution:  distribution,
+			SignatureKeys: keys,
+			Stanza: Stanza{
+				"Source":  source,
+				"Version": version,
+				"Files":   "\n 4efce26825af5842f43961096dd890b3 949 " + section + " optional " + source + "_" + version + ".dsc",
+			},
+		}
+	}
+
+	decision := u.Explain(changes("libfoo", "1.0", "unstable", "contrib/libs", "37E1C17570096AD1"), "")
+	c.Check(decision.Allowed, Equals, true)
+	c.Check(decision.Reason, Matches, "allowed according to rule: .*\"sources\":\\[\"lib\\*\"\\].*")
+	c.Check(decision.Trace, DeepEquals, []string{
+		"rule #1 " + u.Rules[0].String() + ": key 37E1C17570096AD1 is allowed",
+	})
+
+	// source package name isn't allowed
+	decision = u.Explain(changes("app", "1.0", "unstable", "libs", "37E1C17570096AD1"), "")
+	c.Check(decision.Allowed, Equals, false)
+	c.Check(decision.Reason, Equals, "denied as no rule matches")
+	c.Check(decision.Trace, DeepEquals, []string{
+		"rule #1 " + u.Rules[0].String() + ": skipped, source app is not in [lib*]",
+		"rule #2 " + u.Rules[1].String() + ": skipped, no key is allowed",
+	})
+
+	// distribution and component aren't allowed
+	decision = u.Explain(changes("libfoo", "1.0", "stable", "libs", "37E1C17570096AD1"), "")
+	c.Check(decision.Err(), ErrorMatches, "denied as no rule matches")
+	c.Check(decision.Trace[0], Matches, ".*: skipped, distribution stable is not in \\[unstable\\]")
+
+	decision = u.Explain(changes("libfoo", "1.0", "unstable", "non-free/libs", "37E1C17570096AD1"), "")
+	c.Check(decision.Err(), ErrorMatches, "denied as no rule matches")
+	c.Check(decision.Trace[0], Matches, ".*: skipped, component non-free is not in \\[main contrib\\]")
+
+	// downgrades are denied
+	decision = u.Explain(changes("libfoo", "1.0", "unstable", "libs", "37E1C17570096AD1"), "1.1")
+	c.Check(decision.Err(), ErrorMatches, "denied according to rule: .*: downgrade from 1.1 to 1.0")
+	c.Check(decision.Trace[0], Matches, ".*: key 37E1C17570096AD1 is allowed, but version 1.0 is lower than current version 1.1")
+
+	decision = u.Explain(changes("libfoo", "1.2", "unstable", "libs", "37E1C17570096AD1"), "1.1")
+	c.Check(decision.Err(), IsNil)
+
+	// second rule allows anything for the key, including downgrades
+	decision = u.Explain(changes("app", "1.0", "stable", "non-free/libs", "EC4B033C70096AD1"), "1.1")
+	c.Check(decision.Err(), IsNil)
+	c.Check(decision.Trace, HasLen, 2)
+}
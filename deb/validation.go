@@ -0,0 +1,457 @@
+package deb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Validation configures checks packages should pass to be added to local repo
+type Validation struct {
+	// Names of enabled validators
+	Validators []string `codec:",omitempty" json:",omitempty"`
+	// Allowed architectures for "architecture" validator, "all" and "source" are always allowed
+	Architectures []string `codec:",omitempty" json:",omitempty"`
+	// Maximum size of package file in bytes for "max-size" validator
+	MaxSize int64 `codec:",omitempty" json:",omitempty"`
+	// Keyrings with keys trusted to sign packages for "signature" validator, default keyring if empty
+	Keyrings []string `codec:",omitempty" json:",omitempty"`
+	// Reject unsigned packages in "signature" validator
+	RequireSignature bool `codec:",omitempty" json:",omitempty"`
+
+	// verifier used by "signature" validator, see Prepare
+	verifier pgp.Verifier
+}
+
+// VerifierProvider creates verifier trusting keys from keyrings
+type VerifierProvider func(keyrings []string) (pgp.Verifier, error)
+
+// ValidatedFile is a package file being validated
+type ValidatedFile struct {
+	// Name of the package file
+	Filename string
+	// Size of the package file
+	Size int64
+	// Open returns contents of the package file
+	Open func() (io.ReadCloser, error)
+}
+
+// PackageValidator checks package before it's added to local repo
+//
+// file is nil when package file is not available.
+type PackageValidator func(validation *Validation, p *Package, file *ValidatedFile) error
+
+// Names of built-in validators
+const (
+	ValidatorMaintainer     = "maintainer"
+	ValidatorVersion        = "version"
+	ValidatorArchitecture   = "architecture"
+	ValidatorFilename       = "filename"
+	ValidatorDuplicateFiles = "duplicate-files"
+	ValidatorMaxSize        = "max-size"
+	ValidatorSignature      = "signature"
+)
+
+var packageValidators = map[string]PackageValidator{
+	ValidatorMaintainer:     validateMaintainer,
+	ValidatorVersion:        validateVersion,
+	ValidatorArchitecture:   validateArchitecture,
+	ValidatorFilename:       validateFilename,
+	ValidatorDuplicateFiles: validateDuplicateFiles,
+	ValidatorMaxSize:        validateMaxSize,
+	ValidatorSignature:      validateSignature,
+}
+
+// RegisterPackageValidator makes validator available to local repos under the name
+//
+// Should be called during initialization, before any validation is performed.
+func RegisterPackageValidator(name string, validator PackageValidator) {
+	packageValidators[name] = validator
+}
+
+// PackageValidatorNames returns sorted names of all registered validators
+func PackageValidatorNames() []string {
+	result := make([]string, 0, len(packageValidators))
+	for name := range packageValidators {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// NewValidation checks configuration and creates Validation, if no validators
+// are enabled, nil is returned
+func NewValidation(validators []string, architectures []string, maxSize int64, keyrings []string, requireSignature bool) (*Validation, error) {
+	if maxSize < 0 {
+		return nil, fmt.Errorf("maximum package size should be positive: %d", maxSize)
+	}
+
+	for _, name := range validators {
+		if _, ok := packageValidators[name]; !ok {
+			return nil, fmt.Errorf("unknown validator %#v, supported validators: %s", name, strings.Join(PackageValidatorNames(), ", "))
+		}
+
+		if name == ValidatorArchitecture && len(architectures) == 0 {
+			return nil, fmt.Errorf("validator %#v requires list of allowed architectures", name)
+		}
+
+		if name == ValidatorMaxSize && maxSize == 0 {
+			return nil, fmt.Errorf("validator %#v requires maximum package size", name)
+		}
+	}
+
+	if requireSignature && !utils.StrSliceHasItem(validators, ValidatorSignature) {
+		return nil, fmt.Errorf("requiring signature needs validator %#v to be enabled", ValidatorSignature)
+	}
+
+	if len(validators) == 0 {
+		return nil, nil
+	}
+
+	return &Validation{
+		Validators:       validators,
+		Architectures:    architectures,
+		MaxSize:          maxSize,
+		Keyrings:         keyrings,
+		RequireSignature: requireSignature,
+	}, nil
+}
+
+// Prepare returns copy of validation ready to verify package signatures, verifier is
+// created via provider only if "signature" validator is enabled
+//
+// Nil validation is returned as is.
+func (validation *Validation) Prepare(provider VerifierProvider) (*Validation, error) {
+	if validation == nil || !utils.StrSliceHasItem(validation.Validators, ValidatorSignature) {
+		return validation, nil
+	}
+
+	if provider == nil {
+		return nil, fmt.Errorf("verifying package signatures is not supported")
+	}
+
+	verifier, err := provider(validation.Keyrings)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize keyrings for package signatures: %s", err)
+	}
+
+	result := *validation
+	result.verifier = verifier
+
+	return &result, nil
+}
+
+// String returns human-readable description of validation
+func (validation *Validation) String() string {
+	result := []string{}
+
+	for _, name := range validation.Validators {
+		switch name {
+		case ValidatorArchitecture:
+			result = append(result, fmt.Sprintf("%s (%s)", name, strings.Join(validation.Architectures, ", ")))
+		case ValidatorMaxSize:
+			result = append(result, fmt.Sprintf("%s (%d bytes)", name, validation.MaxSize))
+		case ValidatorSignature:
+			details := []string{}
+			if validation.RequireSignature {
+				details = append(details, "required")
+			}
+			if len(validation.Keyrings) > 0 {
+				details = append(details, "keyrings "+strings.Join(validation.Keyrings, ", "))
+			}
+			if len(details) > 0 {
+				result = append(result, fmt.Sprintf("%s (%s)", name, strings.Join(details, ", ")))
+			} else {
+				result = append(result, name)
+			}
+		default:
+			result = append(result, name)
+		}
+	}
+
+	return strings.Join(result, ", ")
+}
+
+// Validate runs enabled validators against the package, returning all failures
+//
+// Nil validation doesn't perform any checks.
+func (validation *Validation) Validate(p *Package, file *ValidatedFile) []error {
+	if validation == nil {
+		return nil
+	}
+
+	var result []error
+
+	for _, name := range validation.Validators {
+		validator, ok := packageValidators[name]
+		if !ok {
+			result = append(result, fmt.Errorf("unknown validator %#v", name))
+			continue
+		}
+
+		if err := validator(validation, p, file); err != nil {
+			result = append(result, fmt.Errorf("%s: %s", name, err))
+		}
+	}
+
+	return result
+}
+
+// NewValidatedFile prepares package file on local filesystem for validation
+func NewValidatedFile(path string) (*ValidatedFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ValidatedFile{
+		Filename: filepath.Base(path),
+		Size:     info.Size(),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}, nil
+}
+
+// NewValidatedPoolFile prepares main file of the package already imported into package pool
+// for validation, nil is returned if package has no main file
+func NewValidatedPoolFile(p *Package, pool aptly.PackagePool) *ValidatedFile {
+	f := mainPackageFile(p)
+	if f == nil {
+		return nil
+	}
+
+	return &ValidatedFile{
+		Filename: f.Filename,
+		Size:     f.Checksums.Size,
+		Open: func() (io.ReadCloser, error) {
+			poolPath, err := f.GetPoolPath(pool)
+			if err != nil {
+				return nil, err
+			}
+
+			return pool.Open(poolPath)
+		},
+	}
+}
+
+// mainPackageFile returns .deb, .udeb, .ddeb or .dsc file of the package
+func mainPackageFile(p *Package) *PackageFile {
+	files := p.Files()
+
+	for i := range files {
+		switch filepath.Ext(files[i].Filename) {
+		case ".deb", ".udeb", ".ddeb", ".dsc":
+			return &files[i]
+		}
+	}
+
+	return nil
+}
+
+// validateMaintainer checks that Maintainer field is present
+func validateMaintainer(_ *Validation, p *Package, _ *ValidatedFile) error {
+	if strings.TrimSpace(p.Extra()["Maintainer"]) == "" {
+		return fmt.Errorf("missing Maintainer field")
+	}
+
+	return nil
+}
+
+// isVersionPart checks that version part contains only allowed characters
+func isVersionPart(part, extra string) bool {
+	for _, r := range part {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(".+~"+extra, r)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateVersion checks that version follows Debian policy and could be used in dependencies
+func validateVersion(_ *Validation, p *Package, _ *ValidatedFile) error {
+	version := p.Version
+
+	if i := strings.Index(version, ":"); i != -1 {
+		epoch := version[:i]
+		if epoch == "" || strings.Trim(epoch, "0123456789") != "" {
+			return fmt.Errorf("invalid epoch in version %#v", p.Version)
+		}
+		version = version[i+1:]
+	}
+
+	upstream, revision, hasRevision := version, "", false
+	if i := strings.LastIndex(version, "-"); i != -1 {
+		upstream, revision, hasRevision = version[:i], version[i+1:], true
+	}
+
+	if upstream == "" || upstream[0] < '0' || upstream[0] > '9' {
+		return fmt.Errorf("upstream version should start with a digit in version %#v", p.Version)
+	}
+
+	extra := ""
+	if hasRevision {
+		extra = "-"
+	}
+	if !isVersionPart(upstream, extra) {
+		return fmt.Errorf("invalid characters in upstream version %#v", p.Version)
+	}
+
+	if hasRevision && (revision == "" || !isVersionPart(revision, "")) {
+		return fmt.Errorf("invalid Debian revision in version %#v", p.Version)
+	}
+
+	if _, err := ParseDependency(fmt.Sprintf("%s (= %s)", p.Name, p.Version)); err != nil {
+		return fmt.Errorf("version %#v can't be used in dependencies: %s", p.Version, err)
+	}
+
+	return nil
+}
+
+// validateArchitecture checks that package architecture is allowed
+func validateArchitecture(validation *Validation, p *Package, _ *ValidatedFile) error {
+	switch p.Architecture {
+	case ArchitectureAll, ArchitectureSource:
+		return nil
+	}
+
+	for _, arch := range validation.Architectures {
+		if arch == p.Architecture {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("architecture %s is not allowed, allowed architectures: %s", p.Architecture,
+		strings.Join(validation.Architectures, ", "))
+}
+
+// validateFilename checks that name of package file matches control data
+func validateFilename(_ *Validation, p *Package, file *ValidatedFile) error {
+	if file == nil {
+		return nil
+	}
+
+	version := p.Version
+	if i := strings.Index(version, ":"); i != -1 {
+		version = version[i+1:]
+	}
+
+	var expected string
+	if p.IsSource {
+		expected = fmt.Sprintf("%s_%s.dsc", p.Name, version)
+	} else {
+		extension := PackageTypeBinary
+		if p.IsUdeb {
+			extension = PackageTypeUdeb
+		} else if filepath.Ext(file.Filename) == ".ddeb" {
+			// debug symbols packages are regular binary packages with .ddeb extension
+			extension = "ddeb"
+		}
+		expected = fmt.Sprintf("%s_%s_%s.%s", p.Name, version, p.Architecture, extension)
+	}
+
+	if file.Filename != expected {
+		return fmt.Errorf("file name %s doesn't match control data, expected %s", file.Filename, expected)
+	}
+
+	return nil
+}
+
+// duplicateFiles returns sorted list of paths which appear more than once
+func duplicateFiles(contents []string) []string {
+	seen := make(map[string]int, len(contents))
+	result := []string{}
+
+	for _, path := range contents {
+		seen[path]++
+		if seen[path] == 2 {
+			result = append(result, path)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// validateDuplicateFiles checks that data.tar of binary package has no duplicate entries
+func validateDuplicateFiles(_ *Validation, p *Package, file *ValidatedFile) error {
+	if file == nil || p.IsSource {
+		return nil
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+
+	contents, err := GetContentsFromDeb(reader, file.Filename)
+	if err != nil {
+		return err
+	}
+
+	if duplicates := duplicateFiles(contents); len(duplicates) > 0 {
+		return fmt.Errorf("duplicate files in data.tar: %s", strings.Join(duplicates, ", "))
+	}
+
+	return nil
+}
+
+// validateMaxSize checks that package file doesn't exceed maximum size
+func validateMaxSize(validation *Validation, _ *Package, file *ValidatedFile) error {
+	if file == nil {
+		return nil
+	}
+
+	if file.Size > validation.MaxSize {
+		return fmt.Errorf("file %s is %d bytes, exceeding maximum size of %d bytes", file.Filename, file.Size, validation.MaxSize)
+	}
+
+	return nil
+}
+
+// validateSignature verifies dpkg-sig signatures embedded into binary package
+func validateSignature(validation *Validation, p *Package, file *ValidatedFile) error {
+	if p.IsSource {
+		return nil
+	}
+
+	if file == nil {
+		if validation.RequireSignature {
+			return fmt.Errorf("package file is not available to verify signature")
+		}
+		return nil
+	}
+
+	if validation.verifier == nil {
+		return fmt.Errorf("no keyring configured to verify signature")
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+
+	signatures, err := VerifyDebSignatures(reader, file.Filename, validation.verifier)
+	if err != nil {
+		return err
+	}
+
+	if len(signatures) == 0 && validation.RequireSignature {
+		return fmt.Errorf("package %s is not signed", file.Filename)
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package deb
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+
+	. "gopkg.in/check.v1"
+)
+
+type ValidationSuite struct {
+	debFile string
+	p       *Package
+}
+
+var _ = Suite(&ValidationSuite{})
+
+func (s *ValidationSuite) SetUpTest(c *C) {
+	s.debFile = "testdata/changes/hardlink_0.2.1_amd64.deb"
+
+	stanza, err := GetControlFileFromDeb(s.debFile)
+	c.Assert(err, IsNil)
+	s.p = NewPackageFromControlFile(stanza)
+}
+
+func (s *ValidationSuite) errors(validation *Validation, p *Package, file *ValidatedFile) []string {
+	result := []string{}
+	for _, err := range validation.Validate(p, file) {
+		result = append(result, err.Error())
+	}
+
+	return result
+}
+
+func (s *ValidationSuite) TestNewValidation(c *C) {
+	validation, err := NewValidation(nil, nil, 0, nil, false)
+	c.Check(err, IsNil)
+	c.Check(validation, IsNil)
+
+	validation, err = NewValidation([]string{"maintainer", "architecture", "max-size"}, []string{"amd64", "arm64"}, 1024, nil, false)
+	c.Check(err, IsNil)
+	c.Check(validation.String(), Equals, "maintainer, architecture (amd64, arm64), max-size (1024 bytes)")
+
+	_, err = NewValidation([]string{"checksum"}, nil, 0, nil, false)
+	c.Check(err, ErrorMatches, "unknown validator \"checksum\", supported validators: architecture, duplicate-files, filename, maintainer, max-size, signature, version")
+
+	_, err = NewValidation([]string{"architecture"}, nil, 0, nil, false)
+	c.Check(err, ErrorMatches, "validator \"architecture\" requires list of allowed architectures")
+
+	_, err = NewValidation([]string{"max-size"}, nil, 0, nil, false)
+	c.Check(err, ErrorMatches, "validator \"max-size\" requires maximum package size")
+
+	_, err = NewValidation(nil, nil, -1, nil, false)
+	c.Check(err, ErrorMatches, "maximum package size should be positive: -1")
+}
+
+func (s *ValidationSuite) TestValidateNil(c *C) {
+	var validation *Validation
+	c.Check(validation.Validate(s.p, nil), IsNil)
+}
+
+func (s *ValidationSuite) TestValidateControlData(c *C) {
+	validation := &Validation{Validators: []string{"maintainer", "version", "architecture"}, Architectures: []string{"i386"}}
+
+	c.Check(s.errors(validation, &Package{Name: "app", Version: "1:1.0-1~bpo1", Architecture: "all", extra: &Stanza{"Maintainer": "Team <team@example.com>"}}, nil),
+		DeepEquals, []string{})
+	c.Check(s.errors(validation, &Package{Name: "app", Version: "1.0-beta-1", Architecture: "i386", extra: &Stanza{"Maintainer": "Team"}}, nil),
+		DeepEquals, []string{})
+
+	c.Check(s.errors(validation, &Package{Name: "app", Version: "v1.0", Architecture: "amd64", extra: &Stanza{}}, nil), DeepEquals, []string{
+		"maintainer: missing Maintainer field",
+		"version: upstream version should start with a digit in version \"v1.0\"",
+		"architecture: architecture amd64 is not allowed, allowed architectures: i386",
+	})
+
+	for version, expected := range map[string]string{
+		"x:1.0":   "invalid epoch in version \"x:1.0\"",
+		"1.0_1":   "invalid characters in upstream version \"1.0_1\"",
+		"1.0-":    "invalid Debian revision in version \"1.0-\"",
+		"1:2:1.0": "invalid characters in upstream version \"1:2:1.0\"",
+	} {
+		c.Check(validateVersion(validation, &Package{Name: "app", Version: version}, nil), ErrorMatches, expected)
+	}
+}
+
+func (s *ValidationSuite) TestValidateFile(c *C) {
+	validation := &Validation{Validators: []string{"filename", "duplicate-files", "max-size"}, MaxSize: 1 << 20}
+
+	file, err := NewValidatedFile(s.debFile)
+	c.Assert(err, IsNil)
+	c.Check(file.Filename, Equals, "hardlink_0.2.1_amd64.deb")
+
+	c.Check(s.errors(validation, s.p, file), DeepEquals, []string{})
+
+	// file is not available, checks are skipped
+	c.Check(s.errors(validation, s.p, nil), DeepEquals, []string{})
+
+	file.Filename = "hardlink_latest.deb"
+	validation.MaxSize = 1024
+	c.Check(s.errors(validation, s.p, file), DeepEquals, []string{
+		"filename: file name hardlink_latest.deb doesn't match control data, expected hardlink_0.2.1_amd64.deb",
+		"max-size: file hardlink_latest.deb is 12468 bytes, exceeding maximum size of 1024 bytes",
+	})
+
+	c.Check(validateFilename(validation, &Package{Name: "app", Version: "1:1.0", IsSource: true}, &ValidatedFile{Filename: "app_1.0.dsc"}), IsNil)
+	c.Check(validateFilename(validation, &Package{Name: "app", Version: "1.0", Architecture: "i386", IsUdeb: true}, &ValidatedFile{Filename: "app_1.0_i386.udeb"}), IsNil)
+	c.Check(validateFilename(validation, &Package{Name: "app-dbgsym", Version: "1.0", Architecture: "i386"}, &ValidatedFile{Filename: "app-dbgsym_1.0_i386.ddeb"}), IsNil)
+	c.Check(validateFilename(validation, &Package{Name: "app-dbgsym", Version: "1.0", Architecture: "i386"}, &ValidatedFile{Filename: "app-dbgsym_1.0_amd64.ddeb"}),
+		ErrorMatches, "file name app-dbgsym_1.0_amd64.ddeb doesn't match control data, expected app-dbgsym_1.0_i386.ddeb")
+}
+
+func (s *ValidationSuite) TestDuplicateFiles(c *C) {
+	c.Check(duplicateFiles([]string{"usr/bin/app", "usr/share/doc/app/copyright"}), DeepEquals, []string{})
+	c.Check(duplicateFiles([]string{"usr/bin/b", "usr/bin/a", "usr/bin/b", "usr/bin/a", "usr/bin/b"}), DeepEquals, []string{"usr/bin/a", "usr/bin/b"})
+}
+
+func (s *ValidationSuite) TestRegisterPackageValidator(c *C) {
+	RegisterPackageValidator("no-hardlink", func(_ *Validation, p *Package, _ *ValidatedFile) error {
+		if p.Name == "hardlink" {
+			return fmt.Errorf("hardlink is not allowed")
+		}
+		return nil
+	})
+	defer delete(packageValidators, "no-hardlink")
+
+	validation, err := NewValidation([]string{"no-hardlink"}, nil, 0, nil, false)
+	c.Assert(err, IsNil)
+	c.Check(s.errors(validation, s.p, nil), DeepEquals, []string{"no-hardlink: hardlink is not allowed"})
+}
+
+func (s *ValidationSuite) TestImportPackageFiles(c *C) {
+	db, _ := goleveldb.NewOpenDB(c.MkDir())
+	defer func() { _ = db.Close() }()
+
+	collectionFactory := NewCollectionFactory(db)
+	pool := files.NewPackagePool(c.MkDir(), false)
+	reporter := &aptly.RecordingResultReporter{}
+	list := NewPackageList()
+
+	packageFile, _ := filepath.Abs(s.debFile)
+	validation := &Validation{Validators: []string{"architecture"}, Architectures: []string{"i386"}}
+
+	processedFiles, failedFiles, err := ImportPackageFiles(list, []string{packageFile}, false, &NullVerifier{}, pool,
+		collectionFactory.PackageCollection(), reporter, nil, validation, collectionFactory.ChecksumCollection)
+	c.Assert(err, IsNil)
+	c.Check(processedFiles, HasLen, 0)
+	c.Check(failedFiles, DeepEquals, []string{packageFile})
+	c.Check(reporter.Warnings, DeepEquals, []string{"Package " + packageFile +
+		" failed validation: architecture: architecture amd64 is not allowed, allowed architectures: i386"})
+	c.Check(list.Len(), Equals, 0)
+
+	validation.Architectures = append(validation.Architectures, "amd64")
+
+	processedFiles, failedFiles, err = ImportPackageFiles(list, []string{packageFile}, false, &NullVerifier{}, pool,
+		collectionFactory.PackageCollection(), reporter, nil, validation, collectionFactory.ChecksumCollection)
+	c.Assert(err, IsNil)
+	c.Check(processedFiles, DeepEquals, []string{packageFile})
+	c.Check(failedFiles, HasLen, 0)
+	c.Check(list.Len(), Equals, 1)
+
+	// package in the pool is validated using pool file
+	_ = list.ForEach(func(p *Package) error {
+		file := NewValidatedPoolFile(p, pool)
+		c.Assert(file, NotNil)
+		c.Check(file.Filename, Equals, "hardlink_0.2.1_amd64.deb")
+
+		validation = &Validation{Validators: []string{"duplicate-files", "filename", "max-size"}, MaxSize: 1024}
+		c.Check(s.errors(validation, p, file), DeepEquals, []string{
+			"max-size: file hardlink_0.2.1_amd64.deb is 12468 bytes, exceeding maximum size of 1024 bytes",
+		})
+		return nil
+	})
+}
+
+func (s *ValidationSuite) TestMainPackageFile(c *C) {
+	p := &Package{Name: "app-dbgsym", Version: "1.0", Architecture: "i386"}
+	p.UpdateFiles(PackageFiles{{Filename: "app-dbgsym_1.0_i386.ddeb"}})
+	c.Check(mainPackageFile(p).Filename, Equals, "app-dbgsym_1.0_i386.ddeb")
+
+	p.UpdateFiles(PackageFiles{{Filename: "app_1.0.orig.tar.gz"}, {Filename: "app_1.0.dsc"}})
+	c.Check(mainPackageFile(p).Filename, Equals, "app_1.0.dsc")
+
+	p.UpdateFiles(PackageFiles{{Filename: "app_1.0.orig.tar.gz"}})
+	c.Check(mainPackageFile(p), IsNil)
+}
@@ -24,5 +24,7 @@ package docs
 // @Tag.description.markdown
 // @Tag.name Tasks
 // @Tag.description.markdown
+// @Tag.name Promotions
+// @Tag.description.markdown
 
 // version will be appended here:
@@ -0,0 +1,315 @@
+// Package hooks runs actions configured to happen before and after aptly operations
+package hooks
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Stages of operation hooks are run at
+const (
+	// Pre hooks run before operation, failing hook aborts operation
+	Pre = "pre"
+	// Post hooks run asynchronously after successful operation
+	Post = "post"
+)
+
+// Operations hooks could be attached to
+const (
+	RepoAdd        = "repo-add"
+	RepoRemove     = "repo-remove"
+	MirrorUpdate   = "mirror-update"
+	SnapshotCreate = "snapshot-create"
+	PublishUpdate  = "publish-update"
+	PublishSwitch  = "publish-switch"
+	PublishDrop    = "publish-drop"
+)
+
+// defaultTimeout is used for hooks without timeout configured
+const defaultTimeout = 60 * time.Second
+
+// maxRetryDelay caps exponential backoff between retries of post hooks
+const maxRetryDelay = time.Minute
+
+// Objects are affected objects passed to hooks
+type Objects map[string]interface{}
+
+// Payload is a JSON document passed to hooks
+type Payload struct {
+	// Event name, <stage>-<operation>
+	Event string
+	// Stage and operation
+	Stage, Operation string
+	// Time of the event
+	Time time.Time
+	// Objects affected by the operation
+	Objects Objects
+}
+
+// Error is returned when pre hook fails
+type Error struct {
+	// Event hook has been run for
+	Event string
+	// Hook is command or URL of the hook
+	Hook string
+	// Err is the failure reported by the hook
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s hook %s failed: %s", e.Event, e.Hook, e.Err)
+}
+
+// Unwrap returns failure reported by the hook
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Runner runs hooks configured for events
+type Runner struct {
+	hooks         []utils.Hook
+	client        *http.Client
+	wg            sync.WaitGroup
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+	stop          chan struct{}
+	stopOnce      sync.Once
+}
+
+// NewRunner creates runner for hooks
+func NewRunner(hooks []utils.Hook) *Runner {
+	return &Runner{
+		hooks:         hooks,
+		client:        &http.Client{},
+		retryDelay:    time.Second,
+		maxRetryDelay: maxRetryDelay,
+		stop:          make(chan struct{}),
+	}
+}
+
+// matches checks whether hook is configured for event
+func matches(hook utils.Hook, event string) bool {
+	for _, pattern := range hook.Events {
+		if matched, err := filepath.Match(pattern, event); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// name returns short description of the hook for messages
+func name(hook utils.Hook) string {
+	if len(hook.Command) > 0 {
+		return hook.Command[0]
+	}
+
+	return hook.URL
+}
+
+// hooksFor returns hooks configured for event
+func (r *Runner) hooksFor(event string) []utils.Hook {
+	var result []utils.Hook
+
+	for _, hook := range r.hooks {
+		if matches(hook, event) {
+			result = append(result, hook)
+		}
+	}
+
+	return result
+}
+
+// newPayload encodes payload for the event
+func newPayload(stage, operation string, objects Objects) ([]byte, error) {
+	return json.Marshal(&Payload{
+		Event:     stage + "-" + operation,
+		Stage:     stage,
+		Operation: operation,
+		Time:      time.Now(),
+		Objects:   objects,
+	})
+}
+
+// Pre runs hooks configured to run before operation one by one, returning error
+// of the first failing hook (as *Error), so that operation could be aborted
+func (r *Runner) Pre(operation string, objects Objects) error {
+	event := Pre + "-" + operation
+
+	hooks := r.hooksFor(event)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	payload, err := newPayload(Pre, operation, objects)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		if err = r.run(hook, event, payload); err != nil {
+			return &Error{Event: event, Hook: name(hook), Err: err}
+		}
+	}
+
+	return nil
+}
+
+// Post starts hooks configured to run after operation in background,
+// failed hooks are retried and logged
+func (r *Runner) Post(operation string, objects Objects) {
+	event := Post + "-" + operation
+
+	hooks := r.hooksFor(event)
+	if len(hooks) == 0 {
+		return
+	}
+
+	payload, err := newPayload(Post, operation, objects)
+	if err != nil {
+		log.Warn().Msgf("unable to run %s hooks: %s", event, err)
+		return
+	}
+
+	for _, hook := range hooks {
+		r.wg.Add(1)
+
+		go func(hook utils.Hook) {
+			defer r.wg.Done()
+
+			delay := r.retryDelay
+
+			for attempt := 0; ; attempt++ {
+				err := r.run(hook, event, payload)
+				if err == nil {
+					return
+				}
+
+				if attempt >= hook.Retries {
+					log.Warn().Msgf("%s hook %s failed: %s", event, name(hook), err)
+					return
+				}
+
+				log.Debug().Msgf("%s hook %s failed, retrying: %s", event, name(hook), err)
+
+				select {
+				case <-time.After(delay):
+				case <-r.stop:
+					log.Warn().Msgf("%s hook %s failed, not retrying on shutdown: %s", event, name(hook), err)
+					return
+				}
+
+				delay *= 2
+				if delay > r.maxRetryDelay {
+					delay = r.maxRetryDelay
+				}
+			}
+		}(hook)
+	}
+}
+
+// Wait waits for post hooks running in background to finish
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+// Shutdown cancels pending retries of post hooks and waits up to timeout for
+// running hooks to finish, returning false if some hooks are still running
+func (r *Runner) Shutdown(timeout time.Duration) bool {
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// run runs single hook with payload
+func (r *Runner) run(hook utils.Hook, event string, payload []byte) error {
+	timeout := defaultTimeout
+	if hook.Timeout > 0 {
+		timeout = time.Duration(hook.Timeout) * time.Second
+	}
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), timeout)
+	defer cancel()
+
+	if len(hook.Command) > 0 {
+		return runCommand(ctx, hook.Command, event, payload)
+	}
+
+	if hook.URL != "" {
+		return r.runWebhook(ctx, hook.URL, event, payload)
+	}
+
+	return fmt.Errorf("neither command nor url configured")
+}
+
+// runCommand runs local command passing payload on stdin, output of the failed
+// command is returned as error message
+func runCommand(ctx gocontext.Context, command []string, event string, payload []byte) error {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), "APTLY_HOOK_EVENT="+event)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if message := strings.TrimSpace(string(output)); message != "" {
+			return fmt.Errorf("%s", message)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// runWebhook posts payload to URL, response body of failed request is returned
+// as error message
+func (r *Runner) runWebhook(ctx gocontext.Context, url string, event string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Aptly-Event", event)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if message := strings.TrimSpace(string(body)); message != "" {
+			return fmt.Errorf("HTTP %d: %s", resp.StatusCode, message)
+		}
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	return nil
+}
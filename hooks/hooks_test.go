@@ -0,0 +1,215 @@
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+// Launch gocheck tests
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type HooksSuite struct {
+	dir string
+}
+
+var _ = Suite(&HooksSuite{})
+
+func (s *HooksSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+}
+
+func (s *HooksSuite) TestMatches(c *C) {
+	hook := utils.Hook{Events: []string{"pre-publish-switch", "post-*"}}
+
+	c.Check(matches(hook, "pre-publish-switch"), Equals, true)
+	c.Check(matches(hook, "post-repo-add"), Equals, true)
+	c.Check(matches(hook, "pre-repo-add"), Equals, false)
+	c.Check(matches(utils.Hook{}, "pre-repo-add"), Equals, false)
+}
+
+func (s *HooksSuite) TestPreCommand(c *C) {
+	output := filepath.Join(s.dir, "payload")
+
+	runner := NewRunner([]utils.Hook{
+		{Events: []string{"pre-repo-add"}, Command: []string{"sh", "-c", `cat > "$1"; echo "$APTLY_HOOK_EVENT" >> "$1"`, "hook", output}},
+		{Events: []string{"pre-publish-*"}, Command: []string{"sh", "-c", "echo tests failed; exit 1"}},
+	})
+
+	c.Assert(runner.Pre(RepoAdd, Objects{"Repo": "testing"}), IsNil)
+
+	contents, err := os.ReadFile(output)
+	c.Assert(err, IsNil)
+
+	var payload Payload
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	c.Assert(decoder.Decode(&payload), IsNil)
+	c.Check(payload.Event, Equals, "pre-repo-add")
+	c.Check(payload.Stage, Equals, Pre)
+	c.Check(payload.Operation, Equals, RepoAdd)
+	c.Check(payload.Objects, DeepEquals, Objects{"Repo": "testing"})
+
+	rest, _ := io.ReadAll(decoder.Buffered())
+	c.Check(string(rest), Equals, "pre-repo-add\n")
+
+	err = runner.Pre(PublishSwitch, nil)
+	c.Check(err, ErrorMatches, "pre-publish-switch hook sh failed: tests failed")
+
+	var hookErr *Error
+	c.Assert(errors.As(err, &hookErr), Equals, true)
+	c.Check(hookErr.Event, Equals, "pre-publish-switch")
+	c.Check(hookErr.Hook, Equals, "sh")
+	c.Check(runner.Pre(MirrorUpdate, nil), IsNil)
+}
+
+func (s *HooksSuite) TestPreTimeout(c *C) {
+	runner := NewRunner([]utils.Hook{
+		{Events: []string{"pre-mirror-update"}, Command: []string{"sleep", "10"}, Timeout: 1},
+	})
+
+	start := time.Now()
+	c.Check(runner.Pre(MirrorUpdate, nil), ErrorMatches, "pre-mirror-update hook sleep failed: .*killed.*")
+	c.Check(time.Since(start) < 5*time.Second, Equals, true)
+}
+
+func (s *HooksSuite) TestPreWebhook(c *C) {
+	var payload Payload
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("X-Aptly-Event"), Equals, "pre-publish-drop")
+		c.Check(json.NewDecoder(r.Body).Decode(&payload), IsNil)
+
+		if payload.Objects["Distribution"] == "stable" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("stable is frozen\n"))
+		}
+	}))
+	defer server.Close()
+
+	runner := NewRunner([]utils.Hook{{Events: []string{"pre-publish-drop"}, URL: server.URL}})
+
+	c.Check(runner.Pre(PublishDrop, Objects{"Distribution": "testing"}), IsNil)
+	c.Check(payload.Event, Equals, "pre-publish-drop")
+
+	c.Check(runner.Pre(PublishDrop, Objects{"Distribution": "stable"}), ErrorMatches,
+		"pre-publish-drop hook http://.* failed: HTTP 403: stable is frozen")
+}
+
+func (s *HooksSuite) TestPostRetries(c *C) {
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	runner := NewRunner([]utils.Hook{{Events: []string{"post-*"}, URL: server.URL, Retries: 5}})
+	runner.retryDelay = time.Millisecond
+
+	runner.Post(SnapshotCreate, Objects{"Snapshot": "snap1"})
+	runner.Wait()
+	c.Check(attempts, Equals, 3)
+
+	attempts = -10
+	runner.hooks[0].Retries = 1
+
+	runner.Post(SnapshotCreate, Objects{"Snapshot": "snap1"})
+	runner.Wait()
+	c.Check(attempts, Equals, -8)
+}
+
+func (s *HooksSuite) TestNoAction(c *C) {
+	runner := NewRunner([]utils.Hook{{Events: []string{"pre-repo-remove"}}})
+
+	c.Check(runner.Pre(RepoRemove, nil), ErrorMatches, "pre-repo-remove hook .*failed: neither command nor url configured")
+}
+
+func (s *HooksSuite) TestPostBackoff(c *C) {
+	var (
+		mu       sync.Mutex
+		attempts []time.Time
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts = append(attempts, time.Now())
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	runner := NewRunner([]utils.Hook{{Events: []string{"post-*"}, URL: server.URL, Retries: 4}})
+	runner.retryDelay = 10 * time.Millisecond
+	runner.maxRetryDelay = 20 * time.Millisecond
+
+	runner.Post(SnapshotCreate, nil)
+	runner.Wait()
+
+	c.Assert(attempts, HasLen, 5)
+	for i := 1; i < len(attempts); i++ {
+		c.Check(attempts[i].Sub(attempts[i-1]) < time.Second, Equals, true)
+	}
+}
+
+func (s *HooksSuite) TestShutdown(c *C) {
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	runner := NewRunner([]utils.Hook{
+		{Events: []string{"post-*"}, URL: server.URL, Retries: 5},
+		{Events: []string{"post-*"}, Command: []string{"sleep", "10"}, Timeout: 2},
+	})
+	runner.retryDelay = time.Hour
+
+	runner.Post(SnapshotCreate, nil)
+
+	// retry of failed webhook is cancelled, running command is not waited for
+	start := time.Now()
+	c.Check(runner.Shutdown(100*time.Millisecond), Equals, false)
+	c.Check(time.Since(start) < 5*time.Second, Equals, true)
+
+	mu.Lock()
+	c.Check(attempts <= 1, Equals, true)
+	mu.Unlock()
+
+	runner = NewRunner([]utils.Hook{{Events: []string{"post-*"}, URL: server.URL, Retries: 5}})
+	runner.retryDelay = time.Hour
+
+	runner.Post(SnapshotCreate, nil)
+	c.Check(runner.Shutdown(5*time.Second), Equals, true)
+}
@@ -0,0 +1,54 @@
+package hooks
+
+import (
+	"github.com/aptly-dev/aptly/deb"
+)
+
+// RemoteRepoObjects describes mirror for hooks payload
+func RemoteRepoObjects(repo *deb.RemoteRepo) Objects {
+	return Objects{
+		"Mirror":       repo.Name,
+		"ArchiveRoot":  repo.ArchiveRoot,
+		"Distribution": repo.Distribution,
+		"NumPackages":  repo.NumPackages(),
+	}
+}
+
+// SnapshotObjects describes snapshot for hooks payload
+func SnapshotObjects(snapshot *deb.Snapshot) Objects {
+	return Objects{
+		"Snapshot":    snapshot.Name,
+		"Description": snapshot.Description,
+		"SourceKind":  snapshot.SourceKind,
+		"NumPackages": snapshot.NumPackages(),
+	}
+}
+
+// PublishedRepoObjects describes published repository for hooks payload
+func PublishedRepoObjects(published *deb.PublishedRepo) Objects {
+	return Objects{
+		"Storage":      published.Storage,
+		"Prefix":       published.Prefix,
+		"Distribution": published.Distribution,
+		"SourceKind":   published.SourceKind,
+		"Components":   published.Components(),
+	}
+}
+
+// LocalRepoChanges describes local repo and packages added and removed
+// by the operation (as package keys) for hooks payload
+func LocalRepoChanges(repo *deb.LocalRepo, before *deb.PackageRefList) Objects {
+	after := repo.RefList()
+	if after == nil {
+		after = deb.NewPackageRefList()
+	}
+	if before == nil {
+		before = deb.NewPackageRefList()
+	}
+
+	return Objects{
+		"Repo":    repo.Name,
+		"Added":   after.Subtract(before).Strings(),
+		"Removed": before.Subtract(after).Strings(),
+	}
+}
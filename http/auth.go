@@ -0,0 +1,213 @@
+package http
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// netrcCredentials is a single login/password pair from netrc file
+type netrcCredentials struct {
+	login    string
+	password string
+}
+
+// Auth holds authentication settings applied by downloader to every request
+//
+// Bearer token (if configured) takes precedence over netrc credentials,
+// netrc credentials are matched by request host name.
+type Auth struct {
+	machines     map[string]netrcCredentials
+	defaultCreds *netrcCredentials
+	token        string
+	tlsConfig    *tls.Config
+}
+
+// LoadAuth reads files referenced by mirror authentication settings
+func LoadAuth(config utils.MirrorAuth) (*Auth, error) {
+	auth := &Auth{
+		machines: make(map[string]netrcCredentials),
+	}
+
+	if config.NetrcFile != "" {
+		f, err := os.Open(config.NetrcFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read netrc file")
+		}
+		defer func() { _ = f.Close() }()
+
+		err = auth.parseNetrc(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse netrc file %s", config.NetrcFile)
+		}
+	}
+
+	if config.TokenFile != "" {
+		token, err := os.ReadFile(config.TokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read token file")
+		}
+
+		auth.token = strings.TrimSpace(string(token))
+		if auth.token == "" {
+			return nil, fmt.Errorf("token file %s is empty", config.TokenFile)
+		}
+	}
+
+	if config.CACertFile != "" || config.ClientCertFile != "" || config.ClientKeyFile != "" {
+		auth.tlsConfig = &tls.Config{}
+	}
+
+	if config.CACertFile != "" {
+		pem, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read CA bundle")
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", config.CACertFile)
+		}
+
+		auth.tlsConfig.RootCAs = pool
+	}
+
+	if config.ClientCertFile != "" || config.ClientKeyFile != "" {
+		if config.ClientCertFile == "" || config.ClientKeyFile == "" {
+			return nil, fmt.Errorf("both client certificate and key should be configured")
+		}
+
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load client certificate")
+		}
+
+		auth.tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return auth, nil
+}
+
+// parseNetrc parses netrc(5) format: machine, default, login & password tokens
+// are recognized, macro definitions (macdef) are skipped
+func (auth *Auth) parseNetrc(r io.Reader) error {
+	var tokens []string
+
+	scanner := bufio.NewScanner(r)
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// macro definition ends with empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		for _, field := range strings.Fields(line) {
+			if field == "macdef" {
+				inMacro = true
+				break
+			}
+			tokens = append(tokens, field)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var (
+		current *netrcCredentials
+		machine string
+	)
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		if machine == "" {
+			auth.defaultCreds = current
+		} else {
+			auth.machines[machine] = *current
+		}
+		current = nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+
+		if token == "default" {
+			flush()
+			current, machine = &netrcCredentials{}, ""
+			continue
+		}
+
+		if i+1 >= len(tokens) {
+			return fmt.Errorf("missing value for %s", token)
+		}
+		i++
+		value := tokens[i]
+
+		switch token {
+		case "machine":
+			flush()
+			current, machine = &netrcCredentials{}, value
+		case "login", "password", "account":
+			if current == nil {
+				return fmt.Errorf("%s outside of machine definition", token)
+			}
+			if token == "login" {
+				current.login = value
+			} else if token == "password" {
+				current.password = value
+			}
+		default:
+			return fmt.Errorf("unexpected token %#v", token)
+		}
+	}
+
+	flush()
+
+	return nil
+}
+
+// apply sets authorization header on the request, unless request
+// already carries own credentials (e.g. in URL)
+func (auth *Auth) apply(req *http.Request) {
+	if auth == nil || req.Header.Get("Authorization") != "" || req.URL.User != nil {
+		return
+	}
+
+	if auth.token != "" {
+		req.Header.Set("Authorization", "Bearer "+auth.token)
+		return
+	}
+
+	if creds, ok := auth.machines[req.URL.Hostname()]; ok {
+		req.SetBasicAuth(creds.login, creds.password)
+	} else if auth.defaultCreds != nil {
+		req.SetBasicAuth(auth.defaultCreds.login, auth.defaultCreds.password)
+	}
+}
+
+// clientTLSConfig returns TLS configuration for custom CA and client certificate,
+// nil if defaults should be used
+func (auth *Auth) clientTLSConfig() *tls.Config {
+	if auth == nil || auth.tlsConfig == nil {
+		return nil
+	}
+
+	return auth.tlsConfig.Clone()
+}
@@ -0,0 +1,208 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type AuthSuite struct {
+	dir  string
+	dest string
+	ctx  context.Context
+}
+
+var _ = Suite(&AuthSuite{})
+
+func (s *AuthSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.dest = filepath.Join(c.MkDir(), "dest")
+	s.ctx = context.Background()
+}
+
+func (s *AuthSuite) writeFile(c *C, name, contents string) string {
+	path := filepath.Join(s.dir, name)
+	c.Assert(os.WriteFile(path, []byte(contents), 0600), IsNil)
+	return path
+}
+
+// authEchoServer replies with Authorization header of the request
+func authEchoServer() *httptest.Server {
+	return httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+}
+
+func (s *AuthSuite) TestParseNetrc(c *C) {
+	auth := &Auth{machines: make(map[string]netrcCredentials)}
+
+	c.Assert(auth.parseNetrc(strings.NewReader(`machine repo.example.com login user password secret
+machine other.example.com
+    login other
+    password "quoted"
+
+macdef init
+cd /pub
+machine fake login fake password fake
+
+default login anonymous password guest
+`)), IsNil)
+
+	c.Check(auth.machines, DeepEquals, map[string]netrcCredentials{
+		"repo.example.com":  {login: "user", password: "secret"},
+		"other.example.com": {login: "other", password: "\"quoted\""},
+	})
+	c.Check(auth.defaultCreds, DeepEquals, &netrcCredentials{login: "anonymous", password: "guest"})
+
+	c.Check(auth.parseNetrc(strings.NewReader("login user")), ErrorMatches, "login outside of machine definition")
+	c.Check(auth.parseNetrc(strings.NewReader("machine repo.example.com login")), ErrorMatches, "missing value for login")
+	c.Check(auth.parseNetrc(strings.NewReader("machine repo.example.com port 80")), ErrorMatches, "unexpected token \"port\"")
+}
+
+func (s *AuthSuite) TestLoadAuthErrors(c *C) {
+	_, err := LoadAuth(utils.MirrorAuth{NetrcFile: filepath.Join(s.dir, "no-such-file")})
+	c.Check(err, ErrorMatches, "unable to read netrc file: .*no such file or directory")
+
+	_, err = LoadAuth(utils.MirrorAuth{TokenFile: s.writeFile(c, "token", "\n")})
+	c.Check(err, ErrorMatches, "token file .* is empty")
+
+	_, err = LoadAuth(utils.MirrorAuth{CACertFile: s.writeFile(c, "ca.pem", "garbage")})
+	c.Check(err, ErrorMatches, "no certificates found in CA bundle .*")
+
+	_, err = LoadAuth(utils.MirrorAuth{ClientCertFile: s.writeFile(c, "client.pem", "garbage")})
+	c.Check(err, ErrorMatches, "both client certificate and key should be configured")
+
+	auth, err := LoadAuth(utils.MirrorAuth{})
+	c.Assert(err, IsNil)
+	c.Check(auth.clientTLSConfig(), IsNil)
+}
+
+func (s *AuthSuite) TestNetrc(c *C) {
+	srv := authEchoServer()
+	srv.Start()
+	defer srv.Close()
+
+	auth, err := LoadAuth(utils.MirrorAuth{
+		NetrcFile: s.writeFile(c, "netrc", "machine 127.0.0.1 login user password secret\n"),
+	})
+	c.Assert(err, IsNil)
+
+	d := NewDownloaderWithAuth(0, 1, nil, auth)
+	c.Assert(d.Download(s.ctx, srv.URL+"/Release", s.dest), IsNil)
+
+	contents, _ := os.ReadFile(s.dest)
+	c.Check(string(contents), Equals, "Basic dXNlcjpzZWNyZXQ=")
+
+	// credentials in URL take precedence
+	c.Assert(d.Download(s.ctx, strings.Replace(srv.URL, "://", "://other:pass@", 1)+"/Release", s.dest), IsNil)
+	contents, _ = os.ReadFile(s.dest)
+	c.Check(string(contents), Equals, "Basic b3RoZXI6cGFzcw==")
+
+	// host not listed in netrc
+	auth, err = LoadAuth(utils.MirrorAuth{
+		NetrcFile: s.writeFile(c, "netrc", "machine repo.example.com login user password secret\n"),
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(NewDownloaderWithAuth(0, 1, nil, auth).Download(s.ctx, srv.URL+"/Release", s.dest), IsNil)
+	contents, _ = os.ReadFile(s.dest)
+	c.Check(string(contents), Equals, "")
+}
+
+func (s *AuthSuite) TestToken(c *C) {
+	srv := authEchoServer()
+	srv.Start()
+	defer srv.Close()
+
+	auth, err := LoadAuth(utils.MirrorAuth{
+		TokenFile: s.writeFile(c, "token", "s3cr3t\n"),
+		NetrcFile: s.writeFile(c, "netrc", "default login user password secret\n"),
+	})
+	c.Assert(err, IsNil)
+
+	for _, d := range []aptly.Downloader{NewDownloaderWithAuth(0, 1, nil, auth), NewGrabDownloaderWithAuth(0, 1, nil, auth)} {
+		_ = os.Remove(s.dest)
+		c.Assert(d.Download(s.ctx, srv.URL+"/Release", s.dest), IsNil)
+
+		contents, _ := os.ReadFile(s.dest)
+		c.Check(string(contents), Equals, "Bearer s3cr3t")
+	}
+}
+
+// generateCertificate creates self-signed certificate and key, returning them PEM-encoded
+func generateCertificate(c *C, commonName string) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (s *AuthSuite) TestTLS(c *C) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caFile := s.writeFile(c, "ca.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})))
+
+	certPEM, keyPEM := generateCertificate(c, "aptly-client")
+	certFile := s.writeFile(c, "client.pem", string(certPEM))
+	keyFile := s.writeFile(c, "client.key", string(keyPEM))
+
+	// server certificate is not trusted
+	c.Check(NewDownloader(0, 1, nil).Download(s.ctx, srv.URL+"/Release", s.dest), ErrorMatches, ".*certificate.*")
+
+	// no client certificate
+	auth, err := LoadAuth(utils.MirrorAuth{CACertFile: caFile})
+	c.Assert(err, IsNil)
+	c.Check(NewDownloaderWithAuth(0, 1, nil, auth).Download(s.ctx, srv.URL+"/Release", s.dest), NotNil)
+
+	auth, err = LoadAuth(utils.MirrorAuth{CACertFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile})
+	c.Assert(err, IsNil)
+
+	d := NewDownloaderWithAuth(0, 1, nil, auth)
+	c.Assert(d.Download(s.ctx, srv.URL+"/Release", s.dest), IsNil)
+
+	contents, _ := os.ReadFile(s.dest)
+	c.Check(string(contents), Equals, "aptly-client")
+
+	size, err := d.GetLength(s.ctx, srv.URL+"/Release")
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, int64(len("aptly-client")))
+}
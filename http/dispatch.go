@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Check interface
+var (
+	_ aptly.Downloader = (*schemeDownloader)(nil)
+)
+
+// schemeDownloader routes each request to the downloader registered
+// for URL scheme, falling back to default downloader
+type schemeDownloader struct {
+	fallback aptly.Downloader
+	schemes  map[string]aptly.Downloader
+}
+
+// NewSchemeDownloader creates Downloader which dispatches requests based on URL scheme
+//
+// Plain paths (without scheme) are dispatched to downloader registered for "" scheme.
+func NewSchemeDownloader(fallback aptly.Downloader, schemes map[string]aptly.Downloader) aptly.Downloader {
+	return &schemeDownloader{
+		fallback: fallback,
+		schemes:  schemes,
+	}
+}
+
+func (downloader *schemeDownloader) pick(rawURL string) aptly.Downloader {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return downloader.fallback
+	}
+
+	if d, ok := downloader.schemes[u.Scheme]; ok {
+		return d
+	}
+
+	return downloader.fallback
+}
+
+// GetProgress returns Progress object
+func (downloader *schemeDownloader) GetProgress() aptly.Progress {
+	return downloader.fallback.GetProgress()
+}
+
+// GetLength returns size of the object at url
+func (downloader *schemeDownloader) GetLength(ctx context.Context, url string) (int64, error) {
+	return downloader.pick(url).GetLength(ctx, url)
+}
+
+// Download starts new download task
+func (downloader *schemeDownloader) Download(ctx context.Context, url string, destination string) error {
+	return downloader.pick(url).Download(ctx, url, destination)
+}
+
+// DownloadWithChecksum starts new download task with checksum verification
+func (downloader *schemeDownloader) DownloadWithChecksum(ctx context.Context, url string, destination string,
+	expected *utils.ChecksumInfo, ignoreMismatch bool) error {
+	return downloader.pick(url).DownloadWithChecksum(ctx, url, destination, expected, ignoreMismatch)
+}
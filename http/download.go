@@ -32,17 +32,25 @@ type downloaderImpl struct {
 	aggWriter io.Writer
 	maxTries  int
 	client    *http.Client
+	auth      *Auth
 }
 
 // NewDownloader creates new instance of Downloader which specified number
 // of threads and download limit in bytes/sec
 func NewDownloader(downLimit int64, maxTries int, progress aptly.Progress) aptly.Downloader {
+	return NewDownloaderWithAuth(downLimit, maxTries, progress, nil)
+}
+
+// NewDownloaderWithAuth creates new instance of Downloader which applies
+// authentication settings to every request (auth could be nil)
+func NewDownloaderWithAuth(downLimit int64, maxTries int, progress aptly.Progress, auth *Auth) aptly.Downloader {
 	transport := http.Transport{}
 	transport.Proxy = http.DefaultTransport.(*http.Transport).Proxy
 	transport.ResponseHeaderTimeout = 30 * time.Second
 	transport.TLSHandshakeTimeout = http.DefaultTransport.(*http.Transport).TLSHandshakeTimeout
 	transport.ExpectContinueTimeout = http.DefaultTransport.(*http.Transport).ExpectContinueTimeout
 	transport.DisableCompression = true
+	transport.TLSClientConfig = auth.clientTLSConfig()
 	initTransport(&transport)
 	transport.RegisterProtocol("ftp", &protocol.FTPRoundTripper{})
 	transport.RegisterProtocol("ar+https", NewGCPRoundTripper(&transport))
@@ -50,6 +58,7 @@ func NewDownloader(downLimit int64, maxTries int, progress aptly.Progress) aptly
 	downloader := &downloaderImpl{
 		progress:  progress,
 		maxTries:  maxTries,
+		auth:      auth,
 		aggWriter: io.Writer(progress),
 		client: &http.Client{
 			Transport: &transport,
@@ -167,6 +176,7 @@ func (downloader *downloaderImpl) newRequest(ctx context.Context, method, url st
 	}
 	req.Close = true
 	req = req.WithContext(ctx)
+	downloader.auth.apply(req)
 
 	proxyURL, _ := downloader.client.Transport.(*http.Transport).Proxy(req)
 	if proxyURL == nil && (req.URL.Scheme == "http" || req.URL.Scheme == "https") {
@@ -285,34 +295,46 @@ func (downloader *downloaderImpl) download(req *http.Request, url, destination s
 	}
 
 	if expected != nil {
-		actual := checksummer.Sum()
-
-		if actual.Size != expected.Size {
-			err = fmt.Errorf("%s: size check mismatch %d != %d", url, actual.Size, expected.Size)
-		} else if expected.MD5 != "" && actual.MD5 != expected.MD5 {
-			err = fmt.Errorf("%s: md5 hash mismatch %#v != %#v", url, actual.MD5, expected.MD5)
-		} else if expected.SHA1 != "" && actual.SHA1 != expected.SHA1 {
-			err = fmt.Errorf("%s: sha1 hash mismatch %#v != %#v", url, actual.SHA1, expected.SHA1)
-		} else if expected.SHA256 != "" && actual.SHA256 != expected.SHA256 {
-			err = fmt.Errorf("%s: sha256 hash mismatch %#v != %#v", url, actual.SHA256, expected.SHA256)
-		} else if expected.SHA512 != "" && actual.SHA512 != expected.SHA512 {
-			err = fmt.Errorf("%s: sha512 hash mismatch %#v != %#v", url, actual.SHA512, expected.SHA512)
-		}
-
+		err = VerifyChecksums(url, checksummer.Sum(), expected, ignoreMismatch, downloader.progress)
 		if err != nil {
-			if ignoreMismatch {
-				if downloader.progress != nil {
-					downloader.progress.Printf("WARNING: %s\n", err.Error())
-				}
-			} else {
-				_ = os.Remove(temppath)
-				return "", err
-			}
-		} else {
-			// update checksums if they match, so that they contain exactly expected set
-			*expected = actual
+			_ = os.Remove(temppath)
+			return "", err
 		}
 	}
 
 	return temppath, nil
 }
+
+// VerifyChecksums compares actual checksums of downloaded file with expected ones
+//
+// If checksums match, expected is updated to contain the complete set of checksums.
+// If ignoreMismatch is set, mismatch is reported as a warning via progress.
+func VerifyChecksums(url string, actual utils.ChecksumInfo, expected *utils.ChecksumInfo, ignoreMismatch bool, progress aptly.Progress) error {
+	var err error
+
+	if actual.Size != expected.Size {
+		err = fmt.Errorf("%s: size check mismatch %d != %d", url, actual.Size, expected.Size)
+	} else if expected.MD5 != "" && actual.MD5 != expected.MD5 {
+		err = fmt.Errorf("%s: md5 hash mismatch %#v != %#v", url, actual.MD5, expected.MD5)
+	} else if expected.SHA1 != "" && actual.SHA1 != expected.SHA1 {
+		err = fmt.Errorf("%s: sha1 hash mismatch %#v != %#v", url, actual.SHA1, expected.SHA1)
+	} else if expected.SHA256 != "" && actual.SHA256 != expected.SHA256 {
+		err = fmt.Errorf("%s: sha256 hash mismatch %#v != %#v", url, actual.SHA256, expected.SHA256)
+	} else if expected.SHA512 != "" && actual.SHA512 != expected.SHA512 {
+		err = fmt.Errorf("%s: sha512 hash mismatch %#v != %#v", url, actual.SHA512, expected.SHA512)
+	}
+
+	if err != nil {
+		if !ignoreMismatch {
+			return err
+		}
+		if progress != nil {
+			progress.Printf("WARNING: %s\n", err.Error())
+		}
+		return nil
+	}
+
+	// update checksums if they match, so that they contain exactly expected set
+	*expected = actual
+	return nil
+}
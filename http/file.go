@@ -0,0 +1,165 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Check interface
+var (
+	_ aptly.Downloader = (*fileDownloader)(nil)
+)
+
+// fileDownloader is implementation of Downloader interface which
+// fetches files from local filesystem (file:// URLs or plain paths)
+//
+// Files are hardlinked into destination when possible, otherwise they are copied.
+type fileDownloader struct {
+	progress aptly.Progress
+}
+
+// NewFileDownloader creates new instance of Downloader which fetches
+// files from local directories (e.g. mounted DVD or NFS share)
+func NewFileDownloader(progress aptly.Progress) aptly.Downloader {
+	return &fileDownloader{
+		progress: progress,
+	}
+}
+
+// localPath converts file:// URL or plain path into filesystem path
+func localPath(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "file":
+		if u.Host != "" && u.Host != "localhost" {
+			return "", fmt.Errorf("%s: remote hosts are not supported in file:// URLs", rawURL)
+		}
+		return filepath.FromSlash(u.Path), nil
+	case "":
+		return filepath.FromSlash(u.Path), nil
+	}
+
+	return "", fmt.Errorf("%s: not a local path", rawURL)
+}
+
+func (downloader *fileDownloader) stat(url string) (string, os.FileInfo, error) {
+	path, err := localPath(url)
+	if err != nil {
+		return "", nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil, &Error{Code: 404, URL: url}
+		}
+		if os.IsPermission(err) {
+			return "", nil, &Error{Code: 403, URL: url}
+		}
+		return "", nil, errors.Wrap(err, url)
+	}
+
+	if info.IsDir() {
+		return "", nil, fmt.Errorf("%s: is a directory", url)
+	}
+
+	return path, info, nil
+}
+
+// GetProgress returns Progress object
+func (downloader *fileDownloader) GetProgress() aptly.Progress {
+	return downloader.progress
+}
+
+// GetLength returns size of the file
+func (downloader *fileDownloader) GetLength(_ context.Context, url string) (int64, error) {
+	_, info, err := downloader.stat(url)
+	if err != nil {
+		return -1, err
+	}
+
+	return info.Size(), nil
+}
+
+// Download copies file to destination
+func (downloader *fileDownloader) Download(ctx context.Context, url string, destination string) error {
+	return downloader.DownloadWithChecksum(ctx, url, destination, nil, false)
+}
+
+// DownloadWithChecksum copies (or hardlinks) file to destination with checksum verification
+func (downloader *fileDownloader) DownloadWithChecksum(ctx context.Context, url string, destination string,
+	expected *utils.ChecksumInfo, ignoreMismatch bool) error {
+
+	if downloader.progress != nil {
+		downloader.progress.Printf("Copying: %s\n", url)
+		defer downloader.progress.Flush()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, info, err := downloader.stat(url)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(destination), 0777)
+	if err != nil {
+		return errors.Wrap(err, url)
+	}
+
+	temppath := destination + ".down"
+	_ = os.Remove(temppath)
+
+	err = os.Link(path, temppath)
+	if err != nil {
+		// different filesystems or not allowed to link, fallback to copy
+		err = utils.CopyFile(path, temppath)
+		if err != nil {
+			_ = os.Remove(temppath)
+			return errors.Wrap(err, url)
+		}
+	}
+
+	if expected != nil {
+		var actual utils.ChecksumInfo
+
+		actual, err = utils.ChecksumsForFile(temppath)
+		if err != nil {
+			_ = os.Remove(temppath)
+			return errors.Wrap(err, url)
+		}
+
+		err = VerifyChecksums(url, actual, expected, ignoreMismatch, downloader.progress)
+		if err != nil {
+			_ = os.Remove(temppath)
+			return err
+		}
+	}
+
+	if downloader.progress != nil {
+		downloader.progress.AddBar(int(info.Size()))
+	}
+
+	err = os.Rename(temppath, destination)
+	// rename is no-op if destination is already a hardlink to the same file
+	_ = os.Remove(temppath)
+	if err != nil {
+		return errors.Wrap(err, url)
+	}
+
+	return nil
+}
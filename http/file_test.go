@@ -0,0 +1,141 @@
+package http
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type FileDownloaderSuite struct {
+	root string
+	url  string
+	dest string
+	d    aptly.Downloader
+	ctx  context.Context
+}
+
+var _ = Suite(&FileDownloaderSuite{})
+
+func (s *FileDownloaderSuite) SetUpTest(c *C) {
+	s.root = c.MkDir()
+	s.url = (&url.URL{Scheme: "file", Path: s.root}).String()
+	s.dest = filepath.Join(c.MkDir(), "sub", "dest")
+	s.d = NewFileDownloader(nil)
+	s.ctx = context.Background()
+
+	c.Assert(os.MkdirAll(filepath.Join(s.root, "dists", "stable"), 0755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(s.root, "dists", "stable", "Release"), []byte("Hello, /test"), 0644), IsNil)
+}
+
+func (s *FileDownloaderSuite) TestLocalPath(c *C) {
+	path, err := localPath("file:///srv/mirror%20dir/dists/Release")
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, "/srv/mirror dir/dists/Release")
+
+	path, err = localPath("/srv/mirror/pool/a_1.0+b1.deb")
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, "/srv/mirror/pool/a_1.0+b1.deb")
+
+	_, err = localPath("file://example.com/srv/mirror")
+	c.Check(err, ErrorMatches, ".*remote hosts are not supported.*")
+
+	_, err = localPath("http://example.com/srv/mirror")
+	c.Check(err, ErrorMatches, ".*not a local path")
+}
+
+func (s *FileDownloaderSuite) TestGetLength(c *C) {
+	size, err := s.d.GetLength(s.ctx, s.url+"/dists/stable/Release")
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, int64(12))
+
+	_, err = s.d.GetLength(s.ctx, s.url+"/dists/stable/InRelease")
+	c.Check(err, ErrorMatches, "HTTP code 404.*")
+
+	_, err = s.d.GetLength(s.ctx, s.url+"/dists/stable")
+	c.Check(err, ErrorMatches, ".*is a directory")
+}
+
+func (s *FileDownloaderSuite) TestDownload(c *C) {
+	c.Assert(s.d.Download(s.ctx, s.url+"/dists/stable/Release", s.dest), IsNil)
+
+	contents, err := os.ReadFile(s.dest)
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Equals, "Hello, /test")
+
+	_, err = os.Stat(s.dest + ".down")
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	// downloading again over existing (possibly hardlinked) file
+	c.Assert(s.d.Download(s.ctx, s.url+"/dists/stable/Release", s.dest), IsNil)
+	_, err = os.Stat(s.dest + ".down")
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	c.Check(s.d.Download(s.ctx, s.url+"/dists/stable/InRelease", s.dest), ErrorMatches, "HTTP code 404.*")
+}
+
+func (s *FileDownloaderSuite) TestDownloadPlainPath(c *C) {
+	c.Assert(s.d.Download(s.ctx, filepath.Join(s.root, "dists", "stable", "Release"), s.dest), IsNil)
+
+	contents, err := os.ReadFile(s.dest)
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Equals, "Hello, /test")
+}
+
+func (s *FileDownloaderSuite) TestDownloadWithChecksum(c *C) {
+	url := s.url + "/dists/stable/Release"
+
+	c.Assert(s.d.DownloadWithChecksum(s.ctx, url, s.dest, &utils.ChecksumInfo{}, false),
+		ErrorMatches, ".*size check mismatch 12 != 0")
+	_, err := os.Stat(s.dest)
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	c.Assert(s.d.DownloadWithChecksum(s.ctx, url, s.dest, &utils.ChecksumInfo{Size: 12, MD5: "abcdef"}, false),
+		ErrorMatches, ".*md5 hash mismatch \"a1acb0fe91c7db45ec4d775192ec5738\" != \"abcdef\"")
+
+	c.Assert(s.d.DownloadWithChecksum(s.ctx, url, s.dest, &utils.ChecksumInfo{Size: 12, MD5: "abcdef"}, true),
+		IsNil)
+
+	checksums := utils.ChecksumInfo{Size: 12, MD5: "a1acb0fe91c7db45ec4d775192ec5738"}
+	c.Assert(s.d.DownloadWithChecksum(s.ctx, url, s.dest, &checksums, false), IsNil)
+	// download backfills missing checksums
+	c.Check(checksums.SHA256, Equals, "b3c92ee1246176ed35f6e8463cd49074f29442f5bbffc3f8591cde1dcc849dac")
+}
+
+func (s *FileDownloaderSuite) TestDownloadTryCompression(c *C) {
+	expectedChecksums := map[string]utils.ChecksumInfo{
+		"Release": {Size: 12, MD5: "a1acb0fe91c7db45ec4d775192ec5738"},
+	}
+
+	baseURL, _ := url.Parse(s.url + "/dists/stable/")
+	r, file, err := DownloadTryCompression(s.ctx, s.d, baseURL, "Release", expectedChecksums, false)
+	c.Assert(err, IsNil)
+	defer func() { _ = file.Close() }()
+
+	buf := make([]byte, 5)
+	_, _ = r.Read(buf)
+	c.Check(string(buf), Equals, "Hello")
+}
+
+func (s *FileDownloaderSuite) TestSchemeDownloader(c *C) {
+	fallback := NewFakeDownloader().ExpectResponse("http://example.com/dists/stable/Release", "Remote")
+	d := NewSchemeDownloader(fallback, map[string]aptly.Downloader{"file": s.d, "": s.d})
+
+	c.Assert(d.Download(s.ctx, s.url+"/dists/stable/Release", s.dest), IsNil)
+	contents, _ := os.ReadFile(s.dest)
+	c.Check(string(contents), Equals, "Hello, /test")
+
+	size, err := d.GetLength(s.ctx, filepath.Join(s.root, "dists", "stable", "Release"))
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, int64(12))
+
+	c.Assert(d.Download(s.ctx, "http://example.com/dists/stable/Release", s.dest), IsNil)
+	contents, _ = os.ReadFile(s.dest)
+	c.Check(string(contents), Equals, "Remote")
+	c.Check(fallback.Empty(), Equals, true)
+}
@@ -26,6 +26,7 @@ type GrabDownloader struct {
 	progress  aptly.Progress
 	maxTries  int
 	downLimit int64
+	auth      *Auth
 }
 
 // Check interface
@@ -35,12 +36,27 @@ var (
 
 // NewGrabDownloader creates new expected downloader
 func NewGrabDownloader(downLimit int64, maxTries int, progress aptly.Progress) *GrabDownloader {
+	return NewGrabDownloaderWithAuth(downLimit, maxTries, progress, nil)
+}
+
+// NewGrabDownloaderWithAuth creates new expected downloader which applies
+// authentication settings to every request (auth could be nil)
+func NewGrabDownloaderWithAuth(downLimit int64, maxTries int, progress aptly.Progress, auth *Auth) *GrabDownloader {
 	client := grab.NewClient()
+	if tlsConfig := auth.clientTLSConfig(); tlsConfig != nil {
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		}
+	}
 	return &GrabDownloader{
 		client:    client,
 		progress:  progress,
 		maxTries:  maxTries,
 		downLimit: downLimit,
+		auth:      auth,
 	}
 }
 
@@ -123,6 +139,7 @@ func (d *GrabDownloader) download(ctx context.Context, url string, destination s
 		return errors.Wrap(err, url)
 	}
 	req = req.WithContext(ctx)
+	d.auth.apply(req.HTTPRequest)
 	if d.downLimit > 0 {
 		req.RateLimiter = rate.NewLimiter(rate.Limit(d.downLimit), int(d.downLimit))
 	}
@@ -163,7 +180,8 @@ func (d *GrabDownloader) GetLength(ctx context.Context, url string) (int64, erro
 	if err != nil {
 		return -1, err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	d.auth.apply(req)
+	resp, err := d.client.HTTPClient.Do(req)
 	if err != nil {
 		return -1, err
 	}
@@ -0,0 +1,218 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithy "github.com/aws/smithy-go"
+	"github.com/pkg/errors"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Check interface
+var (
+	_ aptly.Downloader = (*Downloader)(nil)
+)
+
+// Downloader fetches files from S3 buckets addressed as s3://name/key URLs
+//
+// Name is looked up in the configured endpoints, which provide bucket, prefix,
+// credentials, region and endpoint. Names not present in configuration
+// are treated as bucket names accessed with default AWS credentials chain.
+type Downloader struct {
+	sync.Mutex
+
+	progress  aptly.Progress
+	endpoints map[string]utils.S3PublishRoot
+	clients   map[string]*s3.Client
+}
+
+// NewDownloader creates new instance of Downloader for S3 buckets
+func NewDownloader(endpoints map[string]utils.S3PublishRoot, progress aptly.Progress) *Downloader {
+	return &Downloader{
+		progress:  progress,
+		endpoints: endpoints,
+		clients:   make(map[string]*s3.Client),
+	}
+}
+
+// parseURL splits s3://name/key URL into endpoint name and key
+func parseURL(rawURL string) (name, key string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	if u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("%s: not an s3://name/key URL", rawURL)
+	}
+
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// locate resolves s3://name/key URL into S3 client, bucket and key in the bucket
+func (downloader *Downloader) locate(rawURL string) (client *s3.Client, bucket, key string, err error) {
+	name, key, err := parseURL(rawURL)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	params, ok := downloader.endpoints[name]
+	if !ok || params.Bucket == "" {
+		params.Bucket = name
+	}
+
+	client, err = downloader.client(name, params)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return client, params.Bucket, path.Join(params.Prefix, key), nil
+}
+
+func (downloader *Downloader) client(name string, params utils.S3PublishRoot) (*s3.Client, error) {
+	downloader.Lock()
+	defer downloader.Unlock()
+
+	if client, ok := downloader.clients[name]; ok {
+		return client, nil
+	}
+
+	config, err := loadConfig(params.AccessKeyID, params.SecretAccessKey, params.SessionToken, params.Region, params.Debug)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newClient(&config, params.Endpoint, params.ForceVirtualHostedStyle)
+	downloader.clients[name] = client
+
+	return client, nil
+}
+
+// translateError converts S3 errors into http.Error, so that missing
+// files are handled the same way as with HTTP downloader
+func translateError(err error, rawURL string) error {
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		switch ae.ErrorCode() {
+		case "NotFound", "NoSuchKey", "NoSuchBucket":
+			return &http.Error{Code: 404, URL: rawURL}
+		case "Forbidden", "AccessDenied":
+			return &http.Error{Code: 403, URL: rawURL}
+		}
+	}
+
+	return errors.Wrap(err, rawURL)
+}
+
+// GetProgress returns Progress object
+func (downloader *Downloader) GetProgress() aptly.Progress {
+	return downloader.progress
+}
+
+// GetLength returns size of the object
+func (downloader *Downloader) GetLength(ctx context.Context, rawURL string) (int64, error) {
+	client, bucket, key, err := downloader.locate(rawURL)
+	if err != nil {
+		return -1, err
+	}
+
+	output, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return -1, translateError(err, rawURL)
+	}
+
+	if output.ContentLength == nil {
+		return -1, fmt.Errorf("could not determine length of %s", rawURL)
+	}
+
+	return *output.ContentLength, nil
+}
+
+// Download fetches object into destination file
+func (downloader *Downloader) Download(ctx context.Context, rawURL string, destination string) error {
+	return downloader.DownloadWithChecksum(ctx, rawURL, destination, nil, false)
+}
+
+// DownloadWithChecksum fetches object into destination file with checksum verification
+func (downloader *Downloader) DownloadWithChecksum(ctx context.Context, rawURL string, destination string,
+	expected *utils.ChecksumInfo, ignoreMismatch bool) error {
+
+	if downloader.progress != nil {
+		downloader.progress.Printf("Downloading: %s\n", rawURL)
+		defer downloader.progress.Flush()
+	}
+
+	client, bucket, key, err := downloader.locate(rawURL)
+	if err != nil {
+		return err
+	}
+
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return translateError(err, rawURL)
+	}
+	defer func() { _ = output.Body.Close() }()
+
+	err = os.MkdirAll(filepath.Dir(destination), 0777)
+	if err != nil {
+		return errors.Wrap(err, rawURL)
+	}
+
+	temppath := destination + ".down"
+
+	outfile, err := os.Create(temppath)
+	if err != nil {
+		return errors.Wrap(err, rawURL)
+	}
+	defer func() { _ = outfile.Close() }()
+
+	checksummer := utils.NewChecksumWriter()
+	writers := []io.Writer{outfile, checksummer}
+	if downloader.progress != nil {
+		writers = append(writers, downloader.progress)
+	}
+
+	_, err = io.Copy(io.MultiWriter(writers...), output.Body)
+	if err == nil {
+		err = outfile.Close()
+	}
+	if err != nil {
+		_ = os.Remove(temppath)
+		return errors.Wrap(err, rawURL)
+	}
+
+	if expected != nil {
+		err = http.VerifyChecksums(rawURL, checksummer.Sum(), expected, ignoreMismatch, downloader.progress)
+		if err != nil {
+			_ = os.Remove(temppath)
+			return err
+		}
+	}
+
+	err = os.Rename(temppath, destination)
+	if err != nil {
+		_ = os.Remove(temppath)
+		return errors.Wrap(err, rawURL)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/aptly-dev/aptly/utils"
+)
+
+type DownloaderSuite struct {
+	srv  *Server
+	d    *Downloader
+	dest string
+	ctx  context.Context
+}
+
+var _ = Suite(&DownloaderSuite{})
+
+func (s *DownloaderSuite) SetUpTest(c *C) {
+	var err error
+	s.srv, err = NewServer(&Config{})
+	c.Assert(err, IsNil)
+
+	s.d = NewDownloader(map[string]utils.S3PublishRoot{
+		"upstream": {
+			Region:          "test-1",
+			Bucket:          "upstream-bucket",
+			AccessKeyID:     "aa",
+			SecretAccessKey: "bb",
+			Endpoint:        s.srv.URL(),
+		},
+	}, nil)
+	s.dest = filepath.Join(c.MkDir(), "dest")
+	s.ctx = context.Background()
+
+	client, _, _, err := s.d.locate("s3://upstream/")
+	c.Assert(err, IsNil)
+
+	_, err = client.CreateBucket(s.ctx, &s3.CreateBucketInput{
+		Bucket: aws.String("upstream-bucket"),
+		CreateBucketConfiguration: &types.CreateBucketConfiguration{
+			LocationConstraint: "test-1",
+		}})
+	c.Assert(err, IsNil)
+
+	_, err = client.PutObject(s.ctx, &s3.PutObjectInput{
+		Bucket: aws.String("upstream-bucket"),
+		Key:    aws.String("debian/dists/stable/Release"),
+		Body:   bytes.NewReader([]byte("Hello, /test")),
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *DownloaderSuite) TearDownTest(c *C) {
+	s.srv.Quit()
+}
+
+func (s *DownloaderSuite) TestParseURL(c *C) {
+	name, key, err := parseURL("s3://upstream/debian/dists/stable/Release")
+	c.Assert(err, IsNil)
+	c.Check(name, Equals, "upstream")
+	c.Check(key, Equals, "debian/dists/stable/Release")
+
+	_, _, err = parseURL("http://upstream/debian/")
+	c.Check(err, ErrorMatches, ".*not an s3://name/key URL")
+}
+
+func (s *DownloaderSuite) TestLocate(c *C) {
+	s.d.endpoints["prefixed"] = utils.S3PublishRoot{Bucket: "upstream-bucket", Prefix: "debian", Region: "test-1"}
+
+	_, bucket, key, err := s.d.locate("s3://upstream/debian/dists/stable/Release")
+	c.Assert(err, IsNil)
+	c.Check(bucket, Equals, "upstream-bucket")
+	c.Check(key, Equals, "debian/dists/stable/Release")
+
+	_, bucket, key, err = s.d.locate("s3://prefixed/dists/stable/Release")
+	c.Assert(err, IsNil)
+	c.Check(bucket, Equals, "upstream-bucket")
+	c.Check(key, Equals, "debian/dists/stable/Release")
+
+	_, bucket, key, err = s.d.locate("s3://other-bucket/debian/dists/stable/Release")
+	c.Assert(err, IsNil)
+	c.Check(bucket, Equals, "other-bucket")
+	c.Check(key, Equals, "debian/dists/stable/Release")
+}
+
+func (s *DownloaderSuite) TestGetLength(c *C) {
+	size, err := s.d.GetLength(s.ctx, "s3://upstream/debian/dists/stable/Release")
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, int64(12))
+
+	_, err = s.d.GetLength(s.ctx, "s3://upstream/debian/dists/stable/InRelease")
+	c.Check(err, ErrorMatches, "HTTP code 404.*")
+}
+
+func (s *DownloaderSuite) TestDownload(c *C) {
+	c.Assert(s.d.Download(s.ctx, "s3://upstream/debian/dists/stable/Release", s.dest), IsNil)
+
+	contents, err := os.ReadFile(s.dest)
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Equals, "Hello, /test")
+
+	c.Check(s.d.Download(s.ctx, "s3://upstream/debian/dists/stable/InRelease", s.dest), ErrorMatches, "HTTP code 404.*")
+}
+
+func (s *DownloaderSuite) TestDownloadWithChecksum(c *C) {
+	url := "s3://upstream/debian/dists/stable/Release"
+
+	c.Check(s.d.DownloadWithChecksum(s.ctx, url, s.dest, &utils.ChecksumInfo{Size: 12, MD5: "abcdef"}, false),
+		ErrorMatches, ".*md5 hash mismatch \"a1acb0fe91c7db45ec4d775192ec5738\" != \"abcdef\"")
+	_, err := os.Stat(s.dest)
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	c.Check(s.d.DownloadWithChecksum(s.ctx, url, s.dest, &utils.ChecksumInfo{Size: 12, MD5: "abcdef"}, true), IsNil)
+
+	checksums := utils.ChecksumInfo{Size: 12, MD5: "a1acb0fe91c7db45ec4d775192ec5738"}
+	c.Assert(s.d.DownloadWithChecksum(s.ctx, url, s.dest, &checksums, false), IsNil)
+	c.Check(checksums.SHA256, Equals, "b3c92ee1246176ed35f6e8463cd49074f29442f5bbffc3f8591cde1dcc849dac")
+}
@@ -80,17 +80,8 @@ func NewPublishedStorageRaw(
 		storageClass = ""
 	}
 
-	var baseEndpoint *string
-	if endpoint != "" {
-		baseEndpoint = aws.String(endpoint)
-	}
-
 	result := &PublishedStorage{
-		s3: s3.NewFromConfig(*config, func(o *s3.Options) {
-			o.UsePathStyle = !forceVirtualHostedStyle
-			o.HTTPSignerV4 = signer.NewSigner()
-			o.BaseEndpoint = baseEndpoint
-		}),
+		s3:               newClient(config, endpoint, forceVirtualHostedStyle),
 		bucket:           bucket,
 		config:           config,
 		acl:              acl,
@@ -127,6 +118,20 @@ func NewPublishedStorage(
 	accessKey, secretKey, sessionToken, region, endpoint, bucket, defaultACL, prefix, storageClass, encryptionMethod string,
 	plusWorkaround, disableMultiDel, _, forceVirtualHostedStyle, debug bool) (*PublishedStorage, error) {
 
+	config, err := loadConfig(accessKey, secretKey, sessionToken, region, debug)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := NewPublishedStorageRaw(bucket, defaultACL, prefix, storageClass,
+		encryptionMethod, plusWorkaround, disableMultiDel, forceVirtualHostedStyle, &config, endpoint)
+
+	return result, err
+}
+
+// loadConfig builds aws config from static credentials (if any) falling back
+// to default credentials chain
+func loadConfig(accessKey, secretKey, sessionToken, region string, debug bool) (aws.Config, error) {
 	opts := []func(*config.LoadOptions) error{config.WithRegion(region)}
 	if accessKey != "" {
 		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, sessionToken)))
@@ -136,15 +141,21 @@ func NewPublishedStorage(
 		opts = append(opts, config.WithLogger(&logger{}))
 	}
 
-	config, err := config.LoadDefaultConfig(context.TODO(), opts...)
-	if err != nil {
-		return nil, err
-	}
+	return config.LoadDefaultConfig(context.TODO(), opts...)
+}
 
-	result, err := NewPublishedStorageRaw(bucket, defaultACL, prefix, storageClass,
-		encryptionMethod, plusWorkaround, disableMultiDel, forceVirtualHostedStyle, &config, endpoint)
+// newClient creates S3 client for the given endpoint (empty for AWS)
+func newClient(config *aws.Config, endpoint string, forceVirtualHostedStyle bool) *s3.Client {
+	var baseEndpoint *string
+	if endpoint != "" {
+		baseEndpoint = aws.String(endpoint)
+	}
 
-	return result, err
+	return s3.NewFromConfig(*config, func(o *s3.Options) {
+		o.UsePathStyle = !forceVirtualHostedStyle
+		o.HTTPSignerV4 = signer.NewSigner()
+		o.BaseEndpoint = baseEndpoint
+	})
 }
 
 // String returns the storage as string
@@ -0,0 +1,82 @@
+from lib import BaseTest
+
+
+class AuditSnapshot1Test(BaseTest):
+    """
+    audit snapshot: vulnerable packages
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly snapshot create snap1 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot audit -advisories=${files}/debian-security-tracker.json -release=wheezy snap1"
+
+
+class AuditSnapshot2Test(BaseTest):
+    """
+    audit snapshot: JSON output, other release
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly snapshot create snap2 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot audit -advisories=${files}/debian-security-tracker.json -release=stretch -json snap2"
+
+
+class AuditSnapshot3Test(BaseTest):
+    """
+    audit snapshot: no advisories
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly snapshot create snap3 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot audit snap3"
+    expectedCode = 1
+
+
+class AuditSnapshot4Test(BaseTest):
+    """
+    audit snapshot: no such snapshot
+    """
+    runCmd = "aptly snapshot audit -advisories=${files}/debian-security-tracker.json no-such-snapshot"
+    expectedCode = 1
+
+
+class AuditSnapshot5Test(BaseTest):
+    """
+    audit snapshot: release defaults to distribution of the source repo
+    """
+    fixtureCmds = [
+        "aptly repo create -distribution=wheezy local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly snapshot create snap5 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot audit -advisories=${files}/debian-security-tracker.json snap5"
+
+
+class AuditSnapshot6Test(BaseTest):
+    """
+    audit snapshot: release is required if distribution is unknown
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly snapshot create snap6 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot audit -advisories=${files}/debian-security-tracker.json snap6"
+    expectedCode = 1
+
+
+class AuditSnapshot7Test(BaseTest):
+    """
+    audit snapshot: release derived from distribution matches no advisories
+    """
+    fixtureCmds = [
+        "aptly repo create -distribution=stable local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly snapshot create snap7 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot audit -advisories=${files}/debian-security-tracker.json snap7"
+    expectedCode = 1
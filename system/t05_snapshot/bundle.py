@@ -0,0 +1,51 @@
+from lib import BaseTest
+
+
+class ExportSnapshot1Test(BaseTest):
+    """
+    export snapshot: regular export
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly snapshot create snap1 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot export -skip-signing snap1 ${aptlyroot}/snap1.bundle"
+    gold_processor = BaseTest.expand_environ
+
+
+class ExportSnapshot2Test(BaseTest):
+    """
+    export snapshot: no such snapshot
+    """
+    runCmd = "aptly snapshot export -skip-signing no-such-snapshot ${aptlyroot}/snap1.bundle"
+    expectedCode = 1
+
+
+class ImportSnapshot1Test(BaseTest):
+    """
+    import snapshot: regular import
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly snapshot create snap1 from repo local-repo",
+        "aptly snapshot export -skip-signing snap1 ${aptlyroot}/snap1.bundle",
+    ]
+    runCmd = "aptly snapshot import -ignore-signatures ${aptlyroot}/snap1.bundle snap2"
+
+    def check(self):
+        self.check_output()
+        self.check_cmd_output("aptly snapshot search snap2", "snapshot_search")
+
+
+class ImportSnapshot2Test(BaseTest):
+    """
+    import snapshot: snapshot already exists
+    """
+    fixtureCmds = [
+        "aptly snapshot create snap1 empty",
+        "aptly snapshot export -skip-signing snap1 ${aptlyroot}/snap1.bundle",
+    ]
+    runCmd = "aptly snapshot import -ignore-signatures ${aptlyroot}/snap1.bundle"
+    expectedCode = 1
@@ -117,3 +117,23 @@ class CreateSnapshot10Test(BaseTest):
         "aptly mirror update -ignore-signatures non-free"
     ]
     runCmd = "aptly snapshot create oh-snap from mirror non-free"
+
+
+class CreateSnapshot11Test(BaseTest):
+    """
+    create snapshot: as of time, mirror without update history
+    """
+    fixtureDB = True
+    runCmd = "aptly snapshot create -as-of 2026-09-01T00:00Z snap11 from mirror wheezy-main"
+    expectedCode = 1
+
+
+class CreateSnapshot12Test(BaseTest):
+    """
+    create snapshot: as of time from local repo
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+    ]
+    runCmd = "aptly snapshot create -as-of 2026-09-01 snap12 from repo local-repo"
+    expectedCode = 1
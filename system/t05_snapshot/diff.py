@@ -80,3 +80,34 @@ class DiffSnapshot6Test(BaseTest):
         "aptly snapshot create snap2 from mirror wheezy-main",
     ]
     runCmd = "aptly snapshot diff snap1 snap2"
+
+
+class DiffSnapshot7Test(BaseTest):
+    """
+    diff snapshot and local repo: CSV format
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly snapshot create snap1 from repo local-repo",
+        "aptly repo remove local-repo libboost-program-options-dev",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.62.0.1_i386.deb ${changes}/hardlink_0.2.1_amd64.deb",
+    ]
+    runCmd = "aptly snapshot diff -format=csv snap1 repo:local-repo"
+
+
+class DiffSnapshot8Test(BaseTest):
+    """
+    diff snapshot and local repo: changelog format
+    """
+    fixtureCmds = DiffSnapshot7Test.fixtureCmds
+    runCmd = "aptly snapshot diff -format=changelog snap1 repo:local-repo"
+
+
+class DiffSnapshot9Test(BaseTest):
+    """
+    diff snapshot and local repo: unknown format
+    """
+    fixtureCmds = DiffSnapshot7Test.fixtureCmds
+    runCmd = "aptly snapshot diff -format=xml snap1 repo:local-repo"
+    expectedCode = 1
@@ -0,0 +1,45 @@
+from lib import BaseTest
+
+
+class GCSnapshot1Test(BaseTest):
+    """
+    gc snapshots: dry run
+    """
+    configOverride = {"snapshotRetention": [{"pattern": "snap*", "keepLast": 1}]}
+    fixtureCmds = [
+        "aptly snapshot create snap1 empty",
+        "aptly snapshot create snap2 empty",
+        "aptly snapshot create snap3 empty",
+        "aptly snapshot merge release snap1",
+    ]
+    runCmd = "aptly snapshot gc -dry-run"
+
+    def check(self):
+        self.check_output()
+        self.check_cmd_output("aptly snapshot list -raw", "snapshot_list")
+
+
+class GCSnapshot2Test(BaseTest):
+    """
+    gc snapshots: drop expired snapshots
+    """
+    configOverride = {"snapshotRetention": [{"pattern": "snap*", "keepLast": 1}]}
+    fixtureCmds = [
+        "aptly snapshot create snap1 empty",
+        "aptly snapshot create snap2 empty",
+        "aptly snapshot create snap3 empty",
+        "aptly snapshot merge release snap1",
+    ]
+    runCmd = "aptly snapshot gc"
+
+    def check(self):
+        self.check_output()
+        self.check_cmd_output("aptly snapshot list -raw", "snapshot_list")
+
+
+class GCSnapshot3Test(BaseTest):
+    """
+    gc snapshots: no retention rules
+    """
+    runCmd = "aptly snapshot gc"
+    expectedCode = 1
@@ -0,0 +1,45 @@
+from lib import BaseTest
+
+
+class LicensesSnapshot1Test(BaseTest):
+    """
+    licenses snapshot: all packages
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${changes}/hardlink_0.2.1_amd64.deb ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly snapshot create snap1 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot licenses snap1"
+
+
+class LicensesSnapshot2Test(BaseTest):
+    """
+    licenses snapshot: packages matching license query
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${changes}/hardlink_0.2.1_amd64.deb ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly snapshot create snap2 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot licenses snap2 '$License (% Exp*)'"
+
+
+class LicensesSnapshot3Test(BaseTest):
+    """
+    licenses snapshot: JSON
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${changes}/hardlink_0.2.1_amd64.deb ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly snapshot create snap3 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot licenses -json snap3"
+
+
+class LicensesSnapshot4Test(BaseTest):
+    """
+    licenses snapshot: no such snapshot
+    """
+    runCmd = "aptly snapshot licenses no-such-snapshot"
+    expectedCode = 1
@@ -141,3 +141,25 @@ class ListSnapshot10Test(BaseTest):
 
     def outputMatchPrepare(self, s):
         return re.sub(r'[ ]*"CreatedAt": "[^"]+",?\n', '', s)
+
+
+class ListSnapshot11Test(BaseTest):
+    """
+    list snapshots: filtered by labels, sorted by label value
+    """
+    fixtureCmds = [
+        "aptly snapshot create -set-label team=infra -set-label release=2026.10 snap1 empty",
+        "aptly snapshot create -set-label team=infra -set-label release=2026.04 snap2 empty",
+        "aptly snapshot create -set-label team=web -set-label release=2025.10 snap3 empty",
+        "aptly snapshot create snap4 empty",
+        "aptly snapshot edit -set-label approved=true snap1",
+    ]
+    runCmd = "aptly -with-label=team=infra -sort=label:release snapshot list"
+
+
+class ListSnapshot12Test(BaseTest):
+    """
+    list snapshots: wrong label selector
+    """
+    runCmd = "aptly -with-label=!=infra snapshot list"
+    expectedCode = 1
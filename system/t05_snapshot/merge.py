@@ -184,3 +184,36 @@ class MergeSnapshot11Test(BaseTest):
     ]
     runCmd = "aptly snapshot merge -no-remove -latest snap2 snap1"
     expectedCode = 1
+
+
+class MergeSnapshot12Test(BaseTest):
+    """
+    merge snapshots: priorities, pins, excludes and report
+    """
+    fixtureCmds = [
+        "aptly repo create repo1",
+        "aptly repo create repo2",
+        "aptly repo add repo1 ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly repo add repo2 ${files}/libboost-program-options-dev_1.62.0.1_i386.deb",
+        "aptly snapshot create snap1 from repo repo1",
+        "aptly snapshot create snap2 from repo repo2",
+    ]
+    runCmd = "aptly snapshot merge -priority=snap1:900 -exclude=*:pyspi -report snap3 snap1 snap2"
+
+    def check(self):
+        def remove_created_at(s):
+            return re.sub(r"Created At: [0-9:A-Za-z -]+\n", "", s)
+
+        self.check_output()
+        self.check_cmd_output("aptly snapshot show -with-packages snap3", "snapshot_show", match_prepare=remove_created_at)
+
+
+class MergeSnapshot13Test(BaseTest):
+    """
+    merge snapshots: rule references snapshot which is not a source
+    """
+    fixtureCmds = [
+        "aptly snapshot create snap1 empty",
+    ]
+    runCmd = "aptly snapshot merge -pin=snap2:nginx snap3 snap1"
+    expectedCode = 1
@@ -0,0 +1,59 @@
+import re
+
+from lib import BaseTest
+
+
+def removeVariableFields(s):
+    s = re.sub(r'[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}', 'UUID', s)
+    s = re.sub(r'"(created|timestamp)": "[^"]+"', r'"\1": "TIME"', s)
+    return re.sub(r'"(Tool: aptly-[^"]*|version": "[0-9][^"]*)"(?=\n)', '"VERSION"', s)
+
+
+class SBOMSnapshot1Test(BaseTest):
+    """
+    sbom snapshot: SPDX
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly snapshot create snap1 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot sbom snap1"
+
+    def outputMatchPrepare(self, s):
+        return removeVariableFields(s)
+
+
+class SBOMSnapshot2Test(BaseTest):
+    """
+    sbom snapshot: CycloneDX
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly snapshot create snap2 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot sbom -format=cyclonedx-json -purl-namespace=ubuntu snap2"
+
+    def outputMatchPrepare(self, s):
+        return removeVariableFields(s)
+
+
+class SBOMSnapshot3Test(BaseTest):
+    """
+    sbom snapshot: wrong format
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo",
+        "aptly snapshot create snap3 from repo local-repo",
+    ]
+    runCmd = "aptly snapshot sbom -format=xml snap3"
+    expectedCode = 1
+
+
+class SBOMSnapshot4Test(BaseTest):
+    """
+    sbom snapshot: no such snapshot
+    """
+    runCmd = "aptly snapshot sbom no-such-snapshot"
+    expectedCode = 1
@@ -0,0 +1,21 @@
+from lib import BaseTest
+
+
+class AuditPublish1Test(BaseTest):
+    """
+    audit publish: vulnerable packages in published local repo
+    """
+    fixtureCmds = [
+        "aptly repo create -distribution=wheezy local-repo",
+        "aptly repo add local-repo ${files}/libboost-program-options-dev_1.49.0.1_i386.deb ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly publish repo -skip-signing local-repo",
+    ]
+    runCmd = "aptly publish audit -advisories=${files}/debian-security-tracker.json -release=wheezy wheezy"
+
+
+class AuditPublish2Test(BaseTest):
+    """
+    audit publish: no such published repository
+    """
+    runCmd = "aptly publish audit -advisories=${files}/debian-security-tracker.json wheezy ppa"
+    expectedCode = 1
@@ -0,0 +1,30 @@
+import re
+
+from lib import BaseTest
+
+
+class SBOMPublish1Test(BaseTest):
+    """
+    sbom publish: all components of published repository
+    """
+    fixtureCmds = [
+        "aptly repo create local-repo1",
+        "aptly repo add local-repo1 ${files}/libboost-program-options-dev_1.49.0.1_i386.deb",
+        "aptly repo create local-repo2",
+        "aptly repo add local-repo2 ${files}/pyspi_0.6.1-1.3.dsc",
+        "aptly publish repo -skip-signing -distribution=wheezy -component=main,contrib local-repo1 local-repo2",
+    ]
+    runCmd = "aptly publish sbom -format=cyclonedx-json wheezy"
+
+    def outputMatchPrepare(self, s):
+        s = re.sub(r'[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}', 'UUID', s)
+        s = re.sub(r'"timestamp": "[^"]+"', '"timestamp": "TIME"', s)
+        return re.sub(r'"version": "[0-9][^"]*"(?=\n)', '"version": "VERSION"', s)
+
+
+class SBOMPublish2Test(BaseTest):
+    """
+    sbom publish: no such published repository
+    """
+    runCmd = "aptly publish sbom wheezy ppa"
+    expectedCode = 1
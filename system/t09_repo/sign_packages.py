@@ -0,0 +1,34 @@
+import glob
+import inspect
+import os
+
+from lib import BaseTest
+
+
+class SignPackagesRepo1Test(BaseTest):
+    """
+    sign packages and add them to local repo: signed copy is imported into the pool
+    """
+    fixtureCmds = [
+        "aptly repo create -comment=Repo1 -distribution=squeeze repo1",
+    ]
+    runCmd = "aptly repo sign-packages -keyring=${files}/aptly.pub -secret-keyring=${files}/aptly.sec repo1 " \
+        "${files}/libboost-program-options-dev_1.49.0.1_i386.deb"
+
+    def check(self):
+        self.check_cmd_output("aptly repo show -with-packages repo1", "repo_show")
+
+        # signed copy is in the pool, it differs from the original file
+        pool_files = glob.glob(os.path.join(os.environ["HOME"], self.aptlyDir, "pool", "*", "*",
+                                            "*_libboost-program-options-dev_1.49.0.1_i386.deb"))
+        self.check_equal(len(pool_files), 1)
+        with open(pool_files[0], "rb") as f:
+            self.check_in(b"_gpgbuilder", f.read())
+
+        # unsigned original is not imported and is not modified
+        self.check_not_exists('pool/c7/6b/4bd12fd92e4dfe1b55b18a67a669_libboost-program-options-dev_1.49.0.1_i386.deb')
+
+        original = os.path.join(os.path.dirname(inspect.getsourcefile(BaseTest)), "files",
+                                "libboost-program-options-dev_1.49.0.1_i386.deb")
+        with open(original, "rb") as f:
+            self.check_not_in(b"_gpgbuilder", f.read())
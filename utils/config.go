@@ -39,6 +39,18 @@ type ConfigStructure struct {
 	EnableSwaggerEndpoint bool `json:"enableSwaggerEndpoint"         yaml:"enable_swagger_endpoint"`
 	AsyncAPI              bool `json:"AsyncAPI"                      yaml:"async_api"` // OBSOLETE
 
+	// Incoming queues for .changes uploads, processed by API server
+	IncomingQueues map[string]IncomingQueue `json:"incomingQueues"                yaml:"incoming_queues"`
+
+	// Promotion paths between local repos, requiring approvals
+	PromotionPaths map[string]PromotionPath `json:"promotionPaths"                yaml:"promotion_paths"`
+
+	// Hooks run before and after repository operations
+	Hooks []Hook `json:"hooks"                         yaml:"hooks"`
+
+	// Snapshot retention rules, evaluated by snapshot gc
+	SnapshotRetention []SnapshotRetentionRule `json:"snapshotRetention"             yaml:"snapshot_retention"`
+
 	// Database
 	DatabaseBackend DBConfig `json:"databaseBackend"               yaml:"database_backend"`
 
@@ -48,6 +60,13 @@ type ConfigStructure struct {
 	DownloadLimit          int64  `json:"downloadSpeedLimit"            yaml:"download_limit"`
 	DownloadRetries        int    `json:"downloadRetries"               yaml:"download_retries"`
 	DownloadSourcePackages bool   `json:"downloadSourcePackages"        yaml:"download_sourcepackages"`
+	MirrorHistoryRetention string `json:"mirrorHistoryRetention"        yaml:"mirror_history_retention"`
+
+	// Mirroring from S3 buckets (s3:// archive roots), looked up by endpoint name
+	S3MirrorRoots map[string]S3PublishRoot `json:"S3MirrorEndpoints"             yaml:"s3_mirror_endpoints"`
+
+	// Authentication for mirrors, referenced by name from the mirror
+	MirrorAuth map[string]MirrorAuth `json:"mirrorAuth"                    yaml:"mirror_auth"`
 
 	// Signing
 	GpgProvider      string   `json:"gpgProvider"                   yaml:"gpg_provider"`
@@ -189,6 +208,74 @@ type S3PublishRoot struct {
 	Debug                   bool   `json:"debug"                      yaml:"debug"`
 }
 
+// MirrorAuth describes authentication settings for remote repositories,
+// secrets are kept in files referenced from configuration
+type MirrorAuth struct {
+	NetrcFile      string `json:"netrcFile"       yaml:"netrc_file"`
+	TokenFile      string `json:"tokenFile"       yaml:"token_file"`
+	CACertFile     string `json:"caCertFile"      yaml:"ca_cert_file"`
+	ClientCertFile string `json:"clientCertFile"  yaml:"client_cert_file"`
+	ClientKeyFile  string `json:"clientKeyFile"   yaml:"client_key_file"`
+}
+
+// IncomingQueue describes directory watched for .changes uploads
+type IncomingQueue struct {
+	Directory        string `json:"directory"         yaml:"directory"`
+	RejectDirectory  string `json:"rejectDirectory"   yaml:"reject_directory"`
+	Repo             string `json:"repo"              yaml:"repo"`
+	UploadersFile    string `json:"uploadersFile"     yaml:"uploaders_file"`
+	AcceptUnsigned   bool   `json:"acceptUnsigned"    yaml:"accept_unsigned"`
+	IgnoreSignatures bool   `json:"ignoreSignatures"  yaml:"ignore_signatures"`
+	ForceReplace     bool   `json:"forceReplace"      yaml:"force_replace"`
+	PollInterval     int    `json:"pollInterval"      yaml:"poll_interval"`
+}
+
+// GetRejectDirectory returns directory for rejected uploads, defaults to reject/ under queue directory
+func (queue IncomingQueue) GetRejectDirectory() string {
+	if queue.RejectDirectory != "" {
+		return queue.RejectDirectory
+	}
+
+	return filepath.Join(queue.Directory, "reject")
+}
+
+// GetRepo returns template of local repo name, defaults to Distribution field of .changes file
+func (queue IncomingQueue) GetRepo() string {
+	if queue.Repo != "" {
+		return queue.Repo
+	}
+
+	return "{{.Distribution}}"
+}
+
+// PromotionPath describes promotion of packages from one local repo to another,
+// promotion is executed once required number of approvals is collected
+type PromotionPath struct {
+	From      string `json:"from"       yaml:"from"`
+	To        string `json:"to"         yaml:"to"`
+	Approvals int    `json:"approvals"  yaml:"approvals"`
+	Move      bool   `json:"move"       yaml:"move"`
+}
+
+// Hook describes local command or HTTP webhook run before or after aptly operations,
+// events are named <pre|post>-<operation>, e.g. pre-publish-switch, and could be glob patterns
+type Hook struct {
+	Events  []string `json:"events"   yaml:"events"`
+	Command []string `json:"command"  yaml:"command"`
+	URL     string   `json:"url"      yaml:"url"`
+	Timeout int      `json:"timeout"  yaml:"timeout"`
+	Retries int      `json:"retries"  yaml:"retries"`
+}
+
+// SnapshotRetentionRule selects snapshots by name pattern and labels, matching snapshots are
+// kept if they're among KeepLast latest ones or younger than KeepAge, others are dropped by snapshot gc
+type SnapshotRetentionRule struct {
+	Pattern  string   `json:"pattern"   yaml:"pattern"`
+	Labels   []string `json:"labels"    yaml:"labels"`
+	KeepLast int      `json:"keepLast"  yaml:"keep_last"`
+	KeepAge  string   `json:"keepAge"   yaml:"keep_age"`
+}
+
 // SwiftPublishRoot describes single OpenStack Swift publishing entry point
 type SwiftPublishRoot struct {
 	Container      string `json:"container"       yaml:"container"`
@@ -239,6 +326,12 @@ var Config = ConfigStructure{
 	PpaBaseURL:             "http://ppa.launchpad.net",
 	FileSystemPublishRoots: map[string]FileSystemPublishRoot{},
 	S3PublishRoots:         map[string]S3PublishRoot{},
+	S3MirrorRoots:          map[string]S3PublishRoot{},
+	MirrorAuth:             map[string]MirrorAuth{},
+	IncomingQueues:         map[string]IncomingQueue{},
+	PromotionPaths:         map[string]PromotionPath{},
+	Hooks:                  []Hook{},
+	SnapshotRetention:      []SnapshotRetentionRule{},
 	SwiftPublishRoots:      map[string]SwiftPublishRoot{},
 	AzurePublishRoots:      map[string]AzureEndpoint{},
 	AsyncAPI:               false,
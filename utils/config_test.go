@@ -90,6 +90,10 @@ func (s *ConfigSuite) TestSaveConfig(c *C) {
 		"  \"enableMetricsEndpoint\": false,\n" +
 		"  \"enableSwaggerEndpoint\": false,\n" +
 		"  \"AsyncAPI\": false,\n" +
+		"  \"incomingQueues\": null,\n" +
+		"  \"promotionPaths\": null,\n" +
+		"  \"hooks\": null,\n" +
+		"  \"snapshotRetention\": null,\n" +
 		"  \"databaseBackend\": {\n" +
 		"    \"type\": \"\",\n" +
 		"    \"dbPath\": \"\",\n" +
@@ -100,6 +104,9 @@ func (s *ConfigSuite) TestSaveConfig(c *C) {
 		"  \"downloadSpeedLimit\": 0,\n" +
 		"  \"downloadRetries\": 0,\n" +
 		"  \"downloadSourcePackages\": false,\n" +
+		"  \"mirrorHistoryRetention\": \"\",\n" +
+		"  \"S3MirrorEndpoints\": null,\n" +
+		"  \"mirrorAuth\": null,\n" +
 		"  \"gpgProvider\": \"gpg\",\n" +
 		"  \"gpgDisableSign\": false,\n" +
 		"  \"gpgDisableVerify\": false,\n" +
@@ -258,6 +265,10 @@ func (s *ConfigSuite) TestSaveYAML2Config(c *C) {
 		"enable_metrics_endpoint: false\n" +
 		"enable_swagger_endpoint: false\n" +
 		"async_api: false\n" +
+		"incoming_queues: {}\n" +
+		"promotion_paths: {}\n" +
+		"hooks: []\n" +
+		"snapshot_retention: []\n" +
 		"database_backend:\n" +
 		"    type: \"\"\n" +
 		"    db_path: \"\"\n" +
@@ -267,6 +278,9 @@ func (s *ConfigSuite) TestSaveYAML2Config(c *C) {
 		"download_limit: 0\n" +
 		"download_retries: 0\n" +
 		"download_sourcepackages: false\n" +
+		"mirror_history_retention: \"\"\n" +
+		"s3_mirror_endpoints: {}\n" +
+		"mirror_auth: {}\n" +
 		"gpg_provider: \"\"\n" +
 		"gpg_disable_sign: false\n" +
 		"gpg_disable_verify: false\n" +
@@ -315,6 +329,48 @@ serve_in_api_mode: true
 enable_metrics_endpoint: true
 enable_swagger_endpoint: true
 async_api: true
+incoming_queues:
+    uploads:
+        directory: /srv/incoming
+        reject_directory: /srv/incoming-reject
+        repo: '{{.Distribution}}-uploads'
+        uploaders_file: /etc/aptly/uploaders.json
+        accept_unsigned: false
+        ignore_signatures: false
+        force_replace: true
+        poll_interval: 30
+promotion_paths:
+    prod:
+        from: staging
+        to: prod
+        approvals: 2
+        move: true
+    staging:
+        from: dev
+        to: staging
+        approvals: 1
+        move: false
+hooks:
+    - events:
+        - pre-publish-switch
+      command:
+        - /usr/local/bin/check-tests
+        - --strict
+      url: ""
+      timeout: 30
+      retries: 0
+    - events:
+        - post-*
+      command: []
+      url: https://hooks.example.com/aptly
+      timeout: 0
+      retries: 3
+snapshot_retention:
+    - pattern: ci-*
+      labels:
+        - team=infra
+      keep_last: 10
+      keep_age: 30d
 database_backend:
     type: etcd
     db_path: ""
@@ -324,6 +380,31 @@ download_concurrency: 40
 download_limit: 100
 download_retries: 10
 download_sourcepackages: true
+mirror_history_retention: 90d
+s3_mirror_endpoints:
+    upstream:
+        region: eu-west-1
+        bucket: upstream-debian
+        prefix: ""
+        acl: ""
+        access_key_id: "3"
+        secret_access_key: secret2
+        session_token: ""
+        endpoint: http://minio.local:9000
+        storage_class: ""
+        encryption_method: ""
+        plus_workaround: false
+        disable_multidel: false
+        force_sigv2: false
+        force_virtualhosted_style: false
+        debug: false
+mirror_auth:
+    vendor:
+        netrc_file: /etc/aptly/vendor.netrc
+        token_file: ""
+        ca_cert_file: /etc/aptly/vendor-ca.pem
+        client_cert_file: /etc/aptly/client.pem
+        client_key_file: /etc/aptly/client.key
 gpg_provider: gpg
 gpg_disable_sign: true
 gpg_disable_verify: true